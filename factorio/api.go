@@ -0,0 +1,197 @@
+// Package factorio imports the machine-readable Factorio API documentation and generates LuaCATS definitions from it.
+package factorio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RuntimeAPI is the contents of Factorio's `runtime-api.json`.
+type RuntimeAPI struct {
+	Application        string         `json:"application"`
+	Stage              string         `json:"stage"`
+	ApplicationVersion string         `json:"application_version"`
+	APIVersion         int            `json:"api_version"`
+	Classes            []Class        `json:"classes"`
+	Events             []Event        `json:"events"`
+	Defines            []Define       `json:"defines"`
+	BuiltinTypes       []BuiltinType  `json:"builtin_types"`
+	Concepts           []Concept      `json:"concepts"`
+	GlobalObjects      []GlobalObject `json:"global_objects"`
+	GlobalFunctions    []Method       `json:"global_functions"`
+}
+
+type Class struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parent      string      `json:"parent"`
+	Abstract    bool        `json:"abstract"`
+	Methods     []Method    `json:"methods"`
+	Attributes  []Attribute `json:"attributes"`
+}
+
+type Method struct {
+	Name              string      `json:"name"`
+	Description       string      `json:"description"`
+	Parameters        []Parameter `json:"parameters"`
+	VariadicParameter *Parameter  `json:"variadic_parameter"`
+	ReturnValues      []Parameter `json:"return_values"`
+	TakesTable        bool        `json:"takes_table"`
+	TableIsOptional   bool        `json:"table_is_optional"`
+}
+
+// Parameter is a method parameter, return value, event field, or table field.
+type Parameter struct {
+	Name        string `json:"name"`
+	Order       int    `json:"order"`
+	Description string `json:"description"`
+	Type        Type   `json:"type"`
+	Optional    bool   `json:"optional"`
+}
+
+type Attribute struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        *Type  `json:"type"`      // API version 4.
+	ReadType    *Type  `json:"read_type"` // API version 5 and later.
+	WriteType   *Type  `json:"write_type"`
+	Optional    bool   `json:"optional"`
+}
+
+type Event struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Data        []Parameter `json:"data"`
+}
+
+type Define struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Values      []DefineValue `json:"values"`
+	Subkeys     []Define      `json:"subkeys"`
+}
+
+type DefineValue struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type BuiltinType struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Concept struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        Type   `json:"type"`
+}
+
+type GlobalObject struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        Type   `json:"type"`
+}
+
+// Type is either a reference to a named type, or a complex type such as an array or union.
+type Type struct {
+	Name string `json:"-"` // Set if the type is a reference to a named type.
+
+	Complex     string          `json:"complex_type"`
+	Value       json.RawMessage `json:"value"` // A type, or a primitive value for literals.
+	Key         *Type           `json:"key"`
+	Options     []Type          `json:"options"`
+	Parameters  json.RawMessage `json:"parameters"` // Types for functions, and parameters for tables.
+	Values      []Type          `json:"values"`
+	Attributes  []Attribute     `json:"attributes"`
+	Description string          `json:"description"`
+}
+
+func (t *Type) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &t.Name)
+	}
+	type plain Type
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+// Load reads and decodes a `runtime-api.json` file.
+func Load(path string) (*RuntimeAPI, error) {
+	var api RuntimeAPI
+	if err := decode(path, "runtime", &api); err != nil {
+		return nil, err
+	}
+	return &api, nil
+}
+
+// header contains the fields that are common to every API file.
+type header struct {
+	Stage              string `json:"stage"`
+	ApplicationVersion string `json:"application_version"`
+}
+
+// decode reads the API file at path into v, checking that it describes the given stage.
+func decode(path string, stage string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var h header
+	if err := json.Unmarshal(data, &h); err != nil {
+		return fmt.Errorf("Failed to decode %s: %w", path, err)
+	}
+	if h.Stage != "" && h.Stage != stage {
+		return fmt.Errorf("%s describes the %s stage, not the %s stage", path, h.Stage, stage)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("Failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// PrototypeAPI is the contents of Factorio's `prototype-api.json`.
+type PrototypeAPI struct {
+	Application        string          `json:"application"`
+	Stage              string          `json:"stage"`
+	ApplicationVersion string          `json:"application_version"`
+	APIVersion         int             `json:"api_version"`
+	Prototypes         []Prototype     `json:"prototypes"`
+	Types              []PrototypeType `json:"types"`
+	Defines            []Define        `json:"defines"`
+}
+
+type Prototype struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Parent      string     `json:"parent"`
+	Abstract    bool       `json:"abstract"`
+	Typename    string     `json:"typename"` // The value of the `type` field of instances of this prototype.
+	Properties  []Property `json:"properties"`
+}
+
+// PrototypeType is a type used by prototype properties. Builtin types have a Type with the name "builtin".
+type PrototypeType struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Parent      string     `json:"parent"`
+	Type        Type       `json:"type"`
+	Properties  []Property `json:"properties"`
+}
+
+type Property struct {
+	Name        string `json:"name"`
+	Order       int    `json:"order"`
+	Description string `json:"description"`
+	Type        Type   `json:"type"`
+	Optional    bool   `json:"optional"`
+}
+
+// LoadPrototypes reads and decodes a `prototype-api.json` file.
+func LoadPrototypes(path string) (*PrototypeAPI, error) {
+	var api PrototypeAPI
+	if err := decode(path, "prototype", &api); err != nil {
+		return nil, err
+	}
+	return &api, nil
+}
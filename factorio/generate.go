@@ -0,0 +1,489 @@
+package factorio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// Generate converts the API into a LuaCATS definition file.
+func (api *RuntimeAPI) Generate() string {
+	g := newGenerator(api.ApplicationVersion, api.APIVersion)
+	g.line("---@meta")
+	g.line("-- Factorio %s runtime API, %s.", api.ApplicationVersion, generatedBy)
+	g.builtins(api.BuiltinTypes)
+	for _, concept := range api.Concepts {
+		g.concept(concept)
+	}
+	for _, class := range api.Classes {
+		g.class(class)
+	}
+	if len(api.Events) > 0 {
+		g.blank()
+		g.line("---@class EventData")
+		g.line("---@field name defines.events The identifier of the event this handler was registered to.")
+		g.line("---@field tick uint The tick during which the event happened.")
+		g.line("---@field mod_name string? The name of the mod that raised the event, if it was raised by a mod.")
+	}
+	for _, event := range api.Events {
+		g.event(event)
+	}
+	g.defines(api.Defines)
+	for _, object := range api.GlobalObjects {
+		g.blank()
+		g.description(object.Description)
+		g.line("---@type %s", g.typ(object.Type))
+		g.line("%s = nil", object.Name)
+	}
+	for _, fn := range api.GlobalFunctions {
+		g.globalFunction(fn)
+	}
+	return g.sb.String()
+}
+
+// DefinitionsDir returns the directory that generated definitions are cached in.
+func DefinitionsDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "luapls", "factorio"), nil
+}
+
+// Import generates definitions from the given `runtime-api.json` or `prototype-api.json` and writes them to the
+// definitions cache, returning the path of the generated file. Definitions that were already generated for the same
+// stage and Factorio version are reused.
+func Import(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var h header
+	if err := json.Unmarshal(data, &h); err != nil {
+		return "", fmt.Errorf("Failed to decode %s: %w", path, err)
+	}
+	dir, err := DefinitionsDir()
+	if err != nil {
+		return "", err
+	}
+	out := filepath.Join(dir, fmt.Sprintf("%s-api-%s.lua", h.Stage, h.ApplicationVersion))
+	if info, err := os.Stat(out); err == nil && isCurrent(out) {
+		if source, err := os.Stat(path); err == nil && !source.ModTime().After(info.ModTime()) {
+			return out, nil
+		}
+	}
+	var defs string
+	switch h.Stage {
+	case "runtime":
+		api, err := Load(path)
+		if err != nil {
+			return "", err
+		}
+		defs = api.Generate()
+	case "prototype":
+		api, err := LoadPrototypes(path)
+		if err != nil {
+			return "", err
+		}
+		defs = api.Generate()
+	default:
+		return "", fmt.Errorf("%s describes an unknown stage '%s'", path, h.Stage)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(out, []byte(defs), 0644); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// generatedBy is written at the top of generated definitions. Its revision is increased whenever the definitions
+// change, so that definitions that were generated by an older version are generated again.
+const generatedBy = "generated by luapls (revision 2)"
+
+// isCurrent returns whether the definitions at the given path were generated by the current revision.
+func isCurrent(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, 256)
+	n, _ := f.Read(header)
+	return strings.Contains(string(header[:n]), generatedBy)
+}
+
+type generator struct {
+	sb strings.Builder
+	// docs is the address of the online documentation of the Factorio version, and apiVersion the version of the
+	// format of the API, which the layout of the documentation changed with.
+	docs       string
+	apiVersion int
+}
+
+func newGenerator(version string, apiVersion int) *generator {
+	return &generator{docs: "https://lua-api.factorio.com/" + version + "/", apiVersion: apiVersion}
+}
+
+// link returns a markdown link to the given page of the online documentation.
+func (g *generator) link(page string) string {
+	return fmt.Sprintf("[Online documentation](%s%s)", g.docs, page)
+}
+
+// documented returns a description followed by a link to the given page of the online documentation.
+func (g *generator) documented(description string, page string) string {
+	if description == "" {
+		return g.link(page)
+	}
+	return description + "\n\n" + g.link(page)
+}
+
+// classPage returns the page of a runtime class, or of one of its members if member is not empty.
+func (g *generator) classPage(class string, member string) string {
+	page := "classes/" + class + ".html"
+	switch {
+	case member == "":
+		return page
+	case g.apiVersion >= 5:
+		return page + "#" + member
+	default:
+		return page + "#" + class + "." + member
+	}
+}
+
+// conceptPage returns the page of a runtime concept. Concepts had a page of their own from API version 5.
+func (g *generator) conceptPage(concept string) string {
+	if g.apiVersion >= 5 {
+		return "concepts/" + concept + ".html"
+	}
+	return "concepts.html#" + concept
+}
+
+// line writes a formatted line, without the trailing space left by an empty description.
+func (g *generator) line(format string, args ...any) {
+	g.sb.WriteString(strings.TrimRight(fmt.Sprintf(format, args...), " "))
+	g.sb.WriteByte('\n')
+}
+
+func (g *generator) blank() {
+	g.sb.WriteByte('\n')
+}
+
+// description writes a multi-line description as doc comment lines.
+func (g *generator) description(description string) {
+	if description == "" {
+		return
+	}
+	for _, line := range strings.Split(description, "\n") {
+		g.line("---%s", line)
+	}
+}
+
+func (g *generator) builtins(builtins []BuiltinType) {
+	for _, builtin := range builtins {
+		var target string
+		switch {
+		case builtinNames[builtin.Name]:
+			continue
+		case strings.Contains(builtin.Name, "int"):
+			target = "integer"
+		case builtin.Name == "double" || builtin.Name == "float":
+			target = "number"
+		case builtin.Name == "bool":
+			target = "boolean"
+		default:
+			target = "any"
+		}
+		g.blank()
+		g.description(builtin.Description)
+		g.line("---@alias %s %s", builtin.Name, target)
+	}
+}
+
+// builtinNames contains the types that luapls understands without a definition.
+var builtinNames = map[string]bool{
+	"any":      true,
+	"boolean":  true,
+	"function": true,
+	"integer":  true,
+	"nil":      true,
+	"number":   true,
+	"string":   true,
+	"table":    true,
+}
+
+func (g *generator) concept(concept Concept) {
+	page := g.conceptPage(concept.Name)
+	g.blank()
+	g.description(g.documented(concept.Description, page))
+	switch concept.Type.Complex {
+	case "struct":
+		g.line("---@class %s", concept.Name)
+		for _, attribute := range concept.Type.Attributes {
+			g.attribute(attribute, page)
+		}
+	case "table":
+		g.line("---@class %s", concept.Name)
+		for _, param := range g.tableParameters(concept.Type) {
+			g.field(param.Name, param.Optional, g.typ(param.Type), param.Description, page)
+		}
+	default:
+		g.line("---@alias %s %s", concept.Name, g.typ(concept.Type))
+	}
+}
+
+func (g *generator) class(class Class) {
+	g.blank()
+	g.description(g.documented(class.Description, g.classPage(class.Name, "")))
+	if class.Parent != "" {
+		g.line("---@class %s : %s", class.Name, class.Parent)
+	} else {
+		g.line("---@class %s", class.Name)
+	}
+	for _, attribute := range class.Attributes {
+		g.attribute(attribute, g.classPage(class.Name, attribute.Name))
+	}
+	for _, method := range class.Methods {
+		g.field(method.Name, false, g.methodType(method), method.Description, g.classPage(class.Name, method.Name))
+	}
+}
+
+func (g *generator) event(event Event) {
+	page := "events.html#" + event.Name
+	g.blank()
+	g.description(g.documented(event.Description, page))
+	g.line("---@class EventData.%s : EventData", event.Name)
+	for _, param := range event.Data {
+		g.field(param.Name, param.Optional, g.typ(param.Type), param.Description, page)
+	}
+}
+
+func (g *generator) defines(defines []Define) {
+	if len(defines) == 0 {
+		return
+	}
+	g.blank()
+	g.line("defines = {}")
+	for _, define := range defines {
+		g.define("defines", define)
+	}
+}
+
+func (g *generator) define(prefix string, define Define) {
+	path := prefix + "." + define.Name
+	g.blank()
+	g.description(g.documented(define.Description, "defines.html#"+path))
+	// Defines are referred to as types by the rest of the API, and only their values are accepted where they are.
+	g.line("---@enum %s", path)
+	if len(define.Values) == 0 {
+		g.line("%s = {}", path)
+	} else {
+		g.line("%s = {", path)
+		for i, value := range define.Values {
+			g.description(value.Description)
+			g.line("\t%s = %d,", tableKey(value.Name), i)
+		}
+		g.line("}")
+	}
+	for _, subkey := range define.Subkeys {
+		g.define(path, subkey)
+	}
+}
+
+func (g *generator) globalFunction(fn Method) {
+	g.blank()
+	g.description(fn.Description)
+	names := []string{}
+	for _, param := range fn.Parameters {
+		name := paramName(param.Name)
+		g.line("---@param %s%s %s %s", name, optional(param.Optional), g.typ(param.Type), flatten(param.Description))
+		names = append(names, name)
+	}
+	if fn.VariadicParameter != nil {
+		g.line("---@param ... %s %s", g.typ(fn.VariadicParameter.Type), flatten(fn.VariadicParameter.Description))
+		names = append(names, "...")
+	}
+	if len(fn.ReturnValues) > 0 {
+		g.line("---@return %s", g.returnTypes(fn.ReturnValues))
+	}
+	g.line("function %s(%s) end", fn.Name, strings.Join(names, ", "))
+}
+
+func (g *generator) attribute(attribute Attribute, page string) {
+	typ := attribute.ReadType
+	if typ == nil {
+		typ = attribute.Type
+	}
+	if typ == nil {
+		typ = attribute.WriteType
+	}
+	if typ == nil {
+		return
+	}
+	g.field(attribute.Name, attribute.Optional, g.typ(*typ), attribute.Description, page)
+}
+
+// field writes a field with its description, followed by a link to the given page of the online documentation. The
+// description is separated from the type by `#`, since a link would otherwise be read as an array type.
+func (g *generator) field(name string, isOptional bool, typ string, description string, page string) {
+	g.line("---@field %s%s %s # %s", name, optional(isOptional), typ, strings.TrimLeft(flatten(description)+" "+g.link(page), " "))
+}
+
+// methodType returns the function type of a class method. Methods that take a table receive all of their parameters
+// as fields of a single table.
+func (g *generator) methodType(method Method) string {
+	params := []string{}
+	if method.TakesTable {
+		fields := []string{}
+		for _, param := range method.Parameters {
+			fields = append(fields, fmt.Sprintf("%s%s: %s", param.Name, optional(param.Optional), g.typ(param.Type)))
+		}
+		params = append(params, fmt.Sprintf("params%s: { %s }", optional(method.TableIsOptional), strings.Join(fields, ", ")))
+	} else {
+		for _, param := range method.Parameters {
+			params = append(params, fmt.Sprintf("%s%s: %s", paramName(param.Name), optional(param.Optional), g.typ(param.Type)))
+		}
+	}
+	if method.VariadicParameter != nil {
+		params = append(params, "...: "+g.typ(method.VariadicParameter.Type))
+	}
+	typ := fmt.Sprintf("fun(%s)", strings.Join(params, ", "))
+	if len(method.ReturnValues) > 0 {
+		typ += ": " + g.returnTypes(method.ReturnValues)
+	}
+	return typ
+}
+
+func (g *generator) returnTypes(values []Parameter) string {
+	types := []string{}
+	for _, value := range values {
+		typ := g.typ(value.Type)
+		if value.Optional {
+			typ = wrap(typ) + "?"
+		}
+		types = append(types, typ)
+	}
+	return strings.Join(types, ", ")
+}
+
+// typ converts a type to its LuaCATS representation.
+func (g *generator) typ(typ Type) string {
+	if typ.Name != "" {
+		return typ.Name
+	}
+	switch typ.Complex {
+	case "array":
+		return wrap(g.rawType(typ.Value)) + "[]"
+	case "dictionary", "LuaCustomTable":
+		return fmt.Sprintf("table<%s, %s>", g.typ(*typ.Key), g.rawType(typ.Value))
+	case "function":
+		var params []Type
+		json.Unmarshal(typ.Parameters, &params)
+		names := []string{}
+		for i, param := range params {
+			names = append(names, fmt.Sprintf("arg%d: %s", i+1, g.typ(param)))
+		}
+		return fmt.Sprintf("fun(%s)", strings.Join(names, ", "))
+	case "literal":
+		return string(typ.Value)
+	case "LuaLazyLoadedValue":
+		return "LuaLazyLoadedValue"
+	case "struct":
+		fields := []string{}
+		for _, attribute := range typ.Attributes {
+			if attribute.ReadType != nil || attribute.Type != nil {
+				fieldType := attribute.ReadType
+				if fieldType == nil {
+					fieldType = attribute.Type
+				}
+				fields = append(fields, fmt.Sprintf("%s%s: %s", attribute.Name, optional(attribute.Optional), g.typ(*fieldType)))
+			}
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	case "table":
+		fields := []string{}
+		for _, param := range g.tableParameters(typ) {
+			fields = append(fields, fmt.Sprintf("%s%s: %s", param.Name, optional(param.Optional), g.typ(param.Type)))
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	case "tuple":
+		values := []string{}
+		for _, value := range typ.Values {
+			values = append(values, g.typ(value))
+		}
+		return wrap(strings.Join(values, "|")) + "[]"
+	case "type":
+		return g.rawType(typ.Value)
+	case "union":
+		options := []string{}
+		seen := map[string]bool{}
+		for _, option := range typ.Options {
+			if option := g.typ(option); !seen[option] {
+				seen[option] = true
+				options = append(options, option)
+			}
+		}
+		return strings.Join(options, "|")
+	}
+	return "any"
+}
+
+// rawType converts a type that has not been decoded yet.
+func (g *generator) rawType(raw json.RawMessage) string {
+	var typ Type
+	if err := json.Unmarshal(raw, &typ); err != nil {
+		return "any"
+	}
+	return g.typ(typ)
+}
+
+// tableParameters returns the fields of a table type, sorted by their order in the documentation.
+func (g *generator) tableParameters(typ Type) []Parameter {
+	var params []Parameter
+	json.Unmarshal(typ.Parameters, &params)
+	sort.SliceStable(params, func(i, j int) bool { return params[i].Order < params[j].Order })
+	return params
+}
+
+func optional(isOptional bool) string {
+	if isOptional {
+		return "?"
+	}
+	return ""
+}
+
+// wrap parenthesizes a union so that a suffix can be applied to it.
+func wrap(typ string) string {
+	if strings.Contains(typ, "|") {
+		return "(" + typ + ")"
+	}
+	return typ
+}
+
+// flatten collapses a description onto a single line.
+func flatten(description string) string {
+	return strings.Join(strings.Fields(description), " ")
+}
+
+// paramName renames parameters that would otherwise be Lua keywords.
+func paramName(name string) string {
+	if _, ok := token.Reserved[name]; ok {
+		return "_" + name
+	}
+	return name
+}
+
+// tableKey returns a table constructor key for the given name, quoting it if it is not a valid identifier.
+func tableKey(name string) string {
+	if _, ok := token.Reserved[name]; ok {
+		return "[" + strconv.Quote(name) + "]"
+	}
+	return name
+}
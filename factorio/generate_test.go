@@ -0,0 +1,263 @@
+package factorio
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const runtimeAPI = `{
+	"application": "factorio",
+	"stage": "runtime",
+	"application_version": "1.1.100",
+	"api_version": 4,
+	"builtin_types": [
+		{ "name": "string", "description": "" },
+		{ "name": "uint", "description": "32-bit unsigned integer." },
+		{ "name": "double", "description": "" }
+	],
+	"concepts": [
+		{
+			"name": "MapPosition",
+			"description": "Coordinates on a surface.",
+			"type": {
+				"complex_type": "table",
+				"parameters": [
+					{ "name": "y", "order": 1, "type": "double", "optional": false },
+					{ "name": "x", "order": 0, "type": "double", "optional": false }
+				]
+			}
+		},
+		{
+			"name": "Direction",
+			"description": "",
+			"type": {
+				"complex_type": "union",
+				"options": [
+					{ "complex_type": "literal", "value": "north" },
+					{ "complex_type": "literal", "value": "south" }
+				]
+			}
+		}
+	],
+	"classes": [
+		{
+			"name": "LuaControl",
+			"description": "",
+			"methods": [],
+			"attributes": [
+				{ "name": "position", "description": "", "type": "MapPosition", "optional": false }
+			]
+		},
+		{
+			"name": "LuaEntity",
+			"description": "The primary interface for interacting with entities.",
+			"parent": "LuaControl",
+			"attributes": [
+				{ "name": "name", "description": "Name of the entity prototype.", "type": "string", "optional": false },
+				{ "name": "unit_number", "description": "", "type": "uint", "optional": true }
+			],
+			"methods": [
+				{
+					"name": "teleport",
+					"description": "",
+					"parameters": [
+						{ "name": "position", "order": 0, "type": "MapPosition", "optional": false },
+						{ "name": "end", "order": 1, "type": "uint", "optional": true }
+					],
+					"return_values": [{ "type": "boolean", "optional": false }]
+				},
+				{
+					"name": "get_inventory",
+					"description": "",
+					"takes_table": true,
+					"parameters": [
+						{ "name": "inventory", "order": 0, "type": "defines.inventory", "optional": false },
+						{ "name": "direction", "order": 1, "type": "Direction", "optional": true }
+					],
+					"return_values": [{ "type": { "complex_type": "array", "value": "LuaEntity" }, "optional": true }]
+				}
+			]
+		},
+		{
+			"name": "LuaGameScript",
+			"description": "",
+			"attributes": [
+				{ "name": "tick", "description": "Current map tick.", "type": "uint", "optional": false },
+				{
+					"name": "entities",
+					"description": "",
+					"type": { "complex_type": "dictionary", "key": "uint", "value": "LuaEntity" },
+					"optional": false
+				}
+			],
+			"methods": []
+		}
+	],
+	"events": [
+		{
+			"name": "on_built_entity",
+			"description": "Called when a player builds an entity.",
+			"data": [{ "name": "created_entity", "order": 0, "type": "LuaEntity", "optional": false }]
+		}
+	],
+	"defines": [
+		{
+			"name": "inventory",
+			"description": "",
+			"values": [{ "name": "fuel", "description": "" }, { "name": "chest", "description": "" }]
+		},
+		{
+			"name": "events",
+			"description": "",
+			"values": [{ "name": "on_built_entity", "description": "" }]
+		}
+	],
+	"global_objects": [
+		{ "name": "game", "description": "The main scripting interface.", "type": "LuaGameScript" }
+	],
+	"global_functions": [
+		{
+			"name": "log",
+			"description": "Print to the log file.",
+			"parameters": [{ "name": "string", "order": 0, "type": "string", "optional": false }],
+			"return_values": []
+		}
+	]
+}`
+
+func TestGenerate(t *testing.T) {
+	var api RuntimeAPI
+	require.NoError(t, json.Unmarshal([]byte(runtimeAPI), &api))
+	defs := api.Generate()
+
+	root := t.TempDir()
+	path := filepath.Join(root, "runtime-api.lua")
+	require.NoError(t, os.WriteFile(path, []byte(defs), 0644))
+	env := types.NewEnvironment()
+	env.RootPath = t.TempDir()
+	env.Library = []string{root}
+	env.Init()
+
+	uri, err := util.PathToURI(path)
+	require.NoError(t, err)
+	require.NotNil(t, env.Info[uri])
+	assert.Empty(t, env.Diagnostics(uri), defs)
+	assert.NotNil(t, env.Info[uri].Meta)
+
+	assert.Equal(t, "LuaGameScript", env.GlobalType("game", "").String())
+
+	entity, ok := env.Types["LuaEntity"].(*types.Named)
+	require.True(t, ok)
+	assert.Equal(t, "string", entity.Field("name").Type.String())
+	assert.Equal(t, "function(position: MapPosition, _end: uint|nil) → boolean", entity.Field("teleport").Type.String())
+	assert.NotNil(t, env.Types["EventData.on_built_entity"])
+	inventory, ok := env.Types["defines.inventory"].(*types.Enum)
+	require.True(t, ok)
+	assert.NotEmpty(t, inventory.Members)
+
+	for _, page := range []string{
+		"classes/LuaEntity.html",
+		"classes/LuaEntity.html#LuaEntity.teleport",
+		"concepts.html#MapPosition",
+		"events.html#on_built_entity",
+		"defines.html#defines.inventory",
+	} {
+		assert.Contains(t, defs, "[Online documentation](https://lua-api.factorio.com/1.1.100/"+page+")")
+	}
+}
+
+const prototypeAPI = `{
+	"application": "factorio",
+	"stage": "prototype",
+	"application_version": "1.1.100",
+	"api_version": 4,
+	"prototypes": [
+		{
+			"name": "PrototypeBase",
+			"description": "The abstract base for prototypes.",
+			"abstract": true,
+			"typename": null,
+			"properties": [
+				{ "name": "type", "order": 0, "description": "", "type": "string", "optional": false },
+				{ "name": "name", "order": 1, "description": "Unique textual identification.", "type": "string", "optional": false }
+			]
+		},
+		{
+			"name": "ItemPrototype",
+			"description": "",
+			"parent": "PrototypeBase",
+			"typename": "item",
+			"properties": [
+				{ "name": "stack_size", "order": 0, "description": "", "type": "ItemCountType", "optional": false },
+				{ "name": "icon", "order": 1, "description": "", "type": "FileName", "optional": true },
+				{ "name": "flags", "order": 2, "description": "", "type": { "complex_type": "array", "value": "ItemPrototypeFlag" }, "optional": true }
+			]
+		}
+	],
+	"types": [
+		{ "name": "bool", "description": "", "type": "builtin" },
+		{ "name": "string", "description": "", "type": "builtin" },
+		{ "name": "uint32", "description": "", "type": "builtin" },
+		{ "name": "ItemCountType", "description": "", "type": "uint32" },
+		{ "name": "FileName", "description": "A slash-separated path.", "type": "string" },
+		{
+			"name": "ItemPrototypeFlag",
+			"description": "",
+			"type": {
+				"complex_type": "union",
+				"options": [
+					{ "complex_type": "literal", "value": "hidden" },
+					{ "complex_type": "literal", "value": "hide-from-bonus-gui" }
+				]
+			}
+		}
+	]
+}`
+
+func TestGeneratePrototypes(t *testing.T) {
+	var api PrototypeAPI
+	require.NoError(t, json.Unmarshal([]byte(prototypeAPI), &api))
+	defs := api.Generate()
+
+	library := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(library, "prototype-api.lua"), []byte(defs), 0644))
+	root := t.TempDir()
+	data := filepath.Join(root, "data.lua")
+	require.NoError(t, os.WriteFile(data, []byte(`data:extend({
+	{ type = "item", name = "iron-plate", stack_size = 100, flags = { "hidden" }, result = "iron-plate" },
+	{ type = "item", name = "copper-plate" },
+})`), 0644))
+	env := types.NewEnvironment()
+	env.RootPath = root
+	env.Include = DataFiles
+	env.Library = []string{library}
+	env.Init()
+
+	defsURI, err := util.PathToURI(filepath.Join(library, "prototype-api.lua"))
+	require.NoError(t, err)
+	assert.Empty(t, env.Diagnostics(defsURI), defs)
+
+	item, ok := env.Types["ItemPrototype"].(*types.Named)
+	require.True(t, ok)
+	require.NotNil(t, item.Field("name"), "inherited properties should be flattened")
+	assert.Equal(t, `"item"`, item.Field("type").Type.String())
+	assert.Contains(t, defs, "(https://lua-api.factorio.com/1.1.100/prototypes/ItemPrototype.html#stack_size)")
+	assert.Contains(t, defs, "(https://lua-api.factorio.com/1.1.100/prototypes/PrototypeBase.html#name)", "inherited properties should link to their parent")
+
+	dataURI, err := util.PathToURI(data)
+	require.NoError(t, err)
+	assert.True(t, env.Owns(dataURI))
+	assert.False(t, env.Owns(defsURI))
+	diagnostics := env.Diagnostics(dataURI)
+	require.Len(t, diagnostics, 2)
+	assert.Equal(t, "Unknown field 'result' in 'ItemPrototype'", diagnostics[0].Message)
+	assert.Equal(t, "Missing required field 'stack_size' of 'ItemPrototype'", diagnostics[1].Message)
+}
@@ -0,0 +1,229 @@
+package factorio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModInfo is the contents of a mod's `info.json`.
+type ModInfo struct {
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	FactorioVersion string   `json:"factorio_version"`
+	Dependencies    []string `json:"dependencies"`
+}
+
+// ReadModInfo reads the `info.json` in the given mod directory.
+func ReadModInfo(dir string) (*ModInfo, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "info.json"))
+	if err != nil {
+		return nil, err
+	}
+	var info ModInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("Failed to decode %s: %w", filepath.Join(dir, "info.json"), err)
+	}
+	if info.FactorioVersion == "" {
+		// Mods that do not specify a version are for 0.12, but the closest supported version is the oldest one that
+		// has machine-readable docs.
+		info.FactorioVersion = "1.1"
+	}
+	return &info, nil
+}
+
+type DependencyKind int
+
+const (
+	DependencyRequired DependencyKind = iota
+	DependencyOptional
+	DependencyHiddenOptional
+	DependencyIncompatible
+	DependencyNoLoadOrder
+)
+
+// Dependency is a parsed entry of a mod's dependencies.
+type Dependency struct {
+	Kind    DependencyKind
+	Name    string
+	Op      string // The version comparison, such as `>=`, or empty if there is no version constraint.
+	Version string
+}
+
+var dependencyPattern = regexp.MustCompile(`^(!|\?|\(\?\)|~)?\s*(.+?)\s*(?:(<=|>=|=|<|>)\s*(\S+))?$`)
+
+// ParseDependency parses a dependency string such as `? some-mod >= 1.2.0`.
+func ParseDependency(s string) (Dependency, bool) {
+	match := dependencyPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return Dependency{}, false
+	}
+	dep := Dependency{Name: match[2], Op: match[3], Version: match[4]}
+	switch match[1] {
+	case "?":
+		dep.Kind = DependencyOptional
+	case "(?)":
+		dep.Kind = DependencyHiddenOptional
+	case "!":
+		dep.Kind = DependencyIncompatible
+	case "~":
+		dep.Kind = DependencyNoLoadOrder
+	}
+	return dep, true
+}
+
+// FindMod returns the directory of the unpacked mod with the given name among the given directories, or an empty
+// string if it is not present. Directories may be named after the mod alone, or after its name and version, in which
+// case the newest version is chosen. Zipped mods cannot be read and are skipped.
+func FindMod(dirs []string, name string) string {
+	for _, dir := range dirs {
+		if path := filepath.Join(dir, name); isDir(path) {
+			return path
+		}
+		matches, _ := filepath.Glob(filepath.Join(dir, name+"_*"))
+		versions := []string{}
+		for _, match := range matches {
+			if isDir(match) && isVersion(strings.TrimPrefix(filepath.Base(match), name+"_")) {
+				versions = append(versions, match)
+			}
+		}
+		if len(versions) > 0 {
+			sort.Slice(versions, func(i, j int) bool {
+				return CompareVersions(
+					strings.TrimPrefix(filepath.Base(versions[i]), name+"_"),
+					strings.TrimPrefix(filepath.Base(versions[j]), name+"_"),
+				) < 0
+			})
+			return versions[len(versions)-1]
+		}
+	}
+	return ""
+}
+
+// CompareVersions compares two dotted version strings numerically, returning -1, 0, or 1. Missing parts are treated
+// as zero.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// CachedDefinitions returns the newest generated definitions for the given stage whose Factorio version starts with
+// the given version, such as `1.1`, or an empty string if there are none.
+func CachedDefinitions(stage string, version string) string {
+	dir, err := DefinitionsDir()
+	if err != nil {
+		return ""
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, stage+"-api-*.lua"))
+	best, bestVersion := "", ""
+	for _, match := range matches {
+		v := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), stage+"-api-"), ".lua")
+		if !MatchesVersion(v, version) {
+			continue
+		}
+		if best == "" || CompareVersions(v, bestVersion) > 0 {
+			best, bestVersion = match, v
+		}
+	}
+	return best
+}
+
+// MatchesVersion returns whether the full version, such as `1.1.100`, belongs to the given major version, such as
+// `1.1`.
+func MatchesVersion(full string, version string) bool {
+	return full == version || strings.HasPrefix(full, version+".")
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func isVersion(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if _, err := strconv.Atoi(part); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Setup describes the libraries that a mod is analyzed with.
+type Setup struct {
+	Mod *ModInfo
+	// Library contains the directories of the mod's dependencies that were found on disk.
+	Library []string
+	// RuntimeDefinitions and PrototypeDefinitions are the paths of the generated definitions that match the mod's
+	// Factorio version, or empty if there are none.
+	RuntimeDefinitions   string
+	PrototypeDefinitions string
+}
+
+// Detect reads the `info.json` of the mod in root and locates its dependencies and API definitions. Dependencies are
+// searched for next to the mod and, if factorioPath is not empty, in the game's data directory, which is also
+// where the API documentation is read from.
+func Detect(root string, factorioPath string) (*Setup, error) {
+	mod, err := ReadModInfo(root)
+	if err != nil {
+		return nil, err
+	}
+	setup := &Setup{Mod: mod}
+	dirs := []string{filepath.Dir(root)}
+	if factorioPath != "" {
+		dirs = append(dirs, filepath.Join(factorioPath, "data"))
+	}
+	deps := mod.Dependencies
+	if len(deps) == 0 {
+		// Mods depend on the base mod if they do not specify any dependencies.
+		deps = []string{"base"}
+	}
+	for _, dep := range append([]string{"core"}, deps...) {
+		dep, ok := ParseDependency(dep)
+		if !ok || dep.Kind == DependencyIncompatible {
+			continue
+		}
+		if dir := FindMod(dirs, dep.Name); dir != "" && dir != root {
+			setup.Library = append(setup.Library, dir)
+		}
+	}
+	setup.RuntimeDefinitions = definitions(factorioPath, "runtime", mod.FactorioVersion)
+	setup.PrototypeDefinitions = definitions(factorioPath, "prototype", mod.FactorioVersion)
+	return setup, nil
+}
+
+// definitions returns the generated definitions for the stage and version, importing them from the game's
+// documentation if it has the right version and falling back to previously generated definitions otherwise.
+func definitions(factorioPath string, stage string, version string) string {
+	if factorioPath != "" {
+		path := filepath.Join(factorioPath, "doc-html", stage+"-api.json")
+		if data, err := os.ReadFile(path); err == nil {
+			var h header
+			if json.Unmarshal(data, &h) == nil && MatchesVersion(h.ApplicationVersion, version) {
+				if out, err := Import(path); err == nil {
+					return out
+				}
+			}
+		}
+	}
+	return CachedDefinitions(stage, version)
+}
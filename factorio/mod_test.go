@@ -0,0 +1,72 @@
+package factorio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDependency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Dependency
+	}{
+		{"base", Dependency{Kind: DependencyRequired, Name: "base"}},
+		{"base >= 1.1.0", Dependency{Kind: DependencyRequired, Name: "base", Op: ">=", Version: "1.1.0"}},
+		{"? flib", Dependency{Kind: DependencyOptional, Name: "flib"}},
+		{"(?) Some Mod = 0.1", Dependency{Kind: DependencyHiddenOptional, Name: "Some Mod", Op: "=", Version: "0.1"}},
+		{"! bad-mod", Dependency{Kind: DependencyIncompatible, Name: "bad-mod"}},
+		{"~ after", Dependency{Kind: DependencyNoLoadOrder, Name: "after"}},
+	}
+	for _, test := range tests {
+		dep, ok := ParseDependency(test.input)
+		require.True(t, ok, test.input)
+		assert.Equal(t, test.expected, dep, test.input)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	base := t.TempDir()
+	mods := filepath.Join(base, "mods")
+	factorioPath := filepath.Join(base, "factorio")
+	for _, dir := range []string{
+		"mods/my-mod",
+		"mods/flib_0.9.0",
+		"mods/flib_0.12.0",
+		"mods/flib_0.10.0",
+		"mods/other",
+		"factorio/data/base",
+		"factorio/data/core",
+		"factorio/doc-html",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(base, filepath.FromSlash(dir)), 0755))
+	}
+	root := filepath.Join(mods, "my-mod")
+	require.NoError(t, os.WriteFile(filepath.Join(root, "info.json"), []byte(`{
+	"name": "my-mod",
+	"version": "0.1.0",
+	"factorio_version": "1.1",
+	"dependencies": ["base >= 1.1", "? flib", "! other", "? missing"]
+}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(factorioPath, "doc-html", "runtime-api.json"), []byte(runtimeAPI), 0644))
+
+	setup, err := Detect(root, factorioPath)
+	require.NoError(t, err)
+	assert.Equal(t, "my-mod", setup.Mod.Name)
+	assert.Equal(t, []string{
+		filepath.Join(factorioPath, "data", "core"),
+		filepath.Join(factorioPath, "data", "base"),
+		filepath.Join(mods, "flib_0.12.0"),
+	}, setup.Library)
+	assert.FileExists(t, setup.RuntimeDefinitions)
+	assert.Empty(t, setup.PrototypeDefinitions)
+
+	// Definitions that were generated before are reused without the documentation.
+	setup, err = Detect(root, "")
+	require.NoError(t, err)
+	assert.FileExists(t, setup.RuntimeDefinitions)
+}
@@ -0,0 +1,128 @@
+package factorio
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DataFiles contains the patterns of the files that are run during the data stage, relative to the root of a mod.
+var DataFiles = []string{
+	"data.lua",
+	"data-updates.lua",
+	"data-final-fixes.lua",
+	"prototypes/**",
+}
+
+// Generate converts the API into a LuaCATS definition file for the data stage.
+func (api *PrototypeAPI) Generate() string {
+	g := newGenerator(api.ApplicationVersion, api.APIVersion)
+	g.line("---@meta")
+	g.line("-- Factorio %s prototype API, %s.", api.ApplicationVersion, generatedBy)
+
+	types := map[string]*PrototypeType{}
+	for i := range api.Types {
+		types[api.Types[i].Name] = &api.Types[i]
+	}
+	builtins := []BuiltinType{}
+	for _, typ := range api.Types {
+		if typ.Type.Name == "builtin" {
+			builtins = append(builtins, BuiltinType{Name: typ.Name, Description: typ.Description})
+		}
+	}
+	g.builtins(builtins)
+	for _, typ := range api.Types {
+		if typ.Type.Name == "builtin" {
+			continue
+		}
+		g.blank()
+		g.description(g.documented(typ.Description, "types/"+typ.Name+".html"))
+		if typ.Type.Complex != "struct" {
+			g.line("---@alias %s %s", typ.Name, g.typ(typ.Type))
+			continue
+		}
+		g.line("---@class %s", typ.Name)
+		g.properties("types/", typ.Name, typ.Properties, func(name string) (string, []Property) {
+			if parent := types[name]; parent != nil {
+				return parent.Parent, parent.Properties
+			}
+			return "", nil
+		}, typ.Parent)
+	}
+
+	prototypes := map[string]*Prototype{}
+	for i := range api.Prototypes {
+		prototypes[api.Prototypes[i].Name] = &api.Prototypes[i]
+	}
+	instances := []string{}
+	for _, prototype := range api.Prototypes {
+		g.blank()
+		g.description(g.documented(prototype.Description, "prototypes/"+prototype.Name+".html"))
+		g.line("---@class %s", prototype.Name)
+		own := prototype.Properties
+		if prototype.Typename != "" {
+			// The type name is always known, unlike the string of the inherited `type` property.
+			literal := Type{Complex: "literal", Value: json.RawMessage(strconv.Quote(prototype.Typename))}
+			own = append([]Property{{Name: "type", Order: -1, Type: literal}}, own...)
+		}
+		g.properties("prototypes/", prototype.Name, own, func(name string) (string, []Property) {
+			if parent := prototypes[name]; parent != nil {
+				return parent.Parent, parent.Properties
+			}
+			return "", nil
+		}, prototype.Parent)
+		if !prototype.Abstract && prototype.Typename != "" {
+			instances = append(instances, prototype.Name)
+		}
+	}
+	if len(instances) > 0 {
+		g.blank()
+		g.line("---A prototype that can be passed to `data:extend`.")
+		g.line("---@alias AnyPrototype %s", strings.Join(instances, "|"))
+	}
+
+	g.defines(api.Defines)
+
+	g.blank()
+	g.line("---@class data")
+	g.line("---@field raw table<string, table<string, AnyPrototype>> Every prototype that has been defined, by type and name.")
+	g.line("---@field is_demo boolean Whether the game is the demo version.")
+	g.line("---@field extend fun(self: data, prototypes: AnyPrototype[]) Adds prototypes to `data.raw`.")
+	g.blank()
+	g.line("---@type data")
+	g.line("data = nil")
+	g.blank()
+	g.line("---The versions of the active mods, by name.")
+	g.line("---@type table<string, string>")
+	g.line("mods = nil")
+	return g.sb.String()
+}
+
+// properties writes the properties of the prototype or type with the given name along with those that it inherits,
+// which are looked up by parent. Properties are flattened so that each class is complete on its own, and link to the
+// page in dir of the class that declares them.
+func (g *generator) properties(dir string, name string, own []Property, parent func(name string) (string, []Property), parentName string) {
+	chain := [][]Property{own}
+	owners := []string{name}
+	for seen := map[string]bool{}; parentName != "" && !seen[parentName]; {
+		seen[parentName] = true
+		owners = append(owners, parentName)
+		var props []Property
+		parentName, props = parent(parentName)
+		chain = append(chain, props)
+	}
+	written := map[string]bool{}
+	for i, props := range chain {
+		props := append([]Property{}, props...)
+		sort.SliceStable(props, func(i, j int) bool { return props[i].Order < props[j].Order })
+		for _, prop := range props {
+			// Overridden properties are written by the descendant that overrides them.
+			if written[prop.Name] {
+				continue
+			}
+			written[prop.Name] = true
+			g.field(prop.Name, prop.Optional, g.typ(prop.Type), prop.Description, dir+owners[i]+".html#"+prop.Name)
+		}
+	}
+}
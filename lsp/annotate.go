@@ -0,0 +1,124 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+)
+
+// annotationStubsFix returns a fix that adds a `---@param` annotation for each parameter of the function that is
+// declared at the given position, and a `---@return` annotation for each value that it returns, with the inferred
+// types as placeholders. Parameters and return values that are already annotated are left alone. The position must
+// be within the declaration up to the parameter list, so that the fix is not offered everywhere in the body.
+func annotationStubsFix(file *ast.File, info *types.Info, text string, pos token.Pos) (ast.Fix, bool) {
+	decl, params, vararg, fn := functionDeclarationAt(file, info, pos)
+	if decl == nil || fn == nil {
+		return ast.Fix{}, false
+	}
+	doc := info.Docs[decl]
+	lines := []string{}
+	for _, pair := range params.Pairs {
+		name := pair.Node.Token.Literal
+		if doc.Param(name) != nil {
+			continue
+		}
+		var typ types.Type = &types.Unknown{}
+		if sym := info.Defs[pair.Node]; sym != nil {
+			typ = sym.Type
+		}
+		lines = append(lines, fmt.Sprintf("---@param %s %s", name, annotationType(typ)))
+	}
+	if vararg != nil && doc.Param("...") == nil {
+		lines = append(lines, "---@param ... any")
+	}
+	if len(doc.Returns()) == 0 {
+		var values []types.Type
+		var rest types.Type
+		switch ret := fn.Return.(type) {
+		case *types.Tuple:
+			values, rest = ret.Types, ret.Rest
+		case *types.Nil, nil:
+			// Functions that return nothing do not need a `---@return` annotation.
+		default:
+			values = []types.Type{ret}
+		}
+		for _, value := range values {
+			lines = append(lines, "---@return "+annotationType(value))
+		}
+		if rest != nil {
+			lines = append(lines, fmt.Sprintf("---@return %s ...", annotationType(rest)))
+		}
+	}
+	if len(lines) == 0 {
+		return ast.Fix{}, false
+	}
+	lineStart := file.LineBreaks.LineStart(file.LineBreaks.Line(decl.Pos()))
+	indent := ""
+	if lineStart <= len(text) {
+		line := text[lineStart:]
+		indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	}
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(indent + line + "\n")
+	}
+	return ast.Fix{
+		Title: "Generate annotation stubs",
+		Edits: []ast.Edit{{Range: token.Range{Start: lineStart, End: lineStart}, NewText: sb.String()}},
+	}, true
+}
+
+// functionDeclarationAt returns the innermost declaration of a function whose header contains the given position,
+// along with the parameters and signature of the function. Declarations are function statements, and locals,
+// assignments and table fields that assign a single function expression, since those are what doc comments are
+// attached to.
+func functionDeclarationAt(file *ast.File, info *types.Info, pos token.Pos) (ast.Node, *ast.Punctuated[*ast.Identifier], *ast.Unit, *types.Function) {
+	nodePath := ast.GetSemanticNode(file.Block, pos)
+	nodes := append(nodePath.Parents, nodePath.Node)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		switch node := nodes[i].(type) {
+		case *ast.FunctionStatement:
+			if pos <= node.RightParen.End() {
+				return node, &node.Params, node.Vararg, info.Functions[node]
+			}
+			return nil, nil, nil, nil
+		case *ast.FunctionExpression:
+			if pos > node.RightParen.End() {
+				return nil, nil, nil, nil
+			}
+		case *ast.LocalStatement, *ast.AssignmentStatement, *ast.TableSimpleKeyField, *ast.TableExpressionKeyField:
+			if fn := types.DeclaredFunction(node); fn != nil && pos <= fn.RightParen.End() {
+				return node, &fn.Params, fn.Vararg, info.Functions[fn]
+			}
+			return nil, nil, nil, nil
+		case ast.Statement:
+			return nil, nil, nil, nil
+		}
+	}
+	return nil, nil, nil, nil
+}
+
+// annotationType returns how the given type is written in an annotation. Types that were not inferred are written
+// as `any`, and functions and table shapes by their kind, since they would rarely be worth spelling out in full.
+func annotationType(typ types.Type) string {
+	switch typ := typ.(type) {
+	case nil, *types.Unknown:
+		return "any"
+	case *types.Function:
+		return "function"
+	case *types.Table:
+		if len(typ.Fields) > 0 || typ.Value == nil {
+			return "table"
+		}
+	case *types.Union:
+		parts := make([]string, 0, len(typ.Types))
+		for _, member := range typ.Types {
+			parts = append(parts, annotationType(member))
+		}
+		return strings.Join(parts, "|")
+	}
+	return typ.String()
+}
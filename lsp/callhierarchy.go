@@ -0,0 +1,368 @@
+package lsp
+
+import (
+	"encoding/json"
+	"path"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// callable is a function, or the top level of a file, that appears in the call hierarchy.
+type callable struct {
+	uri       protocol.URI
+	name      string
+	detail    string // The path of the table that the function is a field of, if any.
+	kind      protocol.SymbolKind
+	rng       token.Range // The whole definition of the function.
+	selection token.Range // The name of the function, or the `function` keyword of an anonymous function.
+	body      *ast.Block
+}
+
+// callHierarchyData identifies the callable of a call hierarchy item, which is sent back by the client when it asks
+// for the calls of the item.
+type callHierarchyData struct {
+	URI      protocol.URI      `json:"uri"`
+	Position protocol.Position `json:"position"`
+	File     bool              `json:"file,omitempty"` // Whether the item is the top level of the file.
+}
+
+func (s *Server) textDocumentPrepareCallHierarchy(ctx *glsp.Context, params *protocol.CallHierarchyPrepareParams) ([]protocol.CallHierarchyItem, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	if c := callableAt(file, file.ToPos(params.Position, s.encoding)); c != nil {
+		return []protocol.CallHierarchyItem{s.callHierarchyItem(c)}, nil
+	}
+	target := s.referenceTargetAt(file, s.getInfo(file.URI), params.Position)
+	if target == nil {
+		return nil, nil
+	}
+	items := []protocol.CallHierarchyItem{}
+	for _, c := range s.callTargets(s.environmentOf(file.URI), file.URI, target) {
+		items = append(items, s.callHierarchyItem(c))
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items, nil
+}
+
+// callHierarchyIncomingCalls returns the functions that call the item, and the files that call it at their top
+// level. Calls of globals are found in every file of the workspace through the global index.
+func (s *Server) callHierarchyIncomingCalls(ctx *glsp.Context, params *protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	c, err := s.itemCallable(params.Item)
+	if c == nil || err != nil {
+		return nil, err
+	}
+	file := s.getFile(c.uri)
+	target := s.referenceTargetAt(file, s.getInfo(c.uri), file.ToProtocolPos(c.selection.Start, s.encoding))
+	if target == nil {
+		return []protocol.CallHierarchyIncomingCall{}, nil
+	}
+	calls := []protocol.CallHierarchyIncomingCall{}
+	index := map[types.Location]int{}
+	for _, loc := range target.locations(s.environmentOf(c.uri), c.uri, false) {
+		caller := s.getFile(loc.URI)
+		if caller == nil || caller.Block == nil {
+			continue
+		}
+		nodePath := ast.GetSemanticNode(caller.Block, loc.Range.Start)
+		parents, ok := callOf(nodePath)
+		if !ok {
+			continue
+		}
+		from := enclosingCallable(caller, parents)
+		key := types.Location{URI: from.uri, Range: from.selection}
+		i, ok := index[key]
+		if !ok {
+			i = len(calls)
+			index[key] = i
+			calls = append(calls, protocol.CallHierarchyIncomingCall{From: s.callHierarchyItem(from), FromRanges: []protocol.Range{}})
+		}
+		calls[i].FromRanges = append(calls[i].FromRanges, caller.ToProtocolRange(loc.Range, s.encoding))
+	}
+	return calls, nil
+}
+
+// callHierarchyOutgoingCalls returns the functions that the item calls. Calls within nested functions are made by
+// those functions, so they are not included.
+func (s *Server) callHierarchyOutgoingCalls(ctx *glsp.Context, params *protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	c, err := s.itemCallable(params.Item)
+	if c == nil || err != nil {
+		return nil, err
+	}
+	file := s.getFile(c.uri)
+	info := s.getInfo(c.uri)
+	env := s.environmentOf(c.uri)
+	calls := []protocol.CallHierarchyOutgoingCall{}
+	index := map[types.Location]int{}
+	ast.WalkSemantic(c.body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionStatement, *ast.FunctionExpression:
+			return false
+		case *ast.FunctionCall:
+			ident := calledName(n)
+			if ident == nil {
+				return true
+			}
+			rng := file.ToProtocolRange(ast.Range(ident), s.encoding)
+			target := s.referenceTargetAt(file, info, rng.Start)
+			if target == nil {
+				return true
+			}
+			for _, to := range s.callTargets(env, c.uri, target) {
+				key := types.Location{URI: to.uri, Range: to.selection}
+				i, ok := index[key]
+				if !ok {
+					i = len(calls)
+					index[key] = i
+					calls = append(calls, protocol.CallHierarchyOutgoingCall{To: s.callHierarchyItem(to), FromRanges: []protocol.Range{}})
+				}
+				calls[i].FromRanges = append(calls[i].FromRanges, rng)
+			}
+		}
+		return true
+	})
+	return calls, nil
+}
+
+// callTargets returns the functions that a target is defined as. A global may be assigned a function in several
+// places, and targets that are not functions have none.
+func (s *Server) callTargets(env *types.Environment, uri protocol.URI, target *referenceTarget) []*callable {
+	locations := []types.Location{}
+	switch {
+	case target.global != "":
+		for _, site := range env.Globals.Defs(target.global) {
+			locations = append(locations, types.Location{URI: site.URI, Range: site.Range})
+		}
+	case target.field != nil:
+		locations = append(locations, target.field.Loc)
+	case target.symbol != nil && target.symbol.Decl != nil:
+		locations = append(locations, types.Location{URI: uri, Range: ast.Range(target.symbol.Decl)})
+	}
+	callables := []*callable{}
+	for _, loc := range locations {
+		file := s.getFile(loc.URI)
+		if file == nil || file.Block == nil {
+			continue
+		}
+		if c := callableAt(file, loc.Range.Start); c != nil {
+			callables = append(callables, c)
+		}
+	}
+	return callables
+}
+
+// itemCallable returns the callable that a call hierarchy item was created for, or nil if the file has changed such
+// that it no longer exists.
+func (s *Server) itemCallable(item protocol.CallHierarchyItem) (*callable, error) {
+	// The data is sent back as it was decoded from JSON, so it is converted back to its original type.
+	encoded, err := json.Marshal(item.Data)
+	if err != nil {
+		return nil, err
+	}
+	var data callHierarchyData
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	file := s.getFile(data.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	if data.File {
+		return fileCallable(file), nil
+	}
+	pos := file.ToPos(data.Position, s.encoding)
+	if c := callableAt(file, pos); c != nil {
+		return c, nil
+	}
+	// Anonymous functions are identified by their `function` keyword.
+	nodePath := ast.GetSemanticNode(file.Block, pos)
+	if fe, ok := nodePath.Node.(*ast.FunctionExpression); ok && fe.Pos() == pos {
+		return enclosingCallable(file, append(nodePath.Parents, fe)), nil
+	}
+	return nil, nil
+}
+
+func (s *Server) callHierarchyItem(c *callable) protocol.CallHierarchyItem {
+	file := s.getFile(c.uri)
+	item := protocol.CallHierarchyItem{
+		Name:           c.name,
+		Kind:           c.kind,
+		URI:            c.uri,
+		Range:          file.ToProtocolRange(c.rng, s.encoding),
+		SelectionRange: file.ToProtocolRange(c.selection, s.encoding),
+		Data: callHierarchyData{
+			URI:      c.uri,
+			Position: file.ToProtocolPos(c.selection.Start, s.encoding),
+			File:     c.kind == protocol.SymbolKindFile,
+		},
+	}
+	if c.detail != "" {
+		item.Detail = util.Ptr(c.detail)
+	}
+	return item
+}
+
+// callableAt returns the function whose name is at the given position, if any.
+func callableAt(file *ast.File, pos token.Pos) *callable {
+	nodePath := ast.GetSemanticNode(file.Block, pos)
+	for i := len(nodePath.Parents) - 1; i >= 0; i-- {
+		switch p := nodePath.Parents[i].(type) {
+		case *ast.FunctionStatement:
+			if containsPos(p.Name, pos) {
+				return newCallable(file, p.Name, p, &p.Body)
+			}
+		case *ast.LocalStatement:
+			for j, pair := range p.Names.Pairs {
+				if containsPos(pair.Node, pos) && p.Exps != nil && j < len(p.Exps.Pairs) {
+					if fe, ok := p.Exps.Pairs[j].Node.(*ast.FunctionExpression); ok {
+						return newCallable(file, pair.Node, p, &fe.Body)
+					}
+				}
+			}
+		case *ast.AssignmentStatement:
+			for j, pair := range p.Vars.Pairs {
+				if containsPos(pair.Node, pos) && j < len(p.Exps.Pairs) {
+					if fe, ok := p.Exps.Pairs[j].Node.(*ast.FunctionExpression); ok {
+						return newCallable(file, pair.Node, p, &fe.Body)
+					}
+				}
+			}
+		case *ast.TableSimpleKeyField:
+			if fe, ok := p.Expr.(*ast.FunctionExpression); ok && containsPos(&p.Name, pos) {
+				return newCallable(file, &p.Name, p, &fe.Body)
+			}
+		}
+	}
+	return nil
+}
+
+// enclosingCallable returns the innermost function that contains the node with the given parents, or the top level
+// of the file if it is not within a function.
+func enclosingCallable(file *ast.File, parents []ast.Node) *callable {
+	for i := len(parents) - 1; i >= 0; i-- {
+		switch p := parents[i].(type) {
+		case *ast.FunctionStatement:
+			return newCallable(file, p.Name, p, &p.Body)
+		case *ast.FunctionExpression:
+			// A function that is assigned to a name is known by that name.
+			for j := i - 1; j >= 0; j-- {
+				var names, values []ast.Node
+				switch def := parents[j].(type) {
+				case *ast.LocalStatement:
+					if def.Exps != nil {
+						names, values = nodesOf(def.Names.Pairs), nodesOf(def.Exps.Pairs)
+					}
+				case *ast.AssignmentStatement:
+					names, values = nodesOf(def.Vars.Pairs), nodesOf(def.Exps.Pairs)
+				case *ast.TableSimpleKeyField:
+					names, values = []ast.Node{&def.Name}, []ast.Node{def.Expr}
+				default:
+					continue
+				}
+				for k, value := range values {
+					if value == ast.Node(p) && k < len(names) {
+						return newCallable(file, names[k], parents[j], &p.Body)
+					}
+				}
+				break
+			}
+			return &callable{
+				uri:       file.URI,
+				name:      "function",
+				kind:      protocol.SymbolKindFunction,
+				rng:       ast.Range(p),
+				selection: p.FuncTok.Range(),
+				body:      &p.Body,
+			}
+		}
+	}
+	return fileCallable(file)
+}
+
+func fileCallable(file *ast.File) *callable {
+	return &callable{
+		uri:  file.URI,
+		name: path.Base(string(file.URI)),
+		kind: protocol.SymbolKindFile,
+		rng:  ast.Range(file.Block),
+		body: file.Block,
+	}
+}
+
+func newCallable(file *ast.File, name ast.Node, def ast.Node, body *ast.Block) *callable {
+	c := &callable{
+		uri:       file.URI,
+		name:      callableName(name),
+		kind:      protocol.SymbolKindFunction,
+		rng:       ast.Range(def),
+		selection: ast.Range(name),
+		body:      body,
+	}
+	if ie, ok := name.(*ast.IndexExpression); ok {
+		c.name = callableName(ie.Inner)
+		c.detail = callableName(ie.Prefix)
+		c.kind = protocol.SymbolKindMethod
+		c.selection = ast.Range(ie.Inner)
+	}
+	return c
+}
+
+// callableName returns the source text of a name that is made of identifiers and fields, such as `foo.bar:baz`.
+func callableName(name ast.Node) string {
+	switch name := name.(type) {
+	case *ast.Identifier:
+		return name.Token.Literal
+	case *ast.IndexExpression:
+		if inner, ok := name.Inner.(*ast.Identifier); ok && name.RightIndexer == nil {
+			return callableName(name.Prefix) + name.LeftIndexer.Token.Literal + inner.Token.Literal
+		}
+		if lit, ok := name.Inner.(*ast.StringLiteral); ok {
+			if key, ok := types.StringValue(lit); ok {
+				return callableName(name.Prefix) + "." + key
+			}
+		}
+		return callableName(name.Prefix) + "[]"
+	}
+	return "?"
+}
+
+// calledName returns the identifier that names the function of a call, such as `bar` in `foo.bar()`.
+func calledName(fc *ast.FunctionCall) *ast.Identifier {
+	name := fc.Name
+	if ie, ok := name.(*ast.IndexExpression); ok && ie.RightIndexer == nil {
+		name = ie.Inner
+	}
+	ident, _ := name.(*ast.Identifier)
+	return ident
+}
+
+// callOf returns the parents of the call that the identifier at the node path is the name of, or false if it is not
+// called.
+func callOf(nodePath ast.NodePath) ([]ast.Node, bool) {
+	ident, ok := nodePath.Node.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	for i := len(nodePath.Parents) - 1; i >= 0; i-- {
+		if fc, ok := nodePath.Parents[i].(*ast.FunctionCall); ok {
+			return nodePath.Parents[:i], calledName(fc) == ident
+		}
+	}
+	return nil, false
+}
+
+func nodesOf[T ast.Node](pairs []ast.Pair[T]) []ast.Node {
+	nodes := make([]ast.Node, len(pairs))
+	for i, pair := range pairs {
+		nodes[i] = pair.Node
+	}
+	return nodes
+}
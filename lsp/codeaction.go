@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func (s *Server) textDocumentCodeAction(ctx *glsp.Context, params *protocol.CodeActionParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	env := s.environmentOf(file.URI)
+	start, end := file.ToPos(params.Range.Start, s.encoding), file.ToPos(params.Range.End, s.encoding)
+	actions := []protocol.CodeAction{}
+	kind := protocol.CodeActionKindQuickFix
+	text, _ := s.sourceOf(file.URI)
+	diagnostics := s.fileDiagnostics(env, file)
+	if len(allFixes(diagnostics)) > 0 {
+		fixAll := codeActionKindSourceFixAll
+		actions = append(actions, protocol.CodeAction{
+			Title:   "Apply all fixes",
+			Kind:    &fixAll,
+			Command: &protocol.Command{Title: "Apply all fixes", Command: commandApplyAllFixes, Arguments: []any{file.URI}},
+		})
+	}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Range.End < start || diagnostic.Range.Start > end {
+			continue
+		}
+		fixes := diagnostic.Fixes
+		if fix, ok := disableNextLineFix(file, text, diagnostic); ok {
+			fixes = append(fixes, fix)
+		}
+		for _, fix := range fixes {
+			actions = append(actions, protocol.CodeAction{
+				Title:       fix.Title,
+				Kind:        &kind,
+				Diagnostics: []protocol.Diagnostic{s.toProtocolDiagnostic(file, diagnostic)},
+				Edit:        s.fileEdit(file, fix.Edits),
+			})
+		}
+	}
+	if fix, ok := declareLocalFix(env, file, s.getInfo(file.URI), start); ok {
+		actions = append(actions, protocol.CodeAction{Title: fix.Title, Kind: &kind, Edit: s.fileEdit(file, fix.Edits)})
+	}
+	rewrite := protocol.CodeActionKindRefactorRewrite
+	for _, fix := range []func(*ast.File, *types.Info, string, token.Pos) (ast.Fix, bool){annotationStubsFix, stringFormatFix} {
+		if fix, ok := fix(file, s.getInfo(file.URI), text, start); ok {
+			actions = append(actions, protocol.CodeAction{Title: fix.Title, Kind: &rewrite, Edit: s.fileEdit(file, fix.Edits)})
+		}
+	}
+	removeUnused := s.config.RemoveUnusedRequires != nil && *s.config.RemoveUnusedRequires
+	if fix, ok := organizeRequiresFix(env, file, s.getInfo(file.URI), text, removeUnused); ok {
+		organize := protocol.CodeActionKindSourceOrganizeImports
+		actions = append(actions, protocol.CodeAction{Title: fix.Title, Kind: &organize, Edit: s.fileEdit(file, fix.Edits)})
+	}
+	if start != token.InvalidPos && end != token.InvalidPos && start < end {
+		actions = append(actions, s.extractActions(env, file, s.getInfo(file.URI), text, token.Range{Start: start, End: end})...)
+	}
+	return actions, nil
+}
+
+// fileEdit converts edits to a single file into a workspace edit.
+func (s *Server) fileEdit(file *ast.File, edits []ast.Edit) *protocol.WorkspaceEdit {
+	textEdits := []protocol.TextEdit{}
+	for _, edit := range edits {
+		textEdits = append(textEdits, protocol.TextEdit{Range: file.ToProtocolRange(edit.Range, s.encoding), NewText: edit.NewText})
+	}
+	return &protocol.WorkspaceEdit{Changes: map[protocol.DocumentUri][]protocol.TextEdit{file.URI: textEdits}}
+}
+
+// disableNextLineFix returns a fix that suppresses the diagnostic with a `---@diagnostic disable-next-line` annotation
+// on the line above it, indented to match. Diagnostics without a code cannot be suppressed individually.
+func disableNextLineFix(file *ast.File, text string, diagnostic ast.Diagnostic) (ast.Fix, bool) {
+	if diagnostic.Code == "" || diagnostic.Code == "syntax-error" {
+		return ast.Fix{}, false
+	}
+	lineStart := file.LineBreaks.LineStart(file.LineBreaks.Line(diagnostic.Range.Start))
+	indent := ""
+	if lineStart <= len(text) {
+		line := text[lineStart:]
+		indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	}
+	return ast.Fix{
+		Title: fmt.Sprintf("Disable '%s' for this line", diagnostic.Code),
+		Edits: []ast.Edit{{
+			Range:   token.Range{Start: lineStart, End: lineStart},
+			NewText: fmt.Sprintf("%s---@diagnostic disable-next-line: %s\n", indent, diagnostic.Code),
+		}},
+	}, true
+}
+
+// declareLocalFix returns a fix that turns an assignment to globals at the given position into a local declaration.
+// It is only offered if the globals are not used anywhere else, or only after the assignment within the same scope,
+// so that making them local does not change what any reference refers to.
+func declareLocalFix(env *types.Environment, file *ast.File, info *types.Info, pos token.Pos) (ast.Fix, bool) {
+	nodePath := ast.GetSemanticNode(file.Block, pos)
+	var stmt *ast.AssignmentStatement
+	for _, node := range append(nodePath.Parents, nodePath.Node) {
+		if assignment, ok := node.(*ast.AssignmentStatement); ok {
+			stmt = assignment
+		}
+	}
+	if stmt == nil {
+		return ast.Fix{}, false
+	}
+	scope := info.Scope.Innermost(stmt.Pos()).Range()
+	names := []string{}
+	for _, pair := range stmt.Vars.Pairs {
+		ident, ok := pair.Node.(*ast.Identifier)
+		if !ok {
+			return ast.Fix{}, false
+		}
+		sym := info.SymbolOf(ident)
+		if sym == nil || sym.Kind != types.SymbolGlobal {
+			return ast.Fix{}, false
+		}
+		for _, site := range env.Globals.Defs(sym.Name) {
+			if site.URI != file.URI || site.Range.Start < stmt.Pos() || !scope.ContainsPos(site.Range.Start) {
+				return ast.Fix{}, false
+			}
+		}
+		// Reads within the assignment itself, such as `x = x + 1`, would not see the new local.
+		for _, site := range env.Globals.Reads(sym.Name) {
+			if site.URI != file.URI || site.Range.Start < stmt.End() || !scope.ContainsPos(site.Range.Start) {
+				return ast.Fix{}, false
+			}
+		}
+		names = append(names, "'"+sym.Name+"'")
+	}
+	return ast.Fix{
+		Title: fmt.Sprintf("Declare %s as local", strings.Join(names, ", ")),
+		Edits: []ast.Edit{{Range: token.Range{Start: stmt.Pos(), End: stmt.Pos()}, NewText: "local "}},
+	}, true
+}
@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// codeLensData identifies the name that a code lens counts the references of, until the lens is resolved.
+type codeLensData struct {
+	URI      protocol.URI      `json:"uri"`
+	Position protocol.Position `json:"position"`
+}
+
+// textDocumentCodeLens returns a lens above every function and class of the file, and above every busted test. The
+// references are not counted until the client resolves the lenses that are visible.
+func (s *Server) textDocumentCodeLens(ctx *glsp.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
+	if s.config.CodeLens != nil && !*s.config.CodeLens {
+		return nil, nil
+	}
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	info := s.getInfo(file.URI)
+	lenses := s.testCodeLenses(file, info)
+	addLens := func(name ast.Expression) {
+		if ie, ok := name.(*ast.IndexExpression); ok {
+			name = ie.Inner
+		}
+		ident, ok := name.(*ast.Identifier)
+		if !ok {
+			return
+		}
+		rng := file.ToProtocolRange(ast.Range(ident), s.encoding)
+		lenses = append(lenses, protocol.CodeLens{Range: rng, Data: codeLensData{URI: file.URI, Position: rng.Start}})
+	}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionStatement:
+			addLens(n.Name)
+		case *ast.LocalStatement:
+			if info.Docs[n].Class() != nil && len(n.Names.Pairs) > 0 {
+				addLens(n.Names.Pairs[0].Node)
+			}
+		case *ast.AssignmentStatement:
+			if info.Docs[n].Class() != nil && len(n.Vars.Pairs) > 0 {
+				addLens(n.Vars.Pairs[0].Node)
+			}
+		}
+		return true
+	})
+	return lenses, nil
+}
+
+// codeLensResolve counts the references of the name that the lens is above.
+func (s *Server) codeLensResolve(ctx *glsp.Context, params *protocol.CodeLens) (*protocol.CodeLens, error) {
+	// The lenses of tests are complete already.
+	if params.Command != nil {
+		return params, nil
+	}
+	// The data is sent back as it was decoded from JSON, so it is converted back to its original type.
+	encoded, err := json.Marshal(params.Data)
+	if err != nil {
+		return nil, err
+	}
+	var data codeLensData
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	count := 0
+	if file := s.getFile(data.URI); file != nil && file.Block != nil {
+		if target := s.referenceTargetAt(file, s.getInfo(file.URI), data.Position); target != nil {
+			count = len(target.locations(s.environmentOf(file.URI), file.URI, false))
+		}
+	}
+	title := fmt.Sprintf("%d references", count)
+	if count == 1 {
+		title = "1 reference"
+	}
+	params.Command = &protocol.Command{Title: title}
+	return params, nil
+}
@@ -0,0 +1,134 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const (
+	// commandShowAST returns the syntax tree of the file whose URI is its only argument, as indented JSON.
+	commandShowAST = "luapls.showAST"
+	// commandReindex discards the analysis of every file and loads the workspace from disk again. Open files keep
+	// the editor's contents.
+	commandReindex = "luapls.reindex"
+	// commandApplyAllFixes applies the preferred fix of every diagnostic in the file whose URI is its only argument.
+	commandApplyAllFixes = "luapls.applyAllFixes"
+)
+
+// commands are the commands that the server executes, rather than the client.
+var commands = []string{commandShowAST, commandReindex, commandApplyAllFixes, commandRunTest}
+
+// codeActionKindSourceFixAll was added in LSP 3.17.
+const codeActionKindSourceFixAll = protocol.CodeActionKind("source.fixAll")
+
+func (s *Server) workspaceExecuteCommand(ctx *glsp.Context, params *protocol.ExecuteCommandParams) (any, error) {
+	switch params.Command {
+	case commandShowAST:
+		file, err := s.commandFile(params)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(file.Block, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case commandReindex:
+		s.reindex(ctx, workDoneToken(ctx))
+		return nil, nil
+	case commandApplyAllFixes:
+		file, err := s.commandFile(params)
+		if err != nil {
+			return nil, err
+		}
+		edits := allFixes(s.fileDiagnostics(s.environmentOf(file.URI), file))
+		if len(edits) == 0 {
+			return nil, nil
+		}
+		params := protocol.ApplyWorkspaceEditParams{Label: util.Ptr("Apply all fixes"), Edit: *s.fileEdit(file, edits)}
+		// Messages are handled one at a time, so the client's response can only be read after this handler returns.
+		go ctx.Call(protocol.ServerWorkspaceApplyEdit, params, &protocol.ApplyWorkspaceEditResponse{})
+		return nil, nil
+	case commandRunTest:
+		return nil, s.runTest(ctx, params)
+	}
+	return nil, fmt.Errorf("Unknown command '%s'", params.Command)
+}
+
+// commandFile returns the file whose URI is the first argument of the command.
+func (s *Server) commandFile(params *protocol.ExecuteCommandParams) (*ast.File, error) {
+	if len(params.Arguments) == 0 {
+		return nil, fmt.Errorf("'%s' requires the URI of a file", params.Command)
+	}
+	uri, ok := params.Arguments[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("'%s' requires the URI of a file", params.Command)
+	}
+	file := s.getFile(uri)
+	if file == nil || file.Block == nil {
+		return nil, fmt.Errorf("'%s' is not part of the workspace", uri)
+	}
+	return file, nil
+}
+
+// reindex loads every environment from disk again and republishes the diagnostics of the workspace. Files that were
+// deleted have their diagnostics cleared. Progress is reported with the given token, if there is one.
+func (s *Server) reindex(ctx *glsp.Context, token *protocol.ProgressToken) {
+	isOpen := func(uri protocol.URI) bool {
+		_, ok := s.contents[uri]
+		return ok
+	}
+	s.withProgress(ctx, token, "Indexing", func(p *progress) {
+		removed := []protocol.URI{}
+		for _, env := range s.allEnvironments() {
+			reportIndexing(env, p)
+			for _, uri := range env.Reindex(isOpen) {
+				if !slices.Contains(removed, uri) {
+					removed = append(removed, uri)
+				}
+			}
+			reportIndexing(env, nil)
+		}
+		if !s.pullDiagnostics() {
+			for _, uri := range removed {
+				ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+					URI:         uri,
+					Diagnostics: []protocol.Diagnostic{},
+				})
+			}
+		}
+		s.publishAll(ctx)
+	})
+}
+
+// allFixes returns the edits of the first fix of each diagnostic. Fixes that overlap an earlier fix are skipped, so
+// that the edits can be applied together.
+func allFixes(diagnostics []ast.Diagnostic) []ast.Edit {
+	edits := []ast.Edit{}
+	overlaps := func(rng token.Range) bool {
+		for _, edit := range edits {
+			if rng.Start < edit.Range.End && edit.Range.Start < rng.End || rng.Start == edit.Range.Start {
+				return true
+			}
+		}
+		return false
+	}
+	for _, diagnostic := range diagnostics {
+		if len(diagnostic.Fixes) == 0 {
+			continue
+		}
+		fix := diagnostic.Fixes[0]
+		if slices.ContainsFunc(fix.Edits, func(edit ast.Edit) bool { return overlaps(edit.Range) }) {
+			continue
+		}
+		edits = append(edits, fix.Edits...)
+	}
+	return edits
+}
@@ -0,0 +1,479 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// completionData identifies the name that a completion item inserts, so that its type and documentation can be
+// found when the item is resolved. Chain holds the names of the expression that a field is accessed on, and is empty
+// for locals and globals. Module holds the name of the module that an auto-require item requires.
+type completionData struct {
+	URI      protocol.URI      `json:"uri"`
+	Position protocol.Position `json:"position"`
+	Name     string            `json:"name"`
+	Chain    []string          `json:"chain,omitempty"`
+	Module   string            `json:"module,omitempty"`
+}
+
+func (s *Server) textDocumentCompletion(ctx *glsp.Context, params *protocol.CompletionParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	info := s.getInfo(file.URI)
+	pos := file.ToPos(params.Position, s.encoding)
+	if pos == token.InvalidPos {
+		return nil, nil
+	}
+	text, err := s.sourceOf(file.URI)
+	if err != nil {
+		return nil, err
+	}
+	if start, ok := requirePathAt(text, pos); ok {
+		return s.requireCompletions(file, start, pos), nil
+	}
+	if chain, method, ok := memberAccessAt(text, pos); ok {
+		return s.memberCompletions(file, info, pos, chain, method), nil
+	}
+	items := []protocol.CompletionItem{}
+	tbl, class := expectedTableAt(file, info, pos)
+	if class == nil {
+		if inStringOrComment(file, pos) {
+			return items, nil
+		}
+		if start, ok := eventArgumentAt(text, pos); ok {
+			items = s.eventCompletions(file, start, pos)
+		}
+		return append(items, s.scopeCompletions(file, info, pos)...), nil
+	}
+	present := map[string]bool{}
+	for _, pair := range tbl.Fields.Pairs {
+		// The field being typed should still be offered.
+		if field, ok := pair.Node.(*ast.TableSimpleKeyField); ok && !(field.Name.Pos() <= pos && pos <= field.Name.End()) {
+			present[field.Name.Token.Literal] = true
+		}
+	}
+	kind := protocol.CompletionItemKindField
+	for _, field := range class.AllFields() {
+		if present[field.Name] {
+			continue
+		}
+		detail := field.Type.String()
+		items = append(items, protocol.CompletionItem{Label: field.Name, Kind: &kind, Detail: &detail})
+	}
+	return items, nil
+}
+
+// expectedTableAt returns the innermost table literal containing the position that is expected to be an instance of
+// a class, along with that class.
+func expectedTableAt(file *ast.File, info *types.Info, pos int) (*ast.TableLiteral, *types.Named) {
+	nodePath := ast.GetSemanticNode(file.Block, pos)
+	nodes := append(nodePath.Parents, nodePath.Node)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		tbl, ok := nodes[i].(*ast.TableLiteral)
+		if !ok {
+			continue
+		}
+		// Only offer fields where a key is being typed, not within the value of another field.
+		if i+1 < len(nodes) && !isKeyPosition(nodes[i+1], pos) {
+			return nil, nil
+		}
+		if class := info.Expected[tbl]; class != nil {
+			return tbl, class
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+// isKeyPosition returns whether the position is within the key of the table field, or within a lone identifier that
+// will become a key once it is followed by `=`.
+func isKeyPosition(field ast.Node, pos int) bool {
+	switch field := field.(type) {
+	case *ast.TableArrayField:
+		_, ok := field.Expr.(*ast.Identifier)
+		return ok
+	case *ast.TableSimpleKeyField:
+		return field.Name.Pos() <= pos && pos <= field.Name.End()
+	}
+	return false
+}
+
+// memberAccessAt returns the names of the expression that a field is being accessed on at the given position, such
+// as `a` and `b` for `a.b.c` or `a.b:c`, and whether it is accessed with `:`. Only chains of names are recognized,
+// since the statement being typed does not parse until it is complete.
+func memberAccessAt(text string, pos token.Pos) ([]string, bool, bool) {
+	if pos > len(text) {
+		return nil, false, false
+	}
+	start := pos
+	for start > 0 && isIdentByte(text[start-1]) {
+		start--
+	}
+	if start == 0 || text[start-1] != '.' && text[start-1] != ':' || isConcat(text, start-1) {
+		return nil, false, false
+	}
+	method := text[start-1] == ':'
+	chain := []string{}
+	end := start - 1
+	for {
+		begin := end
+		for begin > 0 && isIdentByte(text[begin-1]) {
+			begin--
+		}
+		name := text[begin:end]
+		if _, reserved := token.Reserved[name]; reserved || name == "" || name[0] >= '0' && name[0] <= '9' {
+			return nil, false, false
+		}
+		chain = append([]string{name}, chain...)
+		if begin == 0 || text[begin-1] != '.' || isConcat(text, begin-1) {
+			return chain, method, true
+		}
+		end = begin - 1
+	}
+}
+
+// isConcat returns whether the dot at the given offset is part of a `..` operator.
+func isConcat(text string, dot int) bool {
+	return dot > 0 && text[dot-1] == '.' || dot+1 < len(text) && text[dot+1] == '.'
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// memberCompletions returns the fields of the type of the chain of names, or only its methods if they are accessed
+// with `:`.
+func (s *Server) memberCompletions(file *ast.File, info *types.Info, pos token.Pos, chain []string, method bool) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	for _, field := range types.FieldsOf(chainType(s.environmentOf(file.URI), info, pos, chain)) {
+		fn, isFunction := types.Resolve(field.Type).(*types.Function)
+		isMethod := isFunction && len(fn.Params) > 0 && fn.Params[0].Name == "self"
+		if method && !isMethod {
+			continue
+		}
+		kind := protocol.CompletionItemKindField
+		switch {
+		case isMethod:
+			kind = protocol.CompletionItemKindMethod
+		case isFunction:
+			kind = protocol.CompletionItemKindFunction
+		}
+		items = append(items, protocol.CompletionItem{
+			Label: field.Name,
+			Kind:  &kind,
+			Data:  completionData{URI: file.URI, Position: file.ToProtocolPos(pos, s.encoding), Name: field.Name, Chain: chain},
+		})
+	}
+	return items
+}
+
+// chainType returns the type of a chain of names, such as `a.b.c`, as seen from the given position.
+func chainType(env *types.Environment, info *types.Info, pos token.Pos, chain []string) types.Type {
+	var typ types.Type
+	if sym := info.Scope.LookupAt(chain[0], pos); sym != nil {
+		typ = symbolType(sym)
+	} else {
+		typ = env.GlobalType(chain[0], "")
+	}
+	for _, name := range chain[1:] {
+		field := types.FieldOf(typ, name)
+		if field == nil {
+			return nil
+		}
+		typ = field.Type
+	}
+	return typ
+}
+
+// symbolType returns the annotated type of a symbol, or its inferred type if it is not annotated.
+func symbolType(sym *types.Symbol) types.Type {
+	if sym.Annotated != nil {
+		return sym.Annotated
+	}
+	return sym.Type
+}
+
+// scopeCompletions returns the locals that are visible at the given position, followed by the globals of the
+// environment. Their types and documentation are left to be resolved, since there may be thousands of them.
+func (s *Server) scopeCompletions(file *ast.File, info *types.Info, pos token.Pos) []protocol.CompletionItem {
+	env := s.environmentOf(file.URI)
+	position := file.ToProtocolPos(pos, s.encoding)
+	items := []protocol.CompletionItem{}
+	seen := map[string]bool{}
+	add := func(name string, typ types.Type) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		kind := protocol.CompletionItemKindVariable
+		if _, ok := types.Resolve(typ).(*types.Function); ok {
+			kind = protocol.CompletionItemKindFunction
+		}
+		items = append(items, protocol.CompletionItem{
+			Label: name,
+			Kind:  &kind,
+			Data:  completionData{URI: file.URI, Position: position, Name: name},
+		})
+	}
+	for _, sym := range info.Scope.VisibleAt(pos) {
+		add(sym.Name, symbolType(sym))
+	}
+	for _, site := range env.Globals.Search("") {
+		if strings.Contains(site.Path, ".") {
+			continue
+		}
+		var typ types.Type
+		if siteInfo := env.Info[site.URI]; siteInfo != nil {
+			switch value := site.Value.(type) {
+			case *ast.FunctionStatement:
+				typ = siteInfo.TypeOf(value.Name)
+			case ast.Expression:
+				typ = siteInfo.TypeOf(value)
+			}
+		}
+		add(site.Path, typ)
+	}
+	return append(items, s.autoRequireCompletions(file, seen)...)
+}
+
+// autoRequireCompletions offers the modules that the file does not require yet, named after the last component of
+// their module name. Accepting one inserts a `local name = require("module")` line after the file's other requires,
+// or at the top of the file. Names that are already taken are skipped.
+func (s *Server) autoRequireCompletions(file *ast.File, taken map[string]bool) []protocol.CompletionItem {
+	env := s.environmentOf(file.URI)
+	required := map[protocol.URI]bool{file.URI: true}
+	for _, req := range env.Modules.Requires(file.URI) {
+		required[req.Target] = true
+	}
+	modules := env.ModuleNames()
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	insert := file.ToProtocolPos(requireInsertPos(env, file), s.encoding)
+	insert.Character = 0
+	kind := protocol.CompletionItemKindModule
+	items := []protocol.CompletionItem{}
+	for _, module := range names {
+		uri := modules[module]
+		if required[uri] || env.Files[uri] == nil || types.ModuleReturn(env.Files[uri]) == nil {
+			continue
+		}
+		name := module[strings.LastIndexByte(module, '.')+1:]
+		if _, reserved := token.Reserved[name]; reserved || !identifierPattern.MatchString(name) || taken[name] {
+			continue
+		}
+		taken[name] = true
+		items = append(items, protocol.CompletionItem{
+			Label:  name,
+			Kind:   &kind,
+			Detail: util.Ptr(fmt.Sprintf("require(%q)", module)),
+			AdditionalTextEdits: []protocol.TextEdit{{
+				Range:   protocol.Range{Start: insert, End: insert},
+				NewText: fmt.Sprintf("local %s = require(%q)\n", name, module),
+			}},
+			Data: completionData{URI: file.URI, Position: insert, Name: name, Module: module},
+		})
+	}
+	return items
+}
+
+// requireInsertPos returns the position after the last top-level `local x = require("module")` statement of the
+// file, or the start of the file if there is none.
+func requireInsertPos(env *types.Environment, file *ast.File) token.Pos {
+	pos := 0
+	for _, pair := range file.Block.Pairs {
+		ls, ok := pair.Node.(*ast.LocalStatement)
+		if !ok || ls.Exps == nil || len(ls.Exps.Pairs) == 0 {
+			continue
+		}
+		if env.Modules.RequireOf(file.URI, ls.Exps.Pairs[0].Node) != nil {
+			pos = file.LineBreaks.LineStart(file.LineBreaks.Line(ls.End()) + 1)
+		}
+	}
+	return pos
+}
+
+// inStringOrComment returns whether the position is within a string literal or a comment, where names are not
+// completed.
+func inStringOrComment(file *ast.File, pos token.Pos) bool {
+	if _, ok := ast.GetSemanticNode(file.Block, pos).Node.(*ast.StringLiteral); ok {
+		return true
+	}
+	for _, comment := range file.Comments {
+		if comment.Pos < pos && pos <= comment.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// completionItemResolve fills in the type and documentation of a completion item.
+func (s *Server) completionItemResolve(ctx *glsp.Context, params *protocol.CompletionItem) (*protocol.CompletionItem, error) {
+	if params.Data == nil {
+		return params, nil
+	}
+	// The data is sent back as it was decoded from JSON, so it is converted back to its original type.
+	encoded, err := json.Marshal(params.Data)
+	if err != nil {
+		return nil, err
+	}
+	var data completionData
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	file := s.getFile(data.URI)
+	if file == nil || file.Block == nil {
+		return params, nil
+	}
+	info := s.getInfo(file.URI)
+	env := s.environmentOf(file.URI)
+	pos := file.ToPos(data.Position, s.encoding)
+	var typ types.Type
+	var doc *annotation.Doc
+	if data.Module != "" {
+		uri, ok := env.ResolveModule(data.Module)
+		if !ok || env.Files[uri] == nil || env.Info[uri] == nil {
+			return params, nil
+		}
+		if ret := env.Info[uri].TypeOf(types.ModuleReturn(env.Files[uri])); ret != nil {
+			params.Detail = util.Ptr(fmt.Sprintf("require(%q): %s", data.Module, ret))
+		}
+		return params, nil
+	}
+	if len(data.Chain) > 0 {
+		field := types.FieldOf(chainType(env, info, pos, data.Chain), data.Name)
+		if field == nil {
+			return params, nil
+		}
+		typ, doc = field.Type, s.fieldDoc(env, field)
+	} else if sym := info.Scope.LookupAt(data.Name, pos); sym != nil {
+		typ = symbolType(sym)
+		if sym.Node != nil {
+			doc = info.Docs[sym.Node]
+		}
+	} else {
+		typ = env.GlobalType(data.Name, "")
+		for _, site := range env.Globals.Defs(data.Name) {
+			if siteInfo := env.Info[site.URI]; siteInfo != nil && site.Stmt != nil && siteInfo.Docs[site.Stmt] != nil {
+				doc = siteInfo.Docs[site.Stmt]
+				break
+			}
+		}
+	}
+	detail := "unknown"
+	if typ != nil {
+		detail = typ.String()
+	}
+	params.Detail = &detail
+	if doc != nil && doc.Description != "" {
+		params.Documentation = protocol.MarkupContent{Kind: protocol.MarkupKindMarkdown, Value: doc.Description}
+	}
+	return params, nil
+}
+
+// requirePattern matches the text of a line up to the position when it is within the string passed to `require`.
+var requirePattern = regexp.MustCompile(`\brequire\s*\(?\s*["']([\w./-]*)$`)
+
+// requirePathAt returns the start of the module name that is being typed in a `require` call at the given position.
+func requirePathAt(text string, pos token.Pos) (token.Pos, bool) {
+	if pos > len(text) {
+		return 0, false
+	}
+	lineStart := strings.LastIndexByte(text[:pos], '\n') + 1
+	match := requirePattern.FindStringSubmatchIndex(text[lineStart:pos])
+	if match == nil {
+		return 0, false
+	}
+	return lineStart + match[2], true
+}
+
+// requireCompletions returns the name of every module that can be required, replacing the name typed so far.
+func (s *Server) requireCompletions(file *ast.File, start token.Pos, pos token.Pos) []protocol.CompletionItem {
+	env := s.environmentOf(file.URI)
+	modules := env.ModuleNames()
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rng := file.ToProtocolRange(token.Range{Start: start, End: pos}, s.encoding)
+	kind := protocol.CompletionItemKindModule
+	items := []protocol.CompletionItem{}
+	for _, name := range names {
+		uri := modules[name]
+		if uri == file.URI {
+			continue
+		}
+		detail := string(uri)
+		if path, err := util.URIToPath(uri); err == nil {
+			detail = path
+			if rel, err := filepath.Rel(env.RootPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+				detail = filepath.ToSlash(rel)
+			}
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:    name,
+			Kind:     &kind,
+			Detail:   util.Ptr(detail),
+			TextEdit: protocol.TextEdit{Range: rng, NewText: name},
+		})
+	}
+	return items
+}
+
+// eventPattern matches the text of a line up to the position when it is within the events passed to `on_event`,
+// either on their own or in a table.
+var eventPattern = regexp.MustCompile(`[.:]on_event\s*\(\s*(?:\{\s*(?:[\w.]+\s*,\s*)*)?(\w*)$`)
+
+// eventArgumentAt returns the start of the name that is being typed in place of an event passed to `on_event` at the
+// given position.
+func eventArgumentAt(text string, pos token.Pos) (token.Pos, bool) {
+	if pos > len(text) {
+		return 0, false
+	}
+	lineStart := strings.LastIndexByte(text[:pos], '\n') + 1
+	match := eventPattern.FindStringSubmatchIndex(text[lineStart:pos])
+	if match == nil {
+		return 0, false
+	}
+	return lineStart + match[2], true
+}
+
+// eventCompletions returns the members of the events enum, replacing the name typed so far with the full path of the
+// member, such as `defines.events.on_tick`.
+func (s *Server) eventCompletions(file *ast.File, start token.Pos, pos token.Pos) []protocol.CompletionItem {
+	enum, ok := s.environmentOf(file.URI).Types[types.EventsEnum].(*types.Enum)
+	if !ok {
+		return nil
+	}
+	rng := file.ToProtocolRange(token.Range{Start: start, End: pos}, s.encoding)
+	chain := strings.Split(types.EventsEnum, ".")
+	kind := protocol.CompletionItemKindEnumMember
+	items := []protocol.CompletionItem{}
+	for _, member := range enum.Members {
+		items = append(items, protocol.CompletionItem{
+			Label:    member.Name,
+			Kind:     &kind,
+			Detail:   util.Ptr(types.EventsEnum),
+			TextEdit: protocol.TextEdit{Range: rng, NewText: types.EventsEnum + "." + member.Name},
+			Data:     completionData{URI: file.URI, Position: file.ToProtocolPos(pos, s.encoding), Name: member.Name, Chain: chain},
+		})
+	}
+	return items
+}
@@ -0,0 +1,186 @@
+package lsp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/lexer"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+)
+
+// stringFormatFix returns a fix that rewrites the chain of concatenations at the given position into a single
+// `string.format` call. String literals become the format string, and the other operands its arguments. It is only
+// offered for chains that mix string literals with other expressions, and whose parentheses and comments would
+// survive the rewrite.
+func stringFormatFix(file *ast.File, info *types.Info, text string, pos token.Pos) (ast.Fix, bool) {
+	nodePath := ast.GetSemanticNode(file.Block, pos)
+	nodes := append(nodePath.Parents, nodePath.Node)
+	var chain *ast.InfixExpression
+	for i := len(nodes) - 1; i >= 0; i-- {
+		infix, ok := nodes[i].(*ast.InfixExpression)
+		if ok && infix.Operator.Type() == token.CONCAT {
+			chain = infix
+		} else if chain != nil {
+			break
+		}
+	}
+	if chain == nil {
+		return ast.Fix{}, false
+	}
+	operands := concatOperands(chain)
+	quote := byte('"')
+	literals := 0
+	for _, operand := range operands {
+		if lit, ok := operand.(*ast.StringLiteral); ok {
+			if literals == 0 && lit.Token.Type == token.STRING {
+				quote = lit.Token.Literal[0]
+			}
+			literals++
+		}
+	}
+	if literals == 0 || literals == len(operands) {
+		return ast.Fix{}, false
+	}
+
+	// Operands are extended over the parentheses around them, and the whole chain must then consist of nothing but
+	// the operands, the operators between them, and balanced parentheses.
+	ranges := make([]token.Range, len(operands))
+	for i, operand := range operands {
+		ranges[i] = parenthesizedRange(text, ast.Range(operand))
+	}
+	rng := token.Range{Start: ranges[0].Start, End: ranges[len(ranges)-1].End}
+	tokens, _ := lexer.Run(text[rng.Start:rng.End])
+	depth, operand := 0, 0
+	for _, tok := range tokens {
+		start := rng.Start + tok.Pos
+		for operand < len(ranges) && start >= ranges[operand].End {
+			operand++
+		}
+		switch tok.Type {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			depth--
+		case token.CONCAT, token.WHITESPACE, token.EOF:
+		default:
+			if operand == len(ranges) || start < ranges[operand].Start {
+				return ast.Fix{}, false
+			}
+		}
+		if depth < 0 {
+			return ast.Fix{}, false
+		}
+	}
+	if depth != 0 {
+		return ast.Fix{}, false
+	}
+
+	var format strings.Builder
+	args := []string{}
+	for _, operand := range operands {
+		if lit, ok := operand.(*ast.StringLiteral); ok {
+			contents, ok := formatStringContents(lit, quote)
+			if !ok {
+				return ast.Fix{}, false
+			}
+			format.WriteString(contents)
+			continue
+		}
+		// Calls that return several values need not be truncated, since extra arguments are ignored.
+		args = append(args, text[operand.Pos():operand.End()])
+		format.WriteString(formatSpecifier(info, operand))
+	}
+	return ast.Fix{
+		Title: "Convert to string.format",
+		Edits: []ast.Edit{{
+			Range:   rng,
+			NewText: "string.format(" + string(quote) + format.String() + string(quote) + ", " + strings.Join(args, ", ") + ")",
+		}},
+	}, true
+}
+
+// concatOperands returns the operands of a chain of concatenations, in order.
+func concatOperands(expr ast.Expression) []ast.Expression {
+	if infix, ok := expr.(*ast.InfixExpression); ok && infix.Operator.Type() == token.CONCAT {
+		return append(concatOperands(infix.Left), concatOperands(infix.Right)...)
+	}
+	return []ast.Expression{expr}
+}
+
+// parenthesizedRange extends the range of an expression over the parentheses that directly surround it.
+func parenthesizedRange(text string, rng token.Range) token.Range {
+	for {
+		before := strings.TrimRight(text[:rng.Start], " \t\r\n")
+		after := strings.TrimLeft(text[rng.End:], " \t\r\n")
+		if !strings.HasSuffix(before, "(") || !strings.HasPrefix(after, ")") {
+			return rng
+		}
+		rng = token.Range{Start: len(before) - 1, End: len(text) - len(after) + 1}
+	}
+}
+
+// formatSpecifier returns the format specifier that converts the value of the expression to a string in the same
+// way as concatenation. Expressions whose inferred type only admits integers are formatted with `%d`, and everything
+// else with `%s`, since `%d` rejects numbers that are not integers, and `%g` rounds them.
+func formatSpecifier(info *types.Info, expr ast.Expression) string {
+	typ := types.Resolve(info.LiteralTypeOf(expr))
+	members := []types.Type{typ}
+	if union, ok := typ.(*types.Union); ok {
+		members = union.Types
+	}
+	for _, member := range members {
+		lit, ok := types.Resolve(member).(*types.Literal)
+		if !ok {
+			return "%s"
+		}
+		if _, ok := lit.Base.(*types.Number); !ok {
+			return "%s"
+		}
+		if _, err := strconv.ParseInt(lit.Value, 0, 64); err != nil {
+			return "%s"
+		}
+	}
+	return "%d"
+}
+
+// formatStringContents returns the contents of a string literal as they are written in a format string that is
+// quoted with the given quote. Escape sequences are decoded first, so that percent signs written as escapes are
+// escaped as well. It returns false if the literal contains an invalid escape sequence.
+func formatStringContents(lit *ast.StringLiteral, quote byte) (string, bool) {
+	value, ok := types.StringValue(lit)
+	if !ok {
+		return "", false
+	}
+	if lit.Token.Type == token.RAWSTRING {
+		// A line break directly after the opening bracket is not part of the string.
+		value = strings.TrimPrefix(strings.TrimPrefix(value, "\r"), "\n")
+	} else if value, ok = types.Unescape(value); !ok {
+		return "", false
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' || c == quote:
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c < ' ' || c == 0x7f:
+			// Other control characters are written as three digits, so that a digit that follows is not taken as
+			// part of the escape.
+			sb.WriteString(fmt.Sprintf("\\%03d", c))
+		case c == '%':
+			sb.WriteString("%%")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), true
+}
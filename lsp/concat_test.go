@@ -0,0 +1,64 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringFormatFix(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"string", `local name = "x"
+print("Hello " .. name .. "!")`, `local name = "x"
+print(string.format("Hello %s!", name))`},
+		{"integer", `local count = 3
+print("count: " .. count)`, `local count = 3
+print(string.format("count: %d", count))`},
+		{"float", `local ratio = 0.5
+print("ratio: " .. ratio)`, `local ratio = 0.5
+print(string.format("ratio: %s", ratio))`},
+		{"number", `local function len(t) return #t end
+print("len: " .. len({}))`, `local function len(t) return #t end
+print(string.format("len: %s", len({})))`},
+		{"percent", `local n = 1
+print("100% " .. n)`, `local n = 1
+print(string.format("100%% %d", n))`},
+		{"escaped percent", `local n = 1
+print("\37\x25 " .. n)`, `local n = 1
+print(string.format("%%%% %d", n))`},
+		{"escapes", `local s = ""
+print('a\tb\'\"\\\0' .. s)`, `local s = ""
+print(string.format('a\tb\'"\\\000%s', s))`},
+		{"raw string", `local s = ""
+print([[
+"%"]] .. s)`, `local s = ""
+print(string.format("\"%%\"%s", s))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			uri := "file:///main.lua"
+			s := openFile(t, uri, test.src)
+			file := s.getFile(uri)
+			require.NotNil(t, file)
+			text, _ := s.sourceOf(uri)
+			fix, ok := stringFormatFix(file, s.getInfo(uri), text, strings.Index(text, ".."))
+			require.True(t, ok)
+			require.Len(t, fix.Edits, 1)
+			edit := fix.Edits[0]
+			assert.Equal(t, test.want, text[:edit.Range.Start]+edit.NewText+text[edit.Range.End:])
+		})
+	}
+}
+
+func TestStringFormatFixInvalidEscape(t *testing.T) {
+	uri := "file:///main.lua"
+	s := openFile(t, uri, `local n = 1
+print("\q" .. n)`)
+	text, _ := s.sourceOf(uri)
+	_, ok := stringFormatFix(s.getFile(uri), s.getInfo(uri), text, strings.Index(text, ".."))
+	assert.False(t, ok)
+}
@@ -2,32 +2,443 @@ package lsp
 
 import (
 	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/raiguard/luapls/factorio"
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/lint"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
 
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 type Config struct {
-	Roots *[]string `json:"roots"`
+	Roots       *[]string `json:"roots"`
+	PackagePath *[]string `json:"packagePath"`
+	// Library contains additional files and directories, such as definition files, that every environment loads
+	// alongside the workspace.
+	Library *[]string `json:"library"`
+	// Ignore contains patterns of files and directories that are not loaded, such as vendored dependencies, build
+	// outputs and large generated files, in the syntax of `.gitignore` files. The `.gitignore` files of the workspace
+	// are respected as well.
+	Ignore *[]string `json:"ignore"`
+	// Globals contains the globals that are provided by the host application. It is either a list of names, or an
+	// object that maps names to their types in annotation syntax, such as `{"log": "fun(msg: string)"}`.
+	Globals *GlobalsConfig `json:"globals"`
+	// Mode selects how strictly code is checked: `"strict"` also requires parameters to be annotated, and `"off"`
+	// disables the checks for nil safety and undefined fields. Defaults to `"default"`.
+	Mode *string `json:"mode"`
+	// LuaVersion is the version of Lua that the code runs on: `"5.1"`, `"5.2"`, `"5.3"`, `"5.4"`, or `"luajit"`. Globals
+	// of the standard library that only other versions define are reported as undefined. Defaults to every version.
+	LuaVersion *string `json:"luaVersion"`
+	// Format contains the options of the formatter.
+	Format *FormatConfig `json:"format"`
+	// UnusedParameters controls whether parameters that are never read are reported. Defaults to true.
+	UnusedParameters *bool `json:"unusedParameters"`
+	// RemoveUnusedRequires controls whether organizing the requires of a file also removes the ones that are never
+	// used. Defaults to false.
+	RemoveUnusedRequires *bool `json:"removeUnusedRequires"`
+	// CodeLens controls whether the number of references is shown above functions and classes, and whether busted
+	// tests can be run from above them. Defaults to true.
+	CodeLens *bool `json:"codeLens"`
+	// TestCommand is the command that the code lenses of busted tests run. It is split into arguments at whitespace,
+	// which quotes may group, and run without a shell. `${file}` is replaced with the path of the file, and `${name}`
+	// with a filter that matches the test. Defaults to `busted --filter ${name} ${file}`. It is ignored in configuration
+	// files, which may come from the repository that is opened.
+	TestCommand *string `json:"testCommand"`
+	// Severity overrides the severity of diagnostics by rule, such as `"assign-type-mismatch": "error"`. Rules may be
+	// disabled with `"off"`.
+	Severity *map[string]string `json:"severity"`
+	// Environments contains settings for individual environments, such as `factorio-data`, by name. Environments
+	// that do not exist yet are created for the first workspace folder when they include files.
+	Environments *map[string]EnvironmentConfig `json:"environments"`
+	// PullDiagnostics controls whether the client requests diagnostics when it needs them, as introduced in LSP 3.17,
+	// instead of the server publishing them whenever files change. Defaults to false.
+	PullDiagnostics *bool `json:"pullDiagnostics"`
+	// ReloadOnSave controls whether files are read from disk again when they are saved, in case the editor changed
+	// them while saving, such as by formatting them. Defaults to false.
+	ReloadOnSave *bool `json:"reloadOnSave"`
+	// Concurrency is the maximum number of files that are processed at the same time while indexing the workspace.
+	// Zero, the default, uses one worker per CPU.
+	Concurrency *int `json:"concurrency"`
+	// FactorioAPI is the path to Factorio's `runtime-api.json`, which definitions are generated from.
+	FactorioAPI *string `json:"factorioApi"`
+	// FactorioPrototypeAPI is the path to Factorio's `prototype-api.json`. When set, the files that run during the
+	// data stage are checked in a separate environment with the prototype definitions.
+	FactorioPrototypeAPI *string `json:"factorioPrototypeApi"`
+	// FactorioPath is the path to a Factorio installation. It is used to find the base mods and API documentation
+	// when the workspace is a mod.
+	FactorioPath *string `json:"factorioPath"`
+}
+
+// EnvironmentConfig contains the settings that may differ between environments.
+type EnvironmentConfig struct {
+	// Include contains glob patterns, relative to the workspace folder, of the files that the environment checks,
+	// such as `["src/**", "scripts/*.lua"]`. Defaults to every file, or to the files of the data stage for
+	// `factorio-data`.
+	Include *[]string `json:"include"`
+	// Exclude contains glob patterns of files that the environment does not check even though they are included.
+	Exclude *[]string `json:"exclude"`
+	// Priority decides which environment checks a file that several environments include. The one with the highest
+	// priority does, and the default environment only checks the files that no other environment includes. Defaults
+	// to zero.
+	Priority *int `json:"priority"`
+	// LuaVersion is the version of Lua that the environment's files run on. It takes precedence over the global
+	// setting.
+	LuaVersion *string `json:"luaVersion"`
+	// Library contains additional files and directories, such as definition files, that the environment loads
+	// alongside the global library.
+	Library *[]string `json:"library"`
+	// Severity overrides the severity of diagnostics in the environment's files. It takes precedence over the global
+	// setting.
+	Severity *map[string]string `json:"severity"`
 }
 
+// FormatConfig contains the options of the formatter, which take precedence over those that the client sends with
+// each request, so that everyone who works on a project formats it the same way.
+type FormatConfig struct {
+	// IndentStyle is either `"tab"` or `"space"`.
+	IndentStyle *string `json:"indentStyle"`
+	// IndentSize is the number of spaces that are indented with when indenting with spaces.
+	IndentSize *int `json:"indentSize"`
+}
+
+// GlobalsConfig maps the names of globals that are provided by the host application to their types. Globals that
+// are listed without a type are mapped to an empty string.
+type GlobalsConfig map[string]string
+
+func (g *GlobalsConfig) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err == nil {
+		*g = GlobalsConfig{}
+		for _, name := range names {
+			(*g)[name] = ""
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*map[string]string)(g))
+}
+
+// configSection is the section of the client's settings that the configuration is read from.
+const configSection = "luapls"
+
 func (s *Server) didChangeConfiguration(ctx *glsp.Context, params *protocol.DidChangeConfigurationParams) error {
-	return s.updateConfig(params.Settings)
+	// Clients that support `workspace/configuration` may leave the settings out of the notification.
+	if s.pullConfig {
+		s.fetchConfig(ctx)
+		return nil
+	}
+	return s.applyConfig(ctx, params.Settings)
+}
+
+// fetchConfig requests the configuration from the client, and applies it once it is received.
+func (s *Server) fetchConfig(ctx *glsp.Context) {
+	params := protocol.ConfigurationParams{Items: []protocol.ConfigurationItem{{Section: util.Ptr(configSection)}}}
+	// Messages are handled one at a time, so the client's response can only be read after this handler returns.
+	go func() {
+		var result []any
+		ctx.Call(protocol.ServerWorkspaceConfiguration, params, &result)
+		if len(result) == 0 || result[0] == nil {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// Problems with the configuration are already reported to the user.
+		_ = s.applyConfig(ctx, result[0])
+	}()
+}
+
+// applyConfig updates the configuration and brings the workspace up to date with it. Settings that affect how files
+// are loaded or checked cause the workspace to be indexed again, and other settings only cause the diagnostics to
+// be published again.
+func (s *Server) applyConfig(ctx *glsp.Context, settings any) error {
+	old := s.config
+	if err := s.updateConfig(ctx, settings); err != nil {
+		s.idle(ctx)
+		return err
+	}
+	if !s.isInitialized {
+		return nil
+	}
+	if requiresReindex(old, s.config) {
+		s.reindex(ctx, nil)
+	} else {
+		s.publishAll(ctx)
+		s.idle(ctx)
+	}
+	if s.refreshCodeLenses {
+		go ctx.Call(protocol.ServerWorkspaceCodeLensRefresh, nil, new(any))
+	}
+	return nil
 }
 
-func (s *Server) updateConfig(settings any) error {
-	// Because GLSP gives it to us as `any`, we have to re-marshal it to JSON then unmarshal it again.
-	data, err := json.Marshal(settings)
+// requiresReindex returns whether the settings that files are loaded and checked with differ between two
+// configurations.
+func requiresReindex(old Config, new Config) bool {
+	return !reflect.DeepEqual(
+		[]any{old.PackagePath, old.Globals, old.Library, old.Ignore, old.FactorioAPI, old.FactorioPrototypeAPI, old.FactorioPath, environmentLayouts(old)},
+		[]any{new.PackagePath, new.Globals, new.Library, new.Ignore, new.FactorioAPI, new.FactorioPrototypeAPI, new.FactorioPath, environmentLayouts(new)},
+	)
+}
+
+// environmentLayouts returns the settings of each configured environment that decide which files it loads and
+// checks, by name.
+func environmentLayouts(config Config) map[string][]any {
+	layouts := map[string][]any{}
+	if config.Environments != nil {
+		for name, env := range *config.Environments {
+			layouts[name] = []any{env.Include, env.Exclude, env.Priority, env.Library}
+		}
+	}
+	return layouts
+}
+
+// updateConfig replaces the configuration with the given settings. Problems with the settings are reported to the
+// user, and replace those of the previous configuration.
+func (s *Server) updateConfig(ctx *glsp.Context, settings any) error {
+	s.configErrors = nil
+	s.clientSettings = settings
+	s.loadConfigFile(ctx)
+	// Because GLSP gives it to us as `any`, we have to re-marshal it to JSON then unmarshal it again. The client's
+	// settings take precedence over those of the configuration file.
+	data, err := json.Marshal(mergeSettings(s.fileSettings, settings))
 	if err != nil {
+		s.configError(ctx, "Invalid configuration: %s", err)
 		return err
 	}
 
 	var config Config
 	err = json.Unmarshal(data, &config)
 	if err != nil {
+		s.configError(ctx, "Invalid configuration: %s", err)
 		return err
 	}
 
+	if config.TestCommand != nil && s.remote {
+		s.log.Warningf("Ignoring testCommand, which is only accepted from clients that connect over stdio")
+		config.TestCommand = nil
+	}
+	old := s.config
 	s.config = config
+	if config.FactorioPrototypeAPI != nil {
+		s.dataEnvironment()
+	}
+	s.configureEnvironments()
+	// Settings that were removed are reset to their defaults, since the configuration may change at any time.
+	packagePath := types.DefaultPackagePath
+	if config.PackagePath != nil {
+		packagePath = *config.PackagePath
+	}
+	concurrency := 0
+	if config.Concurrency != nil && *config.Concurrency < 0 {
+		s.configError(ctx, "Invalid concurrency %d, expected a positive number of workers or 0 for one per CPU", *config.Concurrency)
+	} else if config.Concurrency != nil {
+		concurrency = *config.Concurrency
+	}
+	if config.LuaVersion != nil && !isLuaVersion(*config.LuaVersion) {
+		s.configError(ctx, "Unknown Lua version '%s', expected one of %s", *config.LuaVersion, strings.Join(luaVersions, ", "))
+	}
+	if config.Environments != nil {
+		for name, env := range *config.Environments {
+			if env.LuaVersion != nil && !isLuaVersion(*env.LuaVersion) {
+				s.configError(ctx, "Unknown Lua version '%s' for environment '%s', expected one of %s", *env.LuaVersion, name, strings.Join(luaVersions, ", "))
+			}
+		}
+	}
+	if config.Mode != nil && !slices.Contains([]lint.Mode{lint.ModeDefault, lint.ModeStrict, lint.ModeOff}, lint.Mode(*config.Mode)) {
+		s.configError(ctx, "Unknown mode '%s', expected default, strict or off", *config.Mode)
+	}
+	if config.Format != nil && config.Format.IndentStyle != nil && *config.Format.IndentStyle != "tab" && *config.Format.IndentStyle != "space" {
+		s.configError(ctx, "Unknown indent style '%s', expected tab or space", *config.Format.IndentStyle)
+	}
+	var ignore []string
+	if config.Ignore != nil {
+		ignore = *config.Ignore
+	}
+	var hostGlobals map[string]string
+	if config.Globals != nil {
+		for name, typ := range *config.Globals {
+			if _, diagnostics := annotation.ParseType(typ); typ != "" && len(diagnostics) > 0 {
+				s.log.Errorf("Invalid type for global '%s': %s", name, diagnostics[0].Message)
+			}
+		}
+		hostGlobals = *config.Globals
+	}
+	for _, env := range s.allEnvironments() {
+		env.PackagePath = packagePath
+		env.Concurrency = concurrency
+		env.HostGlobals = hostGlobals
+		env.Ignore = ignore
+		oldLibrary := old.library(env.Name)
+		env.Library = slices.DeleteFunc(env.Library, func(path string) bool { return slices.Contains(oldLibrary, path) })
+		for _, path := range config.library(env.Name) {
+			s.addLibrary(env, path)
+		}
+	}
+	if config.FactorioAPI != nil {
+		s.importDefinitions(ctx, s.environment, *config.FactorioAPI)
+	}
+	if config.FactorioPrototypeAPI != nil {
+		s.importDefinitions(ctx, s.dataEnvironment(), *config.FactorioPrototypeAPI)
+	}
+	return nil
+}
+
+// dataEnvironment returns the environment for the Factorio data stage, creating it if it does not exist.
+func (s *Server) dataEnvironment() *types.Environment {
+	if env := s.findEnvironment(factorioDataEnvironment); env != nil {
+		return env
+	}
+	env := types.NewEnvironment()
+	env.Name = factorioDataEnvironment
+	env.RootPath = s.environment.RootPath
+	env.PackagePath = s.environment.PackagePath
+	env.HostGlobals = s.environment.HostGlobals
+	env.Ignore = s.environment.Ignore
+	env.Concurrency = s.environment.Concurrency
+	s.environments = append(s.environments, env)
+	s.configureEnvironment(env)
+	return env
+}
+
+// configureEnvironments creates the environments that the configuration names and that include files, discards the
+// ones it created that are no longer named, and applies the settings that decide which files each environment checks.
+// Created environments check the first workspace folder.
+func (s *Server) configureEnvironments() {
+	configs := map[string]EnvironmentConfig{}
+	if s.config.Environments != nil {
+		configs = *s.config.Environments
+	}
+	s.environments = slices.DeleteFunc(s.environments, func(env *types.Environment) bool {
+		if _, ok := configs[env.Name]; ok || !s.configured[env] {
+			return false
+		}
+		delete(s.configured, env)
+		return true
+	})
+	names := []string{}
+	for name, config := range configs {
+		if config.Include != nil && s.findEnvironment(name) == nil {
+			names = append(names, name)
+		}
+	}
+	// Environments with the same priority are chosen in the order that they were created.
+	slices.Sort(names)
+	for _, name := range names {
+		env := s.newEnvironment(s.rootPath)
+		env.Name = name
+		s.environments = append(s.environments, env)
+		s.configured[env] = true
+	}
+	for _, env := range s.environments {
+		s.configureEnvironment(env)
+	}
+}
+
+// configureEnvironment applies the configured settings that decide which files the environment checks. Settings that
+// are not configured are reset to their defaults.
+func (s *Server) configureEnvironment(env *types.Environment) {
+	config := s.environmentConfig(env)
+	env.Include = nil
+	if env.Name == factorioDataEnvironment {
+		env.Include = factorio.DataFiles
+	}
+	if config.Include != nil {
+		env.Include = *config.Include
+	}
+	env.Exclude = nil
+	if config.Exclude != nil {
+		env.Exclude = *config.Exclude
+	}
+	env.Priority = 0
+	if config.Priority != nil {
+		env.Priority = *config.Priority
+	}
+}
+
+// environmentConfig returns the settings of the environment, which are all unset if it is not configured.
+func (s *Server) environmentConfig(env *types.Environment) EnvironmentConfig {
+	if s.config.Environments == nil {
+		return EnvironmentConfig{}
+	}
+	return (*s.config.Environments)[env.Name]
+}
+
+// library returns the files and directories that the named environment loads alongside its root directory, which
+// are those of the global setting followed by its own.
+func (c Config) library(name string) []string {
+	library := []string{}
+	if c.Library != nil {
+		library = append(library, *c.Library...)
+	}
+	if c.Environments != nil {
+		if env, ok := (*c.Environments)[name]; ok && env.Library != nil {
+			library = append(library, *env.Library...)
+		}
+	}
+	return library
+}
+
+// detectMod configures the environments for the Factorio mod in the root directory, if there is one. Definitions
+// that were configured explicitly take precedence over the detected ones.
+func (s *Server) detectMod(root string) {
+	if !util.FileExists(filepath.Join(root, "info.json")) {
+		return
+	}
+	factorioPath := ""
+	if s.config.FactorioPath != nil {
+		factorioPath = *s.config.FactorioPath
+	}
+	setup, err := factorio.Detect(root, factorioPath)
+	if err != nil {
+		s.log.Errorf("Failed to read mod info: %s", err)
+		return
+	}
+	s.log.Infof("Detected Factorio %s mod '%s' with %d dependencies", setup.Mod.FactorioVersion, setup.Mod.Name, len(setup.Library))
+	if setup.RuntimeDefinitions != "" && s.config.FactorioAPI == nil {
+		s.addLibrary(s.environment, setup.RuntimeDefinitions)
+	}
+	if setup.PrototypeDefinitions != "" && s.config.FactorioPrototypeAPI == nil {
+		s.addLibrary(s.dataEnvironment(), setup.PrototypeDefinitions)
+	}
+	for _, dir := range setup.Library {
+		for _, env := range s.allEnvironments() {
+			s.addLibrary(env, dir)
+		}
+	}
+}
+
+// addLibrary adds a file or directory to the environment's library if it is not already there.
+func (s *Server) addLibrary(env *types.Environment, path string) {
+	if !slices.Contains(env.Library, path) {
+		env.Library = append(env.Library, path)
+	}
+}
+
+// factorioDataEnvironment is the name of the environment for files that run during the Factorio data stage.
+const factorioDataEnvironment = "factorio-data"
+
+// findEnvironment returns the additional environment with the given name, or nil if there is none.
+func (s *Server) findEnvironment(name string) *types.Environment {
+	for _, env := range s.environments {
+		if env.Name == name {
+			return env
+		}
+	}
 	return nil
 }
+
+// importDefinitions generates definitions from a Factorio API file and adds them to the environment's library.
+func (s *Server) importDefinitions(ctx *glsp.Context, env *types.Environment, api string) {
+	path, err := factorio.Import(api)
+	if err != nil {
+		s.configError(ctx, "Failed to import Factorio API definitions: %s", err)
+		return
+	}
+	s.addLibrary(env, path)
+}
@@ -0,0 +1,43 @@
+package lsp
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateConfigErrors(t *testing.T) {
+	tests := []struct {
+		settings map[string]any
+		want     []string
+	}{
+		{map[string]any{"mode": "strict", "luaVersion": "5.4", "format": map[string]any{"indentStyle": "tab"}}, nil},
+		{map[string]any{"mode": "lenient"}, []string{"Unknown mode 'lenient', expected default, strict or off"}},
+		{map[string]any{"luaVersion": "6.0"}, []string{"Unknown Lua version '6.0', expected one of 5.1, 5.2, 5.3, 5.4, luajit"}},
+		{map[string]any{"format": map[string]any{"indentStyle": "both"}}, []string{"Unknown indent style 'both', expected tab or space"}},
+		{map[string]any{"concurrency": -1}, []string{"Invalid concurrency -1, expected a positive number of workers or 0 for one per CPU"}},
+	}
+	for _, test := range tests {
+		s := newServer(0)
+		notifications := []notification{}
+		_ = s.updateConfig(testContext(t, nil, &notifications), test.settings)
+		assert.Equal(t, test.want, s.configErrors, test.settings)
+		// Every problem is also shown to the user.
+		assert.Len(t, notifications, len(test.want))
+		for _, n := range notifications {
+			assert.Equal(t, protocol.ServerWindowShowMessage, n.method)
+		}
+	}
+}
+
+func TestNegativeConcurrency(t *testing.T) {
+	s := newServer(0)
+	require.NoError(t, s.updateConfig(testContext(t, nil, nil), map[string]any{"concurrency": 4}))
+	assert.Equal(t, 4, s.environment.Concurrency)
+	// A rejected value falls back to one worker per CPU, rather than keeping the previous value.
+	require.NoError(t, s.updateConfig(testContext(t, nil, nil), map[string]any{"concurrency": -1}))
+	assert.Equal(t, 0, s.environment.Concurrency)
+}
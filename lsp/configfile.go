@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// configFileNames are the names of the files at the root of the workspace that settings are read from. Only the first
+// one that exists is read. They contain the same settings as the client's `luapls` section.
+var configFileNames = []string{".luapls.toml", ".luapls.json"}
+
+// untrustedSettings are the settings that configuration files may not contain, because the files are often committed to
+// the repository that is opened, and the settings run commands.
+var untrustedSettings = []string{"testCommand"}
+
+// readConfigFile returns the settings in the configuration file at the root of the workspace, along with the path of
+// the file. The settings are nil if there is no configuration file.
+func readConfigFile(root string) (map[string]any, string, error) {
+	for _, name := range configFileNames {
+		path := filepath.Join(root, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, path, err
+		}
+		settings := map[string]any{}
+		if filepath.Ext(path) == ".toml" {
+			err = toml.Unmarshal(data, &settings)
+		} else {
+			err = json.Unmarshal(data, &settings)
+		}
+		if err != nil {
+			return nil, path, err
+		}
+		return settings, path, nil
+	}
+	return nil, "", nil
+}
+
+// loadConfigFile reads the configuration files at the root of the workspace into the settings that the client's
+// settings are merged with. The settings of a `.luarc.json` file are overridden by those of luapls' own configuration
+// file. Problems with a file are reported to the user, and the file is then ignored. Untrusted settings are dropped.
+func (s *Server) loadConfigFile(ctx *glsp.Context) {
+	s.fileSettings = nil
+	if s.rootPath == "" {
+		return
+	}
+	for _, read := range []func(string) (map[string]any, string, error){readLuarc, readConfigFile} {
+		settings, path, err := read(s.rootPath)
+		if err != nil {
+			s.configError(ctx, "Invalid configuration file %s: %s", path, err)
+			continue
+		}
+		if settings == nil {
+			continue
+		}
+		s.log.Infof("Read configuration from %s", path)
+		for _, key := range untrustedSettings {
+			if _, ok := settings[key]; ok {
+				s.log.Warningf("Ignoring %s in %s, which is only accepted from the client's settings", key, path)
+				delete(settings, key)
+			}
+		}
+		if merged, ok := mergeSettings(s.fileSettings, settings).(map[string]any); ok {
+			s.fileSettings = merged
+		}
+	}
+}
+
+// isConfigFile returns whether the URI is that of a configuration file at the root of the workspace, including a
+// `.luarc.json` file.
+func (s *Server) isConfigFile(uri protocol.URI) bool {
+	path, err := util.URIToPath(uri)
+	if err != nil || s.rootPath == "" {
+		return false
+	}
+	for _, name := range append(configFileNames, luarcFileName) {
+		if path == filepath.Join(s.rootPath, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// configFileChanged applies the configuration file again along with the client's settings.
+func (s *Server) configFileChanged(ctx *glsp.Context) {
+	// Problems with the configuration are already reported to the user.
+	_ = s.applyConfig(ctx, s.clientSettings)
+}
+
+// mergeSettings returns the settings of base, overridden by those of overlay. Objects are merged key by key, so that
+// the client may override a single severity of the configuration file without replacing the others.
+func mergeSettings(base any, overlay any) any {
+	if overlay == nil {
+		return base
+	}
+	baseMap, ok := base.(map[string]any)
+	overlayMap, isMap := overlay.(map[string]any)
+	if !ok || !isMap {
+		return overlay
+	}
+	merged := map[string]any{}
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+	for key, value := range overlayMap {
+		merged[key] = mergeSettings(merged[key], value)
+	}
+	return merged
+}
+
+// configFileWatchers are the patterns of the configuration files that the client is asked to watch.
+func configFileWatchers() []protocol.FileSystemWatcher {
+	watchers := []protocol.FileSystemWatcher{}
+	for _, name := range append(configFileNames, luarcFileName) {
+		watchers = append(watchers, protocol.FileSystemWatcher{GlobPattern: fmt.Sprintf("**/%s", name)})
+	}
+	return watchers
+}
+
+// luaVersions are the versions of Lua that may be configured, by the name they are configured with.
+var luaVersions = []string{"5.1", "5.2", "5.3", "5.4", "luajit"}
+
+// isLuaVersion returns whether the name is that of a version of Lua that may be configured, ignoring case.
+func isLuaVersion(name string) bool {
+	for _, version := range luaVersions {
+		if strings.EqualFold(name, version) {
+			return true
+		}
+	}
+	return false
+}
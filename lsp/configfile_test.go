@@ -0,0 +1,75 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLuarc(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		want   map[string]any
+		errors int
+	}{
+		{
+			"keys",
+			map[string]string{".luarc.json": `{"runtime.version": "Lua 5.4", "workspace": {"ignoreDir": ["build"]}, "diagnostics": {"globals": ["love"], "disable": ["undefined-global"]}}`},
+			map[string]any{
+				"luaVersion": "5.4",
+				"ignore":     []any{"build"},
+				"globals":    map[string]any{"love": ""},
+				"severity":   map[string]any{"undefined-global": "off"},
+			},
+			0,
+		},
+		{
+			"comments and trailing commas",
+			map[string]string{".luarc.json": "// lua-language-server\n{\n  /* the runtime */ \"runtime.version\": \"LuaJIT\", // trailing\n  \"workspace.ignoreDir\": [\"a//b\", \"c/*d*/\",],\n}\n"},
+			map[string]any{"luaVersion": "luajit", "ignore": []any{"a//b", "c/*d*/"}},
+			0,
+		},
+		{
+			"precedence",
+			map[string]string{
+				".luarc.json":  `{"runtime.version": "Lua 5.1", "diagnostics.globals": ["love"]}`,
+				".luapls.toml": "luaVersion = \"5.4\"\n",
+			},
+			map[string]any{"luaVersion": "5.4", "globals": map[string]any{"love": ""}},
+			0,
+		},
+		{
+			"malformed",
+			map[string]string{".luarc.json": `{"runtime.version": }`},
+			nil,
+			1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, _ := writeFolder(t, test.files)
+			s := newServer(0)
+			s.rootPath = root
+			s.loadConfigFile(testContext(t, nil, nil))
+			assert.Equal(t, test.want, s.fileSettings)
+			assert.Len(t, s.configErrors, test.errors)
+		})
+	}
+}
+
+func TestConfigFileTestCommand(t *testing.T) {
+	root, _ := writeFolder(t, map[string]string{".luapls.toml": "mode = \"strict\"\ntestCommand = \"rm -rf ~\"\n"})
+	s := newServer(0)
+	s.rootPath = root
+	require.NoError(t, s.updateConfig(testContext(t, nil, nil), nil))
+	require.NotNil(t, s.config.Mode)
+	assert.Equal(t, "strict", *s.config.Mode)
+	assert.Nil(t, s.config.TestCommand)
+
+	// The client's settings may still set it.
+	require.NoError(t, s.updateConfig(testContext(t, nil, nil), map[string]any{"testCommand": "busted ${file}"}))
+	require.NotNil(t, s.config.TestCommand)
+	assert.Equal(t, "busted ${file}", *s.config.TestCommand)
+}
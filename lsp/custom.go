@@ -0,0 +1,44 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/tliron/glsp"
+)
+
+// customMethodFunc handles a luapls-specific request or notification. validParams should be false if the params
+// failed to unmarshal.
+type customMethodFunc func(ctx *glsp.Context) (r any, validParams bool, err error)
+
+// customMethod adapts a typed handler function into a customMethodFunc.
+func customMethod[P any](fn func(ctx *glsp.Context, params *P) (any, error)) customMethodFunc {
+	return func(ctx *glsp.Context) (any, bool, error) {
+		var params P
+		if len(ctx.Params) > 0 {
+			if err := json.Unmarshal(ctx.Params, &params); err != nil {
+				return nil, false, err
+			}
+		}
+		r, err := fn(ctx, &params)
+		return r, true, err
+	}
+}
+
+// Handle implements glsp.Handler. It dispatches luapls-specific methods and defers everything else to the protocol
+// handler.
+func (s *Server) Handle(ctx *glsp.Context) (r any, validMethod bool, validParams bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.logTrace(ctx, time.Now())
+	fn, ok := s.customMethods[ctx.Method]
+	if !ok {
+		return s.handler.Handle(ctx)
+	}
+	if !s.handler.IsInitialized() {
+		return nil, true, true, errors.New("server not initialized")
+	}
+	r, validParams, err = fn(ctx)
+	return r, true, validParams, err
+}
@@ -3,6 +3,9 @@ package lsp
 import (
 	"errors"
 
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -16,16 +19,146 @@ func (s *Server) textDocumentDefinition(ctx *glsp.Context, params *protocol.Defi
 		return nil, errors.New("Attempted to goto definition on a file with no AST")
 	}
 
-	// TODO:
-	// pos := file.LineBreaks.ToPos(params.Position)
-	// nodePath := ast.GetNode(file.AST, pos)
-	// def := file.Env.FindDefinition(nodePath)
-	// if def == nil {
-	// 	return nil, nil
-	// }
-	// return &protocol.Location{
-	// 	URI:   params.TextDocument.URI,
-	// 	Range: file.File.ToProtocolRange(ast.Range(def)),
-	// }, nil
-	return nil, nil
+	info := s.getInfo(file.URI)
+	env := s.environmentOf(file.URI)
+	pos := file.ToPos(params.Position, s.encoding)
+	if req := env.Modules.RequireAt(file.URI, pos); req != nil && req.Range.ContainsPos(pos) {
+		if req.Target == "" {
+			return nil, nil
+		}
+		return &protocol.Location{URI: req.Target}, nil
+	}
+	if location := s.moduleFieldDefinition(file, info, pos); location != nil {
+		return location, nil
+	}
+	if path, ok := s.globalPathAt(file, info, params.Position); ok {
+		locations := []protocol.Location{}
+		for _, site := range env.Globals.Defs(path) {
+			if location := s.siteLocation(site); location != nil {
+				locations = append(locations, *location)
+			}
+		}
+		if len(locations) > 0 {
+			return locations, nil
+		}
+	}
+	if location := s.fieldDefinition(file, info, pos); location != nil {
+		return location, nil
+	}
+
+	_, sym := s.identAt(file, info, params.Position)
+	if sym == nil || sym.Decl == nil {
+		return nil, nil
+	}
+	// A local that is declared without a value is defined by the assignments that give it one.
+	if _, ok := sym.Node.(*ast.LocalStatement); ok && sym.Initializer() == nil {
+		locations := []protocol.Location{}
+		for _, ref := range sym.Refs {
+			if ref.Write {
+				locations = append(locations, protocol.Location{
+					URI:   params.TextDocument.URI,
+					Range: file.ToProtocolRange(ast.Range(ref.Ident), s.encoding),
+				})
+			}
+		}
+		if len(locations) > 0 {
+			return locations, nil
+		}
+	}
+	return &protocol.Location{
+		URI:   params.TextDocument.URI,
+		Range: file.ToProtocolRange(ast.Range(sym.Decl), s.encoding),
+	}, nil
+}
+
+// textDocumentDeclaration returns the `local` statement or parameter that declares a local, which may differ from its
+// definition if it is assigned later. Globals, fields, and modules are declared where they are defined.
+func (s *Server) textDocumentDeclaration(ctx *glsp.Context, params *protocol.DeclarationParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, nil
+	}
+	if file.Block == nil {
+		return nil, errors.New("Attempted to goto declaration on a file with no AST")
+	}
+	_, sym := s.identAt(file, s.getInfo(file.URI), params.Position)
+	if sym == nil || sym.Kind == types.SymbolGlobal || sym.Decl == nil {
+		return s.textDocumentDefinition(ctx, &protocol.DefinitionParams{TextDocumentPositionParams: params.TextDocumentPositionParams})
+	}
+	return &protocol.Location{
+		URI:   params.TextDocument.URI,
+		Range: file.ToProtocolRange(ast.Range(sym.Decl), s.encoding),
+	}, nil
+}
+
+// globalPathAt returns the dotted global path of the identifier at the given position, if it is a global or a field
+// of a global.
+func (s *Server) globalPathAt(file *ast.File, info *types.Info, position protocol.Position) (string, bool) {
+	nodePath := ast.GetSemanticNode(file.Block, file.ToPos(position, s.encoding))
+	ident, ok := nodePath.Node.(*ast.Identifier)
+	if !ok {
+		return "", false
+	}
+	if len(nodePath.Parents) > 0 {
+		if ie, ok := nodePath.Parents[len(nodePath.Parents)-1].(*ast.IndexExpression); ok && ie.Inner == ast.Expression(ident) {
+			return types.GlobalPath(ie, info)
+		}
+	}
+	return types.GlobalPath(ident, info)
+}
+
+// moduleFieldDefinition returns the definition of a field accessed on a local that holds a required module, such as
+// `foo` in `local mod = require("mod"); mod.foo()`.
+func (s *Server) moduleFieldDefinition(file *ast.File, info *types.Info, pos token.Pos) *protocol.Location {
+	nodePath := ast.GetSemanticNode(file.Block, pos)
+	ident, ok := nodePath.Node.(*ast.Identifier)
+	if !ok || len(nodePath.Parents) == 0 {
+		return nil
+	}
+	ie, ok := nodePath.Parents[len(nodePath.Parents)-1].(*ast.IndexExpression)
+	if !ok || ie.Inner != ast.Expression(ident) {
+		return nil
+	}
+	prefix, ok := ie.Prefix.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	sym := info.SymbolOf(prefix)
+	if sym == nil || sym.Kind == types.SymbolGlobal {
+		return nil
+	}
+	env := s.environmentOf(file.URI)
+	req := env.Modules.RequireOf(file.URI, sym.Initializer())
+	if req == nil || req.Target == "" {
+		return nil
+	}
+	key, ok := types.FieldKey(ie)
+	if !ok {
+		return nil
+	}
+	node := env.ModuleField(req.Target, key)
+	target := env.Files[req.Target]
+	if node == nil || target == nil {
+		return nil
+	}
+	return &protocol.Location{URI: req.Target, Range: target.ToProtocolRange(ast.Range(node), s.encoding)}
+}
+
+// fieldDefinition returns the definition of a field of a class or table, including fields that are inherited from a
+// parent class or a metatable's `__index`.
+func (s *Server) fieldDefinition(file *ast.File, info *types.Info, pos token.Pos) *protocol.Location {
+	_, field := types.FieldAt(info, ast.GetSemanticNode(file.Block, pos))
+	if field == nil || field.Loc.URI == "" {
+		return nil
+	}
+	return s.typesLocation(field.Loc)
+}
+
+// siteLocation converts a global site into a protocol location.
+func (s *Server) siteLocation(site types.GlobalSite) *protocol.Location {
+	file := s.getFile(site.URI)
+	if file == nil {
+		return nil
+	}
+	return &protocol.Location{URI: site.URI, Range: file.ToProtocolRange(site.Range, s.encoding)}
 }
@@ -1,23 +1,119 @@
 package lsp
 
 import (
+	"slices"
+
 	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/lint"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
 
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 func (s *Server) publishDiagnostics(ctx *glsp.Context, file *ast.File) {
+	if s.pullDiagnostics() {
+		return
+	}
+	env := s.environmentOf(file.URI)
 	diagnostics := []protocol.Diagnostic{}
-	for _, err := range file.Diagnostics {
-		diagnostics = append(diagnostics, protocol.Diagnostic{
-			Range:    file.LineBreaks.ToProtocolRange(err.Range),
-			Severity: &err.Severity,
-			Message:  err.Message,
-		})
+	for _, err := range s.fileDiagnostics(env, file) {
+		diagnostics = append(diagnostics, s.toProtocolDiagnostic(file, err))
 	}
 	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
 		URI:         file.URI,
 		Diagnostics: diagnostics,
 	})
 }
+
+// fileDiagnostics returns the type checking and lint diagnostics of the file, with their configured severities. Those
+// that are disabled by the configuration or by `---@diagnostic` annotations are left out.
+func (s *Server) fileDiagnostics(env *types.Environment, file *ast.File) []ast.Diagnostic {
+	diagnostics := append(env.Diagnostics(file.URI), lint.Check(env, file, s.lintOptions(env))...)
+	return lint.ApplySeverities(lint.Suppress(file, diagnostics), s.severityOverrides(env))
+}
+
+// toProtocolDiagnostic converts a diagnostic in the given file to its protocol representation.
+func (s *Server) toProtocolDiagnostic(file *ast.File, err ast.Diagnostic) protocol.Diagnostic {
+	severity := err.Severity
+	if severity == 0 {
+		severity = protocol.DiagnosticSeverityError
+	}
+	diagnostic := protocol.Diagnostic{
+		Range:    file.ToProtocolRange(err.Range, s.encoding),
+		Severity: &severity,
+		Source:   util.Ptr(LS_NAME),
+		Message:  err.Message,
+	}
+	// Tags that the client does not support are left out, so that it renders the diagnostic as usual.
+	for _, tag := range err.Tags {
+		if slices.Contains(s.diagnosticTags, tag) {
+			diagnostic.Tags = append(diagnostic.Tags, tag)
+		}
+	}
+	if err.Code != "" {
+		diagnostic.Code = &protocol.IntegerOrString{Value: err.Code}
+	}
+	for _, related := range err.Related {
+		target := file
+		if related.URI != "" {
+			target = s.getFile(related.URI)
+		}
+		if target == nil {
+			continue
+		}
+		diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, protocol.DiagnosticRelatedInformation{
+			Location: protocol.Location{URI: target.URI, Range: target.ToProtocolRange(related.Range, s.encoding)},
+			Message:  related.Message,
+		})
+	}
+	return diagnostic
+}
+
+// lintOptions returns the lint options of the environment's files from the configuration.
+func (s *Server) lintOptions(env *types.Environment) lint.Options {
+	opts := lint.Options{}
+	if s.config.Mode != nil {
+		opts.Mode = lint.Mode(*s.config.Mode)
+	}
+	if s.config.UnusedParameters != nil {
+		opts.IgnoreUnusedParameters = !*s.config.UnusedParameters
+	}
+	if s.config.LuaVersion != nil {
+		opts.LuaVersion = *s.config.LuaVersion
+	}
+	if config := s.environmentConfig(env); config.LuaVersion != nil {
+		opts.LuaVersion = *config.LuaVersion
+	}
+	return opts
+}
+
+// severityOverrides returns the configured severities of diagnostics in the environment, by rule.
+func (s *Server) severityOverrides(env *types.Environment) map[string]protocol.DiagnosticSeverity {
+	overrides := map[string]protocol.DiagnosticSeverity{}
+	add := func(config map[string]string) {
+		for code, name := range config {
+			if severity, ok := severities[name]; ok {
+				overrides[code] = severity
+			}
+		}
+	}
+	if s.config.Severity != nil {
+		add(*s.config.Severity)
+	}
+	if config := s.environmentConfig(env); config.Severity != nil {
+		add(*config.Severity)
+	}
+	return overrides
+}
+
+// severities maps the severity names accepted in the configuration to their protocol values.
+var severities = map[string]protocol.DiagnosticSeverity{
+	"error":       protocol.DiagnosticSeverityError,
+	"warning":     protocol.DiagnosticSeverityWarning,
+	"info":        protocol.DiagnosticSeverityInformation,
+	"information": protocol.DiagnosticSeverityInformation,
+	"hint":        protocol.DiagnosticSeverityHint,
+	"off":         0,
+}
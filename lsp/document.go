@@ -2,47 +2,196 @@ package lsp
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/raiguard/luapls/lua/parser"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/util"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
-// TODO: Incremental changes
 func (s *Server) textDocumentDidOpen(ctx *glsp.Context, params *protocol.DidOpenTextDocumentParams) error {
-	file := s.getFile(params.TextDocument.URI)
-	if file == nil {
-		file = s.environment.AddTransientFile(params.TextDocument.URI, params.TextDocument.Text)
+	uri, text := util.NormalizeURI(params.TextDocument.URI), params.TextDocument.Text
+	file := s.getFile(uri)
+	if file != nil {
+		// The editor's contents take precedence over what was loaded from disk.
+		s.setContent(ctx, uri, text)
+		return nil
+	}
+	// Every environment receives the file so that it can be required from any of them.
+	s.transient[uri] = true
+	s.contents[uri] = text
+	for _, env := range s.allEnvironments() {
+		if env.AddTransientFile(uri, text) == nil {
+			continue
+		}
+		for _, checked := range env.Recheck(uri) {
+			if s.environmentOf(checked) == env && (checked == uri || env.Owns(checked)) {
+				s.publishDiagnostics(ctx, env.Files[checked])
+			}
+		}
 	}
-	if file == nil {
+	if s.getFile(uri) == nil {
 		return errors.New("Error creating file")
 	}
-	s.publishDiagnostics(ctx, file)
 	return nil
 }
 
 func (s *Server) textDocumentDidChange(ctx *glsp.Context, params *protocol.DidChangeTextDocumentParams) error {
-	file := s.getFile(params.TextDocument.URI)
-	if file == nil {
+	uri := util.NormalizeURI(params.TextDocument.URI)
+	if s.getFile(uri) == nil {
 		return nil
 	}
+	text, ok := s.contents[uri]
 	for _, change := range params.ContentChanges {
-		if change, ok := change.(protocol.TextDocumentContentChangeEventWhole); ok {
-			before := time.Now()
-			newFile := parser.New(change.Text).ParseFile()
-			s.log.Debugf("Reparse duration: %s", time.Since(before).String())
-			file.Block = newFile.Block
-			file.LineBreaks = newFile.LineBreaks
-			file.Diagnostics = newFile.Diagnostics
-			s.environment.CheckFilePhase1(file)
-			s.publishDiagnostics(ctx, file)
+		switch change := change.(type) {
+		case protocol.TextDocumentContentChangeEventWhole:
+			text, ok = change.Text, true
+		case protocol.TextDocumentContentChangeEvent:
+			if !ok {
+				return fmt.Errorf("Received a change to %s before its contents", uri)
+			}
+			text = applyChange(text, *change.Range, change.Text, s.encoding)
 		}
 	}
+	s.setContent(ctx, uri, text)
 	return nil
 }
 
+// applyChange replaces the given range of the text with newText, counting the characters of the range in the given
+// encoding. Positions past the end of a line or of the text are clamped to it.
+func applyChange(text string, rng protocol.Range, newText string, encoding token.PositionEncoding) string {
+	start, end := offsetOf(text, rng.Start, encoding), offsetOf(text, rng.End, encoding)
+	if end < start {
+		end = start
+	}
+	return text[:start] + newText + text[end:]
+}
+
+// offsetOf returns the byte offset of the given position in the text.
+func offsetOf(text string, position protocol.Position, encoding token.PositionEncoding) int {
+	offset := 0
+	for line := uint32(0); line < position.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+	lineEnd := strings.IndexByte(text[offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(text) - offset
+	}
+	return offset + token.ColumnOffset(text[offset:offset+lineEnd], int(position.Character), encoding)
+}
+
 func (s *Server) textDocumentDidClose(ctx *glsp.Context, params *protocol.DidCloseTextDocumentParams) error {
-	// TODO: Remove file ASTs from memory
+	uri := util.NormalizeURI(params.TextDocument.URI)
+	if s.getFile(uri) == nil {
+		return nil
+	}
+	delete(s.semanticResults, uri)
+	if !s.transient[uri] {
+		// Files that exist on disk remain part of the workspace, so any unsaved changes are discarded.
+		err := s.reload(ctx, uri)
+		delete(s.contents, uri)
+		return err
+	}
+	delete(s.transient, uri)
+	delete(s.contents, uri)
+	for _, env := range s.allEnvironments() {
+		for _, dependent := range env.RemoveFile(uri) {
+			if s.environmentOf(dependent) == env && env.Owns(dependent) {
+				s.publishDiagnostics(ctx, env.Files[dependent])
+			}
+		}
+	}
+	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: []protocol.Diagnostic{},
+	})
+	return nil
+}
+
+func (s *Server) textDocumentDidSave(ctx *glsp.Context, params *protocol.DidSaveTextDocumentParams) error {
+	uri := util.NormalizeURI(params.TextDocument.URI)
+	if s.getFile(uri) == nil {
+		return nil
+	}
+	if s.config.ReloadOnSave == nil || !*s.config.ReloadOnSave {
+		return nil
+	}
+	path, err := util.URIToPath(uri)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Only the files that depend on the saved file are checked again, and only if the editor changed it while
+	// saving it.
+	if text, ok := s.contents[uri]; !ok || text != string(src) {
+		s.setContent(ctx, uri, string(src))
+	}
 	return nil
 }
+
+// setContent replaces the contents of a file in every environment that has it, then rechecks and publishes the
+// diagnostics of the files that were affected.
+func (s *Server) setContent(ctx *glsp.Context, uri protocol.URI, text string) {
+	s.contents[uri] = text
+	before := time.Now()
+	newFile := parser.New(text).ParseFile()
+	s.log.Debugf("Reparse duration: %s", time.Since(before).String())
+	for _, env := range s.allEnvironments() {
+		file := env.Files[uri]
+		if file == nil {
+			continue
+		}
+		file.Block = newFile.Block
+		file.Comments = newFile.Comments
+		file.LineBreaks = newFile.LineBreaks
+		file.Text = newFile.Text
+		file.Diagnostics = newFile.Diagnostics
+		for _, checked := range env.Recheck(uri) {
+			if s.environmentOf(checked) == env && (checked == uri || env.Owns(checked)) {
+				s.publishDiagnostics(ctx, env.Files[checked])
+			}
+		}
+	}
+}
+
+// reload replaces the contents of a file with what is on disk.
+func (s *Server) reload(ctx *glsp.Context, uri protocol.URI) error {
+	path, err := util.URIToPath(uri)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	s.setContent(ctx, uri, string(src))
+	return nil
+}
+
+// sourceOf returns the text of a file: the editor's contents if it is open, or what is on disk otherwise.
+func (s *Server) sourceOf(uri protocol.URI) (string, error) {
+	if text, ok := s.contents[util.NormalizeURI(uri)]; ok {
+		return text, nil
+	}
+	path, err := util.URIToPath(uri)
+	if err != nil {
+		return "", err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(src), nil
+}
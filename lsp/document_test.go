@@ -0,0 +1,109 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/util"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rangeOf(startLine, startChar, endLine, endChar uint32) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: startLine, Character: startChar},
+		End:   protocol.Position{Line: endLine, Character: endChar},
+	}
+}
+
+func TestApplyChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		rng      protocol.Range
+		newText  string
+		encoding token.PositionEncoding
+		want     string
+	}{
+		{"insert", "local x = 1\n", rangeOf(0, 6, 0, 6), "y, ", token.PositionEncodingUTF16, "local y, x = 1\n"},
+		{"multi-line delete", "a = 1\nb = 2\nc = 3\n", rangeOf(0, 5, 2, 0), "\n", token.PositionEncodingUTF16, "a = 1\nc = 3\n"},
+		{"multi-line insert", "a = 1\n", rangeOf(0, 0, 0, 0), "b = 2\nc = 3\n", token.PositionEncodingUTF16, "b = 2\nc = 3\na = 1\n"},
+		{"end of file", "a = 1\n", rangeOf(1, 0, 1, 0), "b = 2\n", token.PositionEncodingUTF16, "a = 1\nb = 2\n"},
+		{"end of file without line break", "a = 1", rangeOf(0, 5, 0, 5), "\n", token.PositionEncodingUTF16, "a = 1\n"},
+		{"past end of line", "a = 1\nb = 2", rangeOf(0, 50, 0, 50), ";", token.PositionEncodingUTF16, "a = 1;\nb = 2"},
+		{"past end of file", "a = 1", rangeOf(3, 0, 3, 0), "\n", token.PositionEncodingUTF16, "a = 1\n"},
+		{"full document", "a = 1\nb = 2\n", rangeOf(0, 0, 2, 0), "c = 3\n", token.PositionEncodingUTF16, "c = 3\n"},
+		{"multi-byte utf-8", "s = 'é😀'\n", rangeOf(0, 7, 0, 11), "x", token.PositionEncodingUTF8, "s = 'éx'\n"},
+		{"multi-byte utf-16", "s = 'é😀'\n", rangeOf(0, 6, 0, 8), "x", token.PositionEncodingUTF16, "s = 'éx'\n"},
+		{"multi-byte utf-32", "s = 'é😀'\n", rangeOf(0, 6, 0, 7), "x", token.PositionEncodingUTF32, "s = 'éx'\n"},
+		{"multi-byte next line", "'😀'\n'😀'", rangeOf(0, 3, 1, 1), "", token.PositionEncodingUTF16, "'😀😀'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, applyChange(test.text, test.rng, test.newText, test.encoding))
+		})
+	}
+}
+
+func TestOffsetOf(t *testing.T) {
+	text := "é😀x\ny"
+	tests := []struct {
+		encoding  token.PositionEncoding
+		character uint32
+		want      int
+	}{
+		{token.PositionEncodingUTF8, 2, 2},
+		{token.PositionEncodingUTF8, 6, 6},
+		{token.PositionEncodingUTF16, 1, 2},
+		{token.PositionEncodingUTF16, 3, 6},
+		{token.PositionEncodingUTF32, 2, 6},
+		{token.PositionEncodingUTF32, 3, 7},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, offsetOf(text, protocol.Position{Line: 0, Character: test.character}, test.encoding), "%s %d", test.encoding, test.character)
+	}
+	assert.Equal(t, 8, offsetOf(text, protocol.Position{Line: 1, Character: 0}, token.PositionEncodingUTF16))
+	assert.Equal(t, len(text), offsetOf(text, protocol.Position{Line: 1, Character: 10}, token.PositionEncodingUTF16))
+}
+
+func TestDidChange(t *testing.T) {
+	uri := "file:///main.lua"
+	s := openFile(t, uri, "local x = 1\n")
+	params := &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}},
+		ContentChanges: []any{
+			protocol.TextDocumentContentChangeEventWhole{Text: "local y = 2\n"},
+			protocol.TextDocumentContentChangeEvent{Range: &protocol.Range{
+				Start: protocol.Position{Line: 1, Character: 0},
+				End:   protocol.Position{Line: 1, Character: 0},
+			}, Text: "print(y)\n"},
+		},
+	}
+	require.NoError(t, s.textDocumentDidChange(testContext(t, params, nil), params))
+	text, _ := s.sourceOf(uri)
+	assert.Equal(t, "local y = 2\nprint(y)\n", text)
+}
+
+func TestDidSaveReload(t *testing.T) {
+	root, rootURI := writeFolder(t, map[string]string{"main.lua": "local x = 1\n"})
+	uri := rootURI + "/main.lua"
+	s := openFile(t, uri, "local x = 1\n")
+	s.config.ReloadOnSave = util.Ptr(true)
+	params := &protocol.DidSaveTextDocumentParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}
+
+	// Nothing is checked again if the file did not change while it was saved.
+	notifications := []notification{}
+	require.NoError(t, s.textDocumentDidSave(testContext(t, params, &notifications), params))
+	assert.Empty(t, notifications)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.lua"), []byte("local y = 2\n"), 0644))
+	require.NoError(t, s.textDocumentDidSave(testContext(t, params, &notifications), params))
+	text, _ := s.sourceOf(uri)
+	assert.Equal(t, "local y = 2\n", text)
+	require.NotEmpty(t, notifications)
+	assert.Equal(t, protocol.ServerTextDocumentPublishDiagnostics, notifications[0].method)
+}
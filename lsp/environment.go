@@ -0,0 +1,46 @@
+package lsp
+
+import (
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// MethodEnvironment returns the environment that a document resolved to, for finding out why it is checked with
+// unexpected globals or definitions.
+const MethodEnvironment = "luapls/environment"
+
+type EnvironmentParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+}
+
+type EnvironmentResult struct {
+	// Name is the name of the environment, which is empty for the default environment, and the name of the folder
+	// for the environments of workspace folders other than the first.
+	Name string `json:"name"`
+	// Root is the directory of the files that the environment checks, which is empty if the document is not part of
+	// any workspace folder.
+	Root     string `json:"root"`
+	Priority int    `json:"priority"`
+	// Library contains the files and directories that the environment loads alongside its root directory.
+	Library []string `json:"library"`
+	// Owned is whether the environment checks the document, rather than only loading it because it is open or part
+	// of its library.
+	Owned bool `json:"owned"`
+}
+
+func (s *Server) environmentInfo(ctx *glsp.Context, params *EnvironmentParams) (any, error) {
+	uri := util.NormalizeURI(params.TextDocument.URI)
+	env := s.environmentOf(uri)
+	library := env.Library
+	if library == nil {
+		library = []string{}
+	}
+	return EnvironmentResult{
+		Name:     env.Name,
+		Root:     env.RootPath,
+		Priority: env.Priority,
+		Library:  library,
+		Owned:    env.Owns(uri),
+	}, nil
+}
@@ -0,0 +1,365 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// extractActions returns the refactorings that extract the selected range into a local or a function. Surrounding
+// whitespace is not part of the selection.
+func (s *Server) extractActions(env *types.Environment, file *ast.File, info *types.Info, text string, rng token.Range) []protocol.CodeAction {
+	for rng.Start < rng.End && rng.Start < len(text) && isSpace(text[rng.Start]) {
+		rng.Start++
+	}
+	for rng.End > rng.Start && rng.End <= len(text) && isSpace(text[rng.End-1]) {
+		rng.End--
+	}
+	if rng.Start >= rng.End || rng.End > len(text) {
+		return nil
+	}
+	kind := protocol.CodeActionKindRefactorExtract
+	actions := []protocol.CodeAction{}
+	for _, fix := range []func() (ast.Fix, error){
+		func() (ast.Fix, error) { return extractLocal(env, file, info, text, rng) },
+		func() (ast.Fix, error) { return extractFunction(env, file, info, text, rng) },
+	} {
+		fix, err := fix()
+		if fix.Title == "" {
+			continue
+		}
+		action := protocol.CodeAction{Title: fix.Title, Kind: &kind}
+		if err != nil {
+			action.Disabled = &struct {
+				Reason string `json:"reason"`
+			}{Reason: err.Error()}
+		} else {
+			action.Edit = s.fileEdit(file, fix.Edits)
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// extractLocal returns a fix that moves the selected expression into a new local, declared before the statement
+// that contains it. A fix with only a title and an error is returned if the expression cannot be moved without
+// changing when it is evaluated.
+func extractLocal(env *types.Environment, file *ast.File, info *types.Info, text string, rng token.Range) (ast.Fix, error) {
+	var expr ast.Expression
+	var stmt ast.Statement
+	var parents []ast.Node
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		if expr != nil || n.Pos() > rng.Start || n.End() < rng.End {
+			return false
+		}
+		// Function calls are statements when they are directly within a block.
+		if _, ok := last(parents).(*ast.Pair[ast.Statement]); ok {
+			stmt = n.(ast.Statement)
+			parents = []ast.Node{}
+		} else if e, ok := n.(ast.Expression); ok && n.Pos() == rng.Start && n.End() == rng.End {
+			expr = e
+			return false
+		}
+		parents = append(parents, n)
+		return true
+	})
+	if expr == nil || stmt == nil || !isExtractableExpression(info, expr, parents) {
+		return ast.Fix{}, nil
+	}
+	fix := ast.Fix{Title: "Extract to local"}
+	switch stmt := stmt.(type) {
+	case *ast.WhileStatement, *ast.RepeatStatement:
+		return fix, fmt.Errorf("The condition of a loop is evaluated on every iteration")
+	case *ast.IfStatement:
+		if len(stmt.Clauses) > 0 && !containsPos(stmt.Clauses[0].Condition, expr.Pos()) {
+			return fix, fmt.Errorf("The condition of an 'elseif' is only evaluated if the previous conditions fail")
+		}
+	}
+	for _, parent := range parents {
+		if infix, ok := parent.(*ast.InfixExpression); ok && isShortCircuit(infix) &&
+			containsPos(infix.Right, expr.Pos()) {
+			return fix, fmt.Errorf("The expression is only evaluated if '%s' allows it", infix.Operator.Token.Literal)
+		}
+	}
+
+	name := uniqueName(env, file, "extracted")
+	indent, alone := indentBefore(file, text, stmt.Pos())
+	separator := "\n" + indent
+	if !alone {
+		separator = "; "
+	}
+	fix.Edits = []ast.Edit{
+		{
+			Range:   token.Range{Start: stmt.Pos(), End: stmt.Pos()},
+			NewText: fmt.Sprintf("local %s = %s%s", name, text[rng.Start:rng.End], separator),
+		},
+		{Range: rng, NewText: name},
+	}
+	return fix, nil
+}
+
+// isExtractableExpression returns whether the expression is a value, rather than a name that is assigned to or
+// declared, or the name of a field.
+func isExtractableExpression(info *types.Info, expr ast.Expression, parents []ast.Node) bool {
+	if _, ok := expr.(*ast.Invalid); ok {
+		return false
+	}
+	if ident, ok := expr.(*ast.Identifier); ok {
+		if sym := info.SymbolOf(ident); sym == nil || sym.Decl == ident {
+			return false
+		}
+	}
+	for _, parent := range parents {
+		switch parent := parent.(type) {
+		case *ast.AssignmentStatement:
+			if containsPos(&parent.Vars, expr.Pos()) {
+				return false
+			}
+		case *ast.FunctionStatement:
+			if containsPos(parent.Name, expr.Pos()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func last(nodes []ast.Node) ast.Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[len(nodes)-1]
+}
+
+// containsPos returns whether the node contains the given position.
+func containsPos(n ast.Node, pos token.Pos) bool {
+	return !ast.IsNil(n) && n.Pos() <= pos && pos < n.End()
+}
+
+// isShortCircuit returns whether the right operand of the expression is only evaluated depending on the left one.
+func isShortCircuit(infix *ast.InfixExpression) bool {
+	return infix.Operator.Type() == token.AND || infix.Operator.Type() == token.OR
+}
+
+// extractFunction returns a fix that moves the selected statements into a new local function, declared before the
+// top-level statement that contains them. Locals that the statements use are passed as parameters. Locals that they
+// assign, and locals that they declare which are used afterwards, are returned.
+func extractFunction(env *types.Environment, file *ast.File, info *types.Info, text string, rng token.Range) (ast.Fix, error) {
+	stmts := selectedStatements(file, rng)
+	if len(stmts) == 0 {
+		return ast.Fix{}, nil
+	}
+	fix := ast.Fix{Title: "Extract to function"}
+	rng = token.Range{Start: stmts[0].Pos(), End: stmts[len(stmts)-1].End()}
+	for _, stmt := range stmts {
+		if err := checkExtractable(stmt, false); err != nil {
+			return fix, err
+		}
+	}
+
+	params, assigned, declared := []string{}, []string{}, []string{}
+	seen := map[*types.Symbol]bool{}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		if n.End() <= rng.Start || n.Pos() >= rng.End {
+			return false
+		}
+		ident, ok := n.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		sym := info.SymbolOf(ident)
+		if sym == nil || sym.Kind == types.SymbolGlobal || seen[sym] {
+			return true
+		}
+		seen[sym] = true
+		if sym.Decl == nil || sym.Decl.Pos() < rng.Start {
+			params = append(params, sym.Name)
+			// Assignments are always returned, since a loop around the statements may read them again.
+			if writesWithin(sym, rng) {
+				assigned = append(assigned, sym.Name)
+			}
+		} else if usedAfter(sym, rng) {
+			declared = append(declared, sym.Name)
+		}
+		return true
+	})
+
+	var top ast.Statement
+	for _, pair := range file.Block.Pairs {
+		if pair.Node.Pos() <= rng.Start && rng.End <= pair.Node.End() {
+			top = pair.Node
+		}
+	}
+	name := uniqueName(env, file, "extracted")
+	unit := indentUnit(text)
+	topIndent, topAlone := indentBefore(file, text, top.Pos())
+	indent, alone := indentBefore(file, text, rng.Start)
+
+	// The body keeps its relative indentation, nested one level within the new function.
+	bodyStart := rng.Start
+	if alone {
+		bodyStart -= len(indent)
+	}
+	body := ""
+	for _, line := range strings.Split(text[bodyStart:rng.End], "\n") {
+		line = strings.TrimPrefix(strings.TrimRight(line, " \t\r"), indent)
+		if line != "" {
+			line = topIndent + unit + line
+		}
+		body += line + "\n"
+	}
+	returns := append(append([]string{}, declared...), assigned...)
+	if len(returns) > 0 {
+		body += topIndent + unit + "return " + strings.Join(returns, ", ") + "\n"
+	}
+	function := fmt.Sprintf("local function %s(%s)\n%s%send\n", name, strings.Join(params, ", "), body, topIndent)
+
+	call := fmt.Sprintf("%s(%s)", name, strings.Join(params, ", "))
+	separator := "\n" + indent
+	if !alone {
+		separator = "; "
+	}
+	switch {
+	case len(declared) > 0 && len(assigned) > 0:
+		call = fmt.Sprintf("local %s%s%s = %s", strings.Join(declared, ", "), separator, strings.Join(returns, ", "), call)
+	case len(declared) > 0:
+		call = fmt.Sprintf("local %s = %s", strings.Join(declared, ", "), call)
+	case len(assigned) > 0:
+		call = fmt.Sprintf("%s = %s", strings.Join(assigned, ", "), call)
+	}
+
+	insertAt := top.Pos()
+	if topAlone {
+		insertAt -= len(topIndent)
+		function = topIndent + function + "\n"
+	} else {
+		function += separator
+	}
+	fix.Edits = []ast.Edit{
+		{Range: token.Range{Start: insertAt, End: insertAt}, NewText: function},
+		{Range: rng, NewText: call},
+	}
+	return fix, nil
+}
+
+// selectedStatements returns the run of statements in a single block that the range covers exactly, or nil if the
+// range is not such a run.
+func selectedStatements(file *ast.File, rng token.Range) []ast.Statement {
+	var selected []ast.Statement
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		if n.End() < rng.Start || n.Pos() > rng.End {
+			return false
+		}
+		block, ok := n.(*ast.Block)
+		if !ok {
+			return true
+		}
+		stmts := []ast.Statement{}
+		for _, pair := range block.Pairs {
+			if pair.Node.Pos() == rng.Start || len(stmts) > 0 {
+				stmts = append(stmts, pair.Node)
+			}
+			if len(stmts) > 0 && (pair.Node.End() == rng.End || pair.End() == rng.End) {
+				selected = stmts
+				break
+			}
+		}
+		return true
+	})
+	return selected
+}
+
+// checkExtractable returns an error if a statement transfers control outside of itself, or uses the varargs of the
+// enclosing function, neither of which would work from within a new function.
+func checkExtractable(n ast.Node, loop bool) error {
+	switch n.(type) {
+	case *ast.FunctionExpression, *ast.FunctionStatement:
+		return nil
+	case *ast.ReturnStatement:
+		return fmt.Errorf("Statements that return cannot be extracted")
+	case *ast.BreakStatement:
+		if !loop {
+			return fmt.Errorf("A 'break' cannot be extracted without its loop")
+		}
+	case *ast.GotoStatement, *ast.LabelStatement:
+		return fmt.Errorf("Statements with labels or 'goto' cannot be extracted")
+	case *ast.Vararg:
+		return fmt.Errorf("Statements that use '...' cannot be extracted")
+	case *ast.ForStatement, *ast.ForInStatement, *ast.WhileStatement, *ast.RepeatStatement:
+		loop = true
+	}
+	for _, child := range n.GetSemanticChildren() {
+		if ast.IsNil(child) {
+			continue
+		}
+		if err := checkExtractable(child, loop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writesWithin returns whether the symbol is assigned to within the range.
+func writesWithin(sym *types.Symbol, rng token.Range) bool {
+	for _, ref := range sym.Refs {
+		if ref.Write && rng.ContainsPos(ref.Ident.Pos()) {
+			return true
+		}
+	}
+	return false
+}
+
+// usedAfter returns whether the symbol is referred to after the range.
+func usedAfter(sym *types.Symbol, rng token.Range) bool {
+	for _, ref := range sym.Refs {
+		if ref.Ident.Pos() >= rng.End {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueName returns a name based on the given one that is not used by any identifier in the file, nor by a global.
+func uniqueName(env *types.Environment, file *ast.File, base string) string {
+	used := map[string]bool{}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok {
+			used[ident.Token.Literal] = true
+		}
+		return true
+	})
+	name := base
+	for i := 2; used[name] || env.Globals.IsDefined(name); i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+// indentBefore returns the indentation of the line that contains the given position, and whether the position is
+// the first thing on its line.
+func indentBefore(file *ast.File, text string, pos token.Pos) (string, bool) {
+	prefix := text[file.LineBreaks.LineStart(file.LineBreaks.Line(pos)):pos]
+	indent := prefix[:len(prefix)-len(strings.TrimLeft(prefix, " \t"))]
+	return indent, indent == prefix
+}
+
+// indentUnit returns the indentation of the first indented line of the text, which is assumed to be a single level.
+func indentUnit(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]; indent != "" && indent != line {
+			if indent[0] == '\t' {
+				return "\t"
+			}
+			return indent
+		}
+	}
+	return "\t"
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
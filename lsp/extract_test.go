@@ -0,0 +1,123 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type extractor func(*types.Environment, *ast.File, *types.Info, string, token.Range) (ast.Fix, error)
+
+// extract runs the extraction on the first occurrence of the selection in the source, and returns the fix and the
+// source with its edits applied.
+func extract(t *testing.T, fn extractor, src string, selection string) (ast.Fix, string, error) {
+	uri := "file:///main.lua"
+	s := openFile(t, uri, src)
+	start := strings.Index(src, selection)
+	require.GreaterOrEqual(t, start, 0)
+	fix, err := fn(s.environmentOf(uri), s.getFile(uri), s.getInfo(uri), src, token.Range{Start: start, End: start + len(selection)})
+	edits := append([]ast.Edit{}, fix.Edits...)
+	// Edits are applied from the end, so that an insertion goes before a replacement that starts at the same position.
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].Range.Start != edits[j].Range.Start {
+			return edits[i].Range.Start > edits[j].Range.Start
+		}
+		return edits[i].Range.End > edits[j].Range.End
+	})
+	for _, edit := range edits {
+		src = src[:edit.Range.Start] + edit.NewText + src[edit.Range.End:]
+	}
+	return fix, src, err
+}
+
+func TestExtractLocal(t *testing.T) {
+	_, result, err := extract(t, extractLocal, `local a, b = 1, 2
+if true then
+  print(a + b)
+end`, "a + b")
+	require.NoError(t, err)
+	assert.Equal(t, `local a, b = 1, 2
+if true then
+  local extracted = a + b
+  print(extracted)
+end`, result)
+
+	fix, _, err := extract(t, extractLocal, `local i = 0
+while i < 10 do i = i + 1 end`, "i < 10")
+	assert.Equal(t, "Extract to local", fix.Title)
+	assert.Error(t, err)
+
+	fix, _, err = extract(t, extractLocal, `local t = nil
+print(t and t.x)`, "t.x")
+	assert.Equal(t, "Extract to local", fix.Title)
+	assert.Error(t, err)
+}
+
+func TestExtractFunctionCapturedLocals(t *testing.T) {
+	_, result, err := extract(t, extractFunction, `local a, b = 1, 2
+local sum = a + b
+print(sum)`, "local sum = a + b")
+	require.NoError(t, err)
+	assert.Equal(t, `local a, b = 1, 2
+local function extracted(a, b)
+	local sum = a + b
+	return sum
+end
+
+local sum = extracted(a, b)
+print(sum)`, result)
+}
+
+func TestExtractFunctionMultipleReturns(t *testing.T) {
+	_, result, err := extract(t, extractFunction, `local function run()
+  local total = 0
+  local x = 1
+  total = total + x
+  local y = 2
+  print(x, y, total)
+end`, `local x = 1
+  total = total + x
+  local y = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, `local function extracted(total)
+  local x = 1
+  total = total + x
+  local y = 2
+  return x, y, total
+end
+
+local function run()
+  local total = 0
+  local x, y
+  x, y, total = extracted(total)
+  print(x, y, total)
+end`, result)
+}
+
+func TestExtractFunctionPartialSelection(t *testing.T) {
+	src := `local a, b = 1, 2
+local sum = a + b
+print(sum)`
+	for _, selection := range []string{"local sum = a", "local sum = a + b\nprint("} {
+		fix, _, err := extract(t, extractFunction, src, selection)
+		assert.NoError(t, err)
+		assert.Empty(t, fix.Title, selection)
+	}
+}
+
+func TestExtractFunctionReturn(t *testing.T) {
+	fix, _, err := extract(t, extractFunction, `local function f()
+  print(1)
+  return 2
+end`, `print(1)
+  return 2`)
+	assert.Equal(t, "Extract to function", fix.Title)
+	assert.Error(t, err)
+}
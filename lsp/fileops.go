@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// fileOperationFilters are the files and folders that the client tells the server about before it renames or
+// deletes them. Folders are included because the client only reports the folder, and not the files within it.
+func fileOperationFilters() *protocol.FileOperationRegistrationOptions {
+	file, folder := protocol.FileOperationPatternKindFile, protocol.FileOperationPatternKindFolder
+	return &protocol.FileOperationRegistrationOptions{Filters: []protocol.FileOperationFilter{
+		{Scheme: util.Ptr("file"), Pattern: protocol.FileOperationPattern{Glob: "**/*.lua", Matches: &file}},
+		{Scheme: util.Ptr("file"), Pattern: protocol.FileOperationPattern{Glob: "**", Matches: &folder}},
+	}}
+}
+
+// workspaceWillRenameFiles updates the requires of the files that are about to be renamed or moved to their new
+// module names. Requires whose names are not string literals, and files that cannot be required by any name after
+// they are moved, are left alone.
+func (s *Server) workspaceWillRenameFiles(ctx *glsp.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	changes := map[protocol.DocumentUri][]protocol.TextEdit{}
+	edited := map[protocol.DocumentUri]map[token.Range]bool{}
+	for _, rename := range params.Files {
+		oldURI, newURI := util.NormalizeURI(rename.OldURI), util.NormalizeURI(rename.NewURI)
+		for _, env := range s.allEnvironments() {
+			for uri := range env.Files {
+				moved, ok := movedURI(uri, oldURI, newURI)
+				if !ok {
+					continue
+				}
+				name, ok := env.ModuleName(moved)
+				if !ok {
+					continue
+				}
+				for _, dependent := range env.Modules.Dependents(uri) {
+					file := env.Files[dependent]
+					if file == nil || s.environmentOf(dependent) != env {
+						continue
+					}
+					for _, req := range env.Modules.Requires(dependent) {
+						lit, ok := req.Call.Args.Pairs[0].Node.(*ast.StringLiteral)
+						if req.Target != uri || req.Name == name || !ok || lit.Token.Type != token.STRING {
+							continue
+						}
+						// Only the contents of the string are replaced, so that its quotes are kept.
+						rng := token.Range{Start: lit.Pos() + 1, End: lit.End() - 1}
+						if edited[dependent][rng] {
+							continue
+						}
+						if edited[dependent] == nil {
+							edited[dependent] = map[token.Range]bool{}
+						}
+						edited[dependent][rng] = true
+						changes[dependent] = append(changes[dependent], protocol.TextEdit{Range: file.ToProtocolRange(rng, s.encoding), NewText: name})
+					}
+				}
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
+
+// workspaceWillDeleteFiles warns the user about modules that are about to be deleted while other files still require
+// them. Nothing is changed, since there is nothing to replace the requires with.
+func (s *Server) workspaceWillDeleteFiles(ctx *glsp.Context, params *protocol.DeleteFilesParams) (*protocol.WorkspaceEdit, error) {
+	for _, deleted := range params.Files {
+		root := util.NormalizeURI(deleted.URI)
+		// Files that require each other within a deleted folder are deleted together.
+		dependents := map[string]map[protocol.DocumentUri]bool{}
+		for _, env := range s.allEnvironments() {
+			for uri := range env.Files {
+				if !withinURI(uri, root) {
+					continue
+				}
+				name, ok := env.ModuleName(uri)
+				if !ok {
+					continue
+				}
+				for _, dependent := range env.Modules.Dependents(uri) {
+					if !withinURI(dependent, root) && s.environmentOf(dependent) == env {
+						if dependents[name] == nil {
+							dependents[name] = map[protocol.DocumentUri]bool{}
+						}
+						dependents[name][dependent] = true
+					}
+				}
+			}
+		}
+		names := []string{}
+		for name := range dependents {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			message := fmt.Sprintf("Module '%s' is still required by %d files", name, len(dependents[name]))
+			if len(dependents[name]) == 1 {
+				message = fmt.Sprintf("Module '%s' is still required by another file", name)
+			}
+			ctx.Notify(protocol.ServerWindowShowMessage, protocol.ShowMessageParams{Type: protocol.MessageTypeWarning, Message: message})
+		}
+	}
+	return nil, nil
+}
+
+// workspaceDidDeleteFiles removes the deleted files from the environments, so that the requires that still refer to
+// them are reported right away rather than when the client reports that the files changed on disk, if it does at all.
+func (s *Server) workspaceDidDeleteFiles(ctx *glsp.Context, params *protocol.DeleteFilesParams) error {
+	for _, deleted := range params.Files {
+		root := util.NormalizeURI(deleted.URI)
+		uris := []protocol.DocumentUri{}
+		for _, env := range s.allEnvironments() {
+			for uri := range env.Files {
+				if _, open := s.contents[uri]; withinURI(uri, root) && !open {
+					uris = append(uris, uri)
+				}
+			}
+		}
+		for _, uri := range uris {
+			s.fileDeleted(ctx, uri)
+		}
+	}
+	return nil
+}
+
+// withinURI returns whether the given URI is the same as root, or is within the folder at root.
+func withinURI(uri, root protocol.DocumentUri) bool {
+	return uri == root || strings.HasPrefix(uri, strings.TrimSuffix(root, "/")+"/")
+}
+
+// movedURI returns the URI that a file has after the file or folder at oldURI is renamed to newURI, or false if the
+// file is not affected by the rename.
+func movedURI(uri, oldURI, newURI protocol.DocumentUri) (protocol.DocumentUri, bool) {
+	if !withinURI(uri, oldURI) {
+		return "", false
+	}
+	if uri == oldURI {
+		return newURI, true
+	}
+	return strings.TrimSuffix(newURI, "/") + uri[len(strings.TrimSuffix(oldURI, "/")):], true
+}
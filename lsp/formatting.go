@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/lua/format"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func (s *Server) textDocumentFormatting(ctx *glsp.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	text, err := s.sourceOf(file.URI)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := format.File(file, text, s.formatOptions(params.Options))
+	if err != nil {
+		return nil, err
+	}
+	if formatted == text {
+		return []protocol.TextEdit{}, nil
+	}
+	return []protocol.TextEdit{{
+		Range:   file.ToProtocolRange(token.Range{Start: 0, End: len(text)}, s.encoding),
+		NewText: formatted,
+	}}, nil
+}
+
+// formatOptions converts the client's formatting options into the indentation to format with, unless it is
+// configured. Tabs are used unless the client or the configuration asks for spaces.
+func (s *Server) formatOptions(options protocol.FormattingOptions) format.Options {
+	insertSpaces, _ := options[protocol.FormattingOptionInsertSpaces].(bool)
+	// Numbers are decoded from JSON as floats.
+	tabSize := 4
+	if size, ok := options[protocol.FormattingOptionTabSize].(float64); ok && size > 0 {
+		tabSize = int(size)
+	}
+	if config := s.config.Format; config != nil {
+		if config.IndentStyle != nil {
+			insertSpaces = *config.IndentStyle == "space"
+		}
+		if config.IndentSize != nil && *config.IndentSize > 0 {
+			tabSize = *config.IndentSize
+		}
+	}
+	if !insertSpaces {
+		return format.Options{Indent: "\t"}
+	}
+	return format.Options{Indent: strings.Repeat(" ", tabSize)}
+}
@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"errors"
+	"sort"
 
 	"github.com/raiguard/luapls/lua/ast"
 	"github.com/tliron/glsp"
@@ -16,24 +17,34 @@ func (s *Server) textDocumentHighlight(ctx *glsp.Context, params *protocol.Docum
 	if file.Block == nil {
 		return nil, errors.New("Attempted to highlight file that has no AST")
 	}
-	nodePath := ast.GetSemanticNode(file.Block, file.LineBreaks.ToPos(params.Position))
-	if nodePath.Node == nil {
+	// TODO: Labels
+	ident, sym := s.identAt(file, s.getInfo(file.URI), params.Position)
+	if ident == nil {
 		return nil, nil
 	}
+	if sym == nil {
+		return []protocol.DocumentHighlight{s.highlight(file, ident, protocol.DocumentHighlightKindText)}, nil
+	}
+	// Declarations give the symbol its initial value, even if it is nil.
 	highlights := []protocol.DocumentHighlight{}
-	// TODO: Labels
-	_, ok := nodePath.Node.(ast.LeafNode)
-	if !ok {
-		return nil, nil
+	if sym.Decl != nil {
+		highlights = append(highlights, s.highlight(file, sym.Decl, protocol.DocumentHighlightKindWrite))
 	}
-	// TODO: References
-	highlights = append(highlights, protocol.DocumentHighlight{Range: file.LineBreaks.ToProtocolRange(ast.Range(nodePath.Node))})
-
-	// TODO:
-	// def := file.Env.FindDefinition(nodePath)
-	// if def != nil {
-	// 	highlights = append(highlights, protocol.DocumentHighlight{Range: file.LineBreaks.ToProtocolRange(ast.Range(def))})
-	// }
+	for _, ref := range sym.Refs {
+		kind := protocol.DocumentHighlightKindRead
+		if ref.Write {
+			kind = protocol.DocumentHighlightKindWrite
+		}
+		highlights = append(highlights, s.highlight(file, ref.Ident, kind))
+	}
+	sort.Slice(highlights, func(i, j int) bool {
+		a, b := highlights[i].Range.Start, highlights[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
 
 	return highlights, nil
 }
+
+func (s *Server) highlight(file *ast.File, ident *ast.Identifier, kind protocol.DocumentHighlightKind) protocol.DocumentHighlight {
+	return protocol.DocumentHighlight{Range: file.ToProtocolRange(ast.Range(ident), s.encoding), Kind: &kind}
+}
@@ -3,8 +3,14 @@ package lsp
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
+	"github.com/raiguard/luapls/lua/annotation"
 	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
 	"github.com/raiguard/luapls/util"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -18,33 +24,321 @@ func (s *Server) textDocumentHover(ctx *glsp.Context, params *protocol.HoverPara
 	if file.Block == nil {
 		return nil, errors.New("Attempted to highlight file with no AST")
 	}
-	nodePath := ast.GetSemanticNode(file.Block, file.LineBreaks.ToPos(params.Position))
+	nodePath := ast.GetSemanticNode(file.Block, file.ToPos(params.Position, s.encoding))
 	if nodePath.Node == nil {
 		return nil, nil
 	}
+	markdown := s.hoverKind == protocol.MarkupKindMarkdown
+	if insight := literalInsight(nodePath.Node, markdown); insight != "" {
+		return &protocol.Hover{
+			Contents: protocol.MarkupContent{Kind: s.hoverKind, Value: insight},
+			Range:    util.Ptr(file.ToProtocolRange(ast.Range(nodePath.Node), s.encoding)),
+		}, nil
+	}
 	ident, ok := nodePath.Node.(*ast.Identifier)
 	if !ok {
 		return nil, nil
 	}
-	// typ, ok := file.Env.Types[ident]
-	// if !ok {
-	// 	typ = &types.Unknown{}
-	// }
-	contents := fmt.Sprintf("```lua\n(variable) %s\n```", ident.Token.Literal)
-	// comments := ident.GetComments()
-	// i := len(nodePath.Parents) - 1
-	// for comments == "" && i >= 0 {
-	// 	comments = nodePath.Parents[i].GetComments()
-	// 	if _, ok := nodePath.Parents[i].(ast.Statement); ok {
-	// 		break
-	// 	}
-	// 	i--
-	// }
-	// if comments != "" {
-	// 	contents += "\n\n" + comments
-	// }
+	info := s.getInfo(file.URI)
+	env := s.environmentOf(file.URI)
+	signature := describeIdent(info, ident, nodePath.Parents)
+	if fn, ok := types.Resolve(info.TypeOf(ident)).(*types.Function); ok {
+		for _, overload := range fn.Overloads {
+			signature += fmt.Sprintf("\n(overload) %s: %s", ident.Token.Literal, overload)
+		}
+	}
+	sections := []string{signature}
+	if markdown {
+		sections[0] = fmt.Sprintf("```lua\n%s\n```", signature)
+	}
+	var expr ast.Expression = ident
+	if len(nodePath.Parents) > 0 {
+		if ie, ok := nodePath.Parents[len(nodePath.Parents)-1].(*ast.IndexExpression); ok && ie.Inner == ast.Expression(ident) {
+			expr = ie
+		}
+	}
+	if deprecated := env.Deprecation(file.URI, expr); deprecated != nil {
+		section := "Deprecated"
+		if markdown {
+			section = "**Deprecated**"
+		}
+		if deprecated.Message != "" {
+			section += ": " + deprecated.Message
+		}
+		sections = append(sections, section)
+	}
+	sym := info.SymbolOf(ident)
+	if sym != nil && sym.Kind == types.SymbolParameter {
+		if description := symbolDescription(info, sym); description != "" {
+			sections = append(sections, description)
+		}
+	} else if doc := s.hoverDoc(env, info, nodePath, sym); doc != nil {
+		if doc.Description != "" {
+			sections = append(sections, doc.Description)
+		}
+		if tags := docTags(doc, markdown); tags != "" {
+			sections = append(sections, tags)
+		}
+	}
+	if data := env.EventData(expr, info.TypeOf(expr)); data != nil {
+		sections = append(sections, eventDataFields(data, markdown))
+	}
 	return &protocol.Hover{
-		Contents: contents,
-		Range:    util.Ptr(file.LineBreaks.ToProtocolRange(ast.Range(ident))),
+		Contents: protocol.MarkupContent{Kind: s.hoverKind, Value: strings.Join(sections, "\n\n")},
+		Range:    util.Ptr(file.ToProtocolRange(ast.Range(ident), s.encoding)),
 	}, nil
 }
+
+// describeIdent returns a Lua-like declaration of the given identifier, such as `local x: string`. Functions are
+// shown with their signature, such as `local function add(a: number, b: number) → number`.
+func describeIdent(info *types.Info, ident *ast.Identifier, parents []ast.Node) string {
+	name := ident.Token.Literal
+	if len(parents) > 0 {
+		if ie, ok := parents[len(parents)-1].(*ast.IndexExpression); ok && ie.Inner == ast.Expression(ident) {
+			typ := info.TypeOf(ie)
+			if fn, ok := types.Resolve(typ).(*types.Function); ok {
+				if ie.LeftIndexer.Type() == token.COLON {
+					return fmt.Sprintf("(method) %s:%s%s", callableName(ie.Prefix), name, functionSignature(fn, true))
+				}
+				return fmt.Sprintf("(field) function %s.%s%s", callableName(ie.Prefix), name, functionSignature(fn, false))
+			}
+			return fmt.Sprintf("(field) %s: %s", name, typ)
+		}
+	}
+	typ := info.TypeOf(ident)
+	sym := info.SymbolOf(ident)
+	if sym == nil {
+		return fmt.Sprintf("(field) %s: %s", name, typ)
+	}
+	fn, isFunction := types.Resolve(typ).(*types.Function)
+	switch {
+	case sym.Kind == types.SymbolParameter:
+		return fmt.Sprintf("(parameter) %s: %s", name, typ)
+	case sym.Kind == types.SymbolLocal && isFunction:
+		return fmt.Sprintf("local function %s%s", name, functionSignature(fn, false))
+	case sym.Kind == types.SymbolLocal:
+		return fmt.Sprintf("local %s: %s", name, typ)
+	case isFunction:
+		return fmt.Sprintf("(global) function %s%s", name, functionSignature(fn, false))
+	default:
+		return fmt.Sprintf("(global) %s: %s", name, typ)
+	}
+}
+
+// functionSignature returns the parameters and return type of a function, such as `(a: number) → number`. The `self`
+// parameter of a method is left out if it is called with `:`.
+func functionSignature(fn *types.Function, method bool) string {
+	params := make([]string, 0, len(fn.Params))
+	for _, param := range callParams(fn, method) {
+		params = append(params, param.String())
+	}
+	signature := "(" + strings.Join(params, ", ") + ")"
+	if fn.Return != nil {
+		signature += " → " + fn.Return.String()
+	}
+	return signature
+}
+
+// symbolDescription returns the doc comment description written above the declaration of a symbol.
+func symbolDescription(info *types.Info, sym *types.Symbol) string {
+	if sym == nil || sym.Node == nil {
+		return ""
+	}
+	doc := info.Docs[sym.Node]
+	if sym.Kind == types.SymbolParameter {
+		if param := doc.Param(sym.Name); param != nil {
+			return param.Description
+		}
+		return ""
+	}
+	if doc == nil {
+		return ""
+	}
+	return doc.Description
+}
+
+// hoverDoc returns the doc comment of the declaration that the identifier at the node path refers to. Globals and
+// fields may be declared in another file.
+func (s *Server) hoverDoc(env *types.Environment, info *types.Info, nodePath ast.NodePath, sym *types.Symbol) *annotation.Doc {
+	if sym != nil && sym.Kind != types.SymbolGlobal {
+		if sym.Node == nil {
+			return nil
+		}
+		return info.Docs[sym.Node]
+	}
+	if _, field := types.FieldAt(info, nodePath); field != nil {
+		return s.fieldDoc(env, field)
+	}
+	if sym == nil {
+		return nil
+	}
+	for _, site := range env.Globals.Defs(sym.Name) {
+		if siteInfo := env.Info[site.URI]; siteInfo != nil && site.Stmt != nil && siteInfo.Docs[site.Stmt] != nil {
+			return siteInfo.Docs[site.Stmt]
+		}
+	}
+	return nil
+}
+
+// fieldDoc returns the doc comment of the declaration of a field. Fields that are declared by a `---@field`
+// annotation are documented by the description of the annotation.
+func (s *Server) fieldDoc(env *types.Environment, field *types.NameAndType) *annotation.Doc {
+	info := env.Info[field.Loc.URI]
+	if info == nil {
+		return nil
+	}
+	for _, doc := range info.DocBlocks {
+		if !doc.Range.ContainsPos(field.Loc.Range.Start) {
+			continue
+		}
+		for _, a := range doc.Annotations {
+			if a, ok := a.(*annotation.Field); ok && a.NameRange == field.Loc.Range && a.Description != "" {
+				return &annotation.Doc{Description: a.Description}
+			}
+		}
+		return nil
+	}
+	return s.docAt(env, field.Loc)
+}
+
+// docAt returns the doc comment of the statement or table field that contains the given location.
+func (s *Server) docAt(env *types.Environment, loc types.Location) *annotation.Doc {
+	file := s.getFile(loc.URI)
+	info := env.Info[loc.URI]
+	if file == nil || file.Block == nil || info == nil {
+		return nil
+	}
+	nodePath := ast.GetSemanticNode(file.Block, loc.Range.Start)
+	for i := len(nodePath.Parents) - 1; i >= 0; i-- {
+		switch parent := nodePath.Parents[i].(type) {
+		case ast.Statement, ast.TableField:
+			return info.Docs[parent]
+		}
+	}
+	return nil
+}
+
+// eventDataFields lists the fields of the data that the handlers of an event receive, one per line.
+func eventDataFields(data *types.Named, markdown bool) string {
+	lines := []string{}
+	if markdown {
+		lines = append(lines, fmt.Sprintf("**Event data** `%s`", data.Name), "")
+	} else {
+		lines = append(lines, fmt.Sprintf("Event data %s:", data.Name))
+	}
+	for _, field := range data.AllFields() {
+		if markdown {
+			lines = append(lines, fmt.Sprintf("- `%s`: `%s`", field.Name, field.Type))
+		} else {
+			lines = append(lines, fmt.Sprintf("- %s: %s", field.Name, field.Type))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// docTags returns the descriptions of the parameters and return values that a doc comment documents, one per line.
+func docTags(doc *annotation.Doc, markdown bool) string {
+	lines := []string{}
+	for _, a := range doc.Annotations {
+		switch a := a.(type) {
+		case *annotation.Param:
+			if a.Description == "" {
+				continue
+			}
+			if markdown {
+				lines = append(lines, fmt.Sprintf("*@param* `%s` — %s", a.Name, a.Description))
+			} else {
+				lines = append(lines, fmt.Sprintf("@param %s — %s", a.Name, a.Description))
+			}
+		case *annotation.Return:
+			if a.Description == "" {
+				continue
+			}
+			if markdown {
+				lines = append(lines, "*@return* — "+a.Description)
+			} else {
+				lines = append(lines, "@return — "+a.Description)
+			}
+		}
+	}
+	if markdown {
+		// Markdown joins consecutive lines into one paragraph unless they end with a hard line break.
+		return strings.Join(lines, "  \n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// literalInsight describes the value of a number literal in decimal, hexadecimal, and binary, or the decoded value of
+// a string literal that contains escape sequences. It returns an empty string for other nodes.
+func literalInsight(node ast.Node, markdown bool) string {
+	code := func(s string) string {
+		if markdown {
+			return "`" + s + "`"
+		}
+		return s
+	}
+	lines := []string{}
+	switch node := node.(type) {
+	case *ast.NumberLiteral:
+		literal := node.Token.Literal
+		hex := strings.HasPrefix(literal, "0x") || strings.HasPrefix(literal, "0X")
+		if hex && !strings.ContainsAny(literal, ".pP") || !hex && !strings.ContainsAny(literal, ".eE") {
+			var value uint64
+			var err error
+			if hex {
+				value, err = strconv.ParseUint(literal[2:], 16, 64)
+			} else {
+				var signed int64
+				signed, err = strconv.ParseInt(literal, 10, 64)
+				value = uint64(signed)
+			}
+			// Like Lua, decimal integers that do not fit in 64 bits are read as floats.
+			if err == nil {
+				lines = append(lines,
+					"Integer",
+					"Decimal: "+code(strconv.FormatUint(value, 10)),
+					"Hexadecimal: "+code(fmt.Sprintf("0x%X", value)),
+					"Binary: "+code(strconv.FormatUint(value, 2)),
+				)
+				break
+			}
+			if hex || !errors.Is(err, strconv.ErrRange) {
+				return ""
+			}
+		}
+		if hex && !strings.ContainsAny(literal, "pP") {
+			literal += "p0"
+		}
+		value, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return ""
+		}
+		lines = append(lines, "Float", "Decimal: "+code(strconv.FormatFloat(value, 'g', -1, 64)))
+		if value == math.Trunc(value) && math.Abs(value) < 1<<53 {
+			lines = append(lines, "Hexadecimal: "+code(fmt.Sprintf("0x%X", int64(value))))
+		}
+	case *ast.StringLiteral:
+		if node.Token.Type == token.RAWSTRING || !strings.ContainsRune(node.Token.Literal, '\\') {
+			return ""
+		}
+		quoted, ok := types.StringValue(node)
+		if !ok {
+			return ""
+		}
+		value, ok := types.Unescape(quoted)
+		if !ok {
+			return ""
+		}
+		length := len(value)
+		if markdown {
+			value = "```text\n" + value + "\n```"
+		}
+		return fmt.Sprintf("%s\n\n%d bytes", value, length)
+	default:
+		return ""
+	}
+	if markdown {
+		return strings.Join(lines, "  \n")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,29 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiteralInsight(t *testing.T) {
+	tests := []struct {
+		literal string
+		want    string
+	}{
+		{"255", "Integer\nDecimal: 255\nHexadecimal: 0xFF\nBinary: 11111111"},
+		{"0x10", "Integer\nDecimal: 16\nHexadecimal: 0x10\nBinary: 10000"},
+		{"9223372036854775807", "Integer\nDecimal: 9223372036854775807\nHexadecimal: 0x7FFFFFFFFFFFFFFF\nBinary: " +
+			"111111111111111111111111111111111111111111111111111111111111111"},
+		{"9223372036854775808", "Float\nDecimal: 9.223372036854776e+18"},
+		{"1.5", "Float\nDecimal: 1.5"},
+		{"0x1p4", "Float\nDecimal: 16\nHexadecimal: 0x10"},
+	}
+	for _, test := range tests {
+		node := &ast.NumberLiteral{Token: token.Token{Type: token.NUMBER, Literal: test.literal}}
+		assert.Equal(t, test.want, literalInsight(node, false), test.literal)
+	}
+}
@@ -0,0 +1,95 @@
+package lsp
+
+import (
+	"sort"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// textDocumentImplementation returns the definitions of the method at the given position in the classes that
+// inherit from the class that declares it, such as the overrides of a base class method or the implementations of a
+// `fun` field.
+func (s *Server) textDocumentImplementation(ctx *glsp.Context, params *protocol.ImplementationParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	owner, field := types.FieldAt(s.getInfo(file.URI), ast.GetSemanticNode(file.Block, file.ToPos(params.Position, s.encoding)))
+	if field == nil {
+		return nil, nil
+	}
+	base := declaringClass(owner, field)
+	if base == nil {
+		return nil, nil
+	}
+	env := s.environmentOf(file.URI)
+	names := []string{}
+	for name := range env.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	locations := []protocol.Location{}
+	for _, name := range names {
+		class, ok := env.Types[name].(*types.Named)
+		if !ok || class == base || !descendsFrom(class, base) {
+			continue
+		}
+		for _, own := range class.Fields {
+			if own.Name != field.Name || own.Loc.URI == "" {
+				continue
+			}
+			if location := s.typesLocation(own.Loc); location != nil {
+				locations = append(locations, *location)
+			}
+		}
+	}
+	return locations, nil
+}
+
+// declaringClass returns the class that declares the given field of the owner, which may be one of the classes that
+// the owner inherits from. It returns nil if the owner is not a class.
+func declaringClass(owner types.Type, field *types.NameAndType) *types.Named {
+	class, ok := types.Resolve(owner).(*types.Named)
+	if !ok {
+		return nil
+	}
+	seen := map[*types.Named]bool{}
+	queue := []*types.Named{class}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		for _, own := range current.Fields {
+			if own.Name == field.Name && own.Loc == field.Loc {
+				return current
+			}
+		}
+		queue = append(queue, current.Supertypes()...)
+	}
+	return class
+}
+
+// descendsFrom returns whether the class inherits from the ancestor, either directly or through other classes.
+func descendsFrom(class *types.Named, ancestor *types.Named) bool {
+	seen := map[*types.Named]bool{}
+	queue := class.Supertypes()
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == ancestor {
+			return true
+		}
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		queue = append(queue, current.Supertypes()...)
+	}
+	return false
+}
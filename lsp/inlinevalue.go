@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"errors"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Inline values were added in LSP 3.17, which the protocol package does not support yet, so the types are declared
+// here.
+
+const MethodTextDocumentInlineValue = "textDocument/inlineValue"
+
+type InlineValueParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	Range        protocol.Range                  `json:"range"`
+	Context      InlineValueContext              `json:"context"`
+}
+
+type InlineValueContext struct {
+	FrameID         protocol.Integer `json:"frameId"`
+	StoppedLocation protocol.Range   `json:"stoppedLocation"`
+}
+
+// InlineValueVariableLookup asks the debug adapter for the value of a variable in the stopped frame.
+type InlineValueVariableLookup struct {
+	Range               protocol.Range `json:"range"`
+	VariableName        string         `json:"variableName"`
+	CaseSensitiveLookup bool           `json:"caseSensitiveLookup"`
+}
+
+// InlineValueEvaluatableExpression asks the debug adapter to evaluate an expression in the stopped frame.
+type InlineValueEvaluatableExpression struct {
+	Range      protocol.Range `json:"range"`
+	Expression string         `json:"expression"`
+}
+
+// textDocumentInlineValue returns the variables and field accesses in the function that execution stopped in, up to
+// where it stopped, so that the debugger can show their values. Locals and parameters are looked up by name, and
+// globals and fields are evaluated.
+func (s *Server) textDocumentInlineValue(ctx *glsp.Context, params *InlineValueParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, errors.New("File not found")
+	}
+	info := s.getInfo(file.URI)
+	if file.Block == nil || info == nil {
+		return nil, errors.New("Attempted to get inline values for a file that has no AST")
+	}
+	stopped := clampPos(file, file.ToPos(params.Context.StoppedLocation.End, s.encoding))
+	start, end := clampPos(file, file.ToPos(params.Range.Start, s.encoding)), clampPos(file, file.ToPos(params.Range.End, s.encoding))
+	end = min(end, stopped)
+
+	var scope ast.Node = file.Block
+	if fn := stoppedFunction(file, stopped); fn != nil {
+		scope = fn
+	}
+	values := []any{}
+	visit := func(node ast.Node) bool {
+		if node.End() <= start || node.Pos() >= end {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.FunctionExpression, *ast.FunctionStatement:
+			// Nested functions run in frames of their own.
+			return false
+		case *ast.IndexExpression:
+			if node.LeftIndexer.Type() != token.COLON && isPlainAccess(node) {
+				values = append(values, InlineValueEvaluatableExpression{
+					Range:      file.ToProtocolRange(ast.Range(node), s.encoding),
+					Expression: file.Text[node.Pos():node.End()],
+				})
+				return false
+			}
+		case *ast.Identifier:
+			sym := info.SymbolOf(node)
+			if sym == nil {
+				return false
+			}
+			rng := file.ToProtocolRange(ast.Range(node), s.encoding)
+			// Upvalues are not variables of the stopped frame, so they are evaluated like globals.
+			if sym.Kind == types.SymbolGlobal || sym.Decl != nil && (sym.Decl.Pos() < scope.Pos() || sym.Decl.Pos() >= scope.End()) {
+				values = append(values, InlineValueEvaluatableExpression{Range: rng, Expression: sym.Name})
+			} else {
+				values = append(values, InlineValueVariableLookup{Range: rng, VariableName: sym.Name, CaseSensitiveLookup: true})
+			}
+		}
+		return true
+	}
+	switch fn := scope.(type) {
+	case *ast.FunctionExpression:
+		ast.WalkSemantic(&fn.Params, visit)
+		ast.WalkSemantic(&fn.Body, visit)
+	case *ast.FunctionStatement:
+		ast.WalkSemantic(&fn.Params, visit)
+		ast.WalkSemantic(&fn.Body, visit)
+	default:
+		ast.WalkSemantic(file.Block, visit)
+	}
+	return values, nil
+}
+
+// stoppedFunction returns the innermost function that contains the given position, or nil if it is at the top level
+// of the file.
+func stoppedFunction(file *ast.File, pos token.Pos) ast.Node {
+	path := ast.GetSemanticNode(file.Block, pos)
+	nodes := append(path.Parents, path.Node)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		switch nodes[i].(type) {
+		case *ast.FunctionExpression, *ast.FunctionStatement:
+			return nodes[i]
+		}
+	}
+	return nil
+}
+
+// isPlainAccess returns whether an index expression only consists of names and literal keys, such as `a.b["c"]`, so
+// that evaluating it cannot call a function.
+func isPlainAccess(node *ast.IndexExpression) bool {
+	plain := true
+	ast.WalkSemantic(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.IndexExpression, *ast.Identifier, *ast.StringLiteral, *ast.NumberLiteral:
+			return true
+		}
+		plain = false
+		return false
+	})
+	return plain
+}
+
+// clampPos returns the given position, or the end of the file if it is past the end.
+func clampPos(file *ast.File, pos token.Pos) token.Pos {
+	if pos == token.InvalidPos {
+		return len(file.Text)
+	}
+	return pos
+}
@@ -0,0 +1,106 @@
+package lsp
+
+import (
+	"fmt"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/lexer"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// MethodAST returns the syntax tree of a document, for displaying it in the editor and for reporting parser bugs.
+const MethodAST = "luapls/ast"
+
+// MethodTokens returns the tokens that the lexer produces for a document, excluding whitespace.
+const MethodTokens = "luapls/tokens"
+
+type InspectParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	// If omitted, the whole document is inspected.
+	Range *protocol.Range `json:"range,omitempty"`
+}
+
+type ASTResult struct {
+	// Node is the innermost node that contains the range. The ranges in the tree are byte offsets in the document.
+	Node  ast.Node       `json:"node"`
+	Range protocol.Range `json:"range"`
+}
+
+type TokenInfo struct {
+	Type    string         `json:"type"`
+	Literal string         `json:"literal"`
+	Range   protocol.Range `json:"range"`
+}
+
+type TokensResult struct {
+	Tokens []TokenInfo `json:"tokens"`
+}
+
+func (s *Server) inspectAST(ctx *glsp.Context, params *InspectParams) (any, error) {
+	file, err := s.inspectedFile(params)
+	if err != nil {
+		return nil, err
+	}
+	var node ast.Node = file.Block
+	if params.Range != nil {
+		start, end := s.inspectedRange(file, *params.Range)
+		ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+			if n.Pos() <= start && end <= n.End() {
+				node = n
+				return true
+			}
+			return false
+		})
+	}
+	return ASTResult{Node: node, Range: file.ToProtocolRange(ast.Range(node), s.encoding)}, nil
+}
+
+func (s *Server) inspectTokens(ctx *glsp.Context, params *InspectParams) (any, error) {
+	file, err := s.inspectedFile(params)
+	if err != nil {
+		return nil, err
+	}
+	start, end := 0, len(file.Text)
+	if params.Range != nil {
+		start, end = s.inspectedRange(file, *params.Range)
+	}
+	tokens, _ := lexer.Run(file.Text)
+	result := TokensResult{Tokens: []TokenInfo{}}
+	for _, tok := range tokens {
+		// An empty range selects the tokens that touch it.
+		overlaps := tok.Pos < end && start < tok.End() || start == end && tok.Pos <= start && start <= tok.End()
+		if tok.Type == token.WHITESPACE || tok.Type == token.EOF || !overlaps {
+			continue
+		}
+		result.Tokens = append(result.Tokens, TokenInfo{
+			Type:    tok.Type.String(),
+			Literal: tok.Literal,
+			Range:   file.ToProtocolRange(token.Range{Start: tok.Pos, End: tok.End()}, s.encoding),
+		})
+	}
+	return result, nil
+}
+
+// inspectedFile returns the document that an inspection request is for.
+func (s *Server) inspectedFile(params *InspectParams) (*ast.File, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, fmt.Errorf("'%s' is not part of the workspace", params.TextDocument.URI)
+	}
+	return file, nil
+}
+
+// inspectedRange returns the positions of an inspected range. Positions past the end of the document are moved to
+// its end.
+func (s *Server) inspectedRange(file *ast.File, rng protocol.Range) (token.Pos, token.Pos) {
+	start, end := file.ToPos(rng.Start, s.encoding), file.ToPos(rng.End, s.encoding)
+	if start == token.InvalidPos {
+		start = len(file.Text)
+	}
+	if end == token.InvalidPos || end < start {
+		end = len(file.Text)
+	}
+	return start, end
+}
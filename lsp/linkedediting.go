@@ -0,0 +1,29 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// textDocumentLinkedEditingRange returns the declaration and every reference of the local at the given position, so
+// that they are edited together. Globals may be referenced from other files, so they are left to rename.
+func (s *Server) textDocumentLinkedEditingRange(ctx *glsp.Context, params *protocol.LinkedEditingRangeParams) (*protocol.LinkedEditingRanges, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	_, sym := s.identAt(file, s.getInfo(file.URI), params.Position)
+	if sym == nil || sym.Kind == types.SymbolGlobal || sym.Decl == nil {
+		return nil, nil
+	}
+	ranges := []protocol.Range{file.ToProtocolRange(ast.Range(sym.Decl), s.encoding)}
+	for _, ref := range sym.Refs {
+		ranges = append(ranges, file.ToProtocolRange(ast.Range(ref.Ident), s.encoding))
+	}
+	return &protocol.LinkedEditingRanges{Ranges: ranges, WordPattern: util.Ptr(strings.Trim(identifierPattern.String(), "^$"))}, nil
+}
@@ -0,0 +1,148 @@
+package lsp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// luarcFileName is the name of lua-language-server's configuration file, which a subset of the settings is read from
+// so that projects that use both servers do not need to configure them twice.
+const luarcFileName = ".luarc.json"
+
+// readLuarc returns the settings of the `.luarc.json` file at the root of the workspace that luapls understands,
+// converted to luapls settings, along with the path of the file. The settings are nil if there is no such file.
+// Settings may be nested, such as `{"runtime": {"version": "Lua 5.4"}}`, or dotted, such as
+// `{"runtime.version": "Lua 5.4"}`. Like lua-language-server, the file may contain comments and trailing commas.
+func readLuarc(root string) (map[string]any, string, error) {
+	path := filepath.Join(root, luarcFileName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, path, err
+	}
+	var luarc map[string]any
+	if err := json.Unmarshal(stripJSONC(data), &luarc); err != nil {
+		return nil, path, err
+	}
+	settings := map[string]any{}
+	if version, ok := luarcSetting(luarc, "runtime.version").(string); ok {
+		// Versions are written as `Lua 5.4` or `LuaJIT`.
+		settings["luaVersion"] = strings.ToLower(strings.TrimPrefix(version, "Lua "))
+	}
+	if library := luarcStrings(luarc, "workspace.library"); library != nil {
+		paths := []any{}
+		for _, dir := range library {
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(root, dir)
+			}
+			paths = append(paths, dir)
+		}
+		settings["library"] = paths
+	}
+	if ignore := luarcStrings(luarc, "workspace.ignoreDir"); ignore != nil {
+		// Both servers write these patterns in the syntax of `.gitignore` files.
+		patterns := []any{}
+		for _, pattern := range ignore {
+			patterns = append(patterns, pattern)
+		}
+		settings["ignore"] = patterns
+	}
+	if globals := luarcStrings(luarc, "diagnostics.globals"); globals != nil {
+		names := map[string]any{}
+		for _, name := range globals {
+			names[name] = ""
+		}
+		settings["globals"] = names
+	}
+	if disable := luarcStrings(luarc, "diagnostics.disable"); disable != nil {
+		// Rules that luapls shares with lua-language-server have the same names.
+		severity := map[string]any{}
+		for _, rule := range disable {
+			severity[rule] = "off"
+		}
+		settings["severity"] = severity
+	}
+	return settings, path, nil
+}
+
+// luarcSetting returns the value of a setting of a `.luarc.json` file, given its dotted name, or nil if it is not set.
+func luarcSetting(luarc map[string]any, name string) any {
+	if value, ok := luarc[name]; ok {
+		return value
+	}
+	section, rest, ok := strings.Cut(name, ".")
+	if !ok {
+		return nil
+	}
+	nested, ok := luarc[section].(map[string]any)
+	if !ok {
+		return nil
+	}
+	return luarcSetting(nested, rest)
+}
+
+// luarcStrings returns the value of a setting of a `.luarc.json` file that is a list of strings. Values that are not
+// strings are skipped.
+func luarcStrings(luarc map[string]any, name string) []string {
+	list, ok := luarcSetting(luarc, name).([]any)
+	if !ok {
+		return nil
+	}
+	strs := []string{}
+	for _, value := range list {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+// stripJSONC returns JSON with comments, with the comments and trailing commas replaced by spaces. Line breaks are
+// kept, so that the positions of syntax errors are unchanged.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	// comma is the position of the last comma outside of a string that only whitespace and comments follow, or -1.
+	comma := -1
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			comma = -1
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := i + 2
+			for end < len(out) && !(out[end] == '*' && end+1 < len(out) && out[end+1] == '/') {
+				end++
+			}
+			end = min(end+2, len(out))
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		case c == ',':
+			comma = i
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				out[comma] = ' '
+			}
+			comma = -1
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			comma = -1
+		}
+	}
+	return out
+}
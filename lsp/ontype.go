@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/lua/lexer"
+	"github.com/raiguard/luapls/lua/parser"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// textDocumentOnTypeFormatting indents the line that was started by typing a newline, and closes the block that the
+// previous line opened with an `end` if it is not closed yet.
+func (s *Server) textDocumentOnTypeFormatting(ctx *glsp.Context, params *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
+	if params.Ch != "\n" {
+		return nil, nil
+	}
+	text, err := s.sourceOf(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(text, "\n")
+	current := int(params.Position.Line)
+	if current == 0 || current >= len(lines) {
+		return nil, nil
+	}
+	// Blank lines do not affect the indentation of the lines after them.
+	previous := current - 1
+	for previous > 0 && strings.TrimSpace(lines[previous]) == "" {
+		previous--
+	}
+	unit := s.formatOptions(params.Options).Indent
+	baseIndent := leadingSpace(lines[previous])
+	opens, needsEnd := opensBlock(lines[previous])
+	indent := baseIndent
+	if opens {
+		indent += unit
+	}
+	line := strings.TrimRight(lines[current], "\r")
+	if closesBlock(line) {
+		indent = strings.TrimSuffix(indent, unit)
+	}
+
+	edits := []protocol.TextEdit{}
+	if existing := leadingSpace(line); existing != indent {
+		edits = append(edits, protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: protocol.UInteger(current)},
+				End:   protocol.Position{Line: protocol.UInteger(current), Character: protocol.UInteger(len(existing))},
+			},
+			NewText: indent,
+		})
+	}
+	if needsEnd && !closesBlock(line) {
+		// The block is only closed if doing so resolves a syntax error, since the `end` may already be further down.
+		closing := "\n" + baseIndent + "end"
+		lineEnd := protocol.Position{Line: protocol.UInteger(current), Character: protocol.UInteger(token.ColumnLength(line, s.encoding))}
+		offset := len(strings.Join(lines[:current], "\n")) + 1 + len(line)
+		if syntaxErrors(text[:offset]+closing+text[offset:]) < syntaxErrors(text) {
+			edits = append(edits, protocol.TextEdit{Range: protocol.Range{Start: lineEnd, End: lineEnd}, NewText: closing})
+		}
+	}
+	return edits, nil
+}
+
+// opensBlock returns whether a line ends by opening a block or a bracket, which the next line is indented within,
+// and whether the block is closed with `end`.
+func opensBlock(line string) (bool, bool) {
+	tokens := significantTokens(line)
+	if len(tokens) == 0 {
+		return false, false
+	}
+	switch tokens[len(tokens)-1].Type {
+	case token.THEN, token.DO:
+		return true, true
+	case token.ELSE, token.REPEAT, token.LBRACE, token.LPAREN:
+		return true, false
+	case token.RPAREN:
+		if endsWithFunctionHeader(tokens) {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// endsWithFunctionHeader returns whether the tokens end with the name and parameter list of a function, so that its
+// body starts on the next line. Functions that are closed on the same line, such as `print(function() end)`, do not.
+func endsWithFunctionHeader(tokens []token.Token) bool {
+	i := len(tokens) - 2
+	for i >= 0 && tokens[i].Type != token.LPAREN {
+		switch tokens[i].Type {
+		case token.IDENT, token.COMMA, token.VARARG:
+			i--
+		default:
+			return false
+		}
+	}
+	for i--; i >= 0; i-- {
+		switch tokens[i].Type {
+		case token.FUNCTION:
+			return true
+		case token.IDENT, token.DOT, token.COLON:
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// closesBlock returns whether a line starts by closing a block or a bracket, which is not indented within it.
+func closesBlock(line string) bool {
+	tokens := significantTokens(line)
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[0].Type {
+	case token.END, token.ELSE, token.ELSEIF, token.UNTIL, token.RBRACE, token.RPAREN:
+		return true
+	}
+	return false
+}
+
+// significantTokens returns the tokens of a line, excluding whitespace and comments.
+func significantTokens(line string) []token.Token {
+	lexed, _ := lexer.Run(line)
+	tokens := []token.Token{}
+	for _, tok := range lexed {
+		if tok.Type != token.WHITESPACE && tok.Type != token.COMMENT && tok.Type != token.EOF {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+func syntaxErrors(text string) int {
+	return len(parser.New(text).ParseFile().Diagnostics)
+}
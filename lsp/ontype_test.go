@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpensBlock(t *testing.T) {
+	tests := []struct {
+		line            string
+		opens, needsEnd bool
+	}{
+		{"if x then", true, true},
+		{"for i = 1, 10 do", true, true},
+		{"repeat", true, false},
+		{"local t = {", true, false},
+		{"print(", true, false},
+		{"function M.run(a, b)", true, true},
+		{"function M:stop()", true, true},
+		{"local function f(...)", true, true},
+		{"local f = function(a, ...)", true, true},
+		{"foo(function(x)", true, true},
+		{"foo(function(x) -- comment", true, true},
+		{"print(function() end)", false, false},
+		{"foo(function(x) return x end, bar)", false, false},
+		{"local f = function() end", false, false},
+		{"print(f(x))", false, false},
+		{"if (x) then return end", false, false},
+		{"x = 1", false, false},
+		{"", false, false},
+	}
+	for _, test := range tests {
+		opens, needsEnd := opensBlock(test.line)
+		assert.Equal(t, test.opens, opens, test.line)
+		assert.Equal(t, test.needsEnd, needsEnd, test.line)
+	}
+}
+
+func TestClosesBlock(t *testing.T) {
+	for line, want := range map[string]bool{
+		"end":           true,
+		"  else":        true,
+		"elseif x then": true,
+		"until done":    true,
+		"})":            true,
+		"print(x)":      false,
+		"":              false,
+	} {
+		assert.Equal(t, want, closesBlock(line), line)
+	}
+}
+
+func TestOnTypeFormattingClosures(t *testing.T) {
+	tests := []struct {
+		text string
+		want []protocol.TextEdit
+	}{
+		{"print(function() end)\n", []protocol.TextEdit{}},
+		{"local f = function(a)\n", []protocol.TextEdit{
+			{Range: rangeOf(1, 0, 1, 0), NewText: "\t"},
+			{Range: rangeOf(1, 0, 1, 0), NewText: "\nend"},
+		}},
+	}
+	for _, test := range tests {
+		uri := "file:///main.lua"
+		s := openFile(t, uri, test.text)
+		params := &protocol.DocumentOnTypeFormattingParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: 1},
+			},
+			Ch:      "\n",
+			Options: protocol.FormattingOptions{},
+		}
+		edits, err := s.textDocumentOnTypeFormatting(testContext(t, params, nil), params)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, edits, test.text)
+	}
+}
@@ -0,0 +1,114 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/lexer"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+)
+
+// requireStatement is a `local name = require("module")` statement.
+type requireStatement struct {
+	name     string
+	module   string
+	text     string
+	external bool // Whether the module is outside of the workspace.
+	unused   bool
+}
+
+// organizeRequiresFix returns a fix that sorts the requires at the top of the file by module name, with the modules
+// of libraries grouped before the modules of the workspace, and removes requires of the same module into the same
+// name. Unused requires are removed as well if requested. Nothing is offered if the requires are already organized,
+// or if they cannot be reordered without losing comments or changing what a name refers to.
+func organizeRequiresFix(env *types.Environment, file *ast.File, info *types.Info, text string, removeUnused bool) (ast.Fix, bool) {
+	requires := []requireStatement{}
+	var rng token.Range
+	for _, pair := range file.Block.Pairs {
+		req, ok := requireStatementOf(env, info, text, pair.Node)
+		if !ok {
+			break
+		}
+		if len(requires) == 0 {
+			rng.Start = pair.Node.Pos()
+		}
+		rng.End = pair.Node.End()
+		requires = append(requires, req)
+	}
+	if len(requires) == 0 {
+		return ast.Fix{}, false
+	}
+	tokens, _ := lexer.Run(text[rng.Start:rng.End])
+	for _, tok := range tokens {
+		if tok.Type == token.COMMENT {
+			return ast.Fix{}, false
+		}
+	}
+
+	// A repeated require of the same module is removed, and the references to it then refer to the first one.
+	unique := []requireStatement{}
+	byName := map[string]int{}
+	for _, req := range requires {
+		if i, ok := byName[req.name]; ok {
+			if unique[i].module != req.module {
+				return ast.Fix{}, false
+			}
+			unique[i].unused = unique[i].unused && req.unused
+			continue
+		}
+		byName[req.name] = len(unique)
+		unique = append(unique, req)
+	}
+	organized := []requireStatement{}
+	for _, req := range unique {
+		if !removeUnused || !req.unused {
+			organized = append(organized, req)
+		}
+	}
+	sort.SliceStable(organized, func(i, j int) bool {
+		if organized[i].external != organized[j].external {
+			return organized[i].external
+		}
+		return organized[i].module < organized[j].module
+	})
+	lines := []string{}
+	for i, req := range organized {
+		if i > 0 && req.external != organized[i-1].external {
+			lines = append(lines, "")
+		}
+		lines = append(lines, req.text)
+	}
+	newText := strings.Join(lines, "\n")
+	if newText == text[rng.Start:rng.End] {
+		return ast.Fix{}, false
+	}
+	return ast.Fix{Title: "Organize requires", Edits: []ast.Edit{{Range: rng, NewText: newText}}}, true
+}
+
+// requireStatementOf returns the require that the statement declares, if it is a local that holds only a required
+// module. Modules whose names are not string literals may depend on earlier statements, so they are not included.
+func requireStatementOf(env *types.Environment, info *types.Info, text string, stmt ast.Statement) (requireStatement, bool) {
+	local, ok := stmt.(*ast.LocalStatement)
+	if !ok || len(local.Names.Pairs) != 1 || local.Exps == nil || len(local.Exps.Pairs) != 1 {
+		return requireStatement{}, false
+	}
+	fc, ok := local.Exps.Pairs[0].Node.(*ast.FunctionCall)
+	if !ok {
+		return requireStatement{}, false
+	}
+	module, arg, ok := types.RequireName(fc, info)
+	if _, literal := arg.(*ast.StringLiteral); !ok || !literal {
+		return requireStatement{}, false
+	}
+	ident := local.Names.Pairs[0].Node
+	req := requireStatement{name: ident.Token.Literal, module: module, text: text[stmt.Pos():stmt.End()]}
+	if target, ok := env.ResolveModule(module); !ok || !env.Owns(target) {
+		req.external = true
+	}
+	if sym := info.SymbolOf(ident); sym != nil && len(sym.Refs) == 0 {
+		req.unused = true
+	}
+	return req, true
+}
@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// progress reports the progress of long-running work to the client. A nil progress reports nothing.
+type progress struct {
+	ctx        *glsp.Context
+	token      protocol.ProgressToken
+	percentage protocol.UInteger
+}
+
+// workDoneToken returns the progress token that the client sent with a request for reporting the progress of its
+// work, if any.
+func workDoneToken(ctx *glsp.Context) *protocol.ProgressToken {
+	return requestToken(ctx, "workDoneToken")
+}
+
+// partialResultToken returns the progress token that the client sent with a request for receiving partial results,
+// if any.
+func partialResultToken(ctx *glsp.Context) *protocol.ProgressToken {
+	return requestToken(ctx, "partialResultToken")
+}
+
+// requestToken returns the progress token in the given field of the request's parameters. The protocol package drops
+// the value of tokens when it decodes them, so they are decoded from the raw parameters instead.
+func requestToken(ctx *glsp.Context, field string) *protocol.ProgressToken {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(ctx.Params, &params); err != nil {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(params[field], &value); err != nil || value == nil {
+		return nil
+	}
+	return &protocol.ProgressToken{Value: value}
+}
+
+// beginProgress starts reporting progress with the given token, or returns nil if there is no token.
+func beginProgress(ctx *glsp.Context, token *protocol.ProgressToken, title string) *progress {
+	if token == nil {
+		return nil
+	}
+	p := &progress{ctx: ctx, token: *token}
+	p.notify(protocol.WorkDoneProgressBegin{Kind: "begin", Title: title, Percentage: util.Ptr(protocol.UInteger(0))})
+	return p
+}
+
+// report reports that done out of total steps are complete. Reports that would not change the percentage are
+// skipped, so that large workspaces do not flood the client with notifications.
+func (p *progress) report(message string, done int, total int) {
+	if p == nil || total == 0 {
+		return
+	}
+	percentage := protocol.UInteger(done * 100 / total)
+	if percentage == p.percentage && done != total {
+		return
+	}
+	p.percentage = percentage
+	p.notify(protocol.WorkDoneProgressReport{Kind: "report", Message: &message, Percentage: &percentage})
+}
+
+func (p *progress) end() {
+	if p == nil {
+		return
+	}
+	p.notify(protocol.WorkDoneProgressEnd{Kind: "end"})
+}
+
+func (p *progress) notify(value any) {
+	p.ctx.Notify(protocol.MethodProgress, protocol.ProgressParams{Token: p.token, Value: value})
+}
+
+// withProgress runs work while reporting its progress with the given token, and shows the server as busy until it is
+// done. Without a token, one is created first if the client supports progress created by the server, and the work runs
+// once the client has acknowledged it.
+func (s *Server) withProgress(ctx *glsp.Context, token *protocol.ProgressToken, title string, work func(p *progress)) {
+	if token != nil || !s.workDoneProgress {
+		s.setStatus(ctx, StatusIndexing, title)
+		p := beginProgress(ctx, token, title)
+		work(p)
+		p.end()
+		s.idle(ctx)
+		return
+	}
+	s.progressID++
+	token = &protocol.ProgressToken{Value: fmt.Sprintf("luapls-%d", s.progressID)}
+	// Messages are handled one at a time, so the client's response can only be read after this handler returns.
+	go func() {
+		ctx.Call(protocol.ServerWindowWorkDoneProgressCreate, protocol.WorkDoneProgressCreateParams{Token: *token}, new(any))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.setStatus(ctx, StatusIndexing, title)
+		p := beginProgress(ctx, token, title)
+		work(p)
+		p.end()
+		s.idle(ctx)
+	}()
+}
+
+// reportIndexing reports the progress of parsing the files of the environment while it is indexed.
+func reportIndexing(env *types.Environment, p *progress) {
+	if p == nil {
+		env.Progress = nil
+		return
+	}
+	env.Progress = func(done int, total int) {
+		p.report(fmt.Sprintf("Indexing %d/%d files", done, total), done, total)
+	}
+}
@@ -0,0 +1,133 @@
+package lsp
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Pull diagnostics were added in LSP 3.17, which the protocol package does not support yet, so the types are
+// declared here.
+
+const (
+	MethodTextDocumentDiagnostic = "textDocument/diagnostic"
+	MethodWorkspaceDiagnostic    = "workspace/diagnostic"
+)
+
+type DiagnosticOptions struct {
+	Identifier            *string `json:"identifier,omitempty"`
+	InterFileDependencies bool    `json:"interFileDependencies"`
+	WorkspaceDiagnostics  bool    `json:"workspaceDiagnostics"`
+}
+
+type DocumentDiagnosticParams struct {
+	TextDocument     protocol.TextDocumentIdentifier `json:"textDocument"`
+	Identifier       *string                         `json:"identifier,omitempty"`
+	PreviousResultID *string                         `json:"previousResultId,omitempty"`
+}
+
+type WorkspaceDiagnosticParams struct {
+	Identifier        *string            `json:"identifier,omitempty"`
+	PreviousResultIDs []PreviousResultID `json:"previousResultIds"`
+}
+
+type PreviousResultID struct {
+	URI   protocol.DocumentUri `json:"uri"`
+	Value string               `json:"value"`
+}
+
+// DocumentDiagnosticReport is either a full report, with every diagnostic in the file, or a report that the
+// diagnostics are unchanged since the result with the given ID, in which case Items is omitted.
+type DocumentDiagnosticReport struct {
+	Kind     string                `json:"kind"`
+	ResultID string                `json:"resultId"`
+	Items    []protocol.Diagnostic `json:"items,omitempty"`
+}
+
+type WorkspaceDocumentDiagnosticReport struct {
+	DocumentDiagnosticReport
+	URI     protocol.DocumentUri `json:"uri"`
+	Version *protocol.Integer    `json:"version"`
+}
+
+type WorkspaceDiagnosticReport struct {
+	Items []WorkspaceDocumentDiagnosticReport `json:"items"`
+}
+
+const (
+	DocumentDiagnosticReportKindFull      = "full"
+	DocumentDiagnosticReportKindUnchanged = "unchanged"
+)
+
+// serverCapabilities extends the capabilities of the protocol package with those from newer versions of the protocol.
+type serverCapabilities struct {
+	protocol.ServerCapabilities
+	PositionEncoding      token.PositionEncoding `json:"positionEncoding,omitempty"`
+	DiagnosticProvider    *DiagnosticOptions     `json:"diagnosticProvider,omitempty"`
+	TypeHierarchyProvider bool                   `json:"typeHierarchyProvider,omitempty"`
+	InlineValueProvider   bool                   `json:"inlineValueProvider,omitempty"`
+}
+
+type initializeResult struct {
+	Capabilities serverCapabilities                   `json:"capabilities"`
+	ServerInfo   *protocol.InitializeResultServerInfo `json:"serverInfo,omitempty"`
+}
+
+// pullDiagnostics returns whether diagnostics are requested by the client rather than published by the server.
+func (s *Server) pullDiagnostics() bool {
+	return s.config.PullDiagnostics != nil && *s.config.PullDiagnostics
+}
+
+func (s *Server) textDocumentDiagnostic(ctx *glsp.Context, params *DocumentDiagnosticParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return DocumentDiagnosticReport{Kind: DocumentDiagnosticReportKindFull, Items: []protocol.Diagnostic{}}, nil
+	}
+	previous := ""
+	if params.PreviousResultID != nil {
+		previous = *params.PreviousResultID
+	}
+	return s.diagnosticReport(file, previous), nil
+}
+
+func (s *Server) workspaceDiagnostic(ctx *glsp.Context, params *WorkspaceDiagnosticParams) (any, error) {
+	previous := map[protocol.DocumentUri]string{}
+	for _, id := range params.PreviousResultIDs {
+		previous[util.NormalizeURI(id.URI)] = id.Value
+	}
+	report := WorkspaceDiagnosticReport{Items: []WorkspaceDocumentDiagnosticReport{}}
+	for _, env := range s.allEnvironments() {
+		for uri, file := range env.Files {
+			if env.Owns(uri) && s.environmentOf(uri) == env {
+				report.Items = append(report.Items, WorkspaceDocumentDiagnosticReport{
+					DocumentDiagnosticReport: s.diagnosticReport(file, previous[uri]),
+					URI:                      uri,
+				})
+			}
+		}
+	}
+	sort.Slice(report.Items, func(i, j int) bool { return report.Items[i].URI < report.Items[j].URI })
+	return report, nil
+}
+
+// diagnosticReport returns the diagnostics of the file, or an unchanged report if they are the same as those of the
+// previous result. Result IDs are derived from the diagnostics themselves, so no results need to be remembered.
+func (s *Server) diagnosticReport(file *ast.File, previousResultID string) DocumentDiagnosticReport {
+	items := []protocol.Diagnostic{}
+	for _, err := range s.fileDiagnostics(s.environmentOf(file.URI), file) {
+		items = append(items, s.toProtocolDiagnostic(file, err))
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(toJSON(items)))
+	resultID := fmt.Sprintf("%x", hash.Sum64())
+	if resultID == previousResultID {
+		return DocumentDiagnosticReport{Kind: DocumentDiagnosticReportKindUnchanged, ResultID: resultID}
+	}
+	return DocumentDiagnosticReport{Kind: DocumentDiagnosticReportKindFull, ResultID: resultID, Items: items}
+}
@@ -0,0 +1,206 @@
+package lsp
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// partialResultBatch is the number of locations or symbols that are sent in each partial result.
+const partialResultBatch = 100
+
+// referenceTarget is the local, global, or field that references are found for.
+type referenceTarget struct {
+	name   string
+	global string             // The dotted path of a global or a field of a global.
+	field  *types.NameAndType // A field of a class or table.
+	owner  types.Type         // The type that the field was looked up in.
+	symbol *types.Symbol      // A local or parameter.
+}
+
+// referenceTargetAt returns the target of the identifier at the given position, or nil if there is none.
+func (s *Server) referenceTargetAt(file *ast.File, info *types.Info, position protocol.Position) *referenceTarget {
+	ident, sym := s.identAt(file, info, position)
+	if ident == nil {
+		return nil
+	}
+	// Globals and their fields are found through the global index, which spans every file.
+	if path, ok := s.globalPathAt(file, info, position); ok {
+		return &referenceTarget{name: ident.Token.Literal, global: path}
+	}
+	nodePath := ast.GetSemanticNode(file.Block, file.ToPos(position, s.encoding))
+	if owner, field := types.FieldAt(info, nodePath); field != nil {
+		if field.Loc.URI == "" {
+			return nil
+		}
+		return &referenceTarget{name: field.Name, field: field, owner: owner}
+	}
+	if sym == nil || sym.Kind == types.SymbolGlobal {
+		return nil
+	}
+	return &referenceTarget{name: sym.Name, symbol: sym}
+}
+
+// locations returns every location that refers to the target in the given environment, sorted by file and position.
+// Every assignment of a global is a declaration of it.
+func (t *referenceTarget) locations(env *types.Environment, uri protocol.URI, includeDeclaration bool) []types.Location {
+	locations := []types.Location{}
+	t.search(env, uri, includeDeclaration, func(found []types.Location) {
+		locations = append(locations, found...)
+	})
+	sortLocations(locations)
+	return locations
+}
+
+// search calls found with the locations that refer to the target in the given environment as they are found, one
+// file at a time. The locations of each file are sorted by position.
+func (t *referenceTarget) search(env *types.Environment, uri protocol.URI, includeDeclaration bool, found func([]types.Location)) {
+	switch {
+	case t.global != "":
+		// The global index already knows every site, so they only need to be grouped by file.
+		sites := env.Globals.Reads(t.global)
+		if includeDeclaration {
+			sites = append(env.Globals.Defs(t.global), sites...)
+		}
+		locations := []types.Location{}
+		for _, site := range sites {
+			locations = append(locations, types.Location{URI: site.URI, Range: site.Range})
+		}
+		sortLocations(locations)
+		for len(locations) > 0 {
+			end := 1
+			for end < len(locations) && locations[end].URI == locations[0].URI {
+				end++
+			}
+			found(locations[:end])
+			locations = locations[end:]
+		}
+	case t.field != nil:
+		declared := false
+		uris := []protocol.URI{}
+		for uri := range env.Files {
+			uris = append(uris, uri)
+		}
+		sort.Strings(uris)
+		for _, uri := range uris {
+			locations := []types.Location{}
+			for _, ref := range env.FileFieldReferences(uri, t.field.Name, t.field.Loc) {
+				if ref.Location == t.field.Loc {
+					declared = true
+					if !includeDeclaration {
+						continue
+					}
+				}
+				locations = append(locations, ref.Location)
+			}
+			if len(locations) > 0 {
+				sortLocations(locations)
+				found(locations)
+			}
+		}
+		// Fields declared by a `---@field` annotation are not part of the code.
+		if includeDeclaration && !declared {
+			found([]types.Location{t.field.Loc})
+		}
+	case t.symbol != nil:
+		locations := []types.Location{}
+		if includeDeclaration && t.symbol.Decl != nil {
+			locations = append(locations, types.Location{URI: uri, Range: ast.Range(t.symbol.Decl)})
+		}
+		for _, ref := range t.symbol.Refs {
+			locations = append(locations, types.Location{URI: uri, Range: ast.Range(ref.Ident)})
+		}
+		if len(locations) > 0 {
+			sortLocations(locations)
+			found(locations)
+		}
+	}
+}
+
+// sortLocations sorts locations by file and position.
+func sortLocations(locations []types.Location) {
+	sort.SliceStable(locations, func(i, j int) bool {
+		if locations[i].URI != locations[j].URI {
+			return locations[i].URI < locations[j].URI
+		}
+		return locations[i].Range.Start < locations[j].Range.Start
+	})
+}
+
+func (s *Server) textDocumentReferences(ctx *glsp.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, nil
+	}
+	if file.Block == nil {
+		return nil, errors.New("Attempted to find references in a file with no AST")
+	}
+	target := s.referenceTargetAt(file, s.getInfo(file.URI), params.Position)
+	if target == nil {
+		return nil, nil
+	}
+	results := newPartialResults[protocol.Location](ctx)
+	target.search(s.environmentOf(file.URI), file.URI, params.Context.IncludeDeclaration, func(found []types.Location) {
+		for _, loc := range found {
+			if location := s.typesLocation(loc); location != nil {
+				results.add(*location)
+			}
+		}
+		results.flush()
+	})
+	return results.result(), nil
+}
+
+// typesLocation converts a location from the type checker into a protocol location.
+func (s *Server) typesLocation(loc types.Location) *protocol.Location {
+	file := s.getFile(loc.URI)
+	if file == nil {
+		return nil
+	}
+	return &protocol.Location{URI: loc.URI, Range: file.ToProtocolRange(loc.Range, s.encoding)}
+}
+
+// partialResults collects the results of a request. If the client asked for partial results, they are sent to it in
+// batches while they are still being found, once there are enough of them to be worth streaming.
+type partialResults[T any] struct {
+	ctx     *glsp.Context
+	token   *protocol.ProgressToken
+	pending []T
+	sent    bool // Whether any partial results were sent, after which the response must be empty.
+}
+
+func newPartialResults[T any](ctx *glsp.Context) *partialResults[T] {
+	return &partialResults[T]{ctx: ctx, token: partialResultToken(ctx), pending: []T{}}
+}
+
+func (r *partialResults[T]) add(results ...T) {
+	r.pending = append(r.pending, results...)
+}
+
+// flush sends the pending results if there are at least partialResultBatch of them. It is called whenever a unit of
+// the search finishes, such as a file, so that batches are not split in the middle of one.
+func (r *partialResults[T]) flush() {
+	if r.token == nil || len(r.pending) < partialResultBatch {
+		return
+	}
+	r.send()
+}
+
+func (r *partialResults[T]) send() {
+	r.ctx.Notify(protocol.MethodProgress, protocol.ProgressParams{Token: *r.token, Value: r.pending})
+	r.pending = []T{}
+	r.sent = true
+}
+
+// result returns the results to respond to the request with. If partial results were sent, the remaining ones are
+// sent as well, and the response is empty.
+func (r *partialResults[T]) result() []T {
+	if r.sent && len(r.pending) > 0 {
+		r.send()
+	}
+	return r.pending
+}
@@ -0,0 +1,164 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (s *Server) textDocumentRename(ctx *glsp.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, nil
+	}
+	if file.Block == nil {
+		return nil, errors.New("Attempted to rename in a file with no AST")
+	}
+	newName := params.NewName
+	if _, reserved := token.Reserved[newName]; reserved || !identifierPattern.MatchString(newName) {
+		return nil, fmt.Errorf("'%s' is not a valid identifier", newName)
+	}
+	target := s.referenceTargetAt(file, s.getInfo(file.URI), params.Position)
+	if target == nil {
+		return nil, errors.New("There is nothing to rename here")
+	}
+	if newName == target.name {
+		return &protocol.WorkspaceEdit{Changes: map[protocol.DocumentUri][]protocol.TextEdit{}}, nil
+	}
+	env := s.environmentOf(file.URI)
+	if err := s.checkRenameCollision(env, file.URI, target, newName); err != nil {
+		return nil, err
+	}
+
+	locations, err := s.renameLocations(env, file.URI, target)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[protocol.DocumentUri][]protocol.TextEdit{}
+	for _, loc := range locations {
+		location := s.typesLocation(renameRange(loc, target.name))
+		if location == nil {
+			continue
+		}
+		changes[location.URI] = append(changes[location.URI], protocol.TextEdit{Range: location.Range, NewText: newName})
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
+
+func (s *Server) textDocumentPrepareRename(ctx *glsp.Context, params *protocol.PrepareRenameParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	nodePath := ast.GetSemanticNode(file.Block, file.ToPos(params.Position, s.encoding))
+	ident, ok := nodePath.Node.(*ast.Identifier)
+	if !ok {
+		switch nodePath.Node.(type) {
+		case *ast.BooleanLiteral, *ast.NilLiteral, *ast.NumberLiteral, *ast.StringLiteral, *ast.Vararg:
+			return nil, errors.New("Literals cannot be renamed")
+		}
+		return nil, errors.New("Only variables and fields can be renamed")
+	}
+	target := s.referenceTargetAt(file, s.getInfo(file.URI), params.Position)
+	if target == nil {
+		return nil, fmt.Errorf("'%s' cannot be renamed", ident.Token.Literal)
+	}
+	if _, err := s.renameLocations(s.environmentOf(file.URI), file.URI, target); err != nil {
+		return nil, err
+	}
+	return protocol.RangeWithPlaceholder{
+		Range:       file.ToProtocolRange(ast.Range(ident), s.encoding),
+		Placeholder: ident.Token.Literal,
+	}, nil
+}
+
+// renameLocations returns every location that a rename of the target must edit, or an error if any of them are in
+// a file that must not be edited.
+func (s *Server) renameLocations(env *types.Environment, uri protocol.URI, target *referenceTarget) ([]types.Location, error) {
+	// The implicit `self` parameter of methods has no declaration to rename.
+	if target.symbol != nil && target.symbol.Decl == nil {
+		return nil, fmt.Errorf("'%s' cannot be renamed", target.name)
+	}
+	locations := target.locations(env, uri, true)
+	for _, loc := range locations {
+		if s.isReadOnly(loc.URI) {
+			return nil, fmt.Errorf("Cannot rename '%s' because it is defined in a library", target.name)
+		}
+	}
+	return locations, nil
+}
+
+// checkRenameCollision returns an error if renaming the target to the new name would collide with an existing
+// variable or field, changing what some of the references refer to.
+func (s *Server) checkRenameCollision(env *types.Environment, uri protocol.URI, target *referenceTarget, newName string) error {
+	switch {
+	case target.global != "":
+		path := newName
+		i := strings.LastIndexByte(target.global, '.')
+		nested := i >= 0
+		if nested {
+			path = target.global[:i+1] + newName
+		}
+		if env.Globals.IsDefined(path) {
+			return fmt.Errorf("'%s' is already defined", path)
+		}
+		if nested {
+			return nil
+		}
+		// A local of the new name would shadow the renamed global.
+		for _, loc := range target.locations(env, uri, true) {
+			if info := env.Info[loc.URI]; info != nil && info.Scope.LookupAt(newName, loc.Range.Start) != nil {
+				return fmt.Errorf("'%s' would be shadowed by a local of the same name", target.name)
+			}
+		}
+	case target.field != nil:
+		if types.FieldOf(target.owner, newName) != nil {
+			return fmt.Errorf("'%s' already has a field named '%s'", target.owner, newName)
+		}
+	case target.symbol != nil:
+		info := env.Info[uri]
+		if info == nil {
+			return nil
+		}
+		for _, loc := range target.locations(env, uri, true) {
+			if other := info.Scope.LookupAt(newName, loc.Range.Start); other != nil && other != target.symbol {
+				return fmt.Errorf("'%s' is already defined in this scope", newName)
+			}
+		}
+		// The renamed local would shadow reads of a global of the new name within its scope.
+		scope := target.symbol.Scope.Range()
+		for _, site := range env.Globals.FileReads(uri) {
+			if site.Path == newName && site.Range.Start >= target.symbol.VisibleFrom && scope.ContainsPos(site.Range.Start) {
+				return fmt.Errorf("'%s' would shadow the global of the same name", newName)
+			}
+		}
+	}
+	return nil
+}
+
+// isReadOnly returns whether a file is a definition file or a library file, which renames must not edit.
+func (s *Server) isReadOnly(uri protocol.URI) bool {
+	if info := s.getInfo(uri); info.Meta != nil {
+		return true
+	}
+	return !s.environmentOf(uri).Owns(uri)
+}
+
+// renameRange returns the range of the name at the given location, excluding the quotes of string keys such as
+// `t["name"]`.
+func renameRange(loc types.Location, name string) types.Location {
+	if quotes := (loc.Range.End - loc.Range.Start - len(name)) / 2; quotes > 0 {
+		loc.Range.Start += quotes
+		loc.Range.End -= quotes
+	}
+	return loc
+}
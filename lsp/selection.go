@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"errors"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// textDocumentSelectionRange expands the selection at each cursor through the nodes that contain it, from the
+// innermost node out to the whole file.
+func (s *Server) textDocumentSelectionRange(ctx *glsp.Context, params *protocol.SelectionRangeParams) ([]protocol.SelectionRange, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, errors.New("File not found")
+	}
+	if file.Block == nil {
+		return nil, errors.New("Attempted to get selection ranges for a file that has no AST")
+	}
+	result := make([]protocol.SelectionRange, 0, len(params.Positions))
+	for _, position := range params.Positions {
+		result = append(result, s.selectionRange(file, file.ToPos(position, s.encoding)))
+	}
+	return result, nil
+}
+
+// selectionRange returns the ranges of the nodes that contain the given position, each with the range of the node
+// that contains it as its parent. A position just after an identifier, where the cursor is after typing it, selects
+// the identifier.
+func (s *Server) selectionRange(file *ast.File, pos token.Pos) protocol.SelectionRange {
+	fileRange := file.ToProtocolRange(token.Range{Start: 0, End: len(file.Text)}, s.encoding)
+	if pos == token.InvalidPos {
+		return protocol.SelectionRange{Range: fileRange}
+	}
+	path := ast.GetSemanticNode(file.Block, pos)
+	if _, ok := path.Node.(*ast.Identifier); !ok && pos > 0 {
+		if before := ast.GetSemanticNode(file.Block, pos-1); isIdentifier(before.Node) {
+			path = before
+		}
+	}
+	nodes := append(path.Parents, path.Node)
+	var selection *protocol.SelectionRange
+	add := func(rng protocol.Range) {
+		if selection != nil && selection.Range == rng {
+			return
+		}
+		selection = &protocol.SelectionRange{Range: rng, Parent: selection}
+	}
+	add(fileRange)
+	for _, node := range nodes {
+		if !ast.IsNil(node) {
+			add(file.ToProtocolRange(ast.Range(node), s.encoding))
+		}
+	}
+	return *selection
+}
+
+func isIdentifier(node ast.Node) bool {
+	_, ok := node.(*ast.Identifier)
+	return ok
+}
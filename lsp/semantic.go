@@ -0,0 +1,423 @@
+package lsp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/lexer"
+	"github.com/raiguard/luapls/lua/lint"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// semanticTokenTypes is the legend of token types. Tokens refer to their type by its index in this list.
+var semanticTokenTypes = []protocol.SemanticTokenType{
+	protocol.SemanticTokenTypeKeyword,
+	protocol.SemanticTokenTypeNumber,
+	protocol.SemanticTokenTypeString,
+	protocol.SemanticTokenTypeComment,
+	protocol.SemanticTokenTypeParameter,
+	protocol.SemanticTokenTypeVariable,
+	protocol.SemanticTokenTypeFunction,
+	protocol.SemanticTokenTypeProperty,
+	protocol.SemanticTokenTypeMethod,
+	protocol.SemanticTokenTypeClass,
+	protocol.SemanticTokenTypeEnum,
+	protocol.SemanticTokenTypeOperator,
+}
+
+const (
+	semanticKeyword = iota
+	semanticNumber
+	semanticString
+	semanticComment
+	semanticParameter
+	semanticVariable
+	semanticFunction
+	semanticProperty
+	semanticMethod
+	semanticClass
+	semanticEnum
+	semanticOperator
+)
+
+// semanticTokenModifiers is the legend of token modifiers. Tokens refer to their modifiers by a bit set of their
+// indices in this list. There is no standard modifier for globals, so a custom one is used.
+var semanticTokenModifiers = []protocol.SemanticTokenModifier{
+	protocol.SemanticTokenModifierDeclaration,
+	protocol.SemanticTokenModifierReadonly,
+	protocol.SemanticTokenModifierDefaultLibrary,
+	protocol.SemanticTokenModifierDeprecated,
+	"global",
+}
+
+const (
+	semanticDeclaration = 1 << iota
+	semanticReadonly
+	semanticDefaultLibrary
+	semanticDeprecated
+	semanticGlobal
+)
+
+// semanticToken is a classified range of a file.
+type semanticToken struct {
+	Range     token.Range
+	Type      int
+	Modifiers int
+}
+
+// semanticResult is a set of encoded semantic tokens that was sent to the client.
+type semanticResult struct {
+	id   string
+	data []protocol.UInteger
+}
+
+// semanticTokensLegend returns the legend that is advertised to the client.
+func semanticTokensLegend() protocol.SemanticTokensLegend {
+	legend := protocol.SemanticTokensLegend{TokenTypes: []string{}, TokenModifiers: []string{}}
+	for _, typ := range semanticTokenTypes {
+		legend.TokenTypes = append(legend.TokenTypes, string(typ))
+	}
+	for _, modifier := range semanticTokenModifiers {
+		legend.TokenModifiers = append(legend.TokenModifiers, string(modifier))
+	}
+	return legend
+}
+
+func (s *Server) textDocumentSemanticTokensFull(ctx *glsp.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, nil
+	}
+	if file.Block == nil {
+		return nil, errors.New("Attempted to get semantic tokens for a file with no AST")
+	}
+	tokens, err := s.semanticTokens(file)
+	if err != nil {
+		return nil, err
+	}
+	result := s.saveSemanticResult(file.URI, s.encodeSemanticTokens(file, tokens))
+	return &protocol.SemanticTokens{ResultID: &result.id, Data: result.data}, nil
+}
+
+// textDocumentSemanticTokensFullDelta returns the edits that turn the previous result into the current tokens, or the
+// full tokens if the previous result is no longer known.
+func (s *Server) textDocumentSemanticTokensFullDelta(ctx *glsp.Context, params *protocol.SemanticTokensDeltaParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, nil
+	}
+	if file.Block == nil {
+		return nil, errors.New("Attempted to get semantic tokens for a file with no AST")
+	}
+	tokens, err := s.semanticTokens(file)
+	if err != nil {
+		return nil, err
+	}
+	previous, ok := s.semanticResults[file.URI]
+	result := s.saveSemanticResult(file.URI, s.encodeSemanticTokens(file, tokens))
+	if !ok || previous.id != params.PreviousResultID {
+		return &protocol.SemanticTokens{ResultID: &result.id, Data: result.data}, nil
+	}
+	return &protocol.SemanticTokensDelta{ResultId: &result.id, Edits: diffSemanticTokens(previous.data, result.data)}, nil
+}
+
+// textDocumentSemanticTokensRange returns the tokens that overlap the given range, so that the visible part of a large
+// file can be highlighted before the rest of it.
+func (s *Server) textDocumentSemanticTokensRange(ctx *glsp.Context, params *protocol.SemanticTokensRangeParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, nil
+	}
+	if file.Block == nil {
+		return nil, errors.New("Attempted to get semantic tokens for a file with no AST")
+	}
+	tokens, err := s.semanticTokens(file)
+	if err != nil {
+		return nil, err
+	}
+	start, end := file.ToPos(params.Range.Start, s.encoding), file.ToPos(params.Range.End, s.encoding)
+	if start == token.InvalidPos {
+		start = 0
+	}
+	inRange := []semanticToken{}
+	for _, tok := range tokens {
+		if tok.Range.End > start && (end == token.InvalidPos || tok.Range.Start < end) {
+			inRange = append(inRange, tok)
+		}
+	}
+	return &protocol.SemanticTokens{Data: s.encodeSemanticTokens(file, inRange)}, nil
+}
+
+// saveSemanticResult records the tokens that are about to be sent for a file under a new result ID.
+func (s *Server) saveSemanticResult(uri protocol.URI, data []protocol.UInteger) semanticResult {
+	s.semanticResultID++
+	result := semanticResult{id: strconv.Itoa(s.semanticResultID), data: data}
+	s.semanticResults[uri] = result
+	return result
+}
+
+// diffSemanticTokens returns a single edit that replaces the part of the previous data that differs from the current
+// data. Edits while typing usually only change the tokens around the cursor, so the rest is shared.
+func diffSemanticTokens(previous []protocol.UInteger, current []protocol.UInteger) []protocol.SemanticTokensEdit {
+	prefix := 0
+	for prefix < len(previous) && prefix < len(current) && previous[prefix] == current[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(previous)-prefix && suffix < len(current)-prefix &&
+		previous[len(previous)-1-suffix] == current[len(current)-1-suffix] {
+		suffix++
+	}
+	if prefix == len(previous) && prefix == len(current) {
+		return []protocol.SemanticTokensEdit{}
+	}
+	return []protocol.SemanticTokensEdit{{
+		Start:       protocol.UInteger(prefix),
+		DeleteCount: protocol.UInteger(len(previous) - prefix - suffix),
+		Data:        current[prefix : len(current)-suffix],
+	}}
+}
+
+// semanticTokens classifies the tokens of a file. Keywords and literals are taken from the lexer, while identifiers
+// are classified by what they are bound to, and operators by the expressions that they are the operators of.
+func (s *Server) semanticTokens(file *ast.File) ([]semanticToken, error) {
+	text, err := s.sourceOf(file.URI)
+	if err != nil {
+		return nil, err
+	}
+	identifiers := s.classifyIdentifiers(file)
+	operators := operatorRanges(file)
+	lexed, _ := lexer.Run(text)
+	tokens := []semanticToken{}
+	for _, tok := range lexed {
+		typ := -1
+		switch tok.Type {
+		case token.COMMENT:
+			typ = semanticComment
+		case token.NUMBER:
+			typ = semanticNumber
+		case token.STRING, token.RAWSTRING:
+			typ = semanticString
+		case token.IDENT:
+			if class, ok := identifiers[tok.Pos]; ok {
+				tokens = append(tokens, semanticToken{Range: tok.Range(), Type: class.Type, Modifiers: class.Modifiers})
+				continue
+			}
+		case token.AND, token.BREAK, token.DO, token.ELSE, token.ELSEIF, token.END, token.FALSE, token.FOR,
+			token.FUNCTION, token.GOTO, token.IF, token.IN, token.LOCAL, token.NIL, token.NOT, token.OR, token.REPEAT,
+			token.RETURN, token.THEN, token.TRUE, token.UNTIL, token.WHILE:
+			typ = semanticKeyword
+		default:
+			if rng, ok := operators[tok.Pos]; ok && rng == tok.Range() {
+				typ = semanticOperator
+			}
+		}
+		if typ >= 0 {
+			tokens = append(tokens, semanticToken{Range: tok.Range(), Type: typ})
+		}
+	}
+	return tokens, nil
+}
+
+// classifyIdentifiers returns the token type and modifiers of every identifier in the file that names a variable or
+// a field, by position. The ranges of the returned tokens are not set.
+func (s *Server) classifyIdentifiers(file *ast.File) map[token.Pos]semanticToken {
+	env := s.environmentOf(file.URI)
+	info := s.getInfo(file.URI)
+	classes := map[token.Pos]semanticToken{}
+	fields := map[*ast.Identifier]bool{}
+	// Assigned holds the targets of assignments, which declare globals and fields.
+	assigned := map[ast.Expression]bool{}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignmentStatement:
+			for _, pair := range n.Vars.Pairs {
+				assigned[pair.Node] = true
+			}
+		case *ast.FunctionStatement:
+			assigned[n.Name] = true
+		case *ast.IndexExpression:
+			ident, ok := n.Inner.(*ast.Identifier)
+			if !ok || n.LeftIndexer.Type() == token.LBRACK {
+				break
+			}
+			fields[ident] = true
+			class := semanticToken{Type: semanticProperty, Modifiers: expressionModifiers(env, info, file.URI, n)}
+			if _, ok := types.Resolve(info.TypeOf(n)).(*types.Function); ok || n.LeftIndexer.Type() == token.COLON {
+				class.Type = semanticMethod
+			}
+			if assigned[n] {
+				class.Modifiers |= semanticDeclaration
+			}
+			classes[ident.Pos()] = class
+		case *ast.TableSimpleKeyField:
+			fields[&n.Name] = true
+			class := semanticToken{Type: semanticProperty, Modifiers: semanticDeclaration}
+			if _, ok := types.Resolve(info.TypeOf(n.Expr)).(*types.Function); ok {
+				class.Type = semanticMethod
+			}
+			classes[n.Name.Pos()] = class
+		case *ast.Identifier:
+			if fields[n] {
+				break
+			}
+			sym := info.SymbolOf(n)
+			if sym == nil {
+				break
+			}
+			class := semanticToken{Type: symbolTokenType(env, info, sym), Modifiers: expressionModifiers(env, info, file.URI, n)}
+			if info.Defs[n] != nil || sym.Kind == types.SymbolGlobal && assigned[n] {
+				class.Modifiers |= semanticDeclaration
+			}
+			if sym.Kind == types.SymbolGlobal {
+				class.Modifiers |= semanticGlobal
+			}
+			if ls, ok := sym.Node.(*ast.LocalStatement); ok && ls.Attrib(sym.Decl) != nil {
+				// Both `<const>` and `<close>` variables cannot be assigned to.
+				class.Modifiers |= semanticReadonly
+			}
+			classes[n.Pos()] = class
+		}
+		return true
+	})
+	return classes
+}
+
+// operatorRanges returns the ranges of the operators of the expressions in the file, by position. Symbols that are
+// not operators, such as the angle brackets of attributes, are left out.
+func operatorRanges(file *ast.File) map[token.Pos]token.Range {
+	ranges := map[token.Pos]token.Range{}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.InfixExpression:
+			ranges[n.OperatorRange().Start] = n.OperatorRange()
+		case *ast.PrefixExpression:
+			ranges[n.OperatorRange().Start] = n.OperatorRange()
+		}
+		return true
+	})
+	return ranges
+}
+
+// expressionModifiers returns the modifiers of an identifier or field access that depend on its declaration, rather
+// than on where it is used.
+func expressionModifiers(env *types.Environment, info *types.Info, uri protocol.URI, expr ast.Expression) int {
+	modifiers := 0
+	if isDefaultLibrary(env, info, expr) {
+		modifiers |= semanticDefaultLibrary
+	}
+	if env.Deprecation(uri, expr) != nil {
+		modifiers |= semanticDeprecated
+	}
+	return modifiers
+}
+
+// isDefaultLibrary returns whether an identifier or field access refers to the standard library, or to something that
+// is only declared by definition files, such as the API of the program that Lua is embedded in.
+func isDefaultLibrary(env *types.Environment, info *types.Info, expr ast.Expression) bool {
+	if path, ok := types.GlobalPath(expr, info); ok {
+		sites := env.Globals.Defs(path)
+		if len(sites) == 0 {
+			root, _, _ := strings.Cut(path, ".")
+			_, host := env.HostGlobals[root]
+			return host || lint.IsLuaGlobal(root)
+		}
+		for _, site := range sites {
+			if siteInfo := env.Info[site.URI]; siteInfo == nil || siteInfo.Meta == nil {
+				return false
+			}
+		}
+		return true
+	}
+	ie, ok := expr.(*ast.IndexExpression)
+	if !ok {
+		return false
+	}
+	key, ok := types.FieldKey(ie)
+	if !ok {
+		return false
+	}
+	field := types.FieldOf(info.TypeOf(ie.Prefix), key)
+	if field == nil || field.Loc.URI == "" {
+		return false
+	}
+	fieldInfo := env.Info[field.Loc.URI]
+	return fieldInfo != nil && fieldInfo.Meta != nil
+}
+
+// symbolTokenType returns the token type of a local, parameter, or global. Variables that hold the table of a class or
+// enum are classified as such, rather than as a variable.
+func symbolTokenType(env *types.Environment, info *types.Info, sym *types.Symbol) int {
+	if sym.Kind == types.SymbolParameter {
+		return semanticParameter
+	}
+	docs := []*annotation.Doc{}
+	if sym.Kind == types.SymbolGlobal {
+		for _, site := range env.Globals.Defs(sym.Name) {
+			if siteInfo := env.Info[site.URI]; siteInfo != nil && site.Stmt != nil {
+				docs = append(docs, siteInfo.Docs[site.Stmt])
+			}
+		}
+	} else if sym.Node != nil {
+		docs = append(docs, info.Docs[sym.Node])
+	}
+	for _, doc := range docs {
+		if doc.Class() != nil {
+			return semanticClass
+		}
+		if doc.Enum() != nil {
+			return semanticEnum
+		}
+	}
+	typ := sym.Annotated
+	if typ == nil {
+		typ = sym.Type
+	}
+	if _, ok := types.Resolve(typ).(*types.Function); ok {
+		return semanticFunction
+	}
+	return semanticVariable
+}
+
+// encodeSemanticTokens encodes tokens in the relative format of the protocol. Tokens that span several lines, such as
+// long comments, are split at each line break. Characters are counted in the negotiated position encoding.
+func (s *Server) encodeSemanticTokens(file *ast.File, tokens []semanticToken) []protocol.UInteger {
+	lineBreaks := file.LineBreaks
+	data := []protocol.UInteger{}
+	prevLine, prevChar := 0, 0
+	for _, tok := range tokens {
+		for start := tok.Range.Start; start < tok.Range.End; {
+			line := lineBreaks.Line(start)
+			end := tok.Range.End
+			if line < len(lineBreaks) && lineBreaks[line] < end {
+				end = lineBreaks[line]
+			}
+			if end > start {
+				char := int(file.ToProtocolPos(start, s.encoding).Character)
+				length := end - start
+				if s.encoding != token.PositionEncodingUTF8 && end <= len(file.Text) {
+					length = token.ColumnLength(file.Text[start:end], s.encoding)
+				}
+				if line != prevLine {
+					prevChar = 0
+				}
+				data = append(data,
+					protocol.UInteger(line-prevLine),
+					protocol.UInteger(char-prevChar),
+					protocol.UInteger(length),
+					protocol.UInteger(tok.Type),
+					protocol.UInteger(tok.Modifiers),
+				)
+				prevLine, prevChar = line, char
+			}
+			start = end + 1
+		}
+	}
+	return data
+}
@@ -0,0 +1,24 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemanticOperators(t *testing.T) {
+	uri := "file:///main.lua"
+	text := "local x <const> = -1 + 2 .. 'a'\nprint(not x, #x == 1)\n"
+	s := openFile(t, uri, text)
+	tokens, err := s.semanticTokens(s.getFile(uri))
+	require.NoError(t, err)
+	operators := []string{}
+	for _, tok := range tokens {
+		if tok.Type == semanticOperator {
+			operators = append(operators, text[tok.Range.Start:tok.Range.End])
+		}
+	}
+	// The angle brackets of the attribute are not operators, and `not` remains a keyword.
+	assert.Equal(t, []string{"-", "+", "..", "#", "=="}, operators)
+}
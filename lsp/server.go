@@ -1,8 +1,17 @@
 package lsp
 
 import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
 	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
 	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/sourcegraph/jsonrpc2"
 	"github.com/tliron/commonlog"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -16,81 +25,399 @@ const LS_NAME = "luapls"
 
 // Server contains the state for the LSP session.
 type Server struct {
-	environment *types.Environment
-	handler     protocol.Handler
-	log         commonlog.Logger
-	rootPath    string
-	server      *glspserv.Server
+	environment *types.Environment // The default environment, for files that no other environment includes.
+	// environments contains additional environments that are each responsible for a subset of the files, such as
+	// the Factorio data stage.
+	environments []*types.Environment
+	handler      protocol.Handler
+	log          commonlog.Logger
+	rootPath     string // The directory of the first workspace folder.
+	server       *glspserv.Server
+	// folders maps the URI of each workspace folder to the environment that checks it.
+	folders map[protocol.DocumentUri]*types.Environment
+	// configured contains the environments that were created because the configuration names them, which are
+	// discarded when it no longer does.
+	configured map[*types.Environment]bool
 
 	config Config
+	// clientSettings are the settings that the client last sent, and fileSettings those of the configuration file at
+	// the root of the workspace, which the client's settings are merged over.
+	clientSettings any
+	fileSettings   map[string]any
+	// encoding is the unit that the characters of positions are counted in, which was negotiated with the client.
+	// Each session keeps its own, since the clients of separate sessions may negotiate different ones. Every client
+	// of a shared session uses the one that its first client negotiated.
+	encoding token.PositionEncoding
+	// trace is the level of the `$/logTrace` notifications that the client asked for.
+	trace protocol.TraceValue
+	// hoverKind is the format that hovers are written in, which is markdown unless the client prefers plain text.
+	hoverKind protocol.MarkupKind
+	// pullConfig is whether the client supports `workspace/configuration` requests, refreshCodeLenses whether it
+	// supports `workspace/codeLens/refresh` requests, and watchedFiles whether it can be asked to watch files.
+	pullConfig        bool
+	refreshCodeLenses bool
+	watchedFiles      bool
+	// diagnosticTags are the diagnostic tags that the client can display, such as fading unused code.
+	diagnosticTags []protocol.DiagnosticTag
+	// workDoneProgress is whether the client supports progress that the server reports of its own accord, and
+	// progressID is the number of the last progress token that was created.
+	workDoneProgress bool
+	progressID       int
+	// status is the state of the server that was last reported to the client, and configErrors are the problems
+	// with the current configuration.
+	status       StatusParams
+	configErrors []string
+
+	// transient contains the files that were added when they were opened, rather than loaded from disk, so that they
+	// can be removed when they are closed.
+	transient map[protocol.URI]bool
+	// contents contains the current text of every open file, which incremental changes are applied to.
+	contents map[protocol.URI]string
+	// semanticResults contains the semantic tokens that were last sent for each file, which deltas are computed
+	// against.
+	semanticResults  map[protocol.URI]semanticResult
+	semanticResultID int
+
+	customMethods map[string]customMethodFunc
+
+	// mu is held while a message is handled, and by work that finishes after its message was handled, such as
+	// applying configuration that was requested from the client.
+	mu sync.Mutex
+
+	// shared is whether the session is used by every connection. initializeResult is the response to the first
+	// client's `initialize` request, which later clients of a shared session receive as well.
+	shared           bool
+	initializeResult any
+	// remote is whether clients connect over TCP or WebSocket, which any local process may be able to do, rather than
+	// over stdio. Settings that run commands are not accepted from remote clients.
+	remote bool
+	// conns contains the connections of a shared session, which notifications are sent to. connsMu guards it, since
+	// connections open and close while messages are handled.
+	conns   map[*jsonrpc2.Conn]bool
+	connsMu sync.Mutex
 
 	isInitialized bool
 }
 
-func Run(logLevel int) {
-	commonlog.Configure(logLevel, nil)
-
+// newServer returns a session with its handlers registered.
+func newServer(logLevel int) *Server {
 	s := Server{
 		environment: types.NewEnvironment(),
+		encoding:    token.DefaultEncoding,
+		trace:       protocol.TraceValueOff,
+		conns:       map[*jsonrpc2.Conn]bool{},
+		transient:   map[protocol.URI]bool{},
+		contents:    map[protocol.URI]string{},
+		folders:     map[protocol.DocumentUri]*types.Environment{},
+		configured:  map[*types.Environment]bool{},
+
+		semanticResults: map[protocol.URI]semanticResult{},
 	}
 
 	s.handler.Initialize = s.initialize
 	s.handler.Initialized = s.initialized
 	s.handler.WorkspaceDidChangeConfiguration = s.didChangeConfiguration
+	s.handler.WorkspaceDidChangeWorkspaceFolders = s.workspaceDidChangeWorkspaceFolders
+	s.handler.WorkspaceDidChangeWatchedFiles = s.workspaceDidChangeWatchedFiles
+	s.handler.WorkspaceWillRenameFiles = s.workspaceWillRenameFiles
+	s.handler.WorkspaceWillDeleteFiles = s.workspaceWillDeleteFiles
+	s.handler.WorkspaceDidDeleteFiles = s.workspaceDidDeleteFiles
 	s.handler.Shutdown = s.shutdown
 	s.handler.SetTrace = s.setTrace
 	s.handler.TextDocumentDidOpen = s.textDocumentDidOpen
 	s.handler.TextDocumentDidChange = s.textDocumentDidChange
 	s.handler.TextDocumentDidClose = s.textDocumentDidClose
+	s.handler.TextDocumentDidSave = s.textDocumentDidSave
+	s.handler.TextDocumentCodeAction = s.textDocumentCodeAction
+	s.handler.TextDocumentPrepareCallHierarchy = s.textDocumentPrepareCallHierarchy
+	s.handler.CallHierarchyIncomingCalls = s.callHierarchyIncomingCalls
+	s.handler.CallHierarchyOutgoingCalls = s.callHierarchyOutgoingCalls
+	s.handler.TextDocumentCodeLens = s.textDocumentCodeLens
+	s.handler.CodeLensResolve = s.codeLensResolve
+	s.handler.TextDocumentCompletion = s.textDocumentCompletion
+	s.handler.CompletionItemResolve = s.completionItemResolve
+	s.handler.TextDocumentFormatting = s.textDocumentFormatting
+	s.handler.TextDocumentOnTypeFormatting = s.textDocumentOnTypeFormatting
 	s.handler.TextDocumentDocumentHighlight = s.textDocumentHighlight
+	s.handler.TextDocumentDocumentSymbol = s.textDocumentDocumentSymbol
 	s.handler.TextDocumentHover = s.textDocumentHover
+	s.handler.TextDocumentImplementation = s.textDocumentImplementation
+	s.handler.TextDocumentLinkedEditingRange = s.textDocumentLinkedEditingRange
+	s.handler.TextDocumentDeclaration = s.textDocumentDeclaration
 	s.handler.TextDocumentDefinition = s.textDocumentDefinition
+	s.handler.TextDocumentReferences = s.textDocumentReferences
+	s.handler.TextDocumentRename = s.textDocumentRename
+	s.handler.TextDocumentPrepareRename = s.textDocumentPrepareRename
+	s.handler.TextDocumentSelectionRange = s.textDocumentSelectionRange
+	s.handler.TextDocumentSemanticTokensFull = s.textDocumentSemanticTokensFull
+	s.handler.TextDocumentSemanticTokensFullDelta = s.textDocumentSemanticTokensFullDelta
+	s.handler.TextDocumentSemanticTokensRange = s.textDocumentSemanticTokensRange
+	s.handler.TextDocumentSignatureHelp = s.textDocumentSignatureHelp
+	s.handler.WorkspaceSymbol = s.workspaceSymbol
+	s.handler.WorkspaceExecuteCommand = s.workspaceExecuteCommand
 
-	s.server = glspserv.NewServer(&s.handler, LS_NAME, logLevel > 2)
+	s.customMethods = map[string]customMethodFunc{
+		MethodMemoryStats:             customMethod(s.memoryStats),
+		MethodTests:                   customMethod(s.tests),
+		MethodAST:                     customMethod(s.inspectAST),
+		MethodEnvironment:             customMethod(s.environmentInfo),
+		MethodTokens:                  customMethod(s.inspectTokens),
+		MethodTextDocumentDiagnostic:  customMethod(s.textDocumentDiagnostic),
+		MethodWorkspaceDiagnostic:     customMethod(s.workspaceDiagnostic),
+		MethodTextDocumentInlineValue: customMethod(s.textDocumentInlineValue),
+
+		MethodTextDocumentPrepareTypeHierarchy: customMethod(s.textDocumentPrepareTypeHierarchy),
+		MethodTypeHierarchySupertypes:          customMethod(s.typeHierarchySupertypes),
+		MethodTypeHierarchySubtypes:            customMethod(s.typeHierarchySubtypes),
+	}
+
+	s.server = glspserv.NewServer(&s, LS_NAME, logLevel > 2)
 
 	s.log = s.server.Log
 
-	s.server.RunStdio()
+	return &s
 }
 
 func (s *Server) initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, error) {
 	capabilities := s.handler.CreateServerCapabilities()
-	// TODO: RootURI / WorkspaceFolders fallbacks
-	s.environment.RootPath = *params.RootPath
+	// Changes are sent as edits to ranges of the document, which are applied to the text that the editor opened.
+	syncKind := protocol.TextDocumentSyncKindIncremental
+	capabilities.TextDocumentSync.(*protocol.TextDocumentSyncOptions).Change = &syncKind
+	prepareRename := true
+	capabilities.RenameProvider = protocol.RenameOptions{PrepareProvider: &prepareRename}
+	capabilities.SemanticTokensProvider.(*protocol.SemanticTokensOptions).Legend = semanticTokensLegend()
+	capabilities.CodeActionProvider = protocol.CodeActionOptions{
+		CodeActionKinds: []protocol.CodeActionKind{
+			protocol.CodeActionKindQuickFix,
+			protocol.CodeActionKindRefactorExtract,
+			protocol.CodeActionKindRefactorRewrite,
+			protocol.CodeActionKindSourceOrganizeImports,
+			codeActionKindSourceFixAll,
+		},
+	}
+	capabilities.ExecuteCommandProvider = &protocol.ExecuteCommandOptions{Commands: commands}
+	resolveCodeLens := true
+	capabilities.CodeLensProvider = &protocol.CodeLensOptions{ResolveProvider: &resolveCodeLens}
+	capabilities.DocumentOnTypeFormattingProvider = &protocol.DocumentOnTypeFormattingOptions{FirstTriggerCharacter: "\n"}
+	resolveCompletion := true
+	capabilities.CompletionProvider = &protocol.CompletionOptions{
+		TriggerCharacters: []string{".", ":", "\"", "'"},
+		ResolveProvider:   &resolveCompletion,
+	}
+	capabilities.SignatureHelpProvider = &protocol.SignatureHelpOptions{TriggerCharacters: []string{"(", ","}}
+	s.hoverKind = protocol.MarkupKindMarkdown
+	if td := params.Capabilities.TextDocument; td != nil && td.Hover != nil && len(td.Hover.ContentFormat) > 0 {
+		s.hoverKind = td.Hover.ContentFormat[0]
+	}
+	if td := params.Capabilities.TextDocument; td != nil && td.PublishDiagnostics != nil && td.PublishDiagnostics.TagSupport != nil {
+		s.diagnosticTags = td.PublishDiagnostics.TagSupport.ValueSet
+	}
+	if ws := params.Capabilities.Workspace; ws != nil {
+		s.pullConfig = ws.Configuration != nil && *ws.Configuration
+		s.refreshCodeLenses = ws.CodeLens != nil && ws.CodeLens.RefreshSupport != nil && *ws.CodeLens.RefreshSupport
+		s.watchedFiles = ws.DidChangeWatchedFiles != nil && ws.DidChangeWatchedFiles.DynamicRegistration != nil &&
+			*ws.DidChangeWatchedFiles.DynamicRegistration
+	}
+	if w := params.Capabilities.Window; w != nil {
+		s.workDoneProgress = w.WorkDoneProgress != nil && *w.WorkDoneProgress
+	}
+	s.encoding = positionEncoding(ctx)
+	if params.Trace != nil {
+		s.setTraceValue(*params.Trace)
+	}
 
-	s.updateConfig(params.InitializationOptions)
+	// The first workspace folder is checked by the default environment and the environments that are configured for
+	// it, and every other folder by an environment of its own.
+	folders := workspaceRoots(params)
+	if len(folders) > 0 {
+		root, err := util.URIToPath(folders[0].URI)
+		if err != nil {
+			return nil, err
+		}
+		s.rootPath = root
+	}
+	// Problems with the configuration are reported to the user.
+	_ = s.updateConfig(ctx, params.InitializationOptions)
 
-	s.environment.Init()
+	if len(folders) > 0 {
+		root := s.rootPath
+		s.detectMod(root)
+		s.setStatus(ctx, StatusIndexing, "Indexing")
+		p := beginProgress(ctx, workDoneToken(ctx), "Indexing")
+		for _, env := range s.allEnvironments() {
+			env.RootPath = root
+			reportIndexing(env, p)
+			env.Init()
+			reportIndexing(env, nil)
+		}
+		s.folders[folders[0].URI] = s.environment
+		for _, folder := range folders[1:] {
+			s.addFolder(folder, p)
+		}
+		p.end()
+	} else {
+		s.log.Infof("No workspace folder was opened, so only open files are checked")
+	}
+	s.idle(ctx)
+	capabilities.Workspace = &protocol.ServerCapabilitiesWorkspace{
+		WorkspaceFolders: &protocol.WorkspaceFoldersServerCapabilities{
+			Supported:           util.Ptr(true),
+			ChangeNotifications: &protocol.BoolOrString{Value: true},
+		},
+		FileOperations: &protocol.ServerCapabilitiesWorkspaceFileOperations{
+			WillRename: fileOperationFilters(),
+			WillDelete: fileOperationFilters(),
+			DidDelete:  fileOperationFilters(),
+		},
+	}
 
-	return protocol.InitializeResult{
-		Capabilities: capabilities,
-		ServerInfo:   &protocol.InitializeResultServerInfo{Name: LS_NAME},
-	}, nil
+	result := initializeResult{
+		Capabilities: serverCapabilities{
+			ServerCapabilities:    capabilities,
+			PositionEncoding:      s.encoding,
+			TypeHierarchyProvider: true,
+			InlineValueProvider:   true,
+		},
+		ServerInfo: &protocol.InitializeResultServerInfo{Name: LS_NAME},
+	}
+	if s.pullDiagnostics() {
+		result.Capabilities.DiagnosticProvider = &DiagnosticOptions{InterFileDependencies: true, WorkspaceDiagnostics: true}
+	}
+	s.initializeResult = result
+	return result, nil
 }
 
-func (s *Server) initialized(ctx *glsp.Context, params *protocol.InitializedParams) error {
-	s.isInitialized = true
+// positionEncoding returns the encoding that characters of positions are counted in, which is UTF-8 if the client
+// supports it, since that is how positions are stored. Otherwise it is UTF-16, which every client supports.
+func positionEncoding(ctx *glsp.Context) token.PositionEncoding {
+	if slices.Contains(clientEncodings(ctx.Params), token.PositionEncodingUTF8) {
+		return token.PositionEncodingUTF8
+	}
+	return token.PositionEncodingUTF16
+}
 
-	for _, file := range s.environment.Files {
-		s.publishDiagnostics(ctx, file)
+// clientEncodings returns the position encodings that the parameters of an `initialize` request list. The protocol
+// package predates the negotiation, so they are decoded from the raw parameters.
+func clientEncodings(params json.RawMessage) []token.PositionEncoding {
+	var decoded struct {
+		Capabilities struct {
+			General *struct {
+				PositionEncodings []token.PositionEncoding `json:"positionEncodings"`
+			} `json:"general"`
+		} `json:"capabilities"`
+	}
+	if err := json.Unmarshal(params, &decoded); err != nil || decoded.Capabilities.General == nil {
+		return nil
 	}
+	return decoded.Capabilities.General.PositionEncodings
+}
 
+func (s *Server) initialized(ctx *glsp.Context, params *protocol.InitializedParams) error {
+	s.isInitialized = true
+	s.setStatus(ctx, s.status.Kind, s.status.Message)
+	s.publishAll(ctx)
+	if s.pullConfig {
+		s.fetchConfig(ctx)
+	}
+	if s.watchedFiles {
+		s.watchFiles(ctx)
+	}
 	return nil
 }
 
+// publishAll publishes the diagnostics of every file in the workspace, and of the open files outside of it.
+func (s *Server) publishAll(ctx *glsp.Context) {
+	for _, env := range s.allEnvironments() {
+		for uri, file := range env.Files {
+			if (env.Owns(uri) || s.transient[uri]) && s.environmentOf(uri) == env {
+				s.publishDiagnostics(ctx, file)
+			}
+		}
+	}
+}
+
 func (s *Server) shutdown(ctx *glsp.Context) error {
-	protocol.SetTraceValue(protocol.TraceValueOff)
+	s.trace = protocol.TraceValueOff
 	return nil
 }
 
 func (s *Server) setTrace(ctx *glsp.Context, params *protocol.SetTraceParams) error {
-	protocol.SetTraceValue(params.Value)
+	s.setTraceValue(params.Value)
 	return nil
 }
 
+// setTraceValue sets the level of the session's traces. The protocol package keeps a single level for the whole
+// process, so each session keeps its own instead.
+func (s *Server) setTraceValue(value protocol.TraceValue) {
+	// The specification says `message`, but some clients send `messages`.
+	if value == "messages" {
+		value = protocol.TraceValueMessage
+	}
+	s.trace = value
+}
+
+// logTrace sends a trace of a message that was handled to the client, if it asked for them. Verbose traces include
+// the parameters of the message.
+func (s *Server) logTrace(ctx *glsp.Context, started time.Time) {
+	if s.trace == protocol.TraceValueOff || s.trace == "" || ctx.Method == string(protocol.MethodLogTrace) {
+		return
+	}
+	params := protocol.LogTraceParams{
+		Message: fmt.Sprintf("Handled '%s' in %s", ctx.Method, time.Since(started).Round(time.Microsecond)),
+	}
+	if s.trace == protocol.TraceValueVerbose && len(ctx.Params) > 0 {
+		params.Verbose = util.Ptr(string(ctx.Params))
+	}
+	ctx.Notify(string(protocol.MethodLogTrace), params)
+}
+
+// getFile returns the file with the given URI, which may be encoded differently than the server encodes it.
 func (s *Server) getFile(uri protocol.URI) *ast.File {
 	if !s.isInitialized {
 		return nil
 	}
-	return s.environment.Files[uri]
+	uri = util.NormalizeURI(uri)
+	if file := s.environmentOf(uri).Files[uri]; file != nil {
+		return file
+	}
+	// Library files are only loaded by the environments that use them.
+	for _, env := range s.environments {
+		if file := env.Files[uri]; file != nil {
+			return file
+		}
+	}
+	return nil
+}
+
+func (s *Server) getInfo(uri protocol.URI) *types.Info {
+	uri = util.NormalizeURI(uri)
+	env := s.environmentOf(uri)
+	if env.Files[uri] == nil {
+		for _, other := range s.environments {
+			if other.Files[uri] != nil {
+				env = other
+			}
+		}
+	}
+	if info := env.Info[uri]; info != nil {
+		return info
+	}
+	return types.NewInfo()
+}
+
+// environmentOf returns the environment that is responsible for the given file. Of the additional environments that
+// own it, the one with the highest priority is chosen, and the earliest one if several have that priority.
+func (s *Server) environmentOf(uri protocol.URI) *types.Environment {
+	owner := s.environment
+	for _, env := range s.environments {
+		if env.Owns(uri) && (owner == s.environment || env.Priority > owner.Priority) {
+			owner = env
+		}
+	}
+	return owner
+}
+
+// allEnvironments returns the default environment followed by every additional environment.
+func (s *Server) allEnvironments() []*types.Environment {
+	return append([]*types.Environment{s.environment}, s.environments...)
 }
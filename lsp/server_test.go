@@ -0,0 +1,51 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// notification is a notification that a session sent while handling a message in a test.
+type notification struct {
+	method string
+	params any
+}
+
+// testContext returns the context of a message with the given parameters, which records the notifications that are
+// sent while it is handled.
+func testContext(t *testing.T, params any, notifications *[]notification) *glsp.Context {
+	data, err := json.Marshal(params)
+	require.NoError(t, err)
+	return &glsp.Context{
+		Params: data,
+		Notify: func(method string, params any) {
+			if notifications != nil {
+				*notifications = append(*notifications, notification{method, params})
+			}
+		},
+		Call: func(method string, params any, result any) {},
+	}
+}
+
+// openFile returns a session without a workspace folder, in which a file with the given text is open.
+func openFile(t *testing.T, uri protocol.DocumentUri, text string) *Server {
+	s := newServer(0)
+	s.isInitialized = true
+	params := &protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{URI: uri, LanguageID: "lua", Text: text}}
+	require.NoError(t, s.textDocumentDidOpen(testContext(t, params, nil), params))
+	return s
+}
+
+func TestGetInfoNormalizesURI(t *testing.T) {
+	s := openFile(t, "file:///main.lua", "local x = 1")
+	info := s.getInfo("file:///main.lua")
+	require.NotNil(t, info.Scope)
+	assert.Same(t, info, s.getInfo("file:///m%61in.lua"))
+	assert.Same(t, s.getFile("file:///main.lua"), s.getFile("file:///m%61in.lua"))
+}
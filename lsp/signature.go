@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"errors"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func (s *Server) textDocumentSignatureHelp(ctx *glsp.Context, params *protocol.SignatureHelpParams) (*protocol.SignatureHelp, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil {
+		return nil, nil
+	}
+	if file.Block == nil {
+		return nil, errors.New("Attempted to get signature help in a file with no AST")
+	}
+	pos := file.ToPos(params.Position, s.encoding)
+	fc := callAt(file, pos)
+	if fc == nil {
+		return nil, nil
+	}
+	info := s.getInfo(file.URI)
+	fn, ok := types.Resolve(info.TypeOf(fc.Name)).(*types.Function)
+	if !ok {
+		return nil, nil
+	}
+
+	// Methods called with `:` receive the prefix as their first parameter, which is not written in the call.
+	method := false
+	if ie, ok := fc.Name.(*ast.IndexExpression); ok && ie.LeftIndexer.Type() == token.COLON {
+		method = true
+	}
+	name := "function"
+	switch callee := fc.Name.(type) {
+	case *ast.Identifier:
+		name = callee.Token.Literal
+	case *ast.IndexExpression:
+		if key, ok := types.FieldKey(callee); ok {
+			name = key
+		}
+	}
+
+	candidates := append([]*types.Function{fn}, fn.Overloads...)
+	help := &protocol.SignatureHelp{Signatures: []protocol.SignatureInformation{}}
+	for _, candidate := range candidates {
+		help.Signatures = append(help.Signatures, signatureInformation(name, candidate, method))
+	}
+
+	// The overload that the user cycled to is kept while they type, otherwise the first one that accepts the
+	// arguments is shown.
+	active := 0
+	if context := params.Context; context != nil && context.IsRetrigger && context.ActiveSignatureHelp != nil &&
+		context.ActiveSignatureHelp.ActiveSignature != nil && int(*context.ActiveSignatureHelp.ActiveSignature) < len(candidates) {
+		active = int(*context.ActiveSignatureHelp.ActiveSignature)
+	} else {
+		args := []types.Type{}
+		if receiver := types.Receiver(fc, fn, info); receiver != nil {
+			args = append(args, receiver)
+		}
+		for _, pair := range fc.Args.Pairs {
+			args = append(args, info.LiteralTypeOf(pair.Node))
+		}
+		selected := types.SelectOverload(fn, args)
+		for i, candidate := range candidates {
+			if candidate == selected {
+				active = i
+			}
+		}
+	}
+	help.ActiveSignature = util.Ptr(protocol.UInteger(active))
+
+	argument := 0
+	for _, pair := range fc.Args.Pairs {
+		if pair.Delimeter != nil && pair.Delimeter.Pos() < pos {
+			argument++
+		}
+	}
+	for i := range help.Signatures {
+		parameters := help.Signatures[i].Parameters
+		index := argument
+		// Every remaining argument is passed to a trailing `...`.
+		if n := len(parameters); n > 0 && index >= n && callParams(candidates[i], method)[n-1].Name == "..." {
+			index = n - 1
+		}
+		help.Signatures[i].ActiveParameter = util.Ptr(protocol.UInteger(index))
+	}
+	help.ActiveParameter = help.Signatures[active].ActiveParameter
+	return help, nil
+}
+
+// callAt returns the innermost function call whose parentheses contain the given position.
+func callAt(file *ast.File, pos token.Pos) *ast.FunctionCall {
+	nodePath := ast.GetSemanticNode(file.Block, pos)
+	nodes := append(nodePath.Parents, nodePath.Node)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		fc, ok := nodes[i].(*ast.FunctionCall)
+		if !ok || fc.LeftParen == nil || pos <= fc.LeftParen.Pos() {
+			continue
+		}
+		if fc.RightParen == nil || pos <= fc.RightParen.Pos() {
+			return fc
+		}
+	}
+	return nil
+}
+
+// signatureInformation describes a function as it is called, such as `add(a: number, b: number) → number`.
+// Parameters are identified by their offsets in the label, in UTF-16 code units.
+func signatureInformation(name string, fn *types.Function, method bool) protocol.SignatureInformation {
+	params := callParams(fn, method)
+	label := name + "("
+	information := protocol.SignatureInformation{Parameters: []protocol.ParameterInformation{}}
+	for i := range params {
+		if i > 0 {
+			label += ", "
+		}
+		start := token.ColumnLength(label, token.PositionEncodingUTF16)
+		label += params[i].String()
+		information.Parameters = append(information.Parameters, protocol.ParameterInformation{
+			Label: []protocol.UInteger{protocol.UInteger(start), protocol.UInteger(token.ColumnLength(label, token.PositionEncodingUTF16))},
+		})
+	}
+	label += ")"
+	if fn.Return != nil {
+		label += " → " + fn.Return.String()
+	}
+	information.Label = label
+	return information
+}
+
+// callParams returns the parameters of a function that are written in a call, which excludes `self` for methods
+// called with `:`.
+func callParams(fn *types.Function, method bool) []types.NameAndType {
+	if method && len(fn.Params) > 0 && fn.Params[0].Name == "self" {
+		return fn.Params[1:]
+	}
+	return fn.Params
+}
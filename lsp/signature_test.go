@@ -0,0 +1,25 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureParameterOffsets(t *testing.T) {
+	fn := &types.Function{Params: []types.NameAndType{
+		{Name: "self", Type: &types.Table{}},
+		{Name: "a", Type: &types.Number{}},
+		{Name: "b", Type: &types.String{}},
+	}}
+	// The offsets are counted in UTF-16 code units, in which the emoji is two long.
+	information := signatureInformation("😀.run", fn, true)
+	assert.Equal(t, "😀.run(a: number, b: string)", information.Label)
+	assert.Equal(t, []protocol.ParameterInformation{
+		{Label: []protocol.UInteger{7, 16}},
+		{Label: []protocol.UInteger{18, 27}},
+	}, information.Parameters)
+}
@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"sort"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const MethodMemoryStats = "luapls/memoryStats"
+
+type MemoryStatsParams struct {
+	// If omitted, stats are returned for every file in every environment.
+	TextDocument *protocol.TextDocumentIdentifier `json:"textDocument,omitempty"`
+}
+
+type FileMemoryStats struct {
+	URI protocol.URI `json:"uri"`
+	// Environment is the name of the environment that loaded the file. Files that several environments load, such as
+	// library files, are listed once for each of them, since each keeps its own tree.
+	Environment string    `json:"environment"`
+	Stats       ast.Stats `json:"stats"`
+}
+
+type MemoryStatsResult struct {
+	Files []FileMemoryStats `json:"files"`
+	Total ast.Stats         `json:"total"`
+}
+
+func (s *Server) memoryStats(ctx *glsp.Context, params *MemoryStatsParams) (any, error) {
+	result := MemoryStatsResult{Files: []FileMemoryStats{}, Total: ast.Stats{NodesByType: map[string]int{}}}
+	seen := map[*ast.File]bool{}
+	var uri protocol.URI
+	if params.TextDocument != nil {
+		uri = util.NormalizeURI(params.TextDocument.URI)
+	}
+	for _, env := range s.allEnvironments() {
+		uris := make([]protocol.URI, 0, len(env.Files))
+		for fileURI := range env.Files {
+			if uri == "" || fileURI == uri {
+				uris = append(uris, fileURI)
+			}
+		}
+		sort.Strings(uris)
+		for _, fileURI := range uris {
+			file := env.Files[fileURI]
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			stats := ast.ComputeStats(file)
+			result.Files = append(result.Files, FileMemoryStats{URI: file.URI, Environment: env.Name, Stats: stats})
+			result.Total.Add(stats)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package lsp
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStats(t *testing.T) {
+	_, first := writeFolder(t, map[string]string{"main.lua": "print(1)\n"})
+	_, second := writeFolder(t, map[string]string{"other.lua": "local x = 1\n"})
+	s := newServer(0)
+	params := &protocol.InitializeParams{WorkspaceFolders: []protocol.WorkspaceFolder{
+		{URI: first, Name: "first"},
+		{URI: second, Name: "second"},
+	}}
+	_, err := s.initialize(testContext(t, params, nil), params)
+	require.NoError(t, err)
+
+	result, err := s.memoryStats(nil, &MemoryStatsParams{})
+	require.NoError(t, err)
+	stats := result.(MemoryStatsResult)
+	files := map[protocol.URI]string{}
+	for _, file := range stats.Files {
+		files[file.URI] = file.Environment
+	}
+	assert.Equal(t, map[protocol.URI]string{first + "/main.lua": "", second + "/other.lua": "second"}, files)
+	assert.Equal(t, len("print(1)\n")+len("local x = 1\n"), stats.Total.Text)
+	assert.Greater(t, stats.Total.Bytes, stats.Total.Text)
+
+	result, err = s.memoryStats(nil, &MemoryStatsParams{TextDocument: &protocol.TextDocumentIdentifier{URI: second + "/other.lua"}})
+	require.NoError(t, err)
+	stats = result.(MemoryStatsResult)
+	require.Len(t, stats.Files, 1)
+	assert.Equal(t, len("local x = 1\n"), stats.Total.Text)
+}
@@ -0,0 +1,57 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// MethodStatus is sent to the client whenever the state of the server changes, so that editors can display it.
+const MethodStatus = "luapls/status"
+
+type StatusKind string
+
+const (
+	StatusIndexing StatusKind = "indexing"
+	StatusIdle     StatusKind = "idle"
+	// StatusError is reported while the configuration has problems that prevent the workspace from being checked
+	// as configured.
+	StatusError StatusKind = "error"
+)
+
+type StatusParams struct {
+	Kind    StatusKind `json:"kind"`
+	Message string     `json:"message,omitempty"`
+}
+
+// setStatus records the state of the server and reports it to the client. The status is only reported once the
+// client has been initialized, and whatever it is by then is reported at that point.
+func (s *Server) setStatus(ctx *glsp.Context, kind StatusKind, message string) {
+	s.status = StatusParams{Kind: kind, Message: message}
+	if s.isInitialized {
+		ctx.Notify(MethodStatus, s.status)
+	}
+}
+
+// idle reports that the server finished its work, or that it is in error if the configuration has problems.
+func (s *Server) idle(ctx *glsp.Context) {
+	if len(s.configErrors) > 0 {
+		s.setStatus(ctx, StatusError, strings.Join(s.configErrors, "\n"))
+		return
+	}
+	s.setStatus(ctx, StatusIdle, "")
+}
+
+// configError reports a problem with the configuration that prevents the workspace from being checked as
+// configured. The user is shown the problem, since it would otherwise go unnoticed.
+func (s *Server) configError(ctx *glsp.Context, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	s.log.Errorf("%s", message)
+	s.configErrors = append(s.configErrors, message)
+	ctx.Notify(protocol.ServerWindowShowMessage, protocol.ShowMessageParams{
+		Type:    protocol.MessageTypeError,
+		Message: fmt.Sprintf("%s: %s", LS_NAME, message),
+	})
+}
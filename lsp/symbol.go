@@ -0,0 +1,191 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func (s *Server) workspaceSymbol(ctx *glsp.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	results := newPartialResults[protocol.SymbolInformation](ctx)
+	for _, site := range s.environment.Globals.Search(params.Query) {
+		location := s.siteLocation(site)
+		if location == nil {
+			continue
+		}
+		name := site.Path
+		var container *string
+		if i := strings.LastIndexByte(site.Path, '.'); i >= 0 {
+			name = site.Path[i+1:]
+			container = util.Ptr(site.Path[:i])
+		}
+		results.add(protocol.SymbolInformation{
+			Name:          name,
+			Kind:          globalSymbolKind(site.Value, container != nil),
+			Location:      *location,
+			ContainerName: container,
+		})
+		results.flush()
+	}
+	return results.result(), nil
+}
+
+func globalSymbolKind(value ast.Node, isField bool) protocol.SymbolKind {
+	switch value.(type) {
+	case *ast.FunctionStatement, *ast.FunctionExpression:
+		if isField {
+			return protocol.SymbolKindMethod
+		}
+		return protocol.SymbolKindFunction
+	case *ast.TableLiteral:
+		return protocol.SymbolKindObject
+	}
+	if isField {
+		return protocol.SymbolKindField
+	}
+	return protocol.SymbolKindVariable
+}
+
+func (s *Server) textDocumentDocumentSymbol(ctx *glsp.Context, params *protocol.DocumentSymbolParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	info := s.getInfo(file.URI)
+	results := newPartialResults[protocol.DocumentSymbol](ctx)
+	for _, pair := range file.Block.Pairs {
+		results.add(s.statementSymbols(file, info, pair.Node)...)
+		results.flush()
+	}
+	return results.result(), nil
+}
+
+// blockSymbols returns the symbols that the statements of a block declare.
+func (s *Server) blockSymbols(file *ast.File, info *types.Info, block *ast.Block) []protocol.DocumentSymbol {
+	symbols := []protocol.DocumentSymbol{}
+	for _, pair := range block.Pairs {
+		symbols = append(symbols, s.statementSymbols(file, info, pair.Node)...)
+	}
+	return symbols
+}
+
+// statementSymbols returns the symbols that a statement declares: locals, functions, and assignments to globals and
+// fields. The symbols of functions contain the ones that are declared in their bodies, and those of tables their
+// fields. Symbols that are declared in the bodies of other statements, such as loops, belong to the enclosing
+// function.
+func (s *Server) statementSymbols(file *ast.File, info *types.Info, stmt ast.Statement) []protocol.DocumentSymbol {
+	symbols := []protocol.DocumentSymbol{}
+	switch stmt := stmt.(type) {
+	case *ast.FunctionStatement:
+		if stmt.Name == nil {
+			break
+		}
+		kind := protocol.SymbolKindFunction
+		if _, ok := stmt.Name.(*ast.IndexExpression); ok {
+			kind = protocol.SymbolKindMethod
+		}
+		symbol := s.documentSymbol(file, callableName(stmt.Name), kind, stmt, stmt.Name)
+		symbol.Children = s.blockSymbols(file, info, &stmt.Body)
+		symbols = append(symbols, symbol)
+	case *ast.LocalStatement:
+		for i, pair := range stmt.Names.Pairs {
+			kind := protocol.SymbolKindVariable
+			if attrib := stmt.Attrib(pair.Node); attrib != nil && attrib.Name.Token.Literal == "const" {
+				kind = protocol.SymbolKindConstant
+			}
+			symbols = append(symbols, s.valueSymbol(file, info, pair.Node.Token.Literal, kind, stmt, pair.Node, valueAt(stmt.Exps, i)))
+		}
+	case *ast.AssignmentStatement:
+		for i, pair := range stmt.Vars.Pairs {
+			kind := protocol.SymbolKindVariable
+			switch target := pair.Node.(type) {
+			case *ast.Identifier:
+				// Assignments to locals that are already declared do not declare anything.
+				if sym := info.SymbolOf(target); sym == nil || sym.Kind != types.SymbolGlobal {
+					continue
+				}
+			case *ast.IndexExpression:
+				kind = protocol.SymbolKindField
+			default:
+				continue
+			}
+			symbols = append(symbols, s.valueSymbol(file, info, callableName(pair.Node), kind, stmt, pair.Node, valueAt(&stmt.Exps, i)))
+		}
+	case *ast.DoStatement:
+		symbols = s.blockSymbols(file, info, &stmt.Body)
+	case *ast.WhileStatement:
+		symbols = s.blockSymbols(file, info, &stmt.Body)
+	case *ast.RepeatStatement:
+		symbols = s.blockSymbols(file, info, &stmt.Body)
+	case *ast.ForStatement:
+		symbols = s.blockSymbols(file, info, &stmt.Body)
+	case *ast.ForInStatement:
+		symbols = s.blockSymbols(file, info, &stmt.Body)
+	case *ast.IfStatement:
+		for _, clause := range stmt.Clauses {
+			symbols = append(symbols, s.blockSymbols(file, info, &clause.Body)...)
+		}
+	}
+	return symbols
+}
+
+// valueSymbol returns the symbol of a name that is assigned a value. Names that are assigned functions are functions,
+// or methods if they are fields, and names that are assigned tables have the fields of the tables as children.
+func (s *Server) valueSymbol(file *ast.File, info *types.Info, name string, kind protocol.SymbolKind, def ast.Node, selection ast.Node, value ast.Expression) protocol.DocumentSymbol {
+	switch value := value.(type) {
+	case *ast.FunctionExpression:
+		if kind == protocol.SymbolKindField {
+			kind = protocol.SymbolKindMethod
+		} else {
+			kind = protocol.SymbolKindFunction
+		}
+		symbol := s.documentSymbol(file, name, kind, def, selection)
+		symbol.Children = s.blockSymbols(file, info, &value.Body)
+		return symbol
+	case *ast.TableLiteral:
+		symbol := s.documentSymbol(file, name, kind, def, selection)
+		symbol.Children = s.tableSymbols(file, info, value)
+		return symbol
+	}
+	return s.documentSymbol(file, name, kind, def, selection)
+}
+
+// tableSymbols returns the symbols of the fields of a table constructor that have constant names.
+func (s *Server) tableSymbols(file *ast.File, info *types.Info, table *ast.TableLiteral) []protocol.DocumentSymbol {
+	symbols := []protocol.DocumentSymbol{}
+	for _, pair := range table.Fields.Pairs {
+		switch field := pair.Node.(type) {
+		case *ast.TableSimpleKeyField:
+			symbols = append(symbols, s.valueSymbol(file, info, field.Name.Token.Literal, protocol.SymbolKindField, field, &field.Name, field.Expr))
+		case *ast.TableExpressionKeyField:
+			if lit, ok := field.Name.(*ast.StringLiteral); ok {
+				if key, ok := types.StringValue(lit); ok {
+					symbols = append(symbols, s.valueSymbol(file, info, key, protocol.SymbolKindField, field, lit, field.Expr))
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// documentSymbol returns a symbol without children that spans the definition and selects the name.
+func (s *Server) documentSymbol(file *ast.File, name string, kind protocol.SymbolKind, def ast.Node, selection ast.Node) protocol.DocumentSymbol {
+	return protocol.DocumentSymbol{
+		Name:           name,
+		Kind:           kind,
+		Range:          file.ToProtocolRange(ast.Range(def), s.encoding),
+		SelectionRange: file.ToProtocolRange(ast.Range(selection), s.encoding),
+	}
+}
+
+// valueAt returns the expression at the given index of a list of expressions, or nil if there is none.
+func valueAt(exps *ast.Punctuated[ast.Expression], i int) ast.Expression {
+	if exps == nil || i >= len(exps.Pairs) {
+		return nil
+	}
+	return exps.Pairs[i].Node
+}
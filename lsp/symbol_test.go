@@ -0,0 +1,126 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// symbolTree returns the names of the symbols and of their children, indented by depth.
+func symbolTree(symbols []protocol.DocumentSymbol, depth int) []string {
+	lines := []string{}
+	for _, symbol := range symbols {
+		lines = append(lines, fmt.Sprintf("%s%s %d", strings.Repeat("  ", depth), symbol.Name, symbol.Kind))
+		lines = append(lines, symbolTree(symbol.Children, depth+1)...)
+	}
+	return lines
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	uri := "file:///main.lua"
+	s := openFile(t, uri, `local M = { version = 1, ["name"] = "m" }
+local LIMIT <const> = 10
+
+function M.run(count)
+  local total = 0
+  for i = 1, count do
+    local step = i
+    total = total + step
+  end
+  return total
+end
+
+function M:stop() end
+
+config = { debug = false, log = function() end }
+M.state = nil
+
+local function helper() end
+`)
+	params := &protocol.DocumentSymbolParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}
+	result, err := s.textDocumentDocumentSymbol(testContext(t, params, nil), params)
+	require.NoError(t, err)
+	symbols := result.([]protocol.DocumentSymbol)
+	assert.Equal(t, []string{
+		"M 13",
+		"  version 8",
+		"  name 8",
+		"LIMIT 14",
+		"M.run 6",
+		"  total 13",
+		"  step 13",
+		"M:stop 6",
+		"config 13",
+		"  debug 8",
+		"  log 6",
+		"M.state 8",
+		"helper 12",
+	}, symbolTree(symbols, 0))
+	assert.Equal(t, protocol.Range{Start: protocol.Position{Line: 3, Character: 0}, End: protocol.Position{Line: 10, Character: 3}}, symbols[2].Range)
+	assert.Equal(t, protocol.Range{Start: protocol.Position{Line: 3, Character: 9}, End: protocol.Position{Line: 3, Character: 14}}, symbols[2].SelectionRange)
+}
+
+func TestPartialResults(t *testing.T) {
+	uri := "file:///main.lua"
+	text := ""
+	for i := 0; i < partialResultBatch*2+10; i++ {
+		text += fmt.Sprintf("local value%d = %d\n", i, i)
+	}
+	s := openFile(t, uri, text)
+
+	// Without a token, every symbol is in the response.
+	params := &protocol.DocumentSymbolParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}
+	notifications := []notification{}
+	result, err := s.textDocumentDocumentSymbol(testContext(t, params, &notifications), params)
+	require.NoError(t, err)
+	assert.Len(t, result, partialResultBatch*2+10)
+	assert.Empty(t, notifications)
+
+	// With a token, the symbols are streamed in batches while they are found, and the response is empty.
+	withToken := map[string]any{"textDocument": map[string]any{"uri": uri}, "partialResultToken": "symbols"}
+	result, err = s.textDocumentDocumentSymbol(testContext(t, withToken, &notifications), params)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+	sizes := []int{}
+	for _, n := range notifications {
+		require.Equal(t, protocol.MethodProgress, n.method)
+		progress := n.params.(protocol.ProgressParams)
+		assert.Equal(t, "symbols", progress.Token.Value)
+		sizes = append(sizes, len(progress.Value.([]protocol.DocumentSymbol)))
+	}
+	assert.Equal(t, []int{partialResultBatch, partialResultBatch, 10}, sizes)
+}
+
+func TestPartialReferences(t *testing.T) {
+	uri := "file:///main.lua"
+	text := "local value = 1\n" + strings.Repeat("print(value)\n", partialResultBatch+5)
+	s := openFile(t, uri, text)
+	params := &protocol.ReferenceParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 6},
+		},
+		Context: protocol.ReferenceContext{IncludeDeclaration: true},
+	}
+	locations, err := s.textDocumentReferences(testContext(t, params, nil), params)
+	require.NoError(t, err)
+	assert.Len(t, locations, partialResultBatch+6)
+
+	withToken := map[string]any{
+		"textDocument":       map[string]any{"uri": uri},
+		"position":           map[string]any{"line": 0, "character": 6},
+		"context":            map[string]any{"includeDeclaration": true},
+		"partialResultToken": 1,
+	}
+	notifications := []notification{}
+	locations, err = s.textDocumentReferences(testContext(t, withToken, &notifications), params)
+	require.NoError(t, err)
+	assert.Empty(t, locations)
+	require.Len(t, notifications, 1)
+	assert.Len(t, notifications[0].params.(protocol.ProgressParams).Value, partialResultBatch+6)
+}
@@ -0,0 +1,267 @@
+package lsp
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const MethodTests = "luapls/tests"
+
+// defaultTestCommand runs a single busted test or block. `${file}` is replaced with the path of the file, and
+// `${name}` with a pattern that matches the full name of the test.
+const defaultTestCommand = "busted --filter ${name} ${file}"
+
+// commandRunTest runs the test command for a busted test or block in the workspace. Its arguments are the URI of the
+// file, the full name of the test, and whether it is a block. The output of the command is logged, and its result is
+// shown to the user.
+const commandRunTest = "luapls.runTest"
+
+type TestsParams struct {
+	// If omitted, the tests of every file in the workspace are returned.
+	TextDocument *protocol.TextDocumentIdentifier `json:"textDocument,omitempty"`
+}
+
+// Test is a busted `describe` block or test.
+type Test struct {
+	Name string `json:"name"`
+	// FullName is the name of the test prefixed with the names of the blocks that contain it, separated by spaces,
+	// which is how busted identifies tests.
+	FullName string         `json:"fullName"`
+	Block    bool           `json:"block"` // Whether this is a `describe` block rather than a single test.
+	URI      protocol.URI   `json:"uri"`
+	Range    protocol.Range `json:"range"`
+	Children []Test         `json:"children,omitempty"`
+}
+
+type TestsResult struct {
+	Tests []Test `json:"tests"`
+}
+
+// bustedBlocks are the functions that group tests, and bustedTests are the functions that declare a single test.
+var (
+	bustedBlocks = map[string]bool{"describe": true, "context": true, "insulate": true, "expose": true}
+	bustedTests  = map[string]bool{"it": true, "spec": true, "test": true, "pending": true}
+)
+
+func (s *Server) tests(ctx *glsp.Context, params *TestsParams) (any, error) {
+	result := TestsResult{Tests: []Test{}}
+	if params.TextDocument != nil {
+		if file := s.getFile(params.TextDocument.URI); file != nil {
+			result.Tests = s.findTests(file, s.getInfo(file.URI))
+		}
+		return result, nil
+	}
+	for _, env := range s.allEnvironments() {
+		uris := []protocol.URI{}
+		for uri := range env.Files {
+			if env.Owns(uri) {
+				uris = append(uris, uri)
+			}
+		}
+		sort.Strings(uris)
+		for _, uri := range uris {
+			result.Tests = append(result.Tests, s.findTests(env.Files[uri], env.Info[uri])...)
+		}
+	}
+	return result, nil
+}
+
+// findTests returns the busted tests of a file, nested in the `describe` blocks that contain them.
+func (s *Server) findTests(file *ast.File, info *types.Info) []Test {
+	if file.Block == nil || info == nil {
+		return []Test{}
+	}
+	var find func(block *ast.Block, prefix string) []Test
+	find = func(block *ast.Block, prefix string) []Test {
+		tests := []Test{}
+		for _, pair := range block.Pairs {
+			fc, ok := pair.Node.(*ast.FunctionCall)
+			if !ok || len(fc.Args.Pairs) == 0 {
+				continue
+			}
+			ident, ok := fc.Name.(*ast.Identifier)
+			if !ok || !bustedBlocks[ident.Token.Literal] && !bustedTests[ident.Token.Literal] {
+				continue
+			}
+			if sym := info.SymbolOf(ident); sym != nil && sym.Kind != types.SymbolGlobal {
+				continue
+			}
+			lit, ok := fc.Args.Pairs[0].Node.(*ast.StringLiteral)
+			if !ok {
+				continue
+			}
+			name, ok := types.StringValue(lit)
+			if !ok {
+				continue
+			}
+			test := Test{
+				Name:     name,
+				FullName: strings.TrimPrefix(prefix+" "+name, " "),
+				Block:    bustedBlocks[ident.Token.Literal],
+				URI:      file.URI,
+				Range:    file.ToProtocolRange(ast.Range(fc), s.encoding),
+			}
+			if test.Block && len(fc.Args.Pairs) > 1 {
+				if fn, ok := fc.Args.Pairs[1].Node.(*ast.FunctionExpression); ok {
+					test.Children = find(&fn.Body, test.FullName)
+				}
+			}
+			tests = append(tests, test)
+		}
+		return tests
+	}
+	return find(file.Block, "")
+}
+
+// testCodeLenses returns a lens that runs each test of the file, and each block of tests.
+func (s *Server) testCodeLenses(file *ast.File, info *types.Info) []protocol.CodeLens {
+	lenses := []protocol.CodeLens{}
+	var add func(tests []Test)
+	add = func(tests []Test) {
+		for _, test := range tests {
+			title := "Run test"
+			if test.Block {
+				title = "Run tests"
+			}
+			lenses = append(lenses, protocol.CodeLens{
+				Range: protocol.Range{Start: test.Range.Start, End: test.Range.Start},
+				Command: &protocol.Command{
+					Title:     title,
+					Command:   commandRunTest,
+					Arguments: []any{file.URI, test.FullName, test.Block},
+				},
+			})
+			add(test.Children)
+		}
+	}
+	add(s.findTests(file, info))
+	return lenses
+}
+
+// testCommandArgs returns the arguments of the command that runs the test or block with the given full name in the
+// file at the given path. The command is not run by a shell, so that neither the name nor the path can run anything
+// else.
+func (s *Server) testCommandArgs(path string, fullName string, block bool) ([]string, error) {
+	command := defaultTestCommand
+	if s.config.TestCommand != nil {
+		command = *s.config.TestCommand
+	}
+	args, err := splitArgs(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("The test command is empty")
+	}
+	// The full names of the tests within a block start with the name of the block.
+	filter := "^" + luaPatternEscape(fullName) + "$"
+	if block {
+		filter = "^" + luaPatternEscape(fullName) + " "
+	}
+	replacer := strings.NewReplacer("${file}", path, "${name}", filter)
+	for i := range args {
+		args[i] = replacer.Replace(args[i])
+	}
+	return args, nil
+}
+
+// splitArgs splits a command into its arguments at whitespace. Single and double quotes group text that contains
+// whitespace into one argument, and are removed.
+func splitArgs(command string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	for _, c := range command {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated quote in the test command")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// runTest starts the test command for the test that the arguments of commandRunTest name. The command runs in the
+// background in the first workspace folder, or in the directory of the file outside of a workspace, since tests may
+// take a while.
+func (s *Server) runTest(ctx *glsp.Context, params *protocol.ExecuteCommandParams) error {
+	file, err := s.commandFile(params)
+	if err != nil {
+		return err
+	}
+	var name string
+	var block, ok bool
+	if len(params.Arguments) == 3 {
+		name, ok = params.Arguments[1].(string)
+		if ok {
+			block, ok = params.Arguments[2].(bool)
+		}
+	}
+	if !ok {
+		return fmt.Errorf("'%s' requires the URI of a file, the full name of a test, and whether it is a block", params.Command)
+	}
+	path, err := util.URIToPath(file.URI)
+	if err != nil {
+		return err
+	}
+	dir := s.rootPath
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	args, err := s.testCommandArgs(path, name, block)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	go func() {
+		output, err := cmd.CombinedOutput()
+		ctx.Notify(protocol.ServerWindowLogMessage, protocol.LogMessageParams{Type: protocol.MessageTypeLog, Message: string(output)})
+		result := protocol.ShowMessageParams{Type: protocol.MessageTypeInfo, Message: fmt.Sprintf("%s: '%s' passed", LS_NAME, name)}
+		if err != nil {
+			result = protocol.ShowMessageParams{Type: protocol.MessageTypeError, Message: fmt.Sprintf("%s: '%s' failed: %s", LS_NAME, name, err)}
+		}
+		ctx.Notify(protocol.ServerWindowShowMessage, result)
+	}()
+	return nil
+}
+
+// luaPatternEscape escapes the magic characters of a Lua pattern, since busted filters tests by pattern.
+func luaPatternEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune("^$()%.[]*+-?", c) {
+			b.WriteByte('%')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX shell")
+	}
+	uri := "file:///main_spec.lua"
+	s := openFile(t, uri, `describe("math", function()
+  it("adds & calc", function() end)
+end)`)
+	s.rootPath = t.TempDir()
+	s.config.TestCommand = util.Ptr(`sh -c 'printf %s "$1" > result.txt' sh ${name}`)
+	assert.Contains(t, commands, commandRunTest)
+
+	lenses := s.testCodeLenses(s.getFile(uri), s.getInfo(uri))
+	require.Len(t, lenses, 2)
+	assert.Equal(t, []any{uri, "math adds & calc", false}, lenses[1].Command.Arguments)
+
+	messages := make(chan protocol.ShowMessageParams, 1)
+	ctx := &glsp.Context{Notify: func(method string, params any) {
+		if method == protocol.ServerWindowShowMessage {
+			messages <- params.(protocol.ShowMessageParams)
+		}
+	}}
+	_, err := s.workspaceExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: commandRunTest, Arguments: lenses[1].Command.Arguments})
+	require.NoError(t, err)
+	select {
+	case message := <-messages:
+		assert.Equal(t, protocol.MessageTypeInfo, message.Type)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the test command did not finish")
+	}
+	result, err := os.ReadFile(filepath.Join(s.rootPath, "result.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "^math adds & calc$", string(result))
+
+	_, err = s.workspaceExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: commandRunTest, Arguments: []any{uri, "math adds & calc"}})
+	assert.Error(t, err)
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`busted  --filter "${name}" 'a b'c ${file}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"busted", "--filter", "${name}", "a bc", "${file}"}, args)
+	_, err = splitArgs(`busted "${name}`)
+	assert.Error(t, err)
+}
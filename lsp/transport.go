@@ -0,0 +1,292 @@
+package lsp
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/sourcegraph/jsonrpc2"
+	wsjsonrpc2 "github.com/sourcegraph/jsonrpc2/websocket"
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Options configure how the server communicates with clients.
+type Options struct {
+	LogLevel int
+	// Listen is the address that TCP connections are accepted on, and WebSocket the address that WebSocket
+	// connections are accepted on, for editors that run in a browser. If neither is set, the server communicates
+	// over stdin and stdout instead.
+	Listen    string
+	WebSocket string
+	// Shared makes every connection use the same session, so that they see the same workspace and open files.
+	// Otherwise each connection has a session of its own, which is discarded when the connection closes.
+	Shared bool
+	// AllowedOrigins are the origins of the pages that may open WebSocket connections, or `*` for any page. If it is
+	// empty, only pages from the same host may connect.
+	AllowedOrigins []string
+	// Token is required of connections if it is set. WebSocket connections give it either as a bearer token in the
+	// `Authorization` header, or in the `token` query parameter, since browsers cannot set headers on WebSocket
+	// connections. TCP connections give it as the `token` initialization option of their `initialize` request, and
+	// every message before it is rejected.
+	Token string
+}
+
+func Run(options Options) error {
+	commonlog.Configure(options.LogLevel, nil)
+	if options.WebSocket != "" {
+		return runWebSocket(options)
+	}
+	if options.Listen != "" {
+		return runTCP(options)
+	}
+	return newServer(options.LogLevel).server.RunStdio()
+}
+
+// sessions returns a function that returns the session of each new connection.
+func sessions(options Options) func() *Server {
+	if !options.Shared {
+		return func() *Server {
+			s := newServer(options.LogLevel)
+			s.remote = true
+			return s
+		}
+	}
+	shared := newServer(options.LogLevel)
+	shared.shared = true
+	shared.remote = true
+	return func() *Server { return shared }
+}
+
+// runTCP accepts connections until the listener fails.
+func runTCP(options Options) error {
+	log := commonlog.GetLoggerf("%s.server", LS_NAME)
+	listener, err := net.Listen("tcp", options.Listen)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	log.Infof("Listening for TCP connections on %s", listener.Addr())
+
+	session := sessions(options)
+	for id := 1; ; id++ {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		s := session()
+		log.Infof("Accepted connection #%d from %s", id, conn.RemoteAddr())
+		go func(id int) {
+			<-s.serve(jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{}), options.Token).DisconnectNotify()
+			log.Infof("Connection #%d closed", id)
+		}(id)
+	}
+}
+
+// runWebSocket accepts WebSocket connections until the listener fails.
+func runWebSocket(options Options) error {
+	log := commonlog.GetLoggerf("%s.server", LS_NAME)
+	listener, err := net.Listen("tcp", options.WebSocket)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	log.Infof("Listening for WebSocket connections on %s", listener.Addr())
+
+	upgrader := websocket.Upgrader{}
+	if len(options.AllowedOrigins) > 0 {
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			return slices.Contains(options.AllowedOrigins, "*") || slices.Contains(options.AllowedOrigins, r.Header.Get("Origin"))
+		}
+	}
+	session := sessions(options)
+	var count atomic.Int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if options.Token != "" && !authorized(r, options.Token) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already responded with the error.
+			log.Infof("Rejected WebSocket connection from %s: %s", r.RemoteAddr, err)
+			return
+		}
+		defer conn.Close()
+		id := count.Add(1)
+		log.Infof("Accepted WebSocket connection #%d from %s", id, r.RemoteAddr)
+		// The token was already checked before the connection was upgraded.
+		<-session().serve(wsjsonrpc2.NewObjectStream(conn), "").DisconnectNotify()
+		log.Infof("WebSocket connection #%d closed", id)
+	}
+	return http.Serve(listener, http.HandlerFunc(handler))
+}
+
+// authorized returns whether a request carries the given token.
+func authorized(r *http.Request, token string) bool {
+	given := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		given = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// serve handles the messages of a connection with the session. If a token is given, the connection must give it in its
+// `initialize` request before any other message is handled.
+func (s *Server) serve(stream jsonrpc2.ObjectStream, token string) *jsonrpc2.Conn {
+	options := []jsonrpc2.ConnOpt{}
+	if s.server.Debug {
+		options = append(options, jsonrpc2.LogMessages(rpcLogger{commonlog.GetLoggerf("%s.rpc", LS_NAME)}))
+	}
+	handler := s.handleRequest
+	if token != "" {
+		handler = s.authenticate(token)
+	}
+	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(handler), options...)
+	if s.shared {
+		s.connsMu.Lock()
+		s.conns[conn] = true
+		s.connsMu.Unlock()
+		go func() {
+			<-conn.DisconnectNotify()
+			s.connsMu.Lock()
+			delete(s.conns, conn)
+			s.connsMu.Unlock()
+		}()
+	}
+	return conn
+}
+
+// authenticate returns a handler that rejects the messages of a connection until it sends an `initialize` request
+// with the given token. Messages of a connection are handled one at a time, so the handler needs no lock.
+func (s *Server) authenticate(token string) func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+	authenticated := false
+	return func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+		if !authenticated && req.Method == string(protocol.MethodInitialize) && req.Params != nil {
+			var params struct {
+				InitializationOptions struct {
+					Token string `json:"token"`
+				} `json:"initializationOptions"`
+			}
+			_ = json.Unmarshal(*req.Params, &params)
+			authenticated = subtle.ConstantTimeCompare([]byte(params.InitializationOptions.Token), []byte(token)) == 1
+		}
+		if !authenticated {
+			s.log.Infof("Rejected %s from a connection that has not given the token", req.Method)
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "Unauthorized"}
+		}
+		return s.handleRequest(ctx, conn, req)
+	}
+}
+
+// recipients returns the connections that a notification that was sent while handling a message of the given
+// connection is sent to. Every client of a shared session sees the same workspace, so it receives the diagnostics,
+// status and messages of it no matter which client caused them. Progress and traces are the exceptions: progress is
+// reported to the client that created its token, and traces to the client that asked for them. Requests that the
+// session makes, such as `workspace/configuration` and `client/registerCapability`, are not broadcast at all, since
+// they expect a single response; they go to the client whose message is being handled.
+func (s *Server) recipients(conn *jsonrpc2.Conn, method string) []*jsonrpc2.Conn {
+	conns := []*jsonrpc2.Conn{conn}
+	if !s.shared || method == string(protocol.MethodProgress) || method == string(protocol.MethodLogTrace) {
+		return conns
+	}
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	for other := range s.conns {
+		if other != conn {
+			conns = append(conns, other)
+		}
+	}
+	return conns
+}
+
+// handleRequest adapts a message of a connection to the handler of the session, in the same way as the protocol
+// package does for stdio. The connection is closed when the client exits.
+func (s *Server) handleRequest(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	glspCtx := glsp.Context{
+		Method: req.Method,
+		Notify: func(method string, params any) {
+			for _, recipient := range s.recipients(conn, method) {
+				if err := recipient.Notify(ctx, method, params); err != nil {
+					s.log.Errorf("%s", err)
+				}
+			}
+		},
+		// Requests only go to the connection whose message is handled, even in a shared session.
+		Call: func(method string, params any, result any) {
+			if err := conn.Call(ctx, method, params, result); err != nil {
+				s.log.Errorf("%s", err)
+			}
+		},
+	}
+	if req.Params != nil {
+		glspCtx.Params = *req.Params
+	}
+	if s.shared {
+		if r, handled, err := s.handleShared(req.Method, glspCtx.Params); handled {
+			if err != nil {
+				return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: err.Error()}
+			}
+			return r, nil
+		}
+	}
+	r, validMethod, validParams, err := s.Handle(&glspCtx)
+	switch {
+	case req.Method == "exit":
+		return nil, conn.Close()
+	case !validMethod:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: fmt.Sprintf("method not supported: %s", req.Method)}
+	case !validParams:
+		rpcErr := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		if err != nil {
+			rpcErr.Message = err.Error()
+		}
+		return nil, rpcErr
+	case err != nil:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: err.Error()}
+	}
+	return r, nil
+}
+
+// handleShared handles the lifecycle messages of a client of a shared session. Only the first client initializes the
+// session, and clients that shut down only close their connection, so that the session outlives them. Later clients
+// must support the position encoding that the first client negotiated, since the session has only one.
+func (s *Server) handleShared(method string, params json.RawMessage) (any, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch method {
+	case "initialize":
+		if s.initializeResult == nil {
+			return nil, false, nil
+		}
+		// Every client supports UTF-16, whether it lists it or not.
+		if s.encoding != token.PositionEncodingUTF16 && !slices.Contains(clientEncodings(params), s.encoding) {
+			return nil, true, fmt.Errorf("The shared session counts positions in %s, which the client does not support", s.encoding)
+		}
+		return s.initializeResult, true, nil
+	case "initialized":
+		// The session already published its diagnostics and asked the first client for its configuration.
+		return nil, s.isInitialized, nil
+	case "shutdown":
+		return nil, true, nil
+	}
+	return nil, false, nil
+}
+
+// rpcLogger logs the messages of a connection at the debug level.
+type rpcLogger struct {
+	log commonlog.Logger
+}
+
+func (l rpcLogger) Printf(format string, v ...any) {
+	l.log.Debugf(strings.TrimSuffix(format, "\n"), v...)
+}
@@ -0,0 +1,190 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient is a connection to a session, which collects the diagnostics and messages that the session sends, and
+// the methods of the requests that it makes. It answers `workspace/configuration` requests with settings.
+type testClient struct {
+	conn        *jsonrpc2.Conn
+	diagnostics chan protocol.PublishDiagnosticsParams
+	messages    chan protocol.ShowMessageParams
+	calls       chan string
+	settings    any
+}
+
+// connect returns a client of a new connection to the session, which must give the token if it is not empty.
+func connect(t *testing.T, s *Server, token string) *testClient {
+	serverSide, clientSide := net.Pipe()
+	s.serve(jsonrpc2.NewBufferedStream(serverSide, jsonrpc2.VSCodeObjectCodec{}), token)
+	client := &testClient{
+		diagnostics: make(chan protocol.PublishDiagnosticsParams, 10),
+		messages:    make(chan protocol.ShowMessageParams, 10),
+		calls:       make(chan string, 10),
+	}
+	handler := jsonrpc2.HandlerWithError(func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+		if !req.Notif {
+			client.calls <- req.Method
+		}
+		switch {
+		case req.Params == nil:
+		case req.Method == protocol.ServerTextDocumentPublishDiagnostics:
+			var params protocol.PublishDiagnosticsParams
+			if err := json.Unmarshal(*req.Params, &params); err == nil {
+				client.diagnostics <- params
+			}
+		case req.Method == protocol.ServerWindowShowMessage:
+			var params protocol.ShowMessageParams
+			if err := json.Unmarshal(*req.Params, &params); err == nil {
+				client.messages <- params
+			}
+		case req.Method == protocol.ServerWorkspaceConfiguration:
+			return []any{client.settings}, nil
+		}
+		return nil, nil
+	})
+	client.conn = jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(clientSide, jsonrpc2.VSCodeObjectCodec{}), handler)
+	t.Cleanup(func() { client.conn.Close() })
+	return client
+}
+
+// initialize initializes the session with the given position encodings, and returns the one that was negotiated.
+func (c *testClient) initialize(t *testing.T, encodings ...string) string {
+	params := map[string]any{"capabilities": map[string]any{"general": map[string]any{"positionEncodings": encodings}}}
+	var result struct {
+		Capabilities struct {
+			PositionEncoding string `json:"positionEncoding"`
+		} `json:"capabilities"`
+	}
+	require.NoError(t, c.conn.Call(context.Background(), protocol.MethodInitialize, params, &result))
+	require.NoError(t, c.conn.Notify(context.Background(), protocol.MethodInitialized, map[string]any{}))
+	return result.Capabilities.PositionEncoding
+}
+
+func (c *testClient) receive(t *testing.T) protocol.PublishDiagnosticsParams {
+	select {
+	case params := <-c.diagnostics:
+		return params
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no diagnostics were published")
+		return protocol.PublishDiagnosticsParams{}
+	}
+}
+
+func TestSessionEncodings(t *testing.T) {
+	utf8 := connect(t, newServer(0), "")
+	assert.Equal(t, "utf-8", utf8.initialize(t, "utf-8"))
+	utf16 := connect(t, newServer(0), "")
+	assert.Equal(t, "utf-16", utf16.initialize(t))
+
+	// The undefined global starts after a character that is one UTF-16 code unit but two bytes long.
+	text := "local s = \"é\" .. undefined_global\n"
+	open := protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{URI: "file:///main.lua", LanguageID: "lua", Text: text}}
+	for client, character := range map[*testClient]protocol.UInteger{utf8: 18, utf16: 17} {
+		require.NoError(t, client.conn.Notify(context.Background(), protocol.MethodTextDocumentDidOpen, open))
+		params := client.receive(t)
+		require.NotEmpty(t, params.Diagnostics)
+		assert.Equal(t, character, params.Diagnostics[0].Range.Start.Character)
+	}
+}
+
+func TestSharedSessionNotifications(t *testing.T) {
+	s := newServer(0)
+	s.shared = true
+	first := connect(t, s, "")
+	second := connect(t, s, "")
+	first.initialize(t)
+	second.initialize(t)
+
+	open := protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{URI: "file:///main.lua", LanguageID: "lua", Text: "print(undefined_global)\n"}}
+	require.NoError(t, first.conn.Notify(context.Background(), protocol.MethodTextDocumentDidOpen, open))
+	assert.Equal(t, "file:///main.lua", first.receive(t).URI)
+	assert.Equal(t, "file:///main.lua", second.receive(t).URI)
+}
+
+func TestSharedSessionRequests(t *testing.T) {
+	s := newServer(0)
+	s.shared = true
+	first := connect(t, s, "")
+	second := connect(t, s, "")
+	first.settings = map[string]any{"mode": "lenient"}
+
+	// Only the client that initialized the session is asked for its configuration, but every client is shown the
+	// problem with it.
+	params := map[string]any{"capabilities": map[string]any{"workspace": map[string]any{"configuration": true}}}
+	var result any
+	require.NoError(t, first.conn.Call(context.Background(), protocol.MethodInitialize, params, &result))
+	require.NoError(t, first.conn.Notify(context.Background(), protocol.MethodInitialized, map[string]any{}))
+	for _, client := range []*testClient{first, second} {
+		select {
+		case message := <-client.messages:
+			assert.Contains(t, message.Message, "Unknown mode 'lenient'")
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no message was shown")
+		}
+	}
+	assert.Equal(t, protocol.ServerWorkspaceConfiguration, <-first.calls)
+	assert.Empty(t, second.calls)
+}
+
+func TestSharedSessionJoin(t *testing.T) {
+	s := newServer(0)
+	s.shared = true
+	first := connect(t, s, "")
+	assert.Equal(t, "utf-8", first.initialize(t, "utf-8"))
+	open := protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{URI: "file:///main.lua", LanguageID: "lua", Text: "print(undefined_global)\n"}}
+	require.NoError(t, first.conn.Notify(context.Background(), protocol.MethodTextDocumentDidOpen, open))
+	assert.Equal(t, "file:///main.lua", first.receive(t).URI)
+
+	// A client that only supports UTF-16 cannot join a session that counts positions in UTF-8.
+	rejected := connect(t, s, "")
+	var result any
+	params := map[string]any{"capabilities": map[string]any{"general": map[string]any{"positionEncodings": []string{"utf-16"}}}}
+	assert.Error(t, rejected.conn.Call(context.Background(), protocol.MethodInitialize, params, &result))
+
+	second := connect(t, s, "")
+	assert.Equal(t, "utf-8", second.initialize(t, "utf-16", "utf-8"))
+	// The second client's `initialized` notification does not publish the diagnostics of the session again, so the
+	// next diagnostics are those of the file that is opened next.
+	open.TextDocument.URI = "file:///other.lua"
+	require.NoError(t, second.conn.Notify(context.Background(), protocol.MethodTextDocumentDidOpen, open))
+	assert.Equal(t, "file:///other.lua", first.receive(t).URI)
+	assert.Equal(t, "file:///other.lua", second.receive(t).URI)
+}
+
+func TestConnectionToken(t *testing.T) {
+	client := connect(t, newServer(0), "secret")
+	var result any
+	// Every message is rejected until the client has initialized the session with the token.
+	assert.Error(t, client.conn.Call(context.Background(), MethodTests, map[string]any{}, &result))
+	for _, token := range []any{nil, "wrong"} {
+		params := map[string]any{"capabilities": map[string]any{}, "initializationOptions": map[string]any{"token": token}}
+		assert.Error(t, client.conn.Call(context.Background(), protocol.MethodInitialize, params, &result))
+	}
+	params := map[string]any{"capabilities": map[string]any{}, "initializationOptions": map[string]any{"token": "secret"}}
+	require.NoError(t, client.conn.Call(context.Background(), protocol.MethodInitialize, params, &result))
+	require.NoError(t, client.conn.Notify(context.Background(), protocol.MethodInitialized, map[string]any{}))
+	assert.NoError(t, client.conn.Call(context.Background(), MethodTests, map[string]any{}, &result))
+}
+
+func TestRemoteTestCommand(t *testing.T) {
+	s := newServer(0)
+	s.remote = true
+	require.NoError(t, s.updateConfig(testContext(t, nil, nil), map[string]any{"testCommand": "sh ${file}"}))
+	assert.Nil(t, s.config.TestCommand)
+
+	s = newServer(0)
+	require.NoError(t, s.updateConfig(testContext(t, nil, nil), map[string]any{"testCommand": "busted ${file}"}))
+	assert.Equal(t, "busted ${file}", *s.config.TestCommand)
+}
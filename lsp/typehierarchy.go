@@ -0,0 +1,182 @@
+package lsp
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// The type hierarchy was added in LSP 3.17, which the protocol package does not support yet, so the types are
+// declared here.
+
+const (
+	MethodTextDocumentPrepareTypeHierarchy = "textDocument/prepareTypeHierarchy"
+	MethodTypeHierarchySupertypes          = "typeHierarchy/supertypes"
+	MethodTypeHierarchySubtypes            = "typeHierarchy/subtypes"
+)
+
+type TypeHierarchyPrepareParams struct {
+	protocol.TextDocumentPositionParams
+}
+
+type TypeHierarchyItem struct {
+	Name           string               `json:"name"`
+	Kind           protocol.SymbolKind  `json:"kind"`
+	Detail         *string              `json:"detail,omitempty"`
+	URI            protocol.DocumentUri `json:"uri"`
+	Range          protocol.Range       `json:"range"`
+	SelectionRange protocol.Range       `json:"selectionRange"`
+	Data           any                  `json:"data,omitempty"`
+}
+
+type TypeHierarchySupertypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+}
+
+type TypeHierarchySubtypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+}
+
+// typeHierarchyData identifies the class of a type hierarchy item, and the document that the hierarchy was prepared
+// from, whose environment the class is looked up in.
+type typeHierarchyData struct {
+	URI   protocol.URI `json:"uri"`
+	Class string       `json:"class"`
+}
+
+// textDocumentPrepareTypeHierarchy returns the class at the given position. This is either the name of a class or
+// one of its parents in a `---@class` annotation, or an expression whose type is a class.
+func (s *Server) textDocumentPrepareTypeHierarchy(ctx *glsp.Context, params *TypeHierarchyPrepareParams) (any, error) {
+	file := s.getFile(params.TextDocument.URI)
+	if file == nil || file.Block == nil {
+		return nil, nil
+	}
+	info := s.getInfo(file.URI)
+	env := s.environmentOf(file.URI)
+	pos := file.ToPos(params.Position, s.encoding)
+	class := annotatedClassAt(env, info, pos)
+	if class == nil {
+		ident, sym := s.identAt(file, info, params.Position)
+		switch {
+		case sym != nil:
+			class, _ = types.Resolve(sym.Type).(*types.Named)
+		case ident != nil:
+			class, _ = types.Resolve(info.TypeOf(ident)).(*types.Named)
+		}
+	}
+	if class == nil {
+		return nil, nil
+	}
+	item := s.typeHierarchyItem(file.URI, class)
+	if item == nil {
+		return nil, nil
+	}
+	return []TypeHierarchyItem{*item}, nil
+}
+
+// typeHierarchySupertypes returns the parents of the class, including the class that its metatable's `__index`
+// refers to.
+func (s *Server) typeHierarchySupertypes(ctx *glsp.Context, params *TypeHierarchySupertypesParams) (any, error) {
+	data, class, err := s.itemClass(params.Item)
+	if class == nil || err != nil {
+		return nil, err
+	}
+	items := []TypeHierarchyItem{}
+	for _, parent := range class.Supertypes() {
+		if item := s.typeHierarchyItem(data.URI, parent); item != nil {
+			items = append(items, *item)
+		}
+	}
+	return items, nil
+}
+
+// typeHierarchySubtypes returns the classes that inherit directly from the class.
+func (s *Server) typeHierarchySubtypes(ctx *glsp.Context, params *TypeHierarchySubtypesParams) (any, error) {
+	data, class, err := s.itemClass(params.Item)
+	if class == nil || err != nil {
+		return nil, err
+	}
+	env := s.environmentOf(data.URI)
+	names := []string{}
+	for name := range env.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := []TypeHierarchyItem{}
+	for _, name := range names {
+		child, ok := env.Types[name].(*types.Named)
+		if !ok {
+			continue
+		}
+		for _, parent := range child.Supertypes() {
+			if parent != class {
+				continue
+			}
+			if item := s.typeHierarchyItem(data.URI, child); item != nil {
+				items = append(items, *item)
+			}
+			break
+		}
+	}
+	return items, nil
+}
+
+// itemClass returns the class that a type hierarchy item was created for, or nil if it no longer exists.
+func (s *Server) itemClass(item TypeHierarchyItem) (typeHierarchyData, *types.Named, error) {
+	// The data is sent back as it was decoded from JSON, so it is converted back to its original type.
+	var data typeHierarchyData
+	encoded, err := json.Marshal(item.Data)
+	if err != nil {
+		return data, nil, err
+	}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return data, nil, err
+	}
+	class, _ := s.environmentOf(data.URI).Types[data.Class].(*types.Named)
+	return data, class, nil
+}
+
+func (s *Server) typeHierarchyItem(uri protocol.URI, class *types.Named) *TypeHierarchyItem {
+	location := s.typesLocation(class.Loc)
+	if location == nil {
+		return nil
+	}
+	return &TypeHierarchyItem{
+		Name:           class.Name,
+		Kind:           protocol.SymbolKindClass,
+		URI:            location.URI,
+		Range:          location.Range,
+		SelectionRange: location.Range,
+		Data:           typeHierarchyData{URI: uri, Class: class.Name},
+	}
+}
+
+// annotatedClassAt returns the class that is named at the given position in a `---@class` annotation, either as the
+// class being declared or as one of its parents.
+func annotatedClassAt(env *types.Environment, info *types.Info, pos token.Pos) *types.Named {
+	for _, doc := range info.Docs {
+		class := doc.Class()
+		if class == nil {
+			continue
+		}
+		name := ""
+		if class.NameRange.ContainsPos(pos) {
+			name = class.Name
+		}
+		for _, parent := range class.Parents {
+			if named, ok := parent.(*annotation.NamedType); ok && named.Range.ContainsPos(pos) {
+				name = named.Name
+			}
+		}
+		if name != "" {
+			named, _ := env.Types[name].(*types.Named)
+			return named
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// watchFiles asks the client to notify the server when Lua files or configuration files are created, changed, or
+// deleted outside of the editor.
+func (s *Server) watchFiles(ctx *glsp.Context) {
+	params := protocol.RegistrationParams{Registrations: []protocol.Registration{{
+		ID:     "luapls-watched-files",
+		Method: string(protocol.MethodWorkspaceDidChangeWatchedFiles),
+		RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+			Watchers: append([]protocol.FileSystemWatcher{{GlobPattern: "**/*.lua"}}, configFileWatchers()...),
+		},
+	}}}
+	// Messages are handled one at a time, so the client's response can only be read after this handler returns.
+	go ctx.Call(protocol.ServerClientRegisterCapability, params, new(any))
+}
+
+// workspaceDidChangeWatchedFiles updates the environments with files that changed on disk, and applies the
+// configuration file again if it changed. Files that are open in the editor are left alone, since the editor's
+// contents take precedence.
+func (s *Server) workspaceDidChangeWatchedFiles(ctx *glsp.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	configChanged := false
+	for _, change := range params.Changes {
+		uri := util.NormalizeURI(change.URI)
+		if s.isConfigFile(uri) {
+			configChanged = true
+			continue
+		}
+		if _, open := s.contents[uri]; open || !strings.HasSuffix(uri, ".lua") {
+			continue
+		}
+		switch change.Type {
+		case protocol.FileChangeTypeCreated:
+			s.fileCreated(ctx, uri)
+		case protocol.FileChangeTypeChanged:
+			if s.getFile(uri) == nil {
+				s.fileCreated(ctx, uri)
+				continue
+			}
+			if err := s.reload(ctx, uri); err != nil {
+				s.log.Errorf("Failed to reload %s: %s", uri, err)
+			}
+			delete(s.contents, uri)
+		case protocol.FileChangeTypeDeleted:
+			s.fileDeleted(ctx, uri)
+		}
+	}
+	if configChanged {
+		s.configFileChanged(ctx)
+	}
+	return nil
+}
+
+// fileCreated adds a file to every environment that loads the directory it is in.
+func (s *Server) fileCreated(ctx *glsp.Context, uri protocol.URI) {
+	for _, env := range s.allEnvironments() {
+		if !env.Loads(uri) {
+			continue
+		}
+		for _, checked := range env.Load(uri) {
+			if s.environmentOf(checked) == env && env.Owns(checked) {
+				s.publishDiagnostics(ctx, env.Files[checked])
+			}
+		}
+	}
+}
+
+// fileDeleted removes a file from every environment and clears its diagnostics. The requires that still refer to it
+// are reported when the files that contain them are checked again.
+func (s *Server) fileDeleted(ctx *glsp.Context, uri protocol.URI) {
+	if s.getFile(uri) == nil {
+		return
+	}
+	for _, env := range s.allEnvironments() {
+		for _, dependent := range env.DeleteFile(uri) {
+			if s.environmentOf(dependent) == env && env.Owns(dependent) {
+				s.publishDiagnostics(ctx, env.Files[dependent])
+			}
+		}
+	}
+	if !s.pullDiagnostics() {
+		ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: []protocol.Diagnostic{},
+		})
+	}
+}
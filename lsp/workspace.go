@@ -0,0 +1,176 @@
+package lsp
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// workspaceRoots returns the directories of the workspace folders that the client opened, with normalized URIs.
+// Clients that do not support workspace folders send a single root URI, or the deprecated root path.
+func workspaceRoots(params *protocol.InitializeParams) []protocol.WorkspaceFolder {
+	folders := params.WorkspaceFolders
+	if len(folders) == 0 && params.RootURI != nil {
+		folders = []protocol.WorkspaceFolder{{URI: *params.RootURI}}
+	}
+	if len(folders) == 0 && params.RootPath != nil {
+		if uri, err := util.PathToURI(*params.RootPath); err == nil {
+			folders = []protocol.WorkspaceFolder{{URI: uri}}
+		}
+	}
+	roots := []protocol.WorkspaceFolder{}
+	for _, folder := range folders {
+		roots = append(roots, protocol.WorkspaceFolder{URI: util.NormalizeURI(folder.URI), Name: folder.Name})
+	}
+	return roots
+}
+
+func (s *Server) workspaceDidChangeWorkspaceFolders(ctx *glsp.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
+	for _, folder := range params.Event.Removed {
+		s.removeFolder(ctx, util.NormalizeURI(folder.URI))
+	}
+	if len(params.Event.Added) > 0 {
+		s.setStatus(ctx, StatusIndexing, "Indexing")
+		defer s.idle(ctx)
+	}
+	for _, folder := range params.Event.Added {
+		env := s.addFolder(protocol.WorkspaceFolder{URI: util.NormalizeURI(folder.URI), Name: folder.Name}, nil)
+		if env == nil {
+			continue
+		}
+		for uri, file := range env.Files {
+			if env.Owns(uri) && s.environmentOf(uri) == env {
+				s.publishDiagnostics(ctx, file)
+			}
+		}
+	}
+	return nil
+}
+
+// addFolder creates and indexes an environment for a workspace folder other than the first. It uses the same
+// settings as the default environment, along with those that are configured for the name of the folder.
+func (s *Server) addFolder(folder protocol.WorkspaceFolder, p *progress) *types.Environment {
+	if s.folders[folder.URI] != nil {
+		return nil
+	}
+	path, err := util.URIToPath(folder.URI)
+	if err != nil {
+		s.log.Errorf("%s", err)
+		return nil
+	}
+	env := s.newEnvironment(path)
+	env.Name = folder.Name
+	if env.Name == "" {
+		env.Name = filepath.Base(path)
+	}
+	s.configureEnvironment(env)
+	for _, path := range s.config.library(env.Name) {
+		s.addLibrary(env, path)
+	}
+	reportIndexing(env, p)
+	env.Init()
+	reportIndexing(env, nil)
+	s.environments = append(s.environments, env)
+	s.folders[folder.URI] = env
+	return env
+}
+
+// removeFolder discards the environment of a workspace folder and clears the diagnostics of its files. If it is the
+// first folder, the default environment is replaced with an empty one, which only contains the open files, and the
+// other environments that checked the folder are rebuilt without a root directory.
+func (s *Server) removeFolder(ctx *glsp.Context, uri protocol.DocumentUri) {
+	env := s.folders[uri]
+	if env == nil {
+		return
+	}
+	delete(s.folders, uri)
+	removed := []*types.Environment{env}
+	if env == s.environment {
+		removed = append(removed, s.rootEnvironments()...)
+	}
+	owned := []protocol.URI{}
+	for _, env := range removed {
+		for uri := range env.Files {
+			if env.Owns(uri) && s.environmentOf(uri) == env {
+				owned = append(owned, uri)
+			}
+		}
+	}
+	if env == s.environment {
+		// The open files of the folder are no longer part of any folder, so they are kept like files that were opened
+		// from outside of the workspace.
+		for uri := range s.contents {
+			if env.Owns(uri) {
+				s.transient[uri] = true
+			}
+		}
+		isOpen := func(uri protocol.URI) bool {
+			_, ok := s.contents[uri]
+			return ok
+		}
+		for _, other := range removed[1:] {
+			other.RootPath = ""
+			other.Reindex(isOpen)
+		}
+		s.rootPath = ""
+		s.environment = s.newEnvironment("")
+		s.environment.CheckAll()
+	} else {
+		for i, other := range s.environments {
+			if other == env {
+				s.environments = append(s.environments[:i], s.environments[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, uri := range owned {
+		if file := s.getFile(uri); file != nil {
+			s.publishDiagnostics(ctx, file)
+		} else if !s.pullDiagnostics() {
+			ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+				URI:         uri,
+				Diagnostics: []protocol.Diagnostic{},
+			})
+		}
+	}
+}
+
+// rootEnvironments returns the additional environments that check the first workspace folder along with the default
+// environment, such as the ones for the Factorio data stage and the ones that are configured by name.
+func (s *Server) rootEnvironments() []*types.Environment {
+	folders := map[*types.Environment]bool{}
+	for _, env := range s.folders {
+		folders[env] = true
+	}
+	envs := []*types.Environment{}
+	for _, env := range s.environments {
+		if !folders[env] {
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// newEnvironment returns an environment for the given root directory with the settings of the default environment,
+// including its library.
+// The files that are open in the editor are added with their current contents if they are in the root directory, or
+// if they are not part of any folder.
+func (s *Server) newEnvironment(root string) *types.Environment {
+	env := types.NewEnvironment()
+	env.RootPath = root
+	env.PackagePath = s.environment.PackagePath
+	env.HostGlobals = s.environment.HostGlobals
+	env.Ignore = s.environment.Ignore
+	env.Concurrency = s.environment.Concurrency
+	env.Library = slices.Clone(s.environment.Library)
+	for uri, text := range s.contents {
+		if s.transient[uri] || env.Owns(uri) {
+			env.AddTransientFile(uri, text)
+		}
+	}
+	return env
+}
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raiguard/luapls/util"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFolder writes files to a new directory and returns its path and URI.
+func writeFolder(t *testing.T, files map[string]string) (string, protocol.DocumentUri) {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	uri, err := util.PathToURI(root)
+	require.NoError(t, err)
+	return root, uri
+}
+
+func TestWorkspaceFolders(t *testing.T) {
+	library, _ := writeFolder(t, map[string]string{"defs.lua": "---@meta\nshared_value = 1\n"})
+	_, first := writeFolder(t, map[string]string{"main.lua": "print(shared_value)\n", "spec/main_spec.lua": "print(1)\n"})
+	second, secondURI := writeFolder(t, map[string]string{"other.lua": "print(shared_value)\n"})
+
+	s := newServer(0)
+	params := &protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: first, Name: "first"}},
+		InitializationOptions: map[string]any{
+			"library": []any{library},
+			"environments": map[string]any{
+				"other": map[string]any{"luaVersion": "5.1"},
+				"tests": map[string]any{"include": []any{"spec/**"}},
+			},
+		},
+	}
+	_, err := s.initialize(testContext(t, params, nil), params)
+	require.NoError(t, err)
+	tests := s.findEnvironment("tests")
+	require.NotNil(t, tests)
+
+	// Folders that are added later have the settings of the default environment and those configured by their name.
+	added := &protocol.DidChangeWorkspaceFoldersParams{Event: protocol.WorkspaceFoldersChangeEvent{
+		Added: []protocol.WorkspaceFolder{{URI: secondURI, Name: "other"}},
+	}}
+	require.NoError(t, s.workspaceDidChangeWorkspaceFolders(testContext(t, added, nil), added))
+	other := s.folders[secondURI]
+	require.NotNil(t, other)
+	assert.Equal(t, "other", other.Name)
+	assert.Equal(t, second, other.RootPath)
+	assert.Contains(t, other.Library, library)
+	assert.True(t, other.Globals.IsDefined("shared_value"))
+	assert.Equal(t, "5.1", s.lintOptions(other).LuaVersion)
+
+	// Removing the first folder leaves no environment rooted in it.
+	removed := &protocol.DidChangeWorkspaceFoldersParams{Event: protocol.WorkspaceFoldersChangeEvent{
+		Removed: []protocol.WorkspaceFolder{{URI: first}},
+	}}
+	notifications := []notification{}
+	require.NoError(t, s.workspaceDidChangeWorkspaceFolders(testContext(t, removed, &notifications), removed))
+	assert.Empty(t, s.environment.RootPath)
+	assert.Empty(t, tests.RootPath)
+	assert.Empty(t, tests.Files)
+	assert.Empty(t, s.rootPath)
+	cleared := []protocol.DocumentUri{}
+	for _, n := range notifications {
+		cleared = append(cleared, n.params.(protocol.PublishDiagnosticsParams).URI)
+	}
+	assert.ElementsMatch(t, []protocol.DocumentUri{first + "/main.lua", first + "/spec/main_spec.lua"}, cleared)
+	assert.Same(t, other, s.environmentOf(secondURI+"/other.lua"))
+
+	removed.Event.Removed[0].URI = secondURI
+	require.NoError(t, s.workspaceDidChangeWorkspaceFolders(testContext(t, removed, nil), removed))
+	assert.NotContains(t, s.environments, other)
+	assert.Empty(t, s.folders)
+}
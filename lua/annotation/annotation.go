@@ -1,12 +1,127 @@
 package annotation
 
+import "github.com/raiguard/luapls/lua/token"
+
+// Annotation is a single `---@tag` line in a doc comment.
 type Annotation interface {
 	isAnnotation()
+	GetRange() token.Range
+}
+
+// node holds the range of an annotation or type expression.
+type node struct {
+	Range token.Range
+}
+
+func (n *node) GetRange() token.Range {
+	return n.Range
+}
+
+type (
+	// Alias is `---@alias Name Type`. The type may instead be given as a union of variants on the following lines, each
+	// starting with `---|`.
+	Alias struct {
+		node
+		Name      string
+		NameRange token.Range
+		Type      TypeExpr
+	}
+	// Class is `---@class Name [: Parent [, Parent]...]`.
+	// TODO: Generics
+	Class struct {
+		node
+		Name      string
+		NameRange token.Range
+		Parents   []TypeExpr
+	}
+	// Deprecated is `---@deprecated [message]`, which marks the declaration that follows as deprecated. The message
+	// usually names a replacement.
+	Deprecated struct {
+		node
+		Message string
+	}
+	// Diagnostic is `---@diagnostic action[: code[, code]...]`, which disables or enables diagnostics. The action is
+	// one of `disable`, `enable`, `disable-line`, and `disable-next-line`. Without codes, every diagnostic is affected.
+	Diagnostic struct {
+		node
+		Action string
+		Codes  []string
+	}
+	// Enum is `---@enum Name`, which declares the values of the table that follows as the members of a type.
+	Enum struct {
+		node
+		Name      string
+		NameRange token.Range
+	}
+	// Field is `---@field name Type description`. If the name is written in brackets, such as `---@field [string]
+	// number`, then Key holds the key type and Name is empty.
+	Field struct {
+		node
+		Name        string
+		NameRange   token.Range
+		Optional    bool // Whether the name was followed by `?`.
+		Key         TypeExpr
+		Type        TypeExpr
+		Description string
+	}
+	// Generic is `---@generic T [: Constraint] [, U [: Constraint]]...`.
+	Generic struct {
+		node
+		Params []GenericParam
+	}
+	// Meta is `---@meta [name]`, which marks a file as containing only definitions. If a name is given, requiring that
+	// name resolves to the file.
+	Meta struct {
+		node
+		Name string
+	}
+	// Overload is `---@overload fun(...): ...`, an alternative signature for the function that follows.
+	Overload struct {
+		node
+		Type TypeExpr
+	}
+	// Param is `---@param name Type description`.
+	Param struct {
+		node
+		Name        string
+		NameRange   token.Range
+		Optional    bool // Whether the name was followed by `?`.
+		Type        TypeExpr
+		Description string
+	}
+	// Return is `---@return Type [name] [, Type [name]]... description`.
+	Return struct {
+		node
+		Values      []ReturnValue
+		Description string
+	}
+	// Type is `---@type Type [, Type]...`.
+	Type struct {
+		node
+		Types []TypeExpr
+	}
+)
+
+type GenericParam struct {
+	Name       string
+	NameRange  token.Range
+	Constraint TypeExpr // Nil if the parameter is unconstrained.
 }
 
-// TODO: Generics
-type Class struct {
+type ReturnValue struct {
+	Type TypeExpr
 	Name string
 }
 
-func (c *Class) isAnnotation() {}
+func (a *Alias) isAnnotation()      {}
+func (c *Class) isAnnotation()      {}
+func (d *Deprecated) isAnnotation() {}
+func (d *Diagnostic) isAnnotation() {}
+func (e *Enum) isAnnotation()       {}
+func (f *Field) isAnnotation()      {}
+func (g *Generic) isAnnotation()    {}
+func (m *Meta) isAnnotation()       {}
+func (o *Overload) isAnnotation()   {}
+func (p *Param) isAnnotation()      {}
+func (r *Return) isAnnotation()     {}
+func (t *Type) isAnnotation()       {}
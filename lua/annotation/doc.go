@@ -0,0 +1,200 @@
+package annotation
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// Doc is a block of doc comments on consecutive lines. Lines that begin with `@` are parsed as annotations, and all
+// other lines make up the description. A nil block has no annotations.
+type Doc struct {
+	Description string
+	Annotations []Annotation
+	Range       token.Range
+	// EndLine is the zero-based line that the block ends on. The block documents whatever starts on the next line.
+	EndLine int
+}
+
+// ParseDocs groups the doc comments (those starting with `---`) among the given comments into blocks and parses each
+// of them.
+func ParseDocs(comments []token.Token, lineBreaks token.LineBreaks) ([]*Doc, []ast.Diagnostic) {
+	docs := []*Doc{}
+	diagnostics := []ast.Diagnostic{}
+	var current *Doc
+	var description []string
+	flush := func() {
+		if current != nil {
+			current.Description = strings.TrimSpace(strings.Join(description, "\n"))
+			docs = append(docs, current)
+		}
+		current = nil
+		description = nil
+	}
+	for _, comment := range comments {
+		content, ok := strings.CutPrefix(comment.Literal, "---")
+		if !ok || strings.HasPrefix(content, "-") {
+			flush()
+			continue
+		}
+		line := lineBreaks.Line(comment.Pos)
+		if current != nil && line != current.EndLine+1 {
+			flush()
+		}
+		if current == nil {
+			current = &Doc{Range: comment.Range()}
+		}
+		current.Range.End = comment.End()
+		current.EndLine = line
+		if alias := current.lastAlias(); alias != nil && strings.HasPrefix(strings.TrimSpace(content), "|") {
+			variant, diags := ParseVariant(content, comment.Pos+3)
+			diagnostics = append(diagnostics, diags...)
+			alias.addVariant(variant)
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(content), "@") {
+			description = append(description, strings.TrimPrefix(content, " "))
+			continue
+		}
+		a, diags := Parse(content, comment.Pos+3)
+		diagnostics = append(diagnostics, diags...)
+		if a != nil {
+			current.Annotations = append(current.Annotations, a)
+		}
+	}
+	flush()
+	return docs, diagnostics
+}
+
+// lastAlias returns the block's last annotation if it is an alias, since only those may be followed by variants.
+func (d *Doc) lastAlias() *Alias {
+	if len(d.Annotations) == 0 {
+		return nil
+	}
+	alias, _ := d.Annotations[len(d.Annotations)-1].(*Alias)
+	return alias
+}
+
+// addVariant adds a type to the union of types that the alias may be.
+func (a *Alias) addVariant(variant TypeExpr) {
+	if variant == nil {
+		return
+	}
+	switch typ := a.Type.(type) {
+	case nil:
+		a.Type = &UnionType{node{variant.GetRange()}, []TypeExpr{variant}}
+	case *UnionType:
+		typ.Types = append(typ.Types, variant)
+		typ.Range.End = variant.GetRange().End
+	default:
+		a.Type = &UnionType{node{token.Range{Start: typ.GetRange().Start, End: variant.GetRange().End}}, []TypeExpr{typ, variant}}
+	}
+}
+
+// Class returns the block's `---@class` annotation, or nil if it has none.
+func (d *Doc) Class() *Class {
+	if d == nil {
+		return nil
+	}
+	for _, a := range d.Annotations {
+		if class, ok := a.(*Class); ok {
+			return class
+		}
+	}
+	return nil
+}
+
+// Deprecated returns the block's `---@deprecated` annotation, or nil if it has none.
+func (d *Doc) Deprecated() *Deprecated {
+	if d == nil {
+		return nil
+	}
+	for _, a := range d.Annotations {
+		if deprecated, ok := a.(*Deprecated); ok {
+			return deprecated
+		}
+	}
+	return nil
+}
+
+// Enum returns the block's `---@enum` annotation, or nil if it has none.
+func (d *Doc) Enum() *Enum {
+	if d == nil {
+		return nil
+	}
+	for _, a := range d.Annotations {
+		if enum, ok := a.(*Enum); ok {
+			return enum
+		}
+	}
+	return nil
+}
+
+// Type returns the block's `---@type` annotation, or nil if it has none.
+func (d *Doc) Type() *Type {
+	if d == nil {
+		return nil
+	}
+	for _, a := range d.Annotations {
+		if typ, ok := a.(*Type); ok {
+			return typ
+		}
+	}
+	return nil
+}
+
+// Generics returns the type parameters declared by every `---@generic` annotation in the block.
+func (d *Doc) Generics() []GenericParam {
+	params := []GenericParam{}
+	if d == nil {
+		return params
+	}
+	for _, a := range d.Annotations {
+		if generic, ok := a.(*Generic); ok {
+			params = append(params, generic.Params...)
+		}
+	}
+	return params
+}
+
+// Overloads returns the types of every `---@overload` annotation in the block.
+func (d *Doc) Overloads() []TypeExpr {
+	types := []TypeExpr{}
+	if d == nil {
+		return types
+	}
+	for _, a := range d.Annotations {
+		if overload, ok := a.(*Overload); ok && overload.Type != nil {
+			types = append(types, overload.Type)
+		}
+	}
+	return types
+}
+
+// Param returns the `---@param` annotation with the given name, or nil if there is none.
+func (d *Doc) Param(name string) *Param {
+	if d == nil {
+		return nil
+	}
+	for _, a := range d.Annotations {
+		if param, ok := a.(*Param); ok && param.Name == name {
+			return param
+		}
+	}
+	return nil
+}
+
+// Returns returns the values of every `---@return` annotation in the block, in order.
+func (d *Doc) Returns() []ReturnValue {
+	values := []ReturnValue{}
+	if d == nil {
+		return values
+	}
+	for _, a := range d.Annotations {
+		if ret, ok := a.(*Return); ok {
+			values = append(values, ret.Values...)
+		}
+	}
+	return values
+}
@@ -2,6 +2,7 @@ package annotation
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/raiguard/luapls/lua/ast"
 	"github.com/raiguard/luapls/lua/lexer"
@@ -9,13 +10,71 @@ import (
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
-func Parse(src string) (Annotation, []ast.Diagnostic) {
-	tokens, _ := lexer.Run(src)
-	p := parser{tokens, -1, []ast.Diagnostic{}}
+// ignoredTags contains LuaCATS tags that are valid but not yet understood, and should not be reported as unknown.
+var ignoredTags = map[string]bool{
+	"@async":     true,
+	"@cast":      true,
+	"@module":    true,
+	"@nodiscard": true,
+	"@operator":  true,
+	"@package":   true,
+	"@private":   true,
+	"@protected": true,
+	"@see":       true,
+	"@source":    true,
+	"@vararg":    true,
+	"@version":   true,
+}
+
+// diagnosticActions contains the actions that a `---@diagnostic` annotation may take.
+var diagnosticActions = map[string]bool{
+	"disable":           true,
+	"disable-line":      true,
+	"disable-next-line": true,
+	"enable":            true,
+}
+
+// fieldVisibility contains the keywords that may precede the name of a `---@field`.
+var fieldVisibility = map[string]bool{
+	"package":   true,
+	"private":   true,
+	"protected": true,
+	"public":    true,
+}
+
+// Parse parses a single doc comment line, without its leading `---`. base is the position of src within the file,
+// and is added to every range in the result.
+func Parse(src string, base token.Pos) (Annotation, []ast.Diagnostic) {
+	p := newParser(src, base)
 	return p.parse()
 }
 
+// ParseVariant parses an alias variant line, `---| Type`, without its leading `---`.
+func ParseVariant(src string, base token.Pos) (TypeExpr, []ast.Diagnostic) {
+	p := newParser(src, base)
+	p.expect(token.PIPE)
+	return p.parseType(), p.diagnostics
+}
+
+// ParseType parses a type expression on its own, such as one given in the configuration.
+func ParseType(src string) (TypeExpr, []ast.Diagnostic) {
+	p := newParser(src, 0)
+	typ := p.parseType()
+	p.expect(token.EOF)
+	return typ, p.diagnostics
+}
+
+func newParser(src string, base token.Pos) *parser {
+	tokens, _ := lexer.Run(src)
+	for i := range tokens {
+		tokens[i].Pos += base
+	}
+	return &parser{src, base, tokens, -1, []ast.Diagnostic{}}
+}
+
 type parser struct {
+	src         string
+	base        token.Pos
 	tokens      []token.Token
 	pos         int
 	diagnostics []ast.Diagnostic
@@ -23,20 +82,345 @@ type parser struct {
 
 func (p *parser) parse() (Annotation, []ast.Diagnostic) {
 	tok := p.next()
+	start := tok.Pos
+	var a Annotation
 	switch tok.Type {
+	case token.DOC_ALIAS:
+		name := p.parseName()
+		alias := &Alias{Name: name.Literal, NameRange: name.Range()}
+		if p.peek().Type != token.EOF {
+			alias.Type = p.parseType()
+		}
+		a = alias
 	case token.DOC_CLASS:
-		name := p.expect(token.IDENT)
-		return &Class{Name: name.Literal}, p.diagnostics
+		name := p.parseName()
+		class := &Class{Name: name.Literal, NameRange: name.Range()}
+		if p.accept(token.COLON) {
+			for {
+				if parent := p.parseType(); parent != nil {
+					class.Parents = append(class.Parents, parent)
+				}
+				if !p.accept(token.COMMA) {
+					break
+				}
+			}
+		}
+		a = class
+	case token.DOC_DEPRECATED:
+		a = &Deprecated{Message: p.rest()}
+	case token.DOC_DIAGNOSTIC:
+		diagnostic := &Diagnostic{}
+		action, codes, _ := strings.Cut(p.rest(), ":")
+		diagnostic.Action = strings.TrimSpace(action)
+		if !diagnosticActions[diagnostic.Action] {
+			p.diagnostics = append(p.diagnostics, ast.Diagnostic{
+				Message:  fmt.Sprintf("Unknown diagnostic action '%s'", diagnostic.Action),
+				Range:    tok.Range(),
+				Severity: protocol.DiagnosticSeverityWarning,
+				Code:     "annotation-syntax",
+			})
+		}
+		for _, code := range strings.Split(codes, ",") {
+			if code = strings.TrimSpace(code); code != "" {
+				diagnostic.Codes = append(diagnostic.Codes, code)
+			}
+		}
+		for p.peek().Type != token.EOF {
+			p.next()
+		}
+		a = diagnostic
+	case token.DOC_ENUM:
+		name := p.parseName()
+		a = &Enum{Name: name.Literal, NameRange: name.Range()}
+	case token.DOC_FIELD:
+		field := &Field{}
+		if next := p.peek(); next.Type == token.IDENT && fieldVisibility[next.Literal] {
+			p.next()
+		}
+		if p.peek().Type == token.LBRACK {
+			p.next()
+			field.Key = p.parseType()
+			p.expect(token.RBRACK)
+		} else {
+			name := p.expect(token.IDENT)
+			field.Name, field.NameRange = name.Literal, name.Range()
+			field.Optional = p.accept(token.QUESTION)
+		}
+		field.Type = p.parseType()
+		field.Description = p.rest()
+		a = field
+	case token.DOC_GENERIC:
+		generic := &Generic{}
+		for {
+			name := p.expect(token.IDENT)
+			param := GenericParam{Name: name.Literal, NameRange: name.Range()}
+			if p.accept(token.COLON) {
+				param.Constraint = p.parseType()
+			}
+			generic.Params = append(generic.Params, param)
+			if !p.accept(token.COMMA) {
+				break
+			}
+		}
+		a = generic
+	case token.DOC_META:
+		meta := &Meta{}
+		if p.peek().Type == token.IDENT {
+			meta.Name = p.parseName().Literal
+		}
+		a = meta
+	case token.DOC_OVERLOAD:
+		a = &Overload{Type: p.parseType()}
+	case token.DOC_PARAM:
+		param := &Param{}
+		if p.peek().Type == token.VARARG {
+			name := p.next()
+			param.Name, param.NameRange = name.Literal, name.Range()
+		} else {
+			name := p.expect(token.IDENT)
+			param.Name, param.NameRange = name.Literal, name.Range()
+		}
+		param.Optional = p.accept(token.QUESTION)
+		param.Type = p.parseType()
+		param.Description = p.rest()
+		a = param
+	case token.DOC_RETURN:
+		ret := &Return{}
+		for {
+			value := ReturnValue{Type: p.parseType()}
+			// A name of `...` means that any number of values of the type are returned.
+			if next := p.peek().Type; next == token.IDENT || next == token.VARARG {
+				value.Name = p.next().Literal
+			}
+			ret.Values = append(ret.Values, value)
+			if value.Type == nil || p.peek().Type != token.COMMA {
+				break
+			}
+			p.next()
+		}
+		ret.Description = p.rest()
+		a = ret
+	case token.DOC_TYPE:
+		typ := &Type{}
+		for {
+			elem := p.parseType()
+			if elem == nil {
+				break
+			}
+			typ.Types = append(typ.Types, elem)
+			if p.peek().Type != token.COMMA {
+				break
+			}
+			p.next()
+		}
+		a = typ
 	case token.INVALID:
-		p.diagnostics = append(p.diagnostics, ast.Diagnostic{
-			Message:  "Unknown annotation",
-			Range:    tok.Range(),
-			Severity: protocol.DiagnosticSeverityWarning,
-		})
+		if strings.HasPrefix(tok.Literal, "@") && !ignoredTags[tok.Literal] {
+			p.diagnostics = append(p.diagnostics, ast.Diagnostic{
+				Message:  "Unknown annotation",
+				Range:    tok.Range(),
+				Severity: protocol.DiagnosticSeverityWarning,
+				Code:     "unknown-annotation",
+			})
+		}
 		return nil, p.diagnostics
 	default:
 		return nil, p.diagnostics
 	}
+	rng := token.Range{Start: start, End: p.tokens[p.pos].End()}
+	switch a := a.(type) {
+	case *Alias:
+		a.Range = rng
+	case *Class:
+		a.Range = rng
+	case *Deprecated:
+		a.Range = rng
+	case *Diagnostic:
+		a.Range = rng
+	case *Enum:
+		a.Range = rng
+	case *Field:
+		a.Range = rng
+	case *Generic:
+		a.Range = rng
+	case *Meta:
+		a.Range = rng
+	case *Overload:
+		a.Range = rng
+	case *Param:
+		a.Range = rng
+	case *Return:
+		a.Range = rng
+	case *Type:
+		a.Range = rng
+	}
+	return a, p.diagnostics
+}
+
+// parseType parses a type expression, returning nil if there is none.
+func (p *parser) parseType() TypeExpr {
+	typ := p.parsePostfixType()
+	if typ == nil || p.peek().Type != token.PIPE {
+		return typ
+	}
+	union := &UnionType{node{typ.GetRange()}, []TypeExpr{typ}}
+	for p.accept(token.PIPE) {
+		member := p.parsePostfixType()
+		if member == nil {
+			break
+		}
+		union.Types = append(union.Types, member)
+		union.Range.End = member.GetRange().End
+	}
+	return union
+}
+
+func (p *parser) parsePostfixType() TypeExpr {
+	typ := p.parsePrimaryType()
+	if typ == nil {
+		return nil
+	}
+	for {
+		switch p.peek().Type {
+		case token.LBRACK:
+			p.next()
+			end := p.expect(token.RBRACK)
+			typ = &ArrayType{node{token.Range{Start: typ.GetRange().Start, End: end.End()}}, typ}
+		case token.QUESTION:
+			end := p.next()
+			typ = &OptionalType{node{token.Range{Start: typ.GetRange().Start, End: end.End()}}, typ}
+		default:
+			return typ
+		}
+	}
+}
+
+func (p *parser) parsePrimaryType() TypeExpr {
+	tok := p.next()
+	switch tok.Type {
+	case token.FALSE, token.NUMBER, token.RAWSTRING, token.STRING, token.TRUE:
+		return &LiteralType{node{tok.Range()}, tok.Type, tok.Literal}
+	case token.FUNCTION, token.NIL:
+		return &NamedType{node: node{tok.Range()}, Name: tok.Literal}
+	case token.LPAREN:
+		typ := p.parseType()
+		p.expect(token.RPAREN)
+		return typ
+	case token.IDENT:
+		if tok.Literal == "fun" && p.peek().Type == token.LPAREN {
+			return p.parseFunctionType(tok)
+		}
+		typ := &NamedType{node: node{tok.Range()}, Name: tok.Literal}
+		for p.peek().Type == token.DOT {
+			p.next()
+			part := p.expect(token.IDENT)
+			typ.Name += "." + part.Literal
+			typ.Range.End = part.End()
+		}
+		if p.peek().Type == token.LT {
+			p.next()
+			for {
+				arg := p.parseType()
+				if arg == nil {
+					break
+				}
+				typ.Args = append(typ.Args, arg)
+				if p.peek().Type != token.COMMA {
+					break
+				}
+				p.next()
+			}
+			typ.Range.End = p.expect(token.GT).End()
+		}
+		return typ
+	case token.LBRACE:
+		typ := &TableType{node: node{tok.Range()}}
+		for isName(p.peek()) {
+			name := p.next()
+			field := TableTypeField{Name: name.Literal, NameRange: name.Range(), Optional: p.accept(token.QUESTION)}
+			p.expect(token.COLON)
+			field.Type = p.parseType()
+			typ.Fields = append(typ.Fields, field)
+			if p.peek().Type != token.COMMA {
+				break
+			}
+			p.next()
+		}
+		typ.Range.End = p.expect(token.RBRACE).End()
+		return typ
+	}
+	p.diagnostics = append(p.diagnostics, ast.Diagnostic{
+		Message:  "Expected type",
+		Range:    tok.Range(),
+		Severity: protocol.DiagnosticSeverityWarning,
+		Code:     "annotation-syntax",
+	})
+	return nil
+}
+
+func (p *parser) parseFunctionType(fun *token.Token) TypeExpr {
+	typ := &FunctionType{node: node{fun.Range()}}
+	p.expect(token.LPAREN)
+	for isName(p.peek()) || p.peek().Type == token.VARARG {
+		param := FunctionParam{Name: p.next().Literal, Optional: p.accept(token.QUESTION)}
+		if p.peek().Type == token.COLON {
+			p.next()
+			param.Type = p.parseType()
+		}
+		typ.Params = append(typ.Params, param)
+		if p.peek().Type != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	typ.Range.End = p.expect(token.RPAREN).End()
+	if p.peek().Type != token.COLON {
+		return typ
+	}
+	p.next()
+	for {
+		ret := p.parseType()
+		if ret == nil {
+			break
+		}
+		typ.Returns = append(typ.Returns, ret)
+		typ.Range.End = ret.GetRange().End
+		if p.peek().Type != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	return typ
+}
+
+// parseName parses a possibly dotted type name, such as `EventData.on_tick`, and returns it as a single token.
+func (p *parser) parseName() *token.Token {
+	name := *p.expect(token.IDENT)
+	for p.peek().Type == token.DOT {
+		p.next()
+		part := p.expect(token.IDENT)
+		name.Literal += "." + part.Literal
+	}
+	return &name
+}
+
+// isName returns whether the token can be used as a name in a type. Unlike Lua identifiers, keywords are allowed.
+func isName(tok *token.Token) bool {
+	if tok.Type == token.IDENT {
+		return true
+	}
+	keyword, ok := token.Reserved[tok.Literal]
+	return ok && keyword == tok.Type && !strings.HasPrefix(tok.Literal, "@")
+}
+
+// rest returns the remainder of the line after the last consumed token, with an optional leading `#` removed.
+func (p *parser) rest() string {
+	end := p.tokens[p.pos].End() - p.base
+	if end > len(p.src) {
+		return ""
+	}
+	rest := strings.TrimSpace(p.src[end:])
+	return strings.TrimSpace(strings.TrimPrefix(rest, "#"))
 }
 
 func (p *parser) read() *token.Token {
@@ -54,6 +438,23 @@ func (p *parser) next() *token.Token {
 	return tok
 }
 
+// peek returns the next non-whitespace token without consuming it.
+func (p *parser) peek() *token.Token {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+// accept consumes the next non-whitespace token if it has the given type.
+func (p *parser) accept(typ token.TokenType) bool {
+	if p.peek().Type != typ {
+		return false
+	}
+	p.next()
+	return true
+}
+
 func (p *parser) expect(typ token.TokenType) *token.Token {
 	tok := p.next()
 	if tok.Type != typ {
@@ -61,6 +462,7 @@ func (p *parser) expect(typ token.TokenType) *token.Token {
 			Message:  fmt.Sprintf("Expected %s", token.TokenStr[typ]),
 			Range:    tok.Range(),
 			Severity: protocol.DiagnosticSeverityWarning,
+			Code:     "annotation-syntax",
 		})
 	}
 	return tok
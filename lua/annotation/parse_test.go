@@ -0,0 +1,173 @@
+package annotation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/raiguard/luapls/lua/token"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"@class Foo", "class Foo"},
+		{"@class Foo.Bar: Base, Mixin", "class Foo.Bar: Base, Mixin"},
+		{"@alias Callback fun(event: EventData): boolean", "alias Callback fun(event: EventData): boolean"},
+		{"@field private items string[] The items", "field items string[] The items"},
+		{"@field [string] number", "field [string] number"},
+		{"@param name string The name", "param name string The name"},
+		{"@param ... any", "param ... any"},
+		{"@return table<string, Foo.Bar> map # The map", "return table<string, Foo.Bar> map The map"},
+		{"@return number, string", "return number, string"},
+		{"@return boolean ok, any ...", "return boolean ok, any ..."},
+		{"@type { x: number, y: number }", "type { x: number, y: number }"},
+		{"@type string|nil", "type string|nil"},
+		{"@type (string|number)[]?", "type (string|number)[]?"},
+		{`@param side "left"|'right'|1|true`, `param side "left"|'right'|1|true`},
+		{"@param cb? fun(x?: integer): boolean", "param cb? fun(x?: integer): boolean"},
+		{"@field name? string", "field name? string"},
+		{"@generic T, K : string", "generic T, K: string"},
+		{"@overload fun(x: string): number", "overload fun(x: string): number"},
+		{"@meta", "meta"},
+		{"@meta socket.core", "meta socket.core"},
+		{"@deprecated", "deprecated"},
+		{"@deprecated Use `bar` instead.", "deprecated Use `bar` instead."},
+		{"@enum defines.events", "enum defines.events"},
+		{"@diagnostic disable", "diagnostic disable"},
+		{"@diagnostic disable-next-line: undefined-global, unused-local", "diagnostic disable-next-line: undefined-global, unused-local"},
+	}
+	for _, test := range tests {
+		a, diags := Parse(test.src, 0)
+		assert.Empty(t, diags, test.src)
+		require.NotNil(t, a, test.src)
+		assert.Equal(t, test.expected, describe(a), test.src)
+	}
+}
+
+func TestParseUnknownAnnotation(t *testing.T) {
+	a, diags := Parse(" @foo bar", 10)
+	assert.Nil(t, a)
+	require.Len(t, diags, 1)
+	assert.Equal(t, token.Range{Start: 11, End: 15}, diags[0].Range)
+
+	a, diags = Parse(" @nodiscard", 0)
+	assert.Nil(t, a)
+	assert.Empty(t, diags)
+
+	_, diags = Parse("@diagnostic disable-file", 0)
+	require.Len(t, diags, 1)
+	assert.Equal(t, "Unknown diagnostic action 'disable-file'", diags[0].Message)
+}
+
+func TestParseDocs(t *testing.T) {
+	comments := []token.Token{
+		{Type: token.COMMENT, Literal: "--- Does a thing.", Pos: 0},
+		{Type: token.COMMENT, Literal: "---@param x number", Pos: 18},
+		{Type: token.COMMENT, Literal: "-- Not a doc comment", Pos: 37},
+		{Type: token.COMMENT, Literal: "---@class Foo", Pos: 58},
+		{Type: token.COMMENT, Literal: "---@type Foo", Pos: 73},
+	}
+	lineBreaks := token.LineBreaks{17, 36, 57, 71, 72, 85}
+	docs, diags := ParseDocs(comments, lineBreaks)
+	assert.Empty(t, diags)
+	require.Len(t, docs, 3)
+	assert.Equal(t, "Does a thing.", docs[0].Description)
+	assert.Equal(t, 1, docs[0].EndLine)
+	require.NotNil(t, docs[0].Param("x"))
+	assert.Equal(t, token.Range{Start: 28, End: 29}, docs[0].Param("x").NameRange)
+	assert.NotNil(t, docs[1].Class())
+	assert.NotNil(t, docs[2].Type())
+}
+
+func TestParseAliasVariants(t *testing.T) {
+	comments := []token.Token{
+		{Type: token.COMMENT, Literal: "---@alias Side", Pos: 0},
+		{Type: token.COMMENT, Literal: `---| "left" # The left side`, Pos: 15},
+		{Type: token.COMMENT, Literal: `---| "right"`, Pos: 43},
+	}
+	docs, diags := ParseDocs(comments, token.LineBreaks{14, 42})
+	assert.Empty(t, diags)
+	require.Len(t, docs, 1)
+	require.Len(t, docs[0].Annotations, 1)
+	assert.Equal(t, `alias Side "left"|"right"`, describe(docs[0].Annotations[0]))
+}
+
+func describe(a Annotation) string {
+	switch a := a.(type) {
+	case *Alias:
+		return "alias " + a.Name + " " + a.Type.String()
+	case *Class:
+		if len(a.Parents) > 0 {
+			return "class " + a.Name + ": " + joinTypes(a.Parents)
+		}
+		return "class " + a.Name
+	case *Deprecated:
+		return join("deprecated", a.Message)
+	case *Diagnostic:
+		if len(a.Codes) > 0 {
+			return "diagnostic " + a.Action + ": " + strings.Join(a.Codes, ", ")
+		}
+		return "diagnostic " + a.Action
+	case *Enum:
+		return "enum " + a.Name
+	case *Field:
+		name := a.Name
+		if a.Key != nil {
+			name = "[" + a.Key.String() + "]"
+		}
+		if a.Optional {
+			name += "?"
+		}
+		return join("field", name, a.Type.String(), a.Description)
+	case *Generic:
+		params := []string{}
+		for _, param := range a.Params {
+			if param.Constraint != nil {
+				params = append(params, param.Name+": "+param.Constraint.String())
+			} else {
+				params = append(params, param.Name)
+			}
+		}
+		return "generic " + strings.Join(params, ", ")
+	case *Meta:
+		return join("meta", a.Name)
+	case *Overload:
+		return "overload " + a.Type.String()
+	case *Param:
+		name := a.Name
+		if a.Optional {
+			name += "?"
+		}
+		return join("param", name, a.Type.String(), a.Description)
+	case *Return:
+		values := ""
+		for i, value := range a.Values {
+			if i > 0 {
+				values += ", "
+			}
+			values += value.Type.String()
+			if value.Name != "" {
+				values += " " + value.Name
+			}
+		}
+		return join("return", values, a.Description)
+	case *Type:
+		return "type " + joinTypes(a.Types)
+	}
+	return ""
+}
+
+func join(parts ...string) string {
+	result := parts[0]
+	for _, part := range parts[1:] {
+		if part != "" {
+			result += " " + part
+		}
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package annotation
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// TypeExpr is a type written in an annotation, such as `string[]` or `fun(x: number): boolean`.
+type TypeExpr interface {
+	isTypeExpr()
+	GetRange() token.Range
+	String() string
+}
+
+type (
+	// NamedType is a reference to a builtin or user-defined type, with optional type arguments such as
+	// `table<string, number>`.
+	NamedType struct {
+		node
+		Name string
+		Args []TypeExpr
+	}
+	// ArrayType is `Elem[]`.
+	ArrayType struct {
+		node
+		Elem TypeExpr
+	}
+	// FunctionType is `fun(name: Type, ...): Return, ...`.
+	FunctionType struct {
+		node
+		Params  []FunctionParam
+		Returns []TypeExpr
+	}
+	// TableType is a table literal type such as `{ x: number, y: number }`.
+	TableType struct {
+		node
+		Fields []TableTypeField
+	}
+	// UnionType is `A|B`.
+	UnionType struct {
+		node
+		Types []TypeExpr
+	}
+	// OptionalType is `Elem?`, which is shorthand for `Elem|nil`.
+	OptionalType struct {
+		node
+		Elem TypeExpr
+	}
+	// LiteralType is a string, number, or boolean literal such as `"left"`, which only admits that exact value.
+	LiteralType struct {
+		node
+		Kind  token.TokenType
+		Value string // The literal as it was written, including quotes.
+	}
+)
+
+type FunctionParam struct {
+	Name     string
+	Optional bool     // Whether the name was followed by `?`.
+	Type     TypeExpr // Nil if the parameter is untyped.
+}
+
+type TableTypeField struct {
+	Name      string
+	NameRange token.Range
+	Optional  bool // Whether the name was followed by `?`.
+	Type      TypeExpr
+}
+
+func (n *NamedType) isTypeExpr()    {}
+func (a *ArrayType) isTypeExpr()    {}
+func (f *FunctionType) isTypeExpr() {}
+func (t *TableType) isTypeExpr()    {}
+func (u *UnionType) isTypeExpr()    {}
+func (o *OptionalType) isTypeExpr() {}
+func (l *LiteralType) isTypeExpr()  {}
+
+func (n *NamedType) String() string {
+	if len(n.Args) == 0 {
+		return n.Name
+	}
+	return n.Name + "<" + joinTypes(n.Args) + ">"
+}
+
+func (a *ArrayType) String() string {
+	return wrapUnion(a.Elem) + "[]"
+}
+
+func (f *FunctionType) String() string {
+	var sb strings.Builder
+	sb.WriteString("fun(")
+	for i, param := range f.Params {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(param.Name)
+		if param.Optional {
+			sb.WriteByte('?')
+		}
+		if param.Type != nil {
+			sb.WriteString(": ")
+			sb.WriteString(param.Type.String())
+		}
+	}
+	sb.WriteByte(')')
+	if len(f.Returns) > 0 {
+		sb.WriteString(": ")
+		sb.WriteString(joinTypes(f.Returns))
+	}
+	return sb.String()
+}
+
+func (t *TableType) String() string {
+	parts := make([]string, 0, len(t.Fields))
+	for _, field := range t.Fields {
+		name := field.Name
+		if field.Optional {
+			name += "?"
+		}
+		parts = append(parts, name+": "+field.Type.String())
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+func (u *UnionType) String() string {
+	parts := make([]string, 0, len(u.Types))
+	for _, typ := range u.Types {
+		parts = append(parts, typ.String())
+	}
+	return strings.Join(parts, "|")
+}
+
+func (o *OptionalType) String() string {
+	return wrapUnion(o.Elem) + "?"
+}
+
+func (l *LiteralType) String() string {
+	return l.Value
+}
+
+// wrapUnion formats a type that a suffix will be applied to, parenthesizing it if it is a union.
+func wrapUnion(typ TypeExpr) string {
+	if _, ok := typ.(*UnionType); ok {
+		return "(" + typ.String() + ")"
+	}
+	return typ.String()
+}
+
+func joinTypes(types []TypeExpr) string {
+	parts := make([]string, 0, len(types))
+	for _, typ := range types {
+		parts = append(parts, typ.String())
+	}
+	return strings.Join(parts, ", ")
+}
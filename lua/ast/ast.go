@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"reflect"
+
 	"github.com/raiguard/luapls/lua/token"
 )
 
@@ -16,6 +18,27 @@ func Range(n Node) token.Range {
 	return token.Range{Start: n.Pos(), End: n.End()}
 }
 
+// IsNil returns whether the given node is nil, including typed nil pointers stored in the interface.
+func IsNil(n Node) bool {
+	return n == nil || reflect.ValueOf(n).IsNil()
+}
+
+// posOr returns the position of n, or fallback if n is nil.
+func posOr(n Node, fallback token.Pos) token.Pos {
+	if IsNil(n) {
+		return fallback
+	}
+	return n.Pos()
+}
+
+// endOr returns the end position of n, or fallback if n is nil.
+func endOr(n Node, fallback token.Pos) token.Pos {
+	if IsNil(n) {
+		return fallback
+	}
+	return n.End()
+}
+
 type Unit struct {
 	LeadingTrivia  []token.Token
 	Token          token.Token
@@ -11,6 +11,33 @@ type Diagnostic struct {
 	Message  string
 	Range    token.Range
 	Severity protocol.DiagnosticSeverity
+	// Code is the name of the rule that produced the diagnostic, such as `undefined-global`, or the kind of error,
+	// such as `syntax-error`.
+	Code string
+	Tags []protocol.DiagnosticTag
+	// Related contains other locations that explain the diagnostic, such as a previous declaration.
+	Related []Related
+	// Fixes contains edits that resolve the diagnostic, offered as quick fixes.
+	Fixes []Fix
+}
+
+// Related is a location that is related to a diagnostic. An empty URI refers to the file of the diagnostic.
+type Related struct {
+	URI     protocol.URI
+	Range   token.Range
+	Message string
+}
+
+// Fix is a named set of edits to a single file.
+type Fix struct {
+	Title string
+	Edits []Edit
+}
+
+// Edit replaces the text in a range of a file.
+type Edit struct {
+	Range   token.Range
+	NewText string
 }
 
 func (pe *Diagnostic) String() string {
@@ -19,7 +19,10 @@ type FunctionCall struct {
 func (fc *FunctionCall) expressionNode() {}
 func (fc *FunctionCall) statementNode()  {}
 func (fc *FunctionCall) Pos() token.Pos {
-	return fc.Name.Pos()
+	if fc.LeftParen != nil {
+		return posOr(fc.Name, fc.LeftParen.Pos())
+	}
+	return posOr(fc.Name, fc.Args.Pos())
 }
 func (fc *FunctionCall) End() token.Pos {
 	if fc.RightParen != nil {
@@ -31,7 +34,7 @@ func (fc *FunctionCall) End() token.Pos {
 	if fc.LeftParen != nil {
 		return fc.LeftParen.End()
 	}
-	return fc.Name.End()
+	return endOr(fc.Name, fc.Args.End())
 }
 
 type FunctionExpression struct {
@@ -61,13 +64,13 @@ type IndexExpression struct {
 
 func (ie *IndexExpression) expressionNode() {}
 func (ie *IndexExpression) Pos() token.Pos {
-	return ie.Prefix.Pos()
+	return posOr(ie.Prefix, ie.LeftIndexer.Pos())
 }
 func (ie *IndexExpression) End() token.Pos {
 	if ie.RightIndexer != nil {
 		return ie.RightIndexer.End()
 	}
-	return ie.Inner.End()
+	return endOr(ie.Inner, ie.LeftIndexer.End())
 }
 
 type InfixExpression struct {
@@ -78,10 +81,15 @@ type InfixExpression struct {
 
 func (be *InfixExpression) expressionNode() {}
 func (be *InfixExpression) Pos() token.Pos {
-	return be.Left.Pos()
+	return posOr(be.Left, be.Operator.Pos())
 }
 func (be *InfixExpression) End() token.Pos {
-	return be.Right.End()
+	return endOr(be.Right, be.Operator.End())
+}
+
+// OperatorRange returns the range of the operator token, excluding its operands.
+func (be *InfixExpression) OperatorRange() token.Range {
+	return be.Operator.Range()
 }
 
 type PrefixExpression struct {
@@ -94,7 +102,12 @@ func (pe *PrefixExpression) Pos() token.Pos {
 	return pe.Operator.Pos()
 }
 func (pe *PrefixExpression) End() token.Pos {
-	return pe.Right.End()
+	return endOr(pe.Right, pe.Operator.End())
+}
+
+// OperatorRange returns the range of the operator token, excluding its operand.
+func (pe *PrefixExpression) OperatorRange() token.Range {
+	return pe.Operator.Range()
 }
 
 // Literals (also expressions)
@@ -7,7 +7,39 @@ import (
 
 type File struct {
 	Block       *Block
+	Comments    []token.Token // Every comment in the file, in order.
 	Diagnostics []Diagnostic
 	LineBreaks  token.LineBreaks
+	Text        string // The source of the file, which characters are counted in when converting positions.
 	URI         protocol.URI
 }
+
+// ToPos returns the position of the given protocol position, counting its character in the given encoding.
+func (f *File) ToPos(position protocol.Position, encoding token.PositionEncoding) token.Pos {
+	pos := f.LineBreaks.ToPos(protocol.Position{Line: position.Line})
+	if pos == token.InvalidPos || encoding == token.PositionEncodingUTF8 || f.Text == "" {
+		return f.LineBreaks.ToPos(position)
+	}
+	line := f.Text[pos:f.LineBreaks[position.Line]]
+	if int(position.Character) > token.ColumnLength(line, encoding) {
+		return token.InvalidPos
+	}
+	return pos + token.ColumnOffset(line, int(position.Character), encoding)
+}
+
+// ToProtocolPos returns the protocol position of the given position, counting its character in the given encoding.
+func (f *File) ToProtocolPos(pos token.Pos, encoding token.PositionEncoding) protocol.Position {
+	position := f.LineBreaks.ToProtocolPos(pos)
+	lineStart := f.LineBreaks.LineStart(int(position.Line))
+	if encoding != token.PositionEncodingUTF8 && pos <= len(f.Text) && lineStart <= pos {
+		position.Character = uint32(token.ColumnLength(f.Text[lineStart:pos], encoding))
+	}
+	return position
+}
+
+func (f *File) ToProtocolRange(rng token.Range, encoding token.PositionEncoding) protocol.Range {
+	return protocol.Range{
+		Start: f.ToProtocolPos(rng.Start, encoding),
+		End:   f.ToProtocolPos(rng.End, encoding),
+	}
+}
@@ -42,18 +42,24 @@ type Pair[T Node] struct {
 }
 
 func (p *Pair[T]) Pos() token.Pos {
-	return p.Node.Pos()
+	if p.Delimeter != nil {
+		return posOr(p.Node, p.Delimeter.Pos())
+	}
+	return posOr(p.Node, token.InvalidPos)
 }
 
 func (p *Pair[T]) End() token.Pos {
 	if p.Delimeter != nil {
 		return p.Delimeter.End()
 	}
-	return p.Node.End()
+	return endOr(p.Node, token.InvalidPos)
 }
 
 func (p *Pair[T]) String() string {
-	out := p.Node.String()
+	out := ""
+	if !IsNil(p.Node) {
+		out = p.Node.String()
+	}
 	if p.Delimeter != nil {
 		out += p.Delimeter.String()
 	}
@@ -102,7 +102,7 @@ func (fs *FunctionStatement) Pos() token.Pos {
 	if fs.LocalTok != nil {
 		return fs.LocalTok.Pos()
 	}
-	return fs.Name.Pos()
+	return fs.FuncTok.Pos()
 }
 func (fs *FunctionStatement) End() token.Pos {
 	return fs.EndTok.End()
@@ -118,7 +118,7 @@ func (gs *GotoStatement) Pos() token.Pos {
 	return gs.GotoTok.Pos()
 }
 func (gs *GotoStatement) End() token.Pos {
-	return gs.Name.End()
+	return endOr(gs.Name, gs.GotoTok.End())
 }
 
 type IfStatement struct {
@@ -132,7 +132,7 @@ func (is *IfStatement) Pos() token.Pos {
 	return is.IfTok.Pos()
 }
 func (is *IfStatement) End() token.Pos {
-	return is.EndTok.Pos()
+	return is.EndTok.End()
 }
 
 type IfClause struct {
@@ -147,7 +147,13 @@ func (ic *IfClause) Pos() token.Pos {
 	return ic.LeadingTok.Pos()
 }
 func (ic *IfClause) End() token.Pos {
-	return ic.Body.End()
+	if len(ic.Body.Pairs) > 0 {
+		return ic.Body.End()
+	}
+	if ic.ThenTok != nil {
+		return ic.ThenTok.End()
+	}
+	return endOr(ic.Condition, ic.LeadingTok.End())
 }
 
 type LabelStatement struct {
@@ -168,6 +174,7 @@ func (ls *LabelStatement) leaf() {}
 type LocalStatement struct {
 	LocalTok  Unit
 	Names     Punctuated[*Identifier]
+	Attribs   []*Attrib `json:",omitempty"` // The attribute of each name, or nil for names without one.
 	AssignTok *Unit
 	Exps      *Punctuated[Expression]
 }
@@ -183,9 +190,36 @@ func (ls *LocalStatement) End() token.Pos {
 	if ls.AssignTok != nil {
 		return ls.AssignTok.End()
 	}
+	if len(ls.Attribs) > 0 && ls.Attribs[len(ls.Attribs)-1] != nil {
+		return ls.Attribs[len(ls.Attribs)-1].End()
+	}
 	return ls.Names.End()
 }
 
+// Attrib returns the attribute of the given name of the statement, or nil if it has none.
+func (ls *LocalStatement) Attrib(name *Identifier) *Attrib {
+	for i, pair := range ls.Names.Pairs {
+		if pair.Node == name && i < len(ls.Attribs) {
+			return ls.Attribs[i]
+		}
+	}
+	return nil
+}
+
+// Attrib is the attribute of a local variable, such as `<const>` or `<close>`.
+type Attrib struct {
+	LeftAngle  Unit
+	Name       Identifier
+	RightAngle Unit
+}
+
+func (a *Attrib) Pos() token.Pos {
+	return a.LeftAngle.Pos()
+}
+func (a *Attrib) End() token.Pos {
+	return a.RightAngle.End()
+}
+
 type RepeatStatement struct {
 	RepeatTok Unit
 	Body      Block
@@ -198,7 +232,7 @@ func (rs *RepeatStatement) Pos() token.Pos {
 	return rs.RepeatTok.Pos()
 }
 func (rs *RepeatStatement) End() token.Pos {
-	return rs.Condition.End()
+	return endOr(rs.Condition, rs.UntilTok.End())
 }
 
 type ReturnStatement struct {
@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"reflect"
+
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// Stats contains node counts and approximate memory usage for a syntax tree.
+type Stats struct {
+	Nodes       int            // Total number of semantic nodes.
+	NodesByType map[string]int // Number of semantic nodes of each type.
+	Trivia      int            // Total number of leading and trailing trivia tokens.
+	Bytes       int            // Approximate number of bytes retained by the tree, including the source text.
+	Text        int            // Number of bytes of source text.
+}
+
+var (
+	tokenSize   = int(reflect.TypeOf(token.Token{}).Size())
+	unitType    = reflect.TypeOf(Unit{})
+	unitPtrType = reflect.TypeOf(&Unit{})
+)
+
+// ComputeStats walks the given file and tallies its nodes and their approximate memory footprint.
+// Memory is estimated from struct sizes and trivia slices, so it undercounts allocator overhead.
+func ComputeStats(file *File) Stats {
+	stats := Stats{NodesByType: map[string]int{}}
+	stats.Bytes += len(file.LineBreaks) * int(reflect.TypeOf(0).Size())
+	stats.Text = len(file.Text)
+	stats.Bytes += len(file.Text)
+	stats.Bytes += len(file.Diagnostics) * int(reflect.TypeOf(Diagnostic{}).Size())
+	WalkSemantic(file.Block, func(n Node) bool {
+		val := reflect.ValueOf(n)
+		typ := val.Type()
+		if typ.Kind() == reflect.Pointer {
+			val = val.Elem()
+			typ = typ.Elem()
+		}
+		stats.Nodes++
+		stats.NodesByType[nodeTypeName(typ)]++
+		stats.Bytes += int(typ.Size())
+		if typ == unitType || typ.ConvertibleTo(unitType) {
+			stats.addUnit(val.Convert(unitType).Interface().(Unit))
+			return true
+		}
+		if typ.Kind() != reflect.Struct {
+			return true
+		}
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Field(i)
+			switch field.Type() {
+			case unitType:
+				stats.addUnit(field.Interface().(Unit))
+			case unitPtrType:
+				if !field.IsNil() {
+					stats.Bytes += int(unitType.Size())
+					stats.addUnit(*field.Interface().(*Unit))
+				}
+			}
+		}
+		return true
+	})
+	return stats
+}
+
+func (s *Stats) addUnit(u Unit) {
+	trivia := len(u.LeadingTrivia) + len(u.TrailingTrivia)
+	s.Trivia += trivia
+	s.Bytes += trivia * tokenSize
+}
+
+// nodeTypeName strips the generic type arguments from Pair and Punctuated so they are tallied together.
+func nodeTypeName(typ reflect.Type) string {
+	name := typ.Name()
+	for i, r := range name {
+		if r == '[' {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// Add accumulates the given stats into these stats.
+func (s *Stats) Add(other Stats) {
+	s.Nodes += other.Nodes
+	s.Trivia += other.Trivia
+	s.Bytes += other.Bytes
+	s.Text += other.Text
+	if s.NodesByType == nil {
+		s.NodesByType = map[string]int{}
+	}
+	for typ, count := range other.NodesByType {
+		s.NodesByType[typ] += count
+	}
+}
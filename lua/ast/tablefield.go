@@ -13,10 +13,10 @@ type TableArrayField struct {
 
 func (taf *TableArrayField) tableFieldNode() {}
 func (taf *TableArrayField) Pos() token.Pos {
-	return taf.Expr.Pos()
+	return posOr(taf.Expr, token.InvalidPos)
 }
 func (taf *TableArrayField) End() token.Pos {
-	return taf.Expr.End()
+	return endOr(taf.Expr, token.InvalidPos)
 }
 
 type TableSimpleKeyField struct {
@@ -30,7 +30,7 @@ func (tf *TableSimpleKeyField) Pos() token.Pos {
 	return tf.Name.Pos()
 }
 func (tf *TableSimpleKeyField) End() token.Pos {
-	return tf.Expr.End()
+	return endOr(tf.Expr, tf.AssignTok.End())
 }
 
 type TableExpressionKeyField struct {
@@ -46,5 +46,5 @@ func (tf *TableExpressionKeyField) Pos() token.Pos {
 	return tf.LeftBracket.Pos()
 }
 func (tf *TableExpressionKeyField) End() token.Pos {
-	return tf.Expr.End()
+	return endOr(tf.Expr, tf.AssignTok.End())
 }
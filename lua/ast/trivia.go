@@ -2,6 +2,14 @@ package ast
 
 import "github.com/raiguard/luapls/lua/token"
 
+// triviaOf returns the leading trivia of n, or an empty slice if n is nil.
+func triviaOf(n Node) []token.Token {
+	if IsNil(n) {
+		return []token.Token{}
+	}
+	return n.GetLeadingTrivia()
+}
+
 func (node *AssignmentStatement) GetLeadingTrivia() []token.Token {
 	return node.Vars.GetLeadingTrivia()
 }
@@ -27,7 +35,7 @@ func (node *ForStatement) GetLeadingTrivia() []token.Token {
 }
 
 func (node *FunctionCall) GetLeadingTrivia() []token.Token {
-	return node.Name.GetLeadingTrivia()
+	return triviaOf(node.Name)
 }
 
 func (node *FunctionExpression) GetLeadingTrivia() []token.Token {
@@ -58,11 +66,11 @@ func (node *IfStatement) GetLeadingTrivia() []token.Token {
 }
 
 func (node *IndexExpression) GetLeadingTrivia() []token.Token {
-	return node.Prefix.GetLeadingTrivia()
+	return triviaOf(node.Prefix)
 }
 
 func (node *InfixExpression) GetLeadingTrivia() []token.Token {
-	return node.Left.GetLeadingTrivia()
+	return triviaOf(node.Left)
 }
 
 func (node *LabelStatement) GetLeadingTrivia() []token.Token {
@@ -86,7 +94,7 @@ func (node *NumberLiteral) GetLeadingTrivia() []token.Token {
 }
 
 func (node *Pair[T]) GetLeadingTrivia() []token.Token {
-	return node.Node.GetLeadingTrivia()
+	return triviaOf(node.Node)
 }
 
 func (node *Punctuated[T]) GetLeadingTrivia() []token.Token {
@@ -117,7 +125,7 @@ func (node *StringLiteral) GetLeadingTrivia() []token.Token {
 }
 
 func (node *TableArrayField) GetLeadingTrivia() []token.Token {
-	return node.Expr.GetLeadingTrivia()
+	return triviaOf(node.Expr)
 }
 
 func (node *TableSimpleKeyField) GetLeadingTrivia() []token.Token {
@@ -1,8 +1,6 @@
 package ast
 
 import (
-	"reflect"
-
 	"github.com/raiguard/luapls/lua/token"
 )
 
@@ -11,7 +9,7 @@ type Visitor func(node Node) bool
 // WalkSemantic performs a depth-first traversal of the AST nodes, calling the visitor for each node.
 // If the visitor returns false, this node's children are not traversed.
 func WalkSemantic(node Node, visitor Visitor) {
-	if node == nil || reflect.ValueOf(node).IsNil() {
+	if IsNil(node) {
 		return
 	}
 	if !visitor(node) {
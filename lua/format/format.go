@@ -0,0 +1,529 @@
+// Package format prints Lua files in a consistent style. Comments are kept where they were, and the parentheses and
+// string, number, and comment literals of the original are preserved, so that the formatted file has the same
+// meaning.
+package format
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/parser"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// Options controls the layout of formatted files.
+type Options struct {
+	Indent string // The text of a single level of indentation, such as a tab or four spaces.
+}
+
+// File returns the formatted text of a file. It returns an error if the file has syntax errors, or if the formatted
+// text would not parse to the same syntax tree, in which case the file is left as it is.
+func File(file *ast.File, text string, options Options) (string, error) {
+	for _, diagnostic := range file.Diagnostics {
+		if diagnostic.Code == "syntax-error" {
+			return "", errors.New("Cannot format a file with syntax errors")
+		}
+	}
+	p := &printer{
+		text:      text,
+		indent:    options.Indent,
+		comments:  file.Comments,
+		parens:    parentheses(file.Block, text),
+		lineStart: true,
+		blockTop:  true,
+	}
+	p.statements(file.Block)
+	p.flushComments(math.MaxInt, true)
+	if !p.lineStart {
+		p.newline()
+	}
+	formatted := p.out.String()
+
+	result := parser.New(formatted).ParseFile()
+	if len(result.Diagnostics) > 0 || shape(file.Block, p.parens) != shape(result.Block, parentheses(result.Block, formatted)) {
+		return "", errors.New("Formatting would change the meaning of the file")
+	}
+	return formatted, nil
+}
+
+type printer struct {
+	text     string
+	indent   string
+	out      strings.Builder
+	depth    int
+	comments []token.Token
+	next     int              // The index of the next comment to print.
+	parens   map[ast.Node]int // The number of parentheses around expressions.
+	// lineStart is whether nothing has been written to the current line yet.
+	lineStart bool
+	// continued is whether the current line continues a statement that was broken by a comment, and is indented
+	// further.
+	continued bool
+	spaced    bool // Whether the last character written was a space.
+	needSpace bool // Whether a space must separate the next text from an inline comment.
+	// blockTop is whether nothing has been written in the current block yet, where blank lines are not kept.
+	blockTop bool
+}
+
+// write writes text to the current line, indenting it first if it is the start of the line.
+func (p *printer) write(s string) {
+	if p.lineStart {
+		s = strings.TrimLeft(s, " ")
+		p.out.WriteString(strings.Repeat(p.indent, p.depth))
+		if p.continued {
+			p.out.WriteString(p.indent)
+		}
+		p.lineStart = false
+	} else if p.needSpace && s != "" && s[0] != ' ' {
+		p.out.WriteByte(' ')
+	}
+	p.needSpace = false
+	p.out.WriteString(s)
+	if s != "" {
+		p.spaced = s[len(s)-1] == ' '
+	}
+}
+
+// space writes a space, unless it would be at the start of a line or follow another space.
+func (p *printer) space() {
+	if !p.lineStart && !p.spaced && !p.needSpace {
+		p.write(" ")
+	}
+}
+
+func (p *printer) newline() {
+	p.out.WriteByte('\n')
+	p.lineStart = true
+	p.needSpace = false
+	p.continued = true
+}
+
+// token writes a token, preceded by the comments before it.
+func (p *printer) token(pos token.Pos, literal string) {
+	p.flushComments(pos, false)
+	p.write(literal)
+	p.blockTop = false
+}
+
+// startLine starts a new line for the statement or field at the given position, preceded by the comments before it.
+// A single blank line is kept if there were any before it.
+func (p *printer) startLine(pos token.Pos) {
+	p.flushComments(pos, true)
+	if !p.lineStart {
+		p.newline()
+	}
+	if !p.blockTop && p.blankLineBefore(pos) {
+		p.newline()
+	}
+	p.continued = false
+}
+
+// flushComments writes every comment before the given position. Comments on their own line stay on their own line,
+// and comments after code stay after it. When fresh is true, comments on their own line are not indented as a
+// continuation of the previous line.
+func (p *printer) flushComments(pos token.Pos, fresh bool) {
+	for p.next < len(p.comments) && p.comments[p.next].Pos < pos {
+		comment := p.comments[p.next]
+		p.next++
+		if p.ownLine(comment.Pos) {
+			if !p.lineStart {
+				p.newline()
+			}
+			continued := p.continued
+			if !p.blockTop && p.blankLineBefore(comment.Pos) {
+				p.newline()
+			}
+			p.continued = continued && !fresh
+		} else {
+			p.space()
+		}
+		p.write(comment.Literal)
+		p.blockTop = false
+		// Line comments end at the end of the line, and block comments keep the line break that followed them.
+		if !isBlockComment(comment.Literal) || p.lineBreakAfter(comment.End()) {
+			p.newline()
+		} else {
+			p.needSpace = true
+		}
+	}
+}
+
+// ownLine returns whether only whitespace precedes the given position on its line in the original text.
+func (p *printer) ownLine(pos token.Pos) bool {
+	for i := pos - 1; i >= 0; i-- {
+		switch p.text[i] {
+		case '\n':
+			return true
+		case ' ', '\t', '\r':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// blankLineBefore returns whether the whitespace before the given position in the original text contains a blank
+// line.
+func (p *printer) blankLineBefore(pos token.Pos) bool {
+	lines := 0
+	for i := pos - 1; i >= 0 && isSpace(p.text[i]); i-- {
+		if p.text[i] == '\n' {
+			lines++
+		}
+	}
+	return lines >= 2
+}
+
+// lineBreakAfter returns whether only whitespace follows the given position on its line in the original text.
+func (p *printer) lineBreakAfter(pos token.Pos) bool {
+	for i := pos; i < len(p.text); i++ {
+		switch p.text[i] {
+		case '\n':
+			return true
+		case ' ', '\t', '\r':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// isBlockComment returns whether a comment is a long comment, such as `--[[ comment ]]`, rather than a line comment.
+func isBlockComment(literal string) bool {
+	rest := strings.TrimLeft(strings.TrimPrefix(literal, "--["), "=")
+	return strings.HasPrefix(literal, "--[") && strings.HasPrefix(rest, "[")
+}
+
+// block writes the statements of a block one level deeper, followed by the comments before its end.
+func (p *printer) block(block *ast.Block, end token.Pos) {
+	p.depth++
+	p.blockTop = true
+	p.statements(block)
+	p.flushComments(end, true)
+	p.depth--
+	if !p.lineStart {
+		p.newline()
+	}
+	p.continued = false
+}
+
+// statements writes each statement of a block on its own line. Semicolons are dropped, except where the next
+// statement starts with a parenthesis and would otherwise continue the previous one.
+func (p *printer) statements(block *ast.Block) {
+	for i, pair := range block.Pairs {
+		if _, ok := pair.Node.(*ast.SemicolonStatement); ok {
+			continue
+		}
+		p.startLine(pair.Node.Pos())
+		p.statement(pair.Node)
+		if i+1 < len(block.Pairs) && p.startsWithParen(block.Pairs[i+1].Node) {
+			p.write(";")
+		}
+	}
+}
+
+// startsWithParen returns whether a statement starts with an opening parenthesis.
+func (p *printer) startsWithParen(stmt ast.Statement) bool {
+	var node ast.Node = stmt
+	for !ast.IsNil(node) {
+		if p.parens[node] > 0 {
+			return true
+		}
+		switch n := node.(type) {
+		case *ast.AssignmentStatement:
+			node = n.Vars.Pairs[0].Node
+		case *ast.FunctionCall:
+			node = n.Name
+		case *ast.IndexExpression:
+			node = n.Prefix
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch n := stmt.(type) {
+	case *ast.AssignmentStatement:
+		p.expressions(&n.Vars)
+		p.space()
+		p.token(n.Assign.Pos(), "=")
+		p.space()
+		p.expressions(&n.Exps)
+	case *ast.BreakStatement:
+		p.token(n.Pos(), "break")
+	case *ast.DoStatement:
+		p.token(n.DoTok.Pos(), "do")
+		p.block(&n.Body, n.EndTok.Pos())
+		p.token(n.EndTok.Pos(), "end")
+	case *ast.ForStatement:
+		p.token(n.ForTok.Pos(), "for ")
+		p.expression(n.Name)
+		p.token(n.AssignTok.Pos(), " = ")
+		p.expression(n.Start.Node)
+		p.write(", ")
+		p.expression(n.Finish.Node)
+		if n.Step != nil {
+			p.write(", ")
+			p.expression(n.Step.Node)
+		}
+		p.token(n.DoTok.Pos(), " do")
+		p.block(&n.Body, n.EndTok.Pos())
+		p.token(n.EndTok.Pos(), "end")
+	case *ast.ForInStatement:
+		p.token(n.ForTok.Pos(), "for ")
+		p.names(&n.Names)
+		p.token(n.InTok.Pos(), " in ")
+		p.expressions(&n.Exps)
+		p.token(n.DoTok.Pos(), " do")
+		p.block(&n.Body, n.EndTok.Pos())
+		p.token(n.EndTok.Pos(), "end")
+	case *ast.FunctionCall:
+		p.expression(n)
+	case *ast.FunctionStatement:
+		if n.LocalTok != nil {
+			p.token(n.LocalTok.Pos(), "local ")
+		}
+		p.token(n.FuncTok.Pos(), "function ")
+		p.expression(n.Name)
+		p.function(&n.Params, n.Vararg, &n.Body, n.EndTok.Pos())
+	case *ast.GotoStatement:
+		p.token(n.GotoTok.Pos(), "goto ")
+		p.expression(n.Name)
+	case *ast.IfStatement:
+		for _, clause := range n.Clauses {
+			switch clause.LeadingTok.Type() {
+			case token.IF:
+				p.token(clause.LeadingTok.Pos(), "if ")
+			case token.ELSEIF:
+				p.token(clause.LeadingTok.Pos(), "elseif ")
+			default:
+				p.token(clause.LeadingTok.Pos(), "else")
+			}
+			if !ast.IsNil(clause.Condition) {
+				p.expression(clause.Condition)
+				p.token(clause.ThenTok.Pos(), " then")
+			}
+			end := n.EndTok.Pos()
+			if next := nextClause(n, clause); next != nil {
+				end = next.Pos()
+			}
+			p.block(&clause.Body, end)
+		}
+		p.token(n.EndTok.Pos(), "end")
+	case *ast.LabelStatement:
+		p.token(n.LeadingLabelTok.Pos(), "::")
+		p.expression(n.Name)
+		p.token(n.TrailingLabelTok.Pos(), "::")
+	case *ast.LocalStatement:
+		p.token(n.LocalTok.Pos(), "local ")
+		for i, pair := range n.Names.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(pair.Node)
+			if attrib := n.Attrib(pair.Node); attrib != nil {
+				p.write(" <" + attrib.Name.Token.Literal + ">")
+			}
+		}
+		if n.AssignTok != nil {
+			p.space()
+			p.token(n.AssignTok.Pos(), "=")
+			p.space()
+			p.expressions(n.Exps)
+		}
+	case *ast.RepeatStatement:
+		p.token(n.RepeatTok.Pos(), "repeat")
+		p.block(&n.Body, n.UntilTok.Pos())
+		p.token(n.UntilTok.Pos(), "until ")
+		p.expression(n.Condition)
+	case *ast.ReturnStatement:
+		p.token(n.ReturnTok.Pos(), "return")
+		if n.Exps != nil {
+			p.space()
+			p.expressions(n.Exps)
+		}
+	case *ast.WhileStatement:
+		p.token(n.WhileTok.Pos(), "while ")
+		p.expression(n.Condition)
+		p.token(n.DoTok.Pos(), " do")
+		p.block(&n.Body, n.EndTok.Pos())
+		p.token(n.EndTok.Pos(), "end")
+	}
+}
+
+// nextClause returns the clause of an if statement that follows the given one, or nil if it is the last one.
+func nextClause(stmt *ast.IfStatement, clause *ast.IfClause) *ast.IfClause {
+	for i, other := range stmt.Clauses {
+		if other == clause && i+1 < len(stmt.Clauses) {
+			return stmt.Clauses[i+1]
+		}
+	}
+	return nil
+}
+
+// function writes the parameters and body of a function. Functions with empty bodies are kept on a single line.
+func (p *printer) function(params *ast.Punctuated[*ast.Identifier], vararg *ast.Unit, body *ast.Block, end token.Pos) {
+	p.write("(")
+	p.names(params)
+	if vararg != nil {
+		if len(params.Pairs) > 0 {
+			p.write(", ")
+		}
+		p.token(vararg.Pos(), "...")
+	}
+	p.write(")")
+	if len(body.Pairs) == 0 && !p.commentsBefore(end) {
+		p.token(end, " end")
+		return
+	}
+	p.block(body, end)
+	p.token(end, "end")
+}
+
+// commentsBefore returns whether there are comments left to print before the given position.
+func (p *printer) commentsBefore(pos token.Pos) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Pos < pos
+}
+
+func (p *printer) names(names *ast.Punctuated[*ast.Identifier]) {
+	for i, pair := range names.Pairs {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(pair.Node)
+	}
+}
+
+func (p *printer) expressions(exps *ast.Punctuated[ast.Expression]) {
+	for i, pair := range exps.Pairs {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(pair.Node)
+	}
+}
+
+func (p *printer) expression(expr ast.Expression) {
+	parens := p.parens[expr]
+	if parens > 0 {
+		p.token(expr.Pos(), strings.Repeat("(", parens))
+	}
+	switch n := expr.(type) {
+	case *ast.BooleanLiteral:
+		p.token(n.Pos(), n.Token.Literal)
+	case *ast.FunctionCall:
+		p.expression(n.Name)
+		if n.LeftParen == nil {
+			// Calls with a single string or table argument are written without parentheses.
+			p.write(" ")
+			p.expressions(&n.Args)
+			break
+		}
+		p.token(n.LeftParen.Pos(), "(")
+		p.expressions(&n.Args)
+		p.token(n.RightParen.Pos(), ")")
+	case *ast.FunctionExpression:
+		p.token(n.FuncTok.Pos(), "function")
+		p.function(&n.Params, n.Vararg, &n.Body, n.EndUnit.Pos())
+	case *ast.Identifier:
+		p.token(n.Pos(), n.Token.Literal)
+	case *ast.IndexExpression:
+		p.expression(n.Prefix)
+		p.token(n.LeftIndexer.Pos(), n.LeftIndexer.Token.Literal)
+		p.expression(n.Inner)
+		if n.RightIndexer != nil {
+			p.token(n.RightIndexer.Pos(), "]")
+		}
+	case *ast.InfixExpression:
+		p.expression(n.Left)
+		p.space()
+		p.token(n.Operator.Pos(), n.Operator.Token.Literal)
+		p.space()
+		p.expression(n.Right)
+	case *ast.NilLiteral:
+		p.token(n.Pos(), "nil")
+	case *ast.NumberLiteral:
+		p.token(n.Pos(), n.Token.Literal)
+	case *ast.PrefixExpression:
+		p.token(n.Operator.Pos(), n.Operator.Token.Literal)
+		if right, ok := n.Right.(*ast.PrefixExpression); n.Operator.Type() == token.NOT ||
+			ok && right.Operator.Type() == token.MINUS && p.parens[right] == 0 {
+			// `- -x` must not become a comment.
+			p.write(" ")
+		}
+		p.expression(n.Right)
+	case *ast.StringLiteral:
+		p.token(n.Pos(), n.Token.Literal)
+	case *ast.TableLiteral:
+		p.table(n)
+	case *ast.Vararg:
+		p.token(n.Pos(), "...")
+	}
+	if parens > 0 {
+		p.write(strings.Repeat(")", parens))
+	}
+}
+
+// table writes a table constructor. Tables that spanned several lines have a field on each line, and other tables
+// are written on a single line.
+func (p *printer) table(table *ast.TableLiteral) {
+	p.token(table.LeftBrace.Pos(), "{")
+	if len(table.Fields.Pairs) == 0 && !p.commentsBefore(table.RightBrace.Pos()) {
+		p.token(table.RightBrace.Pos(), "}")
+		return
+	}
+	if !strings.Contains(p.text[table.Pos():table.End()], "\n") {
+		p.write(" ")
+		for i, pair := range table.Fields.Pairs {
+			p.field(pair.Node)
+			if pair.Delimeter != nil && i+1 < len(table.Fields.Pairs) {
+				p.token(pair.Delimeter.Pos(), pair.Delimeter.Token.Literal+" ")
+			}
+		}
+		p.space()
+		p.token(table.RightBrace.Pos(), "}")
+		return
+	}
+	continued := p.continued
+	p.depth++
+	p.blockTop = true
+	for _, pair := range table.Fields.Pairs {
+		p.startLine(pair.Node.Pos())
+		p.field(pair.Node)
+		if pair.Delimeter != nil {
+			p.token(pair.Delimeter.Pos(), pair.Delimeter.Token.Literal)
+		}
+	}
+	p.flushComments(table.RightBrace.Pos(), true)
+	p.depth--
+	if !p.lineStart {
+		p.newline()
+	}
+	p.continued = continued
+	p.token(table.RightBrace.Pos(), "}")
+}
+
+func (p *printer) field(field ast.TableField) {
+	switch n := field.(type) {
+	case *ast.TableArrayField:
+		p.expression(n.Expr)
+	case *ast.TableSimpleKeyField:
+		p.expression(&n.Name)
+		p.token(n.AssignTok.Pos(), " = ")
+		p.expression(n.Expr)
+	case *ast.TableExpressionKeyField:
+		p.token(n.LeftBracket.Pos(), "[")
+		p.expression(n.Name)
+		p.token(n.RightBracket.Pos(), "]")
+		p.token(n.AssignTok.Pos(), " = ")
+		p.expression(n.Expr)
+	}
+}
@@ -0,0 +1,114 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/raiguard/luapls/lua/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// format formats the source with two spaces of indentation, and checks that formatting the result again does not
+// change it.
+func format(t *testing.T, src string) string {
+	file := parser.New(src).ParseFile()
+	formatted, err := File(&file, src, Options{Indent: "  "})
+	require.NoError(t, err)
+	again := parser.New(formatted).ParseFile()
+	reformatted, err := File(&again, formatted, Options{Indent: "  "})
+	require.NoError(t, err)
+	assert.Equal(t, formatted, reformatted)
+	return formatted
+}
+
+func TestFormatStatements(t *testing.T) {
+	assert.Equal(t, `local a, b = 1, 2
+if a > b then
+  print(a)
+elseif not b then
+  return
+else
+  for i = 1, 10, 2 do
+    print(i)
+  end
+end
+while true do
+  break
+end
+repeat
+  local x = f()
+until x
+`, format(t, `local a,b=1,2
+if a>b then print(a) elseif not b then return else for i=1,10,2 do print(i) end end
+while true do break end
+repeat local x = f() until x`))
+}
+
+func TestFormatAttribs(t *testing.T) {
+	assert.Equal(t, `local a <const>, b, c <close> = 1, 2, f()
+local d <const> = 4
+`, format(t, `local a<const> , b,c< close > =1,2,f()
+local d <const> =4`))
+}
+
+func TestFormatFunctions(t *testing.T) {
+	assert.Equal(t, `local function f(x, ...)
+  return x
+end
+function M:g() end
+local h = function() end
+`, format(t, `local function f( x,... ) return x end
+function M:g()
+end
+local h = function() end`))
+}
+
+func TestFormatTables(t *testing.T) {
+	assert.Equal(t, `local t = { 1, 2; x = 3, ["y"] = {} }
+local u = {
+  a = 1,
+  b = { c = 2 },
+}
+`, format(t, `local t = {1,2;x=3,["y"]={}}
+local u = {
+    a=1,
+        b={c=2},
+}`))
+}
+
+func TestFormatComments(t *testing.T) {
+	assert.Equal(t, `-- Header
+
+---@param x number
+local function f(x) -- trailing
+  -- inside
+  call(x, --[[ inline ]] 1)
+  -- before end
+end
+-- last
+`, format(t, `-- Header
+
+---@param x number
+local function f(x) -- trailing
+        -- inside
+  call(x,--[[ inline ]]1)
+    -- before end
+end
+-- last`))
+}
+
+func TestFormatBlankLines(t *testing.T) {
+	assert.Equal(t, "local a = 1\n\nlocal b = 2\ndo\n  local c = 3\nend\n", format(t, "local a = 1\n\n\n\nlocal b = 2\ndo\n\n  local c = 3\nend"))
+}
+
+func TestFormatParentheses(t *testing.T) {
+	// Parentheses are not part of the syntax tree, but change the meaning of expressions.
+	assert.Equal(t, "local a = (1 + 2) * 3\nlocal b = ((f()))\nlocal c = - -x;\n(f)()\n", format(t, "local a = (1+2)*3\nlocal b = ((f()))\nlocal c = - - x;\n(f)()"))
+}
+
+func TestFormatSyntaxErrors(t *testing.T) {
+	file := parser.New("local = 1").ParseFile()
+	_, err := File(&file, "local = 1", Options{Indent: "\t"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/lexer"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// parentheses returns the number of parentheses around each expression of the text. They are not part of the syntax
+// tree, but they change the meaning of an expression, such as by discarding the extra values of a function call.
+func parentheses(block *ast.Block, text string) map[ast.Node]int {
+	// The parentheses of calls and function parameters belong to the syntax, and all others group expressions.
+	syntax := map[token.Pos]bool{}
+	expressions := map[token.Range]ast.Node{}
+	ast.WalkSemantic(block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionCall:
+			if n.LeftParen != nil {
+				syntax[n.LeftParen.Pos()] = true
+			}
+		case *ast.FunctionExpression:
+			syntax[n.LeftParen.Pos()] = true
+		case *ast.FunctionStatement:
+			syntax[n.LeftParen.Pos()] = true
+		}
+		// The outermost expression with a range is the one that the parentheses surround.
+		if _, ok := n.(ast.Expression); ok {
+			if _, ok := expressions[ast.Range(n)]; !ok {
+				expressions[ast.Range(n)] = n
+			}
+		}
+		return true
+	})
+
+	lexed, _ := lexer.Run(text)
+	tokens := []token.Token{}
+	for _, tok := range lexed {
+		if tok.Type != token.COMMENT && tok.Type != token.WHITESPACE {
+			tokens = append(tokens, tok)
+		}
+	}
+	matches := map[int]int{}
+	stack := []int{}
+	for i, tok := range tokens {
+		switch tok.Type {
+		case token.LPAREN:
+			stack = append(stack, i)
+		case token.RPAREN:
+			if len(stack) > 0 {
+				matches[stack[len(stack)-1]] = i
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	parens := map[ast.Node]int{}
+	nested := map[int]bool{}
+	for open := range tokens {
+		close, ok := matches[open]
+		if !ok || syntax[tokens[open].Pos] || nested[open] {
+			continue
+		}
+		// Nested parentheses such as `((x))` all surround the same expression.
+		count := 1
+		for open+count < close-count && matches[open+count] == close-count && !syntax[tokens[open+count].Pos] {
+			nested[open+count] = true
+			count++
+		}
+		inner := token.Range{Start: tokens[open+count].Pos, End: tokens[close-count].End()}
+		if n, ok := expressions[inner]; ok {
+			parens[n] += count
+		}
+	}
+	return parens
+}
+
+// shape returns a description of the syntax tree that two trees share if they have the same meaning. Positions,
+// whitespace, comments, and semicolons are ignored.
+func shape(block *ast.Block, parens map[ast.Node]int) string {
+	var b strings.Builder
+	var describe func(n ast.Node)
+	describe = func(n ast.Node) {
+		if ast.IsNil(n) {
+			b.WriteString("_ ")
+			return
+		}
+		if pair, ok := n.(*ast.Pair[ast.Statement]); ok {
+			if _, ok := pair.Node.(*ast.SemicolonStatement); ok {
+				return
+			}
+		}
+		fmt.Fprintf(&b, "%T", n)
+		switch n := n.(type) {
+		case *ast.BooleanLiteral:
+			fmt.Fprintf(&b, "[%s]", n.Token.Literal)
+		case *ast.Identifier:
+			fmt.Fprintf(&b, "[%s]", n.Token.Literal)
+		case *ast.NumberLiteral:
+			fmt.Fprintf(&b, "[%s]", n.Token.Literal)
+		case *ast.StringLiteral:
+			fmt.Fprintf(&b, "[%s]", n.Token.Literal)
+		case *ast.FunctionCall:
+			fmt.Fprintf(&b, "[%t]", n.LeftParen != nil)
+		case *ast.FunctionExpression:
+			fmt.Fprintf(&b, "[%t]", n.Vararg != nil)
+		case *ast.FunctionStatement:
+			fmt.Fprintf(&b, "[%t %t]", n.LocalTok != nil, n.Vararg != nil)
+		case *ast.IfClause:
+			fmt.Fprintf(&b, "[%d]", n.LeadingTok.Type())
+		case *ast.IndexExpression:
+			fmt.Fprintf(&b, "[%d]", n.LeftIndexer.Type())
+		case *ast.InfixExpression:
+			fmt.Fprintf(&b, "[%d]", n.Operator.Type())
+		case *ast.PrefixExpression:
+			fmt.Fprintf(&b, "[%d]", n.Operator.Type())
+		}
+		fmt.Fprintf(&b, "(%d ", parens[n])
+		for _, child := range n.GetSemanticChildren() {
+			describe(child)
+		}
+		b.WriteString(") ")
+	}
+	describe(block)
+	return b.String()
+}
@@ -121,6 +121,10 @@ func (l *Lexer) Next() token.Token {
 		}
 	case ';':
 		tok = token.SEMICOLON
+	case '|':
+		tok = token.PIPE
+	case '?':
+		tok = token.QUESTION
 	case '\'', '"':
 		if l.readString(r) {
 			tok = token.STRING
@@ -0,0 +1,72 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// assignTypeMismatch reports values that are assigned to locals, globals, or class fields whose type was declared by
+// an annotation, but that are not assignable to that type.
+func (l *linter) assignTypeMismatch() {
+	ast.WalkSemantic(l.file.Block, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.AssignmentStatement:
+			for i, pair := range stmt.Vars.Pairs {
+				if i < len(stmt.Exps.Pairs) {
+					declared, loc := l.declaredType(pair.Node)
+					l.checkAssignment(declared, loc, stmt.Exps.Pairs[i].Node)
+				}
+			}
+		case *ast.LocalStatement:
+			if stmt.Exps == nil {
+				break
+			}
+			for i, pair := range stmt.Names.Pairs {
+				if sym := l.info.Defs[pair.Node]; sym != nil && i < len(stmt.Exps.Pairs) {
+					l.checkAssignment(sym.Annotated, types.Location{}, stmt.Exps.Pairs[i].Node)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// declaredType returns the annotated type of the assignment target and where the target was declared, or nil if its
+// type is inferred.
+func (l *linter) declaredType(target ast.Expression) (types.Type, types.Location) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		if sym := l.info.SymbolOf(target); sym != nil {
+			var loc types.Location
+			if sym.Decl != nil {
+				loc = types.Location{URI: l.file.URI, Range: ast.Range(sym.Decl)}
+			}
+			return sym.Annotated, loc
+		}
+	case *ast.IndexExpression:
+		key, ok := types.FieldKey(target)
+		if !ok {
+			return nil, types.Location{}
+		}
+		if class, ok := types.Resolve(l.info.TypeOf(target.Prefix)).(*types.Named); ok {
+			if field := class.Field(key); field != nil && field.Annotated {
+				return field.Type, field.Loc
+			}
+		}
+	}
+	return nil, types.Location{}
+}
+
+// checkAssignment reports the value if it is not assignable to the declared type. The declaration is attached to the
+// diagnostic if its location is known.
+func (l *linter) checkAssignment(declared types.Type, loc types.Location, value ast.Expression) {
+	if declared == nil {
+		return
+	}
+	if types.Assignable(l.info.LiteralTypeOf(value), declared) {
+		return
+	}
+	d := l.report("assign-type-mismatch", ast.Range(value), protocol.DiagnosticSeverityWarning, "Cannot assign '%s' to '%s'", l.info.TypeOf(value), declared)
+	d.Related = related(loc, "Declared as '%s' here", declared)
+}
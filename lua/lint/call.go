@@ -0,0 +1,108 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// callArguments checks the arguments of function calls against the parameters of the function being called. Calls
+// may pass fewer arguments than there are parameters if the remaining parameters accept nil, and any number of
+// arguments to a function that takes `...`.
+func (l *linter) callArguments() {
+	ast.WalkSemantic(l.file.Block, func(n ast.Node) bool {
+		if fc, ok := n.(*ast.FunctionCall); ok {
+			l.checkCall(fc)
+		}
+		return true
+	})
+}
+
+func (l *linter) checkCall(fc *ast.FunctionCall) {
+	fn, ok := types.Resolve(l.info.TypeOf(fc.Name)).(*types.Function)
+	if !ok {
+		return
+	}
+	args := fc.Args.Pairs
+	// Constant arguments are checked by their values, so that they can be passed to parameters of literal types.
+	argTypes := make([]types.Type, 0, len(args))
+	for _, pair := range args {
+		argTypes = append(argTypes, l.info.LiteralTypeOf(pair.Node))
+	}
+	if receiver := types.Receiver(fc, fn, l.info); receiver != nil {
+		fn = types.SelectOverload(fn, append([]types.Type{receiver}, argTypes...))
+	} else {
+		fn = types.SelectOverload(fn, argTypes)
+	}
+	params := fn.Params
+	// Methods called with `:` receive the prefix as their first parameter.
+	if ie, ok := fc.Name.(*ast.IndexExpression); ok && ie.LeftIndexer.Type() == token.COLON {
+		if len(params) == 0 || params[0].Name != "self" {
+			return
+		}
+		params = params[1:]
+	}
+
+	// Generic parameters are checked when the type parameters are inferred.
+	checkTypes := len(fn.TypeParams) == 0
+	for i, pair := range args {
+		param := paramAt(params, i)
+		if param == nil {
+			rng := token.Range{Start: pair.Node.Pos(), End: args[len(args)-1].Node.End()}
+			d := l.report("redundant-argument", rng, protocol.DiagnosticSeverityWarning, "Expected %d arguments, but got %d", len(params), len(args))
+			// The extra arguments are still evaluated, but their values are discarded.
+			d.Tags = []protocol.DiagnosticTag{protocol.DiagnosticTagUnnecessary}
+			return
+		}
+		if checkTypes && !types.Assignable(argTypes[i], param.Type) {
+			d := l.report("argument-type-mismatch", ast.Range(pair.Node), protocol.DiagnosticSeverityWarning,
+				"Cannot pass '%s' to parameter '%s' of type '%s'", l.info.TypeOf(pair.Node), param.Name, param.Type)
+			d.Related = related(param.Loc, "Parameter '%s' is declared here", param.Name)
+		}
+	}
+	if len(args) > 0 && isMultiValue(args[len(args)-1].Node) {
+		// The last argument may expand to any number of values. Forwarded varargs are checked against every
+		// parameter that they may fill.
+		last := args[len(args)-1].Node
+		if _, ok := last.(*ast.Vararg); ok && checkTypes {
+			typ := argTypes[len(args)-1]
+			for i := len(args); i < len(params) && params[i].Name != "..."; i++ {
+				if !types.Assignable(typ, params[i].Type) {
+					d := l.report("argument-type-mismatch", ast.Range(last), protocol.DiagnosticSeverityWarning,
+						"Cannot pass '%s' to parameter '%s' of type '%s'", typ, params[i].Name, params[i].Type)
+					d.Related = related(params[i].Loc, "Parameter '%s' is declared here", params[i].Name)
+				}
+			}
+		}
+		return
+	}
+	for _, param := range params[min(len(args), len(params)):] {
+		if param.Name == "..." || types.Assignable(&types.Nil{}, param.Type) {
+			continue
+		}
+		d := l.report("missing-argument", ast.Range(fc), protocol.DiagnosticSeverityWarning, "Missing argument for parameter '%s'", param.Name)
+		d.Related = related(param.Loc, "Parameter '%s' is declared here", param.Name)
+	}
+}
+
+// paramAt returns the parameter that receives the argument at the given index, or nil if there are too many
+// arguments. A trailing `...` parameter receives every remaining argument.
+func paramAt(params []types.NameAndType, i int) *types.NameAndType {
+	if i < len(params) && params[i].Name != "..." {
+		return &params[i]
+	}
+	if n := len(params); n > 0 && params[n-1].Name == "..." && i >= n-1 {
+		return &params[n-1]
+	}
+	return nil
+}
+
+// isMultiValue returns whether the expression may produce more than one value when it is the last in a list.
+func isMultiValue(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.FunctionCall, *ast.Vararg:
+		return true
+	}
+	return false
+}
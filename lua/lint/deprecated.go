@@ -0,0 +1,54 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// deprecatedUses reports uses of locals, globals, and fields whose declaration is annotated with `---@deprecated`.
+func (l *linter) deprecatedUses() {
+	declarations := map[ast.Node]bool{}
+	ast.WalkSemantic(l.file.Block, func(n ast.Node) bool {
+		var expr ast.Expression
+		switch n := n.(type) {
+		case *ast.AssignmentStatement:
+			for _, pair := range n.Vars.Pairs {
+				declarations[pair.Node] = true
+			}
+		case *ast.FunctionStatement:
+			declarations[n.Name] = true
+		case *ast.Identifier:
+			if sym := l.info.SymbolOf(n); sym != nil && sym.Decl != n && !declarations[n] {
+				expr = n
+			}
+		case *ast.IndexExpression:
+			if !declarations[n] {
+				expr = n
+			}
+		}
+		if expr == nil {
+			return true
+		}
+		deprecated := l.env.Deprecation(l.file.URI, expr)
+		if deprecated == nil {
+			return true
+		}
+		rng, name := ast.Range(expr), ""
+		switch expr := expr.(type) {
+		case *ast.Identifier:
+			name = expr.Token.Literal
+		case *ast.IndexExpression:
+			rng = ast.Range(expr.Inner)
+			name, _ = types.FieldKey(expr)
+		}
+		var d *ast.Diagnostic
+		if deprecated.Message != "" {
+			d = l.report("deprecated", rng, protocol.DiagnosticSeverityHint, "'%s' is deprecated: %s", name, deprecated.Message)
+		} else {
+			d = l.report("deprecated", rng, protocol.DiagnosticSeverityHint, "'%s' is deprecated", name)
+		}
+		d.Tags = []protocol.DiagnosticTag{protocol.DiagnosticTagDeprecated}
+		return true
+	})
+}
@@ -0,0 +1,75 @@
+package lint
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/ast"
+)
+
+// directive is a `---@diagnostic` annotation, along with the line that it is on.
+type directive struct {
+	*annotation.Diagnostic
+	line int
+}
+
+// Suppress removes the diagnostics that are disabled by `---@diagnostic` annotations in the file. `disable` and
+// `enable` apply to the rest of the file, while `disable-line` and `disable-next-line` apply to a single line.
+// Diagnostics without a code can only be disabled by annotations that do not list any codes.
+func Suppress(file *ast.File, diagnostics []ast.Diagnostic) []ast.Diagnostic {
+	directives := parseDirectives(file)
+	if len(directives) == 0 {
+		return diagnostics
+	}
+	return slices.DeleteFunc(slices.Clone(diagnostics), func(diagnostic ast.Diagnostic) bool {
+		line := file.LineBreaks.Line(diagnostic.Range.Start)
+		disabled := false
+		for _, d := range directives {
+			if !d.affects(diagnostic.Code) {
+				continue
+			}
+			switch d.Action {
+			case "disable":
+				if d.Range.Start < diagnostic.Range.Start {
+					disabled = true
+				}
+			case "enable":
+				if d.Range.Start < diagnostic.Range.Start {
+					disabled = false
+				}
+			case "disable-line":
+				if d.line == line {
+					return true
+				}
+			case "disable-next-line":
+				if d.line+1 == line {
+					return true
+				}
+			}
+		}
+		return disabled
+	})
+}
+
+// affects returns whether the directive applies to diagnostics with the given code.
+func (d directive) affects(code string) bool {
+	return len(d.Codes) == 0 || slices.Contains(d.Codes, code)
+}
+
+// parseDirectives returns the `---@diagnostic` annotations in the file, in order.
+func parseDirectives(file *ast.File) []directive {
+	directives := []directive{}
+	for _, comment := range file.Comments {
+		content, ok := strings.CutPrefix(comment.Literal, "---")
+		if !ok || !strings.HasPrefix(strings.TrimSpace(content), "@diagnostic") {
+			continue
+		}
+		// Invalid annotations are reported when the file's doc comments are parsed.
+		a, _ := annotation.Parse(content, comment.Pos+3)
+		if d, ok := a.(*annotation.Diagnostic); ok {
+			directives = append(directives, directive{d, file.LineBreaks.Line(comment.Pos)})
+		}
+	}
+	return directives
+}
@@ -0,0 +1,64 @@
+package lint
+
+import (
+	"strconv"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// duplicateKeys reports keys that are given more than once in a table constructor, including explicit numeric keys
+// that collide with positional fields. Only the last value of a duplicate key is kept.
+func (l *linter) duplicateKeys() {
+	ast.WalkSemantic(l.file.Block, func(n ast.Node) bool {
+		if tbl, ok := n.(*ast.TableLiteral); ok {
+			l.checkTableKeys(tbl)
+		}
+		return true
+	})
+}
+
+func (l *linter) checkTableKeys(tbl *ast.TableLiteral) {
+	seen := map[string]ast.Node{}
+	index := 0
+	for _, pair := range tbl.Fields.Pairs {
+		key, node := "", ast.Node(nil)
+		switch field := pair.Node.(type) {
+		case *ast.TableArrayField:
+			index++
+			key, node = "["+strconv.Itoa(index)+"]", field.Expr
+		case *ast.TableSimpleKeyField:
+			key, node = field.Name.Token.Literal, &field.Name
+		case *ast.TableExpressionKeyField:
+			key, node = constantKey(field.Name), field.Name
+		}
+		if key == "" || ast.IsNil(node) {
+			continue
+		}
+		if previous := seen[key]; previous != nil {
+			d := l.report("duplicate-key", ast.Range(node), protocol.DiagnosticSeverityWarning, "Duplicate key '%s' in table", key)
+			d.Related = []ast.Related{{Range: ast.Range(previous), Message: "Key '" + key + "' was first defined here"}}
+		}
+		seen[key] = node
+	}
+}
+
+// constantKey returns the key of a bracketed string or number literal, normalized so that `["a"]` matches `a` and
+// `[1.0]` matches `[1]`, or an empty string if the key is not constant.
+func constantKey(expr ast.Expression) string {
+	switch expr := expr.(type) {
+	case *ast.StringLiteral:
+		if value, ok := types.StringValue(expr); ok {
+			return value
+		}
+	case *ast.NumberLiteral:
+		if value, err := strconv.ParseInt(expr.Token.Literal, 0, 64); err == nil {
+			return "[" + strconv.FormatInt(value, 10) + "]"
+		}
+		if value, err := strconv.ParseFloat(expr.Token.Literal, 64); err == nil {
+			return "[" + strconv.FormatFloat(value, 'g', -1, 64) + "]"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// undefinedFields reports reads of fields that a class does not have. Only classes that declare fields with `@field`
+// are checked, since the fields of other classes are only known from the assignments that happen to be visible.
+func (l *linter) undefinedFields() {
+	targets := map[ast.Node]bool{}
+	ast.WalkSemantic(l.file.Block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignmentStatement:
+			// Assigning to a field defines it.
+			for _, pair := range n.Vars.Pairs {
+				targets[pair.Node] = true
+			}
+		case *ast.FunctionStatement:
+			targets[n.Name] = true
+		case *ast.IndexExpression:
+			if !targets[n] {
+				l.checkField(n)
+			}
+		}
+		return true
+	})
+}
+
+func (l *linter) checkField(ie *ast.IndexExpression) {
+	key, ok := types.FieldKey(ie)
+	if !ok {
+		return
+	}
+	class, ok := types.Resolve(types.RemoveNil(l.info.TypeOf(ie.Prefix))).(*types.Named)
+	if !ok || !hasDeclaredFields(class) || class.Field(key) != nil {
+		return
+	}
+	d := l.report("undefined-field", ast.Range(ie.Inner), protocol.DiagnosticSeverityWarning, "Undefined field '%s' in '%s'", key, class.Name)
+	d.Related = related(class.Loc, "Class '%s' is declared here", class.Name)
+}
+
+func hasDeclaredFields(class *types.Named) bool {
+	for _, field := range class.AllFields() {
+		if field.Annotated {
+			return true
+		}
+	}
+	return false
+}
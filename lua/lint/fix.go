@@ -0,0 +1,39 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// removeStatement returns an edit that deletes the given statement. When nothing else is on the lines that the
+// statement spans, the lines are deleted as well.
+func (l *linter) removeStatement(stmt ast.Statement) ast.Edit {
+	lines := l.file.LineBreaks
+	startLine, endLine := lines.Line(stmt.Pos()), lines.Line(stmt.End())
+	alone := true
+	ast.WalkSemantic(l.file.Block, func(n ast.Node) bool {
+		other, ok := n.(ast.Statement)
+		if !alone || !ok {
+			return alone
+		}
+		// Statements within this one are removed along with it.
+		if other.Pos() >= stmt.Pos() && other.End() <= stmt.End() {
+			return false
+		}
+		for _, line := range []int{lines.Line(other.Pos()), lines.Line(other.End())} {
+			if line >= startLine && line <= endLine {
+				alone = false
+			}
+		}
+		return alone
+	})
+	if !alone {
+		return ast.Edit{Range: ast.Range(stmt)}
+	}
+	// The last line has no line break to remove.
+	end := stmt.End()
+	if endLine+1 < len(lines) {
+		end = lines.LineStart(endLine + 1)
+	}
+	return ast.Edit{Range: token.Range{Start: lines.LineStart(startLine), End: end}}
+}
@@ -0,0 +1,108 @@
+package lint
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// undefinedGlobals reports reads of globals that are not assigned anywhere in the environment, are not part of the
+// standard library, and are not provided by the host application. Globals declared in definition files are assigned
+// there, so they are never reported.
+func (l *linter) undefinedGlobals() {
+	names := make([]string, 0, len(l.info.Globals))
+	for name := range l.info.Globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := l.env.HostGlobals[name]; ok {
+			continue
+		}
+		if isVersionGlobal(name, l.opts.LuaVersion) || l.env.Globals.IsDefined(name) {
+			continue
+		}
+		for _, ref := range l.info.Globals[name].Refs {
+			if !ref.Write {
+				l.report("undefined-global", ast.Range(ref.Ident), protocol.DiagnosticSeverityWarning, "Undefined global '%s'", name)
+			}
+		}
+	}
+}
+
+// IsLuaGlobal returns whether the name is a global of the standard library of any supported Lua version.
+func IsLuaGlobal(name string) bool {
+	return luaGlobals[name]
+}
+
+// isVersionGlobal returns whether the name is a global of the standard library of the given version of Lua, or of
+// any version if it is empty.
+func isVersionGlobal(name string, version string) bool {
+	if !luaGlobals[name] {
+		return false
+	}
+	versions, ok := versionGlobals[name]
+	return !ok || version == "" || slices.Contains(versions, strings.ToLower(version))
+}
+
+// versionGlobals maps the globals of the standard library that only some versions of Lua define to those versions.
+var versionGlobals = map[string][]string{
+	"_ENV":       {"5.2", "5.3", "5.4"},
+	"bit32":      {"5.2", "5.3"},
+	"getfenv":    {"5.1", "luajit"},
+	"loadstring": {"5.1", "luajit"},
+	"module":     {"5.1", "luajit"},
+	"rawlen":     {"5.2", "5.3", "5.4"},
+	"setfenv":    {"5.1", "luajit"},
+	"unpack":     {"5.1", "luajit"},
+	"utf8":       {"5.3", "5.4"},
+}
+
+// luaGlobals contains the globals defined by the standard libraries of every supported Lua version.
+var luaGlobals = map[string]bool{
+	"_ENV":           true,
+	"_G":             true,
+	"_VERSION":       true,
+	"arg":            true,
+	"assert":         true,
+	"bit32":          true,
+	"collectgarbage": true,
+	"coroutine":      true,
+	"debug":          true,
+	"dofile":         true,
+	"error":          true,
+	"getfenv":        true,
+	"getmetatable":   true,
+	"io":             true,
+	"ipairs":         true,
+	"load":           true,
+	"loadfile":       true,
+	"loadstring":     true,
+	"math":           true,
+	"module":         true,
+	"next":           true,
+	"os":             true,
+	"package":        true,
+	"pairs":          true,
+	"pcall":          true,
+	"print":          true,
+	"rawequal":       true,
+	"rawget":         true,
+	"rawlen":         true,
+	"rawset":         true,
+	"require":        true,
+	"select":         true,
+	"setfenv":        true,
+	"setmetatable":   true,
+	"string":         true,
+	"table":          true,
+	"tonumber":       true,
+	"tostring":       true,
+	"type":           true,
+	"unpack":         true,
+	"utf8":           true,
+	"xpcall":         true,
+}
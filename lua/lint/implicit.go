@@ -0,0 +1,20 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// implicitAny reports function parameters without a `---@param` annotation, since nothing is known about the values
+// that they receive. Parameters that start with `_` are exempt.
+func (l *linter) implicitAny() {
+	for _, sym := range l.info.Symbols {
+		if sym.Kind != types.SymbolParameter || sym.Decl == nil || sym.Annotated != nil || strings.HasPrefix(sym.Name, "_") {
+			continue
+		}
+		l.report("implicit-any", ast.Range(sym.Decl), protocol.DiagnosticSeverityWarning, "Parameter '%s' has no type", sym.Name)
+	}
+}
@@ -0,0 +1,80 @@
+// Package lint implements the diagnostics that report likely mistakes in code that is otherwise valid. Unlike type
+// checking, lint rules may depend on the whole environment, so they are computed when diagnostics are published.
+package lint
+
+import (
+	"fmt"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Options configures the lint rules.
+type Options struct {
+	// Mode selects which groups of rules are run.
+	Mode Mode
+	// IgnoreUnusedParameters disables the unused parameter rule, for projects that do not follow the convention of
+	// prefixing unused parameters with `_`.
+	IgnoreUnusedParameters bool
+	// LuaVersion is the version of Lua that the code runs on, such as `5.1` or `luajit`. Globals of the standard
+	// library that only other versions define are reported as undefined. Every version is accepted if it is empty.
+	LuaVersion string
+}
+
+type linter struct {
+	env         *types.Environment
+	file        *ast.File
+	info        *types.Info
+	opts        Options
+	diagnostics []ast.Diagnostic
+}
+
+// Check runs every lint rule on the given file. Definition files are not checked.
+func Check(env *types.Environment, file *ast.File, opts Options) []ast.Diagnostic {
+	info := env.Info[file.URI]
+	if info == nil || info.Meta != nil || file.Block == nil {
+		return nil
+	}
+	l := &linter{env: env, file: file, info: info, opts: opts, diagnostics: []ast.Diagnostic{}}
+	l.undefinedGlobals()
+	l.unusedLocals()
+	l.unusedParameters()
+	l.shadowing()
+	l.assignTypeMismatch()
+	l.callArguments()
+	if opts.Mode.Enables(GroupNilSafety) {
+		l.nilAccess()
+	}
+	l.unreachableCode()
+	l.duplicateKeys()
+	if opts.Mode.Enables(GroupUndefinedFields) {
+		l.undefinedFields()
+	}
+	if opts.Mode.Enables(GroupImplicitAny) {
+		l.implicitAny()
+	}
+	l.deprecatedUses()
+	l.deletedRequires()
+	return l.diagnostics
+}
+
+// report adds a diagnostic for the given rule and returns it so that tags and fixes can be attached.
+func (l *linter) report(code string, rng token.Range, severity protocol.DiagnosticSeverity, format string, args ...any) *ast.Diagnostic {
+	l.diagnostics = append(l.diagnostics, ast.Diagnostic{
+		Message:  fmt.Sprintf(format, args...),
+		Range:    rng,
+		Severity: severity,
+		Code:     code,
+	})
+	return &l.diagnostics[len(l.diagnostics)-1]
+}
+
+// related returns a related location for a diagnostic, or nil if the location is unknown.
+func related(loc types.Location, format string, args ...any) []ast.Related {
+	if loc.URI == "" {
+		return nil
+	}
+	return []ast.Related{{URI: loc.URI, Range: loc.Range, Message: fmt.Sprintf(format, args...)}}
+}
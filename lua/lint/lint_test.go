@@ -0,0 +1,581 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	"github.com/raiguard/luapls/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// checkFiles writes the given files to a temporary root, initializes an environment for them, and lints main.lua.
+func checkFiles(t *testing.T, files map[string]string, opts Options) []ast.Diagnostic {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	env := types.NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	uri, err := util.PathToURI(filepath.Join(root, "main.lua"))
+	require.NoError(t, err)
+	file := env.Files[uri]
+	require.NotNil(t, file)
+	return Check(env, file, opts)
+}
+
+// checkSource lints a single file.
+func checkSource(t *testing.T, src string) []ast.Diagnostic {
+	return checkFiles(t, map[string]string{"main.lua": src}, Options{})
+}
+
+// messages returns the messages of the diagnostics produced by the given rule.
+func messages(diagnostics []ast.Diagnostic, code string) []string {
+	messages := []string{}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code == code {
+			messages = append(messages, diagnostic.Message)
+		}
+	}
+	return messages
+}
+
+func TestUndefinedGlobals(t *testing.T) {
+	diagnostics := checkFiles(t, map[string]string{
+		"main.lua": `print(shared, missing)
+local x = missing
+defined_here = true
+print(defined_here, game)`,
+		"other.lua": `shared = 1`,
+		"meta.lua": `---@meta
+game = nil`,
+	}, Options{})
+	assert.Equal(t, []string{"Undefined global 'missing'", "Undefined global 'missing'"}, messages(diagnostics, "undefined-global"))
+}
+
+func TestUndefinedHostGlobals(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.lua"), []byte(`print(host, missing)`), 0644))
+	env := types.NewEnvironment()
+	env.RootPath = root
+	env.HostGlobals = map[string]string{"host": "table"}
+	env.Init()
+	uri, err := util.PathToURI(filepath.Join(root, "main.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Undefined global 'missing'"}, messages(Check(env, env.Files[uri], Options{}), "undefined-global"))
+}
+
+func TestUndefinedGlobalsOfVersion(t *testing.T) {
+	src := `print(unpack, setfenv, utf8, rawlen)`
+	assert.Empty(t, messages(checkFiles(t, map[string]string{"main.lua": src}, Options{}), "undefined-global"))
+	assert.Equal(t, []string{"Undefined global 'rawlen'", "Undefined global 'utf8'"},
+		messages(checkFiles(t, map[string]string{"main.lua": src}, Options{LuaVersion: "5.1"}), "undefined-global"))
+	assert.Equal(t, []string{"Undefined global 'setfenv'", "Undefined global 'unpack'"},
+		messages(checkFiles(t, map[string]string{"main.lua": src}, Options{LuaVersion: "5.4"}), "undefined-global"))
+}
+
+func TestUnusedLocals(t *testing.T) {
+	src := `local used, unused = 1, 2
+local _ignored = 3
+local assigned
+assigned = 4
+local function helper() end
+for i, v in pairs({}) do print(v) end
+print(used)
+local call = print("side effect")
+do local inner = 5 end
+local handle <close> = nil
+local last = 6`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{
+		"Unused local 'unused'",
+		"Unused local 'assigned'",
+		"Unused local 'helper'",
+		"Unused local 'i'",
+		"Unused local 'call'",
+		"Unused local 'inner'",
+		"Unused local 'handle'",
+		"Unused local 'last'",
+	}, messages(diagnostics, "unused-local"))
+
+	fixes := map[string]string{}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code == "unused-local" && len(diagnostic.Fixes) > 0 {
+			require.Len(t, diagnostic.Fixes[0].Edits, 1)
+			edit := diagnostic.Fixes[0].Edits[0]
+			fixes[diagnostic.Message] = src[edit.Range.Start:edit.Range.End]
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"Unused local 'helper'": "local function helper() end\n",
+		"Unused local 'inner'":  "local inner = 5",
+		"Unused local 'last'":   "local last = 6",
+	}, fixes)
+}
+
+func TestUnusedParameters(t *testing.T) {
+	src := `local function add(a, b, _c)
+	b = 1
+	return a
+end
+local function stub(x) end
+local t = { callback = function(event, data) print(data) end }
+print(add, stub, t)`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{"Unused parameter 'b'", "Unused parameter 'event'"}, messages(diagnostics, "unused-parameter"))
+	require.Len(t, diagnostics[0].Fixes, 1)
+	assert.Len(t, diagnostics[0].Fixes[0].Edits, 2)
+
+	diagnostics = checkFiles(t, map[string]string{"main.lua": src}, Options{IgnoreUnusedParameters: true})
+	assert.Empty(t, messages(diagnostics, "unused-parameter"))
+}
+
+func TestShadowing(t *testing.T) {
+	src := `local x = 1
+local function f(x)
+	local y = x
+	local x = y
+	for _, y in ipairs({}) do print(y) end
+	local _ = 1
+	local _ = 2
+end
+local x = x + 1
+print(f, x)`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{
+		"'x' shadows local 'x'",
+		"'x' shadows parameter 'x'",
+		"'y' shadows local 'y'",
+		"'x' shadows local 'x'",
+	}, messages(diagnostics, "shadowed-local"))
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code == "shadowed-local" {
+			require.Len(t, diagnostic.Related, 1)
+			assert.Less(t, diagnostic.Related[0].Range.Start, diagnostic.Range.Start)
+		}
+	}
+}
+
+func TestAssignTypeMismatch(t *testing.T) {
+	src := `---@class Point
+---@field x integer
+---@field label string|nil
+local Point = {}
+
+---@type Point
+local p = { x = 1 }
+p.x = "one"
+p.x = 2
+p.label = nil
+p.label = 3
+p.extra = true
+
+---@type string
+local name = 5
+name = "ok"
+local inferred = 1
+inferred = "fine"
+print(p, name, inferred)`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{
+		"Cannot assign 'string' to 'number'",
+		"Cannot assign 'number' to 'string|nil'",
+		"Cannot assign 'number' to 'string'",
+	}, messages(diagnostics, "assign-type-mismatch"))
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code != "assign-type-mismatch" {
+			continue
+		}
+		// Fields point at their `---@field` annotation, while locals are already declared by the reported statement.
+		if diagnostic.Message == "Cannot assign 'number' to 'string'" {
+			assert.Empty(t, diagnostic.Related)
+		} else {
+			require.Len(t, diagnostic.Related, 1)
+			assert.Less(t, diagnostic.Related[0].Range.Start, diagnostic.Range.Start)
+		}
+	}
+
+	assert.Empty(t, messages(ApplySeverities(diagnostics, map[string]protocol.DiagnosticSeverity{
+		"assign-type-mismatch": 0,
+	}), "assign-type-mismatch"))
+}
+
+func TestApplySeverities(t *testing.T) {
+	diagnostics := []ast.Diagnostic{
+		{Code: "undefined-global", Severity: protocol.DiagnosticSeverityWarning},
+		{Code: "unused-local", Severity: protocol.DiagnosticSeverityHint},
+		{Severity: protocol.DiagnosticSeverityError},
+	}
+	diagnostics = ApplySeverities(diagnostics, map[string]protocol.DiagnosticSeverity{
+		"undefined-global": protocol.DiagnosticSeverityError,
+		"unused-local":     0,
+		"":                 0,
+	})
+	require.Len(t, diagnostics, 2)
+	assert.Equal(t, protocol.DiagnosticSeverityError, diagnostics[0].Severity)
+	assert.Equal(t, "", diagnostics[1].Code)
+}
+
+func TestCallArguments(t *testing.T) {
+	src := `---@param x number
+---@param label? string
+local function f(x, label) return x, label end
+
+local function untyped(a, b) return a, b end
+
+local function varargs(first, ...) return first, ... end
+
+---@class Counter
+local Counter = {}
+
+---@param n number
+function Counter:add(n) return n end
+
+f(1)
+f(1, "one")
+f("one")
+f()
+f(1, "one", true)
+f(untyped())
+untyped()
+untyped(1, 2, 3)
+varargs(1, 2, 3)
+Counter:add(1)
+Counter:add()
+Counter.add(Counter, 1)`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{"Cannot pass 'string' to parameter 'x' of type 'number'"}, messages(diagnostics, "argument-type-mismatch"))
+	assert.Equal(t, []string{"Missing argument for parameter 'x'", "Missing argument for parameter 'n'"}, messages(diagnostics, "missing-argument"))
+	assert.Equal(t, []string{"Expected 2 arguments, but got 3", "Expected 2 arguments, but got 3"}, messages(diagnostics, "redundant-argument"))
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code == "argument-type-mismatch" || diagnostic.Code == "missing-argument" {
+			require.Len(t, diagnostic.Related, 1)
+			assert.Less(t, diagnostic.Related[0].Range.Start, diagnostic.Range.Start)
+		}
+		if diagnostic.Code == "redundant-argument" {
+			assert.Equal(t, []protocol.DiagnosticTag{protocol.DiagnosticTagUnnecessary}, diagnostic.Tags)
+		}
+	}
+}
+
+func TestEnumArguments(t *testing.T) {
+	src := `---@enum Direction
+local Direction = { north = 0, east = 1 }
+
+---@param direction Direction
+local function turn(direction) return direction end
+
+turn(Direction.east)
+turn(1)
+turn("north")`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{
+		"Cannot pass 'number' to parameter 'direction' of type 'Direction'",
+		"Cannot pass 'string' to parameter 'direction' of type 'Direction'",
+	}, messages(diagnostics, "argument-type-mismatch"))
+}
+
+func TestLiteralArguments(t *testing.T) {
+	src := `---@param side "left"|"right"
+local function align(side) return side end
+
+local LEFT = "left"
+align("left")
+align(LEFT)
+align("ri" .. "ght")
+align("up")
+
+---@type 1|2
+local n = 1 + 1
+n = 3`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{`Cannot pass 'string' to parameter 'side' of type '"left"|"right"'`}, messages(diagnostics, "argument-type-mismatch"))
+	assert.Equal(t, []string{`Cannot assign 'number' to '1|2'`}, messages(diagnostics, "assign-type-mismatch"))
+}
+
+func TestNilAccess(t *testing.T) {
+	src := `---@class Entity
+---@field name string
+---@field parent Entity|nil
+---@field on_click? fun()
+
+---@return Entity|nil
+local function find() end
+
+---@type Entity
+local entity = find() or {}
+print(entity.name, entity.parent.name)
+if entity.parent then
+	print(entity.parent.name)
+elseif entity.on_click then
+	entity.on_click()
+end
+print(entity.parent and entity.parent.name)
+entity.on_click()
+
+local found = find()
+print(found.name)
+if not found then
+	return
+end
+print(found.name)
+
+local reassigned = find()
+reassigned = reassigned or {}
+print(reassigned.name)
+
+---@param e Entity|nil
+local function describe(e)
+	assert(e)
+	return e.name
+end
+
+---@param e? Entity
+local function describe_or(e)
+	if e == nil then return "" end
+	while e.parent ~= nil do
+		e = e.parent
+	end
+	return e.name
+end
+
+---@param e? Entity
+local function describe_type(e)
+	if type(e) == "table" then
+		return e.name
+	end
+end
+
+local function noop() end
+local ok, err = pcall(noop)
+print(ok, err:upper())
+
+print(find().name, describe, describe_or, describe_type)`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{
+		"'entity.parent' may be nil",
+		"'entity.on_click' may be nil",
+		"'found' may be nil",
+		"'err' may be nil",
+		"Value may be nil",
+	}, messages(diagnostics, "nil-access"))
+}
+
+func TestUnreachableCode(t *testing.T) {
+	src := `for i = 1, 10 do
+	if i > 5 then
+		break
+		print("after break")
+	end
+	goto continue
+	print("skipped")
+	::continue::
+	print(i)
+end
+if false then
+	print("never")
+elseif nil then
+	print("never")
+end
+while false do print("never") end`
+	diagnostics := checkSource(t, src)
+	assert.Len(t, messages(diagnostics, "unreachable-code"), 5)
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	src := `local t = {
+	a = 1,
+	["a"] = 2,
+	"first",
+	[1] = "collides",
+	[2.0] = 3,
+	"second",
+	[key] = 4,
+	[key] = 5,
+}
+print(t)`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{
+		"Duplicate key 'a' in table",
+		"Duplicate key '[1]' in table",
+		"Duplicate key '[2]' in table",
+	}, messages(diagnostics, "duplicate-key"))
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code == "duplicate-key" {
+			require.Len(t, diagnostic.Related, 1)
+			assert.Less(t, diagnostic.Related[0].Range.Start, diagnostic.Range.Start)
+		}
+	}
+}
+
+func TestUndefinedFields(t *testing.T) {
+	src := `---@class Entity
+---@field position number
+local Entity = {}
+
+function Entity:move() return self end
+
+function Entity:speed() return self.position + self.velocity end
+
+---@class Bag
+local Bag = {}
+
+---@type Entity
+local entity = {position = 1}
+entity.cached = true
+print(entity.positon, entity.position, entity.cached, entity["missing"], Entity.move, entity:move())
+
+---@type Bag
+local bag = {}
+print(bag.anything, Bag)`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{
+		"Undefined field 'velocity' in 'Entity'",
+		"Undefined field 'positon' in 'Entity'",
+		"Undefined field 'missing' in 'Entity'",
+	}, messages(diagnostics, "undefined-field"))
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code == "undefined-field" {
+			require.Len(t, diagnostic.Related, 1)
+			assert.Equal(t, "Class 'Entity' is declared here", diagnostic.Related[0].Message)
+		}
+	}
+}
+
+func TestDeprecatedUses(t *testing.T) {
+	diagnostics := checkFiles(t, map[string]string{
+		"main.lua": `---@deprecated Use new_helper instead.
+local function old_helper() end
+
+local M = {}
+
+---@deprecated
+function M.old() end
+
+function M.new() end
+
+old_helper()
+M.old()
+M.new()
+legacy_global()
+Mod.old_api()`,
+		"other.lua": `---@deprecated
+function legacy_global() end
+
+Mod = {}
+
+---@deprecated Use Mod.api.
+function Mod.old_api() end`,
+	}, Options{})
+	assert.Equal(t, []string{
+		"'old_helper' is deprecated: Use new_helper instead.",
+		"'old' is deprecated",
+		"'legacy_global' is deprecated",
+		"'old_api' is deprecated: Use Mod.api.",
+	}, messages(diagnostics, "deprecated"))
+}
+
+func TestVarargArguments(t *testing.T) {
+	src := `---@param ... string
+local function join(...) return table.concat({...}) end
+
+---@param a number
+---@param b number
+local function add(a, b) return a + b end
+
+---@param ... string
+local function forward(...)
+	return add(...), join(...)
+end
+
+join("a", "b", 3)
+forward("a")`
+	diagnostics := checkSource(t, src)
+	assert.Equal(t, []string{
+		"Cannot pass 'string' to parameter 'a' of type 'number'",
+		"Cannot pass 'string' to parameter 'b' of type 'number'",
+		"Cannot pass 'number' to parameter '...' of type 'string'",
+	}, messages(diagnostics, "argument-type-mismatch"))
+}
+
+func TestSuppress(t *testing.T) {
+	src := `---@diagnostic disable-next-line: undefined-global
+print(a)
+print(b) ---@diagnostic disable-line
+---@diagnostic disable: undefined-global
+print(c)
+local unused = d
+---@diagnostic enable
+print(e)
+---@diagnostic disable
+print(f)
+---@diagnostic enable: undefined-global
+print(g)`
+	env := types.NewEnvironment()
+	file := env.AddTransientFile("file:///main.lua", src)
+	require.NotNil(t, file)
+	env.CheckFile(file)
+	diagnostics := Suppress(file, Check(env, file, Options{}))
+	assert.Equal(t, []string{"Undefined global 'e'", "Undefined global 'g'"}, messages(diagnostics, "undefined-global"))
+	assert.Equal(t, []string{"Unused local 'unused'"}, messages(diagnostics, "unused-local"))
+}
+
+func TestModes(t *testing.T) {
+	src := `---@class Entity
+---@field name string
+
+---@param entity Entity
+---@param label? string
+local function describe(entity, label, extra, _ignored)
+	print(entity.position, label:upper(), extra, _ignored)
+end
+
+describe({ name = "a" })`
+	codes := func(mode Mode) []string {
+		diagnostics := checkFiles(t, map[string]string{"main.lua": src}, Options{Mode: mode})
+		return append(append(messages(diagnostics, "nil-access"), messages(diagnostics, "undefined-field")...), messages(diagnostics, "implicit-any")...)
+	}
+	assert.Equal(t, []string{"'label' may be nil", "Undefined field 'position' in 'Entity'"}, codes(""))
+	assert.Equal(t, codes(""), codes(ModeDefault))
+	assert.Equal(t, []string{"'label' may be nil", "Undefined field 'position' in 'Entity'", "Parameter 'extra' has no type"}, codes(ModeStrict))
+	assert.Empty(t, codes(ModeOff))
+}
+
+func TestDeletedRequires(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.lua":     `local util = require("lib.util") local lib = require("lib") local missing = require("missing")`,
+		"lib/util.lua": `return {}`,
+		"lib/init.lua": `return {}`,
+		"lib.lua":      `return {}`,
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	env := types.NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	uriOf := func(name string) string {
+		uri, err := util.PathToURI(filepath.Join(root, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		return uri
+	}
+	main := uriOf("main.lua")
+	assert.Empty(t, messages(Check(env, env.Files[main], Options{}), "missing-module"))
+
+	// `lib` still resolves to `lib/init.lua` once `lib.lua` is deleted.
+	for _, name := range []string{"lib/util.lua", "lib.lua"} {
+		require.NoError(t, os.Remove(filepath.Join(root, filepath.FromSlash(name))))
+		assert.Contains(t, env.DeleteFile(uriOf(name)), main)
+	}
+	assert.Equal(t, []string{"Module 'lib.util' was deleted"}, messages(Check(env, env.Files[main], Options{}), "missing-module"))
+}
@@ -0,0 +1,34 @@
+package lint
+
+// Mode selects how strictly code is checked, so that projects can adopt checking gradually. The zero value is
+// ModeDefault.
+type Mode string
+
+const (
+	// ModeDefault runs every rule except those that require annotations throughout the project.
+	ModeDefault Mode = "default"
+	// ModeStrict runs every rule.
+	ModeStrict Mode = "strict"
+	// ModeOff disables the rules that rely on type information being complete, such as nil safety.
+	ModeOff Mode = "off"
+)
+
+// Group is a set of related rules that modes enable or disable together.
+type Group int
+
+const (
+	GroupNilSafety       Group = iota // Accessing values that may be nil.
+	GroupImplicitAny                  // Parameters without type annotations.
+	GroupUndefinedFields              // Accessing fields that classes do not declare.
+)
+
+// Enables returns whether the mode runs the rules in the group.
+func (m Mode) Enables(group Group) bool {
+	switch m {
+	case ModeStrict:
+		return true
+	case ModeOff:
+		return false
+	}
+	return group != GroupImplicitAny
+}
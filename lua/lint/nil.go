@@ -0,0 +1,280 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// guards is the set of variables and fields, keyed by their path such as `a.b`, that are known not to be nil.
+type guards map[string]bool
+
+// with returns a copy of the guards that also contains the given keys.
+func (g guards) with(keys []string) guards {
+	out := make(guards, len(g)+len(keys))
+	for key := range g {
+		out[key] = true
+	}
+	for _, key := range keys {
+		out[key] = true
+	}
+	return out
+}
+
+// nilAccess reports values that may be nil being indexed or called without first checking that they are not nil.
+// Only values whose type is known to include nil are reported: locals and fields that are annotated as optional, and
+// locals that are initialized from such values and never reassigned.
+func (l *linter) nilAccess() {
+	l.nilBlock(l.file.Block, guards{})
+}
+
+func (l *linter) nilBlock(block *ast.Block, g guards) {
+	g = g.with(nil)
+	for _, pair := range block.Pairs {
+		l.nilStmt(pair.Node, g)
+		// Later statements are guarded by the checks of earlier ones.
+		switch stmt := pair.Node.(type) {
+		case *ast.AssignmentStatement:
+			for i, target := range stmt.Vars.Pairs {
+				key := pathOf(target.Node)
+				if key == "" {
+					continue
+				}
+				delete(g, key)
+				if i < len(stmt.Exps.Pairs) && !mayBeNil(l.info.TypeOf(stmt.Exps.Pairs[i].Node)) {
+					g[key] = true
+				}
+			}
+		case *ast.FunctionCall:
+			// `assert(x)` errors if x is nil.
+			if ident, ok := stmt.Name.(*ast.Identifier); ok && ident.Token.Literal == "assert" && len(stmt.Args.Pairs) > 0 {
+				g = g.with(l.nonNilIf(stmt.Args.Pairs[0].Node, true))
+			}
+		case *ast.IfStatement:
+			// `if not x then return end` guarantees that x is not nil afterwards.
+			if len(stmt.Clauses) == 1 && types.Exits(&stmt.Clauses[0].Body) {
+				g = g.with(l.nonNilIf(stmt.Clauses[0].Condition, false))
+			}
+		case *ast.LocalStatement:
+			for _, name := range stmt.Names.Pairs {
+				delete(g, name.Node.Token.Literal)
+			}
+		}
+	}
+}
+
+func (l *linter) nilStmt(stmt ast.Statement, g guards) {
+	switch stmt := stmt.(type) {
+	case *ast.AssignmentStatement:
+		for _, pair := range stmt.Vars.Pairs {
+			// The target itself is written rather than read, but its prefix is read.
+			if ie, ok := pair.Node.(*ast.IndexExpression); ok {
+				l.nilExpr(ie.Prefix, g)
+				l.checkNil(ie.Prefix, g)
+			}
+		}
+		l.nilExprs(&stmt.Exps, g)
+	case *ast.DoStatement:
+		l.nilBlock(&stmt.Body, g)
+	case *ast.ForStatement:
+		l.nilExpr(stmt.Start.Node, g)
+		l.nilExpr(stmt.Finish.Node, g)
+		if stmt.Step != nil {
+			l.nilExpr(stmt.Step.Node, g)
+		}
+		l.nilBlock(&stmt.Body, g)
+	case *ast.ForInStatement:
+		l.nilExprs(&stmt.Exps, g)
+		l.nilBlock(&stmt.Body, g)
+	case *ast.FunctionCall:
+		l.nilExpr(stmt, g)
+	case *ast.FunctionStatement:
+		l.nilBlock(&stmt.Body, g)
+	case *ast.IfStatement:
+		// Each clause is only reached if the conditions before it were false.
+		otherwise := g
+		for _, clause := range stmt.Clauses {
+			if ast.IsNil(clause.Condition) {
+				l.nilBlock(&clause.Body, otherwise)
+				continue
+			}
+			l.nilExpr(clause.Condition, otherwise)
+			l.nilBlock(&clause.Body, otherwise.with(l.nonNilIf(clause.Condition, true)))
+			otherwise = otherwise.with(l.nonNilIf(clause.Condition, false))
+		}
+	case *ast.LocalStatement:
+		if stmt.Exps != nil {
+			l.nilExprs(stmt.Exps, g)
+		}
+	case *ast.RepeatStatement:
+		l.nilBlock(&stmt.Body, g)
+		l.nilExpr(stmt.Condition, g)
+	case *ast.ReturnStatement:
+		if stmt.Exps != nil {
+			l.nilExprs(stmt.Exps, g)
+		}
+	case *ast.WhileStatement:
+		l.nilExpr(stmt.Condition, g)
+		l.nilBlock(&stmt.Body, g.with(l.nonNilIf(stmt.Condition, true)))
+	}
+}
+
+func (l *linter) nilExprs(exps *ast.Punctuated[ast.Expression], g guards) {
+	for _, pair := range exps.Pairs {
+		l.nilExpr(pair.Node, g)
+	}
+}
+
+func (l *linter) nilExpr(expr ast.Expression, g guards) {
+	switch expr := expr.(type) {
+	case *ast.FunctionCall:
+		l.nilExpr(expr.Name, g)
+		l.checkNil(expr.Name, g)
+		l.nilExprs(&expr.Args, g)
+	case *ast.FunctionExpression:
+		l.nilBlock(&expr.Body, g)
+	case *ast.IndexExpression:
+		l.nilExpr(expr.Prefix, g)
+		l.checkNil(expr.Prefix, g)
+		if expr.LeftIndexer.Type() == token.LBRACK {
+			l.nilExpr(expr.Inner, g)
+		}
+	case *ast.InfixExpression:
+		l.nilExpr(expr.Left, g)
+		switch expr.Operator.Type() {
+		case token.AND:
+			l.nilExpr(expr.Right, g.with(l.nonNilIf(expr.Left, true)))
+		case token.OR:
+			l.nilExpr(expr.Right, g.with(l.nonNilIf(expr.Left, false)))
+		default:
+			l.nilExpr(expr.Right, g)
+		}
+	case *ast.PrefixExpression:
+		l.nilExpr(expr.Right, g)
+	case *ast.TableLiteral:
+		for _, pair := range expr.Fields.Pairs {
+			switch field := pair.Node.(type) {
+			case *ast.TableArrayField:
+				l.nilExpr(field.Expr, g)
+			case *ast.TableExpressionKeyField:
+				l.nilExpr(field.Name, g)
+				l.nilExpr(field.Expr, g)
+			case *ast.TableSimpleKeyField:
+				l.nilExpr(field.Expr, g)
+			}
+		}
+	}
+}
+
+// checkNil reports the expression if it may be nil and is not guarded.
+func (l *linter) checkNil(expr ast.Expression, g guards) {
+	if !l.exprMayBeNil(expr) {
+		return
+	}
+	path := pathOf(expr)
+	if path == "" {
+		l.report("nil-access", ast.Range(expr), protocol.DiagnosticSeverityWarning, "Value may be nil")
+		return
+	}
+	if !g[path] {
+		l.report("nil-access", ast.Range(expr), protocol.DiagnosticSeverityWarning, "'%s' may be nil", path)
+	}
+}
+
+func (l *linter) exprMayBeNil(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		sym := l.info.SymbolOf(expr)
+		if sym == nil {
+			return false
+		}
+		// Conditions such as `type(x) == "table"` narrow the type of the local where it is read.
+		if !mayBeNil(l.info.TypeOf(expr)) {
+			return false
+		}
+		if sym.Annotated != nil {
+			return mayBeNil(sym.Annotated)
+		}
+		// The type of a reassigned local is the combination of everything that is assigned to it, regardless of
+		// where it is read.
+		if sym.Kind != types.SymbolLocal || len(sym.Refs) > sym.Reads() {
+			return false
+		}
+		return mayBeNil(sym.Type)
+	case *ast.FunctionCall, *ast.IndexExpression:
+		return mayBeNil(l.info.TypeOf(expr))
+	}
+	return false
+}
+
+// mayBeNil returns whether the type is a union that includes nil. Values that are always nil are a different kind of
+// mistake.
+func mayBeNil(typ types.Type) bool {
+	union, ok := types.Resolve(typ).(*types.Union)
+	if !ok {
+		return false
+	}
+	for _, member := range union.Types {
+		if _, ok := types.Resolve(member).(*types.Nil); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// nonNilIf returns the paths that are known not to be nil when the condition evaluates to the given truthiness.
+func (l *linter) nonNilIf(cond ast.Expression, truthy bool) []string {
+	switch cond := cond.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+		if path := pathOf(cond); truthy && path != "" {
+			return []string{path}
+		}
+	case *ast.PrefixExpression:
+		if cond.Operator.Type() == token.NOT {
+			return l.nonNilIf(cond.Right, !truthy)
+		}
+	case *ast.InfixExpression:
+		switch cond.Operator.Type() {
+		case token.AND:
+			if truthy {
+				return append(l.nonNilIf(cond.Left, true), l.nonNilIf(cond.Right, true)...)
+			}
+		case token.OR:
+			if !truthy {
+				return append(l.nonNilIf(cond.Left, false), l.nonNilIf(cond.Right, false)...)
+			}
+		case token.NEQ, token.EQUAL:
+			// `x ~= nil` is true, or `x == nil` is false.
+			if truthy != (cond.Operator.Type() == token.NEQ) {
+				return nil
+			}
+			if _, ok := cond.Right.(*ast.NilLiteral); ok {
+				if path := pathOf(cond.Left); path != "" {
+					return []string{path}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// pathOf returns the dotted path of an identifier or field access, such as `a.b.c`, or an empty string if the
+// expression is not a path.
+func pathOf(expr ast.Expression) string {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		return expr.Token.Literal
+	case *ast.IndexExpression:
+		if expr.LeftIndexer.Type() == token.COLON {
+			return ""
+		}
+		key, ok := types.FieldKey(expr)
+		prefix := pathOf(expr.Prefix)
+		if !ok || prefix == "" {
+			return ""
+		}
+		return prefix + "." + key
+	}
+	return ""
+}
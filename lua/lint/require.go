@@ -0,0 +1,15 @@
+package lint
+
+import protocol "github.com/tliron/glsp/protocol_3_16"
+
+// deletedRequires reports requires of modules whose files were deleted, which will fail when they are run.
+func (l *linter) deletedRequires() {
+	for _, req := range l.env.Modules.Requires(l.file.URI) {
+		if req.Target != "" {
+			continue
+		}
+		if _, ok := l.env.DeletedModule(req.Name); ok {
+			l.report("missing-module", req.Range, protocol.DiagnosticSeverityWarning, "Module '%s' was deleted", req.Name)
+		}
+	}
+}
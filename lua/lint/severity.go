@@ -0,0 +1,25 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// ApplySeverities overrides the severity of the diagnostics with the given codes. Diagnostics whose severity is
+// overridden with zero are removed, which disables their rule.
+func ApplySeverities(diagnostics []ast.Diagnostic, severities map[string]protocol.DiagnosticSeverity) []ast.Diagnostic {
+	if len(severities) == 0 {
+		return diagnostics
+	}
+	result := make([]ast.Diagnostic, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		if severity, ok := severities[diagnostic.Code]; ok && diagnostic.Code != "" {
+			if severity == 0 {
+				continue
+			}
+			diagnostic.Severity = severity
+		}
+		result = append(result, diagnostic)
+	}
+	return result
+}
@@ -0,0 +1,36 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// shadowing reports locals that are declared while a local or parameter of the same name is visible.
+func (l *linter) shadowing() {
+	for _, sym := range l.info.Symbols {
+		if sym.Decl == nil || sym.Name == "_" || sym.Name == "self" {
+			continue
+		}
+		shadowed := shadowedSymbol(sym)
+		if shadowed == nil || shadowed.Decl == nil {
+			continue
+		}
+		d := l.report("shadowed-local", ast.Range(sym.Decl), protocol.DiagnosticSeverityWarning, "'%s' shadows %s '%s'", sym.Name, shadowed.Kind, sym.Name)
+		d.Related = []ast.Related{{Range: ast.Range(shadowed.Decl), Message: "Shadowed declaration of '" + sym.Name + "'"}}
+	}
+}
+
+// shadowedSymbol returns the symbol of the same name that was visible where the given symbol was declared.
+func shadowedSymbol(sym *types.Symbol) *types.Symbol {
+	pos := sym.Decl.Pos()
+	for scope := sym.Scope; scope != nil; scope = scope.Parent {
+		for i := len(scope.Symbols) - 1; i >= 0; i-- {
+			other := scope.Symbols[i]
+			if other != sym && other.Name == sym.Name && other.VisibleFrom <= pos {
+				return other
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package lint
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// unreachableCode reports statements that follow a `return`, `break`, or `goto` in the same block, and the bodies
+// of conditions that are always false.
+func (l *linter) unreachableCode() {
+	ast.WalkSemantic(l.file.Block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Punctuated[ast.Statement]:
+			l.unreachableStatements(n)
+		case *ast.IfClause:
+			if isFalsy(n.Condition) {
+				l.reportUnreachable(&n.Body)
+			}
+		case *ast.WhileStatement:
+			if isFalsy(n.Condition) {
+				l.reportUnreachable(&n.Body)
+			}
+		}
+		return true
+	})
+}
+
+func (l *linter) unreachableStatements(block *ast.Block) {
+	for i, pair := range block.Pairs {
+		switch pair.Node.(type) {
+		case *ast.BreakStatement, *ast.GotoStatement, *ast.ReturnStatement:
+		default:
+			continue
+		}
+		rest := block.Pairs[i+1:]
+		// Jumping to a label makes the statements after it reachable again.
+		for j, pair := range rest {
+			if _, ok := pair.Node.(*ast.LabelStatement); ok {
+				rest = rest[:j]
+				break
+			}
+		}
+		if len(rest) > 0 {
+			l.reportUnreachable(&ast.Block{Pairs: rest})
+		}
+	}
+}
+
+func (l *linter) reportUnreachable(block *ast.Block) {
+	if len(block.Pairs) == 0 {
+		return
+	}
+	rng := token.Range{Start: block.Pairs[0].Node.Pos(), End: block.Pairs[len(block.Pairs)-1].Node.End()}
+	d := l.report("unreachable-code", rng, protocol.DiagnosticSeverityHint, "Unreachable code")
+	d.Tags = []protocol.DiagnosticTag{protocol.DiagnosticTagUnnecessary}
+}
+
+// isFalsy returns whether the expression is a literal `false` or `nil`.
+func isFalsy(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.BooleanLiteral:
+		return expr.Token.Type == token.FALSE
+	case *ast.NilLiteral:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// unusedLocals reports locals, local functions, and loop variables that are never read. Names that start with `_`
+// are exempt, since they are conventionally unused.
+func (l *linter) unusedLocals() {
+	for _, sym := range l.info.Symbols {
+		if sym.Kind != types.SymbolLocal || sym.Decl == nil || strings.HasPrefix(sym.Name, "_") || sym.Reads() > 0 {
+			continue
+		}
+		d := l.report("unused-local", ast.Range(sym.Decl), protocol.DiagnosticSeverityHint, "Unused local '%s'", sym.Name)
+		d.Tags = []protocol.DiagnosticTag{protocol.DiagnosticTagUnnecessary}
+		if sym.Refs != nil {
+			// Removing the declaration would leave dangling assignments.
+			continue
+		}
+		if stmt, ok := sym.Node.(ast.Statement); ok && isRemovable(stmt) {
+			d.Fixes = append(d.Fixes, ast.Fix{
+				Title: "Remove unused local '" + sym.Name + "'",
+				Edits: []ast.Edit{l.removeStatement(stmt)},
+			})
+		}
+	}
+}
+
+// isRemovable returns whether the statement declares a single local whose removal does not change the behavior of
+// the program. A `<close>` local is never removable, since its value is closed when it goes out of scope.
+func isRemovable(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.FunctionStatement:
+		return true
+	case *ast.LocalStatement:
+		if len(stmt.Names.Pairs) != 1 {
+			return false
+		}
+		if attrib := stmt.Attrib(stmt.Names.Pairs[0].Node); attrib != nil && attrib.Name.Token.Literal == "close" {
+			return false
+		}
+		if stmt.Exps == nil {
+			return true
+		}
+		pure := true
+		ast.WalkSemantic(stmt, func(n ast.Node) bool {
+			if _, ok := n.(*ast.FunctionCall); ok {
+				pure = false
+			}
+			// Calls within function bodies do not run when the function is created.
+			_, isFunction := n.(*ast.FunctionExpression)
+			return pure && !isFunction
+		})
+		return pure
+	}
+	return false
+}
+
+// unusedParameters reports function parameters that are never read. Parameters that start with `_` are exempt, as
+// are the parameters of empty functions, which are usually stubs that must match a signature.
+func (l *linter) unusedParameters() {
+	if l.opts.IgnoreUnusedParameters {
+		return
+	}
+	for _, sym := range l.info.Symbols {
+		if sym.Kind != types.SymbolParameter || sym.Decl == nil || strings.HasPrefix(sym.Name, "_") || sym.Reads() > 0 {
+			continue
+		}
+		if isEmptyFunction(sym.Node) {
+			continue
+		}
+		d := l.report("unused-parameter", ast.Range(sym.Decl), protocol.DiagnosticSeverityHint, "Unused parameter '%s'", sym.Name)
+		d.Tags = []protocol.DiagnosticTag{protocol.DiagnosticTagUnnecessary}
+		edits := []ast.Edit{{Range: ast.Range(sym.Decl), NewText: "_" + sym.Name}}
+		for _, ref := range sym.Refs {
+			edits = append(edits, ast.Edit{Range: ast.Range(ref.Ident), NewText: "_" + sym.Name})
+		}
+		d.Fixes = append(d.Fixes, ast.Fix{Title: "Rename to '_" + sym.Name + "'", Edits: edits})
+	}
+}
+
+func isEmptyFunction(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.FunctionExpression:
+		return len(node.Body.Pairs) == 0
+	case *ast.FunctionStatement:
+		return len(node.Body.Pairs) == 0
+	}
+	return false
+}
@@ -18,7 +18,9 @@ import (
 )
 
 type Parser struct {
+	comments   []token.Token
 	errors     []ast.Diagnostic
+	input      string
 	lineBreaks []int
 	units      []ast.Unit
 	pos        int
@@ -27,7 +29,9 @@ type Parser struct {
 func New(input string) *Parser {
 	units, lineBreaks := Run(input)
 	p := &Parser{
+		comments:   collectComments(units),
 		errors:     []ast.Diagnostic{},
+		input:      input,
 		lineBreaks: lineBreaks,
 		units:      units,
 	}
@@ -79,6 +83,23 @@ func Run(input string) ([]ast.Unit, []int) {
 	return units, lineBreaks
 }
 
+func collectComments(units []ast.Unit) []token.Token {
+	comments := []token.Token{}
+	for _, unit := range units {
+		for _, tok := range unit.LeadingTrivia {
+			if tok.Type == token.COMMENT {
+				comments = append(comments, tok)
+			}
+		}
+		for _, tok := range unit.TrailingTrivia {
+			if tok.Type == token.COMMENT {
+				comments = append(comments, tok)
+			}
+		}
+	}
+	return comments
+}
+
 func (p *Parser) Errors() []ast.Diagnostic {
 	return p.errors
 }
@@ -86,8 +107,10 @@ func (p *Parser) Errors() []ast.Diagnostic {
 func (p *Parser) ParseFile() ast.File {
 	return ast.File{
 		Block:       util.Ptr(p.parseBlock()),
+		Comments:    p.comments,
 		Diagnostics: p.errors,
 		LineBreaks:  p.lineBreaks,
+		Text:        p.input,
 	}
 }
 
@@ -154,7 +177,9 @@ func (p *Parser) accept(tokenType token.TokenType) *ast.Unit {
 	if !p.tokIs(tokenType) {
 		return nil
 	}
-	return util.Ptr(p.next())
+	unit := util.Ptr(*p.unit())
+	p.next()
+	return unit
 }
 
 func (p *Parser) expect(tokenType token.TokenType) ast.Unit {
@@ -187,24 +212,38 @@ func (p *Parser) expect(tokenType token.TokenType) ast.Unit {
 					Message:  fmt.Sprintf("Extraneous %s", token.TokenStr[tok.Type]),
 					Range:    tok.Range(),
 					Severity: protocol.DiagnosticSeverityError,
+					Code:     "syntax-error",
 				})
 				unit.LeadingTrivia = append(unit.LeadingTrivia, tok)
 			}
 		} else {
+			// The missing token belongs directly after the previous one.
+			pos := 0
+			if initialPos > 0 {
+				pos = p.units[initialPos-1].Token.End()
+			}
 			fakeTok := ast.Unit{
 				LeadingTrivia: []token.Token{},
 				Token: token.Token{
 					Type:    tokenType,
 					Literal: "",
-					Pos:     initialPos,
+					Pos:     pos,
 				},
 				TrailingTrivia: []token.Token{},
 			}
-			p.errors = append(p.errors, ast.Diagnostic{
+			diagnostic := ast.Diagnostic{
 				Message:  fmt.Sprintf("Missing %s", token.TokenStr[tokenType]),
 				Range:    fakeTok.Range(),
 				Severity: protocol.DiagnosticSeverityError,
-			})
+				Code:     "syntax-error",
+			}
+			if text, ok := closingText[tokenType]; ok {
+				diagnostic.Fixes = []ast.Fix{{
+					Title: fmt.Sprintf("Insert '%s'", strings.TrimSpace(text)),
+					Edits: []ast.Edit{{Range: token.Range{Start: pos, End: pos}, NewText: text}},
+				}}
+			}
+			p.errors = append(p.errors, diagnostic)
 			p.next()
 			return fakeTok
 		}
@@ -213,6 +252,16 @@ func (p *Parser) expect(tokenType token.TokenType) ast.Unit {
 	return p.units[p.pos-1]
 }
 
+// closingText contains the text that is inserted to fix a missing token, for the tokens that close a construct.
+var closingText = map[token.TokenType]string{
+	token.DO:     " do",
+	token.END:    " end",
+	token.THEN:   " then",
+	token.RBRACE: "}",
+	token.RBRACK: "]",
+	token.RPAREN: ")",
+}
+
 func (p *Parser) expectedTokenError(expected token.TokenType) {
 	p.addError(
 		fmt.Sprintf("Expected %s, got %s",
@@ -230,11 +279,12 @@ func (p *Parser) addError(message string) {
 		Range:    p.unit().Token.Range(),
 		Message:  message,
 		Severity: protocol.DiagnosticSeverityError,
+		Code:     "syntax-error",
 	})
 }
 
 func (p *Parser) addErrorForNode(node ast.Node, message string) {
-	p.errors = append(p.errors, ast.Diagnostic{Range: ast.Range(node), Message: message, Severity: protocol.DiagnosticSeverityError})
+	p.errors = append(p.errors, ast.Diagnostic{Range: ast.Range(node), Message: message, Severity: protocol.DiagnosticSeverityError, Code: "syntax-error"})
 }
 
 func (p *Parser) tokIs(tokenType token.TokenType) bool {
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+
 	"github.com/raiguard/luapls/lua/ast"
 	"github.com/raiguard/luapls/lua/token"
 	"github.com/raiguard/luapls/util"
@@ -105,10 +107,14 @@ func (p *Parser) parseForStatement() ast.Statement {
 	if bareLoop {
 		var start, finish ast.Pair[ast.Expression]
 		if len(exps.Pairs) < 2 || len(exps.Pairs) > 3 {
-			p.addError("Expected 2 to 3 expressions")
+			p.addErrorForNode(&exps, "Expected 2 to 3 expressions")
 		}
 		start = exps.Pairs[0]
-		finish = exps.Pairs[1]
+		if len(exps.Pairs) > 1 {
+			finish = exps.Pairs[1]
+		} else {
+			finish = ast.Pair[ast.Expression]{Node: &ast.Invalid{Position: exps.End()}}
+		}
 		var step *ast.Pair[ast.Expression]
 		if len(exps.Pairs) > 2 {
 			step = &exps.Pairs[2]
@@ -222,10 +228,8 @@ func (p *Parser) parseLabelStatement() *ast.LabelStatement {
 }
 
 func (p *Parser) parseLocalStatement(localTok ast.Unit) *ast.LocalStatement {
-	ls := &ast.LocalStatement{
-		LocalTok: localTok,
-		Names:    p.parseNameList(),
-	}
+	ls := &ast.LocalStatement{LocalTok: localTok}
+	ls.Names, ls.Attribs = p.parseAttribNameList()
 	if assignTok := p.accept(token.ASSIGN); assignTok != nil {
 		ls.AssignTok = assignTok
 		ls.Exps = util.Ptr(p.parseExpressionList())
@@ -233,6 +237,43 @@ func (p *Parser) parseLocalStatement(localTok ast.Unit) *ast.LocalStatement {
 	return ls
 }
 
+// Identical to parseNameList, but each name may be followed by an attribute. The attributes are nil if no name has
+// one.
+func (p *Parser) parseAttribNameList() (ast.Punctuated[*ast.Identifier], []*ast.Attrib) {
+	list := ast.Punctuated[*ast.Identifier]{StartPos: p.unit().Pos()}
+	attribs := []*ast.Attrib{}
+	found := false
+
+	for {
+		if !p.tokIs(token.IDENT) {
+			break
+		}
+		pair := ast.Pair[*ast.Identifier]{Node: p.parseIdentifier()}
+		var attrib *ast.Attrib
+		if leftAngle := p.accept(token.LT); leftAngle != nil {
+			attrib = &ast.Attrib{LeftAngle: *leftAngle, Name: *p.parseIdentifier(), RightAngle: p.expect(token.GT)}
+			if name := attrib.Name.Token.Literal; name != "" && name != "const" && name != "close" {
+				p.addErrorForNode(&attrib.Name, fmt.Sprintf("Unknown attribute '%s'", name))
+			}
+			found = true
+		}
+		attribs = append(attribs, attrib)
+		if p.tokIs(token.COMMA) {
+			pair.Delimeter = p.unit()
+			p.next()
+		}
+		list.Pairs = append(list.Pairs, pair)
+		if pair.Delimeter == nil {
+			break
+		}
+	}
+
+	if !found {
+		return list, nil
+	}
+	return list, attribs
+}
+
 func (p *Parser) parseRepeatStatement() *ast.RepeatStatement {
 	repeatTok := p.expect(token.REPEAT)
 	body := p.parseBlock()
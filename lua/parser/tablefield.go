@@ -3,6 +3,7 @@ package parser
 import (
 	"github.com/raiguard/luapls/lua/ast"
 	"github.com/raiguard/luapls/lua/token"
+	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 func (p *Parser) parseTableFieldList() ast.Punctuated[ast.TableField] {
@@ -53,7 +54,27 @@ func (p *Parser) parseTableField() ast.TableField {
 
 	name, ok := expr.(*ast.Identifier)
 	if !ok {
-		panic("TODO: Table key was not an identifier!")
+		// A key such as `a.b` is kept as an expression key, with empty brackets where they are missing.
+		p.errors = append(p.errors, ast.Diagnostic{
+			Message:  "Missing brackets around expression key",
+			Range:    ast.Range(expr),
+			Severity: protocol.DiagnosticSeverityError,
+			Code:     "syntax-error",
+			Fixes: []ast.Fix{{
+				Title: "Insert brackets",
+				Edits: []ast.Edit{
+					{Range: token.Range{Start: expr.Pos(), End: expr.Pos()}, NewText: "["},
+					{Range: token.Range{Start: expr.End(), End: expr.End()}, NewText: "]"},
+				},
+			}},
+		})
+		return &ast.TableExpressionKeyField{
+			LeftBracket:  ast.Unit{Token: token.Token{Type: token.LBRACK, Pos: expr.Pos()}},
+			Name:         expr,
+			RightBracket: ast.Unit{Token: token.Token{Type: token.RBRACK, Pos: expr.End()}},
+			AssignTok:    assignTok,
+			Expr:         p.parseExpression(LOWEST, true),
+		}
 	}
 
 	expr = p.parseExpression(LOWEST, true)
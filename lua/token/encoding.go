@@ -0,0 +1,59 @@
+package token
+
+import "unicode/utf8"
+
+// PositionEncoding is the unit that the characters of protocol positions are counted in.
+type PositionEncoding string
+
+const (
+	PositionEncodingUTF8  PositionEncoding = "utf-8"
+	PositionEncodingUTF16 PositionEncoding = "utf-16"
+	PositionEncodingUTF32 PositionEncoding = "utf-32"
+)
+
+// DefaultEncoding is the position encoding of clients that do not negotiate one, which count characters in UTF-16 code
+// units.
+const DefaultEncoding = PositionEncodingUTF16
+
+// ColumnLength returns the length of the given text in the units of the position encoding.
+func ColumnLength(text string, encoding PositionEncoding) int {
+	switch encoding {
+	case PositionEncodingUTF8:
+		return len(text)
+	case PositionEncodingUTF32:
+		return utf8.RuneCountInString(text)
+	}
+	length := 0
+	for _, r := range text {
+		length += utf16Len(r)
+	}
+	return length
+}
+
+// ColumnOffset returns the byte offset in the given line of the column in the units of the position encoding. Columns
+// past the end of the line are clamped to it, and columns in the middle of a character are moved to its start.
+func ColumnOffset(line string, column int, encoding PositionEncoding) int {
+	if encoding == PositionEncodingUTF8 {
+		return min(column, len(line))
+	}
+	units := 0
+	for offset, r := range line {
+		width := 1
+		if encoding == PositionEncodingUTF16 {
+			width = utf16Len(r)
+		}
+		if units+width > column {
+			return offset
+		}
+		units += width
+	}
+	return len(line)
+}
+
+// utf16Len returns the number of UTF-16 code units that encode the given character.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
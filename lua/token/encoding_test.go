@@ -0,0 +1,31 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumns(t *testing.T) {
+	line := "local s = \"héllo 🌙\" -- x"
+	moon := len("local s = \"héllo ")
+	for _, test := range []struct {
+		encoding PositionEncoding
+		length   int
+		column   int
+	}{
+		{PositionEncodingUTF8, len(line), moon},
+		{PositionEncodingUTF16, 25, 17},
+		{PositionEncodingUTF32, 24, 17},
+	} {
+		t.Run(string(test.encoding), func(t *testing.T) {
+			require.Equal(t, test.length, ColumnLength(line, test.encoding))
+			require.Equal(t, moon, ColumnOffset(line, test.column, test.encoding))
+			require.Equal(t, test.column, ColumnLength(line[:moon], test.encoding))
+			require.Equal(t, len(line), ColumnOffset(line, 100, test.encoding))
+		})
+	}
+	// A column in the middle of a surrogate pair is moved to the start of the character.
+	require.Equal(t, moon, ColumnOffset(line, 18, PositionEncodingUTF16))
+	require.Equal(t, moon+len("🌙"), ColumnOffset(line, 19, PositionEncodingUTF16))
+}
@@ -1,6 +1,10 @@
 package token
 
-import protocol "github.com/tliron/glsp/protocol_3_16"
+import (
+	"sort"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
 
 type LineBreaks []int
 
@@ -21,24 +25,25 @@ func (f LineBreaks) ToPos(position protocol.Position) Pos {
 	return lineStart + col
 }
 
-func (f LineBreaks) ToProtocolPos(pos Pos) protocol.Position {
-	if len(f) == 0 {
-		return protocol.Position{
-			Line:      0,
-			Character: uint32(pos),
-		}
+// Line returns the zero-based line that the given position is on. A line break belongs to the line that it ends.
+func (f LineBreaks) Line(pos Pos) int {
+	return sort.SearchInts(f, pos)
+}
+
+// LineStart returns the position of the first character of the given line.
+func (f LineBreaks) LineStart(line int) Pos {
+	if line <= 0 || len(f) == 0 {
+		return 0
 	}
-	line := 0
-	lineStart := 0
-	lineEnd := -1
-	for line < len(f) {
-		lineStart = lineEnd + 1
-		lineEnd = f[line]
-		if lineStart <= pos && lineEnd >= pos {
-			break
-		}
-		line++
+	if line > len(f) {
+		line = len(f)
 	}
+	return f[line-1] + 1
+}
+
+func (f LineBreaks) ToProtocolPos(pos Pos) protocol.Position {
+	line := f.Line(pos)
+	lineStart := f.LineStart(line)
 	return protocol.Position{
 		Line:      uint32(line),
 		Character: uint32(pos - lineStart),
@@ -80,7 +80,20 @@ const (
 	VARARG
 
 	// Annotation
+	DOC_ALIAS
 	DOC_CLASS
+	DOC_DEPRECATED
+	DOC_DIAGNOSTIC
+	DOC_ENUM
+	DOC_FIELD
+	DOC_GENERIC
+	DOC_META
+	DOC_OVERLOAD
+	DOC_PARAM
+	DOC_RETURN
+	DOC_TYPE
+	PIPE
+	QUESTION
 )
 
 func (t TokenType) String() string {
@@ -200,7 +213,20 @@ var TokenStr = map[TokenType]string{
 	VARARG:    "vararg",
 
 	// Annotation
-	DOC_CLASS: "@class",
+	DOC_ALIAS:      "@alias",
+	DOC_CLASS:      "@class",
+	DOC_DEPRECATED: "@deprecated",
+	DOC_DIAGNOSTIC: "@diagnostic",
+	DOC_ENUM:       "@enum",
+	DOC_FIELD:      "@field",
+	DOC_GENERIC:    "@generic",
+	DOC_META:       "@meta",
+	DOC_OVERLOAD:   "@overload",
+	DOC_PARAM:      "@param",
+	DOC_RETURN:     "@return",
+	DOC_TYPE:       "@type",
+	PIPE:           "|",
+	QUESTION:       "?",
 }
 
 var Reserved = map[string]TokenType{
@@ -227,5 +253,16 @@ var Reserved = map[string]TokenType{
 	"until":    UNTIL,
 	"while":    WHILE,
 
-	"@class": DOC_CLASS,
+	"@alias":      DOC_ALIAS,
+	"@class":      DOC_CLASS,
+	"@deprecated": DOC_DEPRECATED,
+	"@diagnostic": DOC_DIAGNOSTIC,
+	"@enum":       DOC_ENUM,
+	"@field":      DOC_FIELD,
+	"@generic":    DOC_GENERIC,
+	"@meta":       DOC_META,
+	"@overload":   DOC_OVERLOAD,
+	"@param":      DOC_PARAM,
+	"@return":     DOC_RETURN,
+	"@type":       DOC_TYPE,
 }
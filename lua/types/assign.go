@@ -0,0 +1,129 @@
+package types
+
+// Assignable returns whether a value of type from may be stored in a location of type to. Unknown types are
+// compatible with everything, since there is not enough information to report a problem.
+func Assignable(from, to Type) bool {
+	from, to = Resolve(from), Resolve(to)
+	switch to := to.(type) {
+	case nil, *Any, *TypeParam, *Unknown:
+		return true
+	case *Union:
+		if from, ok := from.(*Union); ok {
+			for _, member := range from.Types {
+				if !Assignable(member, to) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, member := range to.Types {
+			if Assignable(from, member) {
+				return true
+			}
+		}
+		return false
+	}
+	switch from := from.(type) {
+	case nil, *Any, *TypeParam, *Unknown:
+		return true
+	case *Enum:
+		if to, ok := to.(*Enum); ok {
+			return from.Name == to.Name
+		}
+		// Values of an enum may be used wherever all of its values may.
+		for _, member := range from.Members {
+			if !Assignable(member.Type, to) {
+				return false
+			}
+		}
+		return true
+	case *Literal:
+		if to, ok := to.(*Literal); ok {
+			return sameLiteral(from, to)
+		}
+		return Assignable(from.Base, to)
+	case *Union:
+		for _, member := range from.Types {
+			if !Assignable(member, to) {
+				return false
+			}
+		}
+		return true
+	}
+	switch to.(type) {
+	case *Named, *Table:
+		// Classes are tables, and table literals are commonly used to construct classes.
+		switch from := from.(type) {
+		case *Named:
+			to, ok := to.(*Named)
+			return !ok || from.Inherits(to)
+		case *Table:
+			return true
+		}
+		return false
+	case *Enum, *Literal:
+		// Only the members of an enum are values of it, even if another value is equal to one of them, and only the
+		// literal itself is a value of a literal type.
+		return false
+	}
+	return Identical(from, to) || sameKind(from, to)
+}
+
+// sameKind returns whether two types are the same kind of type, regardless of their details.
+func sameKind(a, b Type) bool {
+	switch a.(type) {
+	case *Boolean:
+		_, ok := b.(*Boolean)
+		return ok
+	case *Function:
+		_, ok := b.(*Function)
+		return ok
+	case *Nil:
+		_, ok := b.(*Nil)
+		return ok
+	case *Number:
+		_, ok := b.(*Number)
+		return ok
+	case *String:
+		_, ok := b.(*String)
+		return ok
+	}
+	return false
+}
+
+// SelectOverload returns the first of the function's signatures that accepts arguments of the given types, or the
+// function itself if none of them do.
+func SelectOverload(fn *Function, args []Type) *Function {
+	if len(fn.Overloads) == 0 {
+		return fn
+	}
+	candidates := append([]*Function{fn}, fn.Overloads...)
+	for _, candidate := range candidates {
+		if Accepts(candidate, args) {
+			return candidate
+		}
+	}
+	return fn
+}
+
+// Accepts returns whether the function can be called with arguments of the given types.
+func Accepts(fn *Function, args []Type) bool {
+	for i, arg := range args {
+		if i >= len(fn.Params) {
+			return false
+		}
+		param := fn.Params[i]
+		if param.Name == "..." {
+			return true
+		}
+		if !Assignable(arg, param.Type) {
+			return false
+		}
+	}
+	for _, param := range fn.Params[len(args):] {
+		if param.Name != "..." && !Assignable(&Nil{}, param.Type) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignable(t *testing.T) {
+	left := &Literal{Value: `"left"`, Base: &String{}}
+	right := &Literal{Value: `"right"`, Base: &String{}}
+	class := &Named{Name: "Foo"}
+	tests := []struct {
+		from, to Type
+		expected bool
+	}{
+		{&Number{}, &Number{}, true},
+		{&Number{}, &String{}, false},
+		{&Unknown{}, &String{}, true},
+		{&String{}, &Any{}, true},
+		{&Nil{}, NewUnion(&String{}, &Nil{}), true},
+		{NewUnion(&String{}, &Nil{}), &String{}, false},
+		{left, &String{}, true},
+		{left, NewUnion(left, right), true},
+		{&String{}, NewUnion(left, right), false},
+		{&Literal{Value: `'left'`, Base: &String{}}, NewUnion(left, right), true},
+		{&Literal{Value: "0x10", Base: &Number{}}, &Literal{Value: "16", Base: &Number{}}, true},
+		{&Table{}, class, true},
+		{class, &Named{Name: "Bar"}, false},
+		{class, &Table{}, true},
+		{&Alias{Name: "Side", Type: NewUnion(left, right)}, &String{}, true},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Assignable(test.from, test.to), "%s -> %s", test.from, test.to)
+	}
+}
@@ -0,0 +1,238 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// binder resolves every identifier in a file to the local, parameter, or global symbol that it refers to.
+type binder struct {
+	info  *Info
+	scope *Scope
+}
+
+// Bind builds the scope tree for the given file and records all declarations and references in info.
+func Bind(file *ast.File, info *Info) {
+	b := binder{info: info, scope: newScope(nil, nil)}
+	info.Scope = b.scope
+	if file.Block != nil {
+		info.Scopes[file.Block] = b.scope
+		b.block(file.Block)
+	}
+	b.scope.names = nil
+}
+
+func (b *binder) openScope(node ast.Node) {
+	b.scope = newScope(b.scope, node)
+	b.info.Scopes[node] = b.scope
+}
+
+func (b *binder) closeScope() {
+	b.scope.names = nil
+	b.scope = b.scope.Parent
+}
+
+func (b *binder) declare(ident *ast.Identifier, kind SymbolKind, node ast.Node, visibleFrom token.Pos) *Symbol {
+	if ident == nil {
+		return nil
+	}
+	sym := &Symbol{
+		Name:        ident.Token.Literal,
+		Kind:        kind,
+		Decl:        ident,
+		Node:        node,
+		Scope:       b.scope,
+		VisibleFrom: visibleFrom,
+	}
+	b.scope.Symbols = append(b.scope.Symbols, sym)
+	b.scope.names[sym.Name] = sym
+	b.info.Symbols = append(b.info.Symbols, sym)
+	b.info.Defs[ident] = sym
+	return sym
+}
+
+func (b *binder) lookup(name string) *Symbol {
+	for scope := b.scope; scope != nil; scope = scope.Parent {
+		if sym := scope.names[name]; sym != nil {
+			return sym
+		}
+	}
+	return nil
+}
+
+func (b *binder) use(ident *ast.Identifier, write bool) {
+	if ident == nil {
+		return
+	}
+	name := ident.Token.Literal
+	sym := b.lookup(name)
+	if sym == nil {
+		sym = b.info.Globals[name]
+		if sym == nil {
+			sym = &Symbol{Name: name, Kind: SymbolGlobal}
+			b.info.Globals[name] = sym
+		}
+	}
+	sym.Refs = append(sym.Refs, Reference{Ident: ident, Write: write})
+	b.info.Uses[ident] = sym
+}
+
+func (b *binder) block(block *ast.Block) {
+	for i := range block.Pairs {
+		b.stmt(block.Pairs[i].Node)
+	}
+}
+
+func (b *binder) stmt(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.AssignmentStatement:
+		b.exprs(&stmt.Exps)
+		for _, pair := range stmt.Vars.Pairs {
+			b.target(pair.Node)
+		}
+	case *ast.DoStatement:
+		b.openScope(stmt)
+		b.block(&stmt.Body)
+		b.closeScope()
+	case *ast.ForStatement:
+		b.expr(stmt.Start.Node)
+		b.expr(stmt.Finish.Node)
+		if stmt.Step != nil {
+			b.expr(stmt.Step.Node)
+		}
+		b.openScope(stmt)
+		b.declare(stmt.Name, SymbolLocal, stmt, stmt.DoTok.End())
+		b.block(&stmt.Body)
+		b.closeScope()
+	case *ast.ForInStatement:
+		b.exprs(&stmt.Exps)
+		b.openScope(stmt)
+		for _, pair := range stmt.Names.Pairs {
+			b.declare(pair.Node, SymbolLocal, stmt, stmt.DoTok.End())
+		}
+		b.block(&stmt.Body)
+		b.closeScope()
+	case *ast.FunctionCall:
+		b.expr(stmt)
+	case *ast.FunctionStatement:
+		if ident, ok := stmt.Name.(*ast.Identifier); ok && stmt.LocalTok != nil {
+			// Local functions are visible inside of their own body to allow recursion.
+			b.declare(ident, SymbolLocal, stmt, ident.Pos())
+		} else {
+			b.target(stmt.Name)
+		}
+		b.function(stmt, &stmt.Params, &stmt.Body, IsMethod(stmt))
+	case *ast.IfStatement:
+		for _, clause := range stmt.Clauses {
+			b.expr(clause.Condition)
+			b.openScope(clause)
+			b.block(&clause.Body)
+			b.closeScope()
+		}
+	case *ast.LocalStatement:
+		if stmt.Exps != nil {
+			b.exprs(stmt.Exps)
+		}
+		for _, pair := range stmt.Names.Pairs {
+			b.declare(pair.Node, SymbolLocal, stmt, stmt.End())
+		}
+	case *ast.RepeatStatement:
+		// The condition of a repeat loop can see locals declared in its body.
+		b.openScope(stmt)
+		b.block(&stmt.Body)
+		b.expr(stmt.Condition)
+		b.closeScope()
+	case *ast.ReturnStatement:
+		if stmt.Exps != nil {
+			b.exprs(stmt.Exps)
+		}
+	case *ast.WhileStatement:
+		b.expr(stmt.Condition)
+		b.openScope(stmt)
+		b.block(&stmt.Body)
+		b.closeScope()
+	}
+}
+
+// target binds the left-hand side of an assignment.
+func (b *binder) target(expr ast.Expression) {
+	if ident, ok := expr.(*ast.Identifier); ok {
+		b.use(ident, true)
+		return
+	}
+	b.expr(expr)
+}
+
+func (b *binder) function(node ast.Node, params *ast.Punctuated[*ast.Identifier], body *ast.Block, method bool) {
+	b.openScope(node)
+	visibleFrom := params.Pos()
+	if method {
+		sym := &Symbol{Name: "self", Kind: SymbolParameter, Node: node, Scope: b.scope, VisibleFrom: visibleFrom}
+		b.scope.Symbols = append(b.scope.Symbols, sym)
+		b.scope.names[sym.Name] = sym
+		b.info.Symbols = append(b.info.Symbols, sym)
+	}
+	for _, pair := range params.Pairs {
+		b.declare(pair.Node, SymbolParameter, node, visibleFrom)
+	}
+	b.block(body)
+	b.closeScope()
+}
+
+func (b *binder) exprs(exps *ast.Punctuated[ast.Expression]) {
+	for _, pair := range exps.Pairs {
+		b.expr(pair.Node)
+	}
+}
+
+func (b *binder) expr(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.FunctionCall:
+		b.expr(expr.Name)
+		b.exprs(&expr.Args)
+	case *ast.FunctionExpression:
+		b.function(expr, &expr.Params, &expr.Body, false)
+	case *ast.Identifier:
+		b.use(expr, false)
+	case *ast.IndexExpression:
+		b.expr(expr.Prefix)
+		// Dot and colon indexes name a field, not a variable.
+		if expr.LeftIndexer.Type() == token.LBRACK {
+			b.expr(expr.Inner)
+		}
+	case *ast.InfixExpression:
+		b.expr(expr.Left)
+		b.expr(expr.Right)
+	case *ast.PrefixExpression:
+		b.expr(expr.Right)
+	case *ast.TableLiteral:
+		for _, pair := range expr.Fields.Pairs {
+			switch field := pair.Node.(type) {
+			case *ast.TableArrayField:
+				b.expr(field.Expr)
+			case *ast.TableExpressionKeyField:
+				b.expr(field.Name)
+				b.expr(field.Expr)
+			case *ast.TableSimpleKeyField:
+				b.expr(field.Expr)
+			}
+		}
+	}
+}
+
+// IsMethod returns whether the given function statement is declared with colon syntax, giving it an implicit `self`
+// parameter.
+func IsMethod(fs *ast.FunctionStatement) bool {
+	ie, ok := fs.Name.(*ast.IndexExpression)
+	return ok && ie.LeftIndexer.Type() == token.COLON
+}
+
+// Receiver returns the type of the value that a method call such as `obj:method()` passes as the `self` parameter of
+// the function, or nil if the call does not use colon syntax or the function does not take `self`.
+func Receiver(fc *ast.FunctionCall, fn *Function, info *Info) Type {
+	ie, ok := fc.Name.(*ast.IndexExpression)
+	if !ok || ie.LeftIndexer.Type() != token.COLON || len(fn.Params) == 0 || fn.Params[0].Name != "self" {
+		return nil
+	}
+	return info.TypeOf(ie.Prefix)
+}
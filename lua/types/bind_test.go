@@ -0,0 +1,96 @@
+package types
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bindSource(t *testing.T, src string) (*ast.File, *Info) {
+	file := parser.New(src).ParseFile()
+	require.Empty(t, file.Diagnostics)
+	info := NewInfo()
+	Bind(&file, info)
+	return &file, info
+}
+
+// identAt returns the identifier at the nth whole-word occurrence of name in src.
+func identAt(t *testing.T, file *ast.File, src string, name string, n int) *ast.Identifier {
+	matches := regexp.MustCompile(`\b`+name+`\b`).FindAllStringIndex(src, -1)
+	require.Greater(t, len(matches), n, "could not find occurrence %d of %q", n, name)
+	pos := matches[n][0]
+	ident, ok := ast.GetSemanticNode(file.Block, pos).Node.(*ast.Identifier)
+	require.True(t, ok, "node at %d is not an identifier", pos)
+	return ident
+}
+
+func TestBindLocalShadowing(t *testing.T) {
+	src := `local x = 1
+local x = x + 1
+print(x)`
+	file, info := bindSource(t, src)
+	first := info.SymbolOf(identAt(t, file, src, "x", 0))
+	second := info.SymbolOf(identAt(t, file, src, "x", 1))
+	require.NotNil(t, first)
+	require.NotNil(t, second)
+	assert.NotSame(t, first, second)
+	assert.Same(t, first, info.SymbolOf(identAt(t, file, src, "x", 2)))
+	assert.Same(t, second, info.SymbolOf(identAt(t, file, src, "x", 3)))
+	assert.Equal(t, SymbolGlobal, info.SymbolOf(identAt(t, file, src, "print", 0)).Kind)
+}
+
+func TestBindScopes(t *testing.T) {
+	src := `local function f(a)
+  for i = 1, a do
+    local inner = i
+  end
+  return inner
+end
+f(f)`
+	file, info := bindSource(t, src)
+	f := info.SymbolOf(identAt(t, file, src, "f", 0))
+	require.NotNil(t, f)
+	assert.Len(t, f.Refs, 2)
+	assert.Equal(t, SymbolParameter, info.SymbolOf(identAt(t, file, src, "a", 1)).Kind)
+	assert.Equal(t, SymbolLocal, info.SymbolOf(identAt(t, file, src, "i", 1)).Kind)
+	assert.Equal(t, SymbolGlobal, info.SymbolOf(identAt(t, file, src, "inner", 1)).Kind)
+}
+
+func TestBindFieldsAreNotVariables(t *testing.T) {
+	src := `local t = { x = 1 }
+t.x = t.y
+t:z()`
+	_, info := bindSource(t, src)
+	assert.Empty(t, info.Globals)
+	require.Len(t, info.Symbols, 1)
+	assert.Len(t, info.Symbols[0].Refs, 3)
+	assert.Equal(t, 3, info.Symbols[0].Reads())
+}
+
+func TestBindMethodSelf(t *testing.T) {
+	src := `function Foo:bar() return self end`
+	file, info := bindSource(t, src)
+	self := info.SymbolOf(identAt(t, file, src, "self", 0))
+	require.NotNil(t, self)
+	assert.Equal(t, SymbolParameter, self.Kind)
+	assert.Nil(t, self.Decl)
+}
+
+func TestScopeLookupAt(t *testing.T) {
+	src := `local a = 1
+do
+  local b = 2
+end`
+	_, info := bindSource(t, src)
+	inside := strings.Index(src, "end")
+	assert.NotNil(t, info.Scope.LookupAt("b", inside))
+	assert.NotNil(t, info.Scope.LookupAt("a", inside))
+	assert.Nil(t, info.Scope.LookupAt("b", len(src)))
+	assert.Len(t, info.Scope.VisibleAt(inside), 2)
+}
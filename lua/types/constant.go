@@ -0,0 +1,244 @@
+package types
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// Constant evaluates an expression that only consists of literals, arithmetic, concatenation, and locals that are
+// initialized with such expressions and never reassigned, and returns its value as a literal type. It returns nil if
+// the expression is not constant. Locals are only followed if info is not nil.
+func Constant(expr ast.Expression, info *Info) *Literal {
+	switch expr := expr.(type) {
+	case *ast.BooleanLiteral:
+		return &Literal{Value: expr.Token.Literal, Base: &Boolean{}}
+	case *ast.Identifier:
+		if info == nil {
+			return nil
+		}
+		sym := info.SymbolOf(expr)
+		if sym == nil || sym.Kind != SymbolLocal || len(sym.Refs) > sym.Reads() {
+			return nil
+		}
+		if init, ok := sym.Initializer().(ast.Expression); ok {
+			return Constant(init, info)
+		}
+	case *ast.InfixExpression:
+		left, right := Constant(expr.Left, info), Constant(expr.Right, info)
+		if left == nil || right == nil {
+			return nil
+		}
+		if expr.Operator.Type() == token.CONCAT {
+			l, lok := concatString(left)
+			r, rok := concatString(right)
+			if !lok || !rok {
+				return nil
+			}
+			return stringLiteral(l + r)
+		}
+		l, lok := literalNumber(left)
+		r, rok := literalNumber(right)
+		if !lok || !rok {
+			return nil
+		}
+		switch expr.Operator.Type() {
+		case token.PLUS:
+			return numberLiteral(l + r)
+		case token.MINUS:
+			return numberLiteral(l - r)
+		case token.MUL:
+			return numberLiteral(l * r)
+		case token.SLASH:
+			if r != 0 {
+				return numberLiteral(l / r)
+			}
+		case token.MOD:
+			if r != 0 {
+				return numberLiteral(l - math.Floor(l/r)*r)
+			}
+		case token.POW:
+			return numberLiteral(math.Pow(l, r))
+		}
+	case *ast.NumberLiteral:
+		if _, ok := parseNumber(expr.Token.Literal); ok {
+			return &Literal{Value: expr.Token.Literal, Base: &Number{}}
+		}
+	case *ast.PrefixExpression:
+		if expr.Operator.Type() != token.MINUS {
+			return nil
+		}
+		if n, ok := literalNumber(Constant(expr.Right, info)); ok {
+			return numberLiteral(-n)
+		}
+	case *ast.StringLiteral:
+		if value, ok := StringValue(expr); ok {
+			return stringLiteral(value)
+		}
+	}
+	return nil
+}
+
+// ConstantString returns the value of an expression that evaluates to a constant string.
+func ConstantString(expr ast.Expression, info *Info) (string, bool) {
+	lit := Constant(expr, info)
+	if lit == nil {
+		return "", false
+	}
+	if _, ok := lit.Base.(*String); !ok {
+		return "", false
+	}
+	return literalString(lit), true
+}
+
+// LiteralTypeOf returns the value of the expression as a literal type if it is constant, and its inferred type
+// otherwise. It is used to check values against literal types, such as a parameter of type `"left"|"right"`.
+func (i *Info) LiteralTypeOf(expr ast.Expression) Type {
+	if lit := Constant(expr, i); lit != nil {
+		return lit
+	}
+	return i.TypeOf(expr)
+}
+
+// sameLiteral returns whether two literal types admit the same value, regardless of how they were written.
+func sameLiteral(a, b *Literal) bool {
+	if !sameKind(a.Base, b.Base) {
+		return false
+	}
+	switch a.Base.(type) {
+	case *Number:
+		an, aok := literalNumber(a)
+		bn, bok := literalNumber(b)
+		return aok && bok && an == bn
+	case *String:
+		return literalString(a) == literalString(b)
+	}
+	return a.Value == b.Value
+}
+
+// concatString returns the text that a string or number literal contributes to a concatenation.
+func concatString(lit *Literal) (string, bool) {
+	switch lit.Base.(type) {
+	case *Number:
+		n, ok := literalNumber(lit)
+		return formatNumber(n), ok
+	case *String:
+		return literalString(lit), true
+	}
+	return "", false
+}
+
+// literalNumber returns the value of a number literal type.
+func literalNumber(lit *Literal) (float64, bool) {
+	if lit == nil {
+		return 0, false
+	}
+	if _, ok := lit.Base.(*Number); !ok {
+		return 0, false
+	}
+	return parseNumber(lit.Value)
+}
+
+// parseNumber parses a Lua number literal, including hexadecimal integers.
+func parseNumber(literal string) (float64, bool) {
+	if strings.HasPrefix(literal, "0x") || strings.HasPrefix(literal, "0X") {
+		i, err := strconv.ParseUint(literal[2:], 16, 64)
+		return float64(i), err == nil
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	return f, err == nil && !strings.ContainsRune(literal, '_')
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+func numberLiteral(n float64) *Literal {
+	if math.IsInf(n, 0) || math.IsNaN(n) {
+		return nil
+	}
+	return &Literal{Value: formatNumber(n), Base: &Number{}}
+}
+
+func stringLiteral(s string) *Literal {
+	return &Literal{Value: `"` + s + `"`, Base: &String{}}
+}
+
+// Unescape decodes the escape sequences of the contents of a quoted string literal. It returns false if the string
+// contains an invalid escape sequence.
+func Unescape(s string) (string, bool) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", false
+		}
+		switch c := s[i]; c {
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n', '\n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'v':
+			sb.WriteByte('\v')
+		case '\\', '"', '\'':
+			sb.WriteByte(c)
+		case 'z':
+			// Skips the whitespace that follows, including line breaks.
+			for i+1 < len(s) && strings.IndexByte(" \t\r\n\f\v", s[i+1]) >= 0 {
+				i++
+			}
+		case 'x':
+			if i+2 >= len(s) {
+				return "", false
+			}
+			b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", false
+			}
+			sb.WriteByte(byte(b))
+			i += 2
+		case 'u':
+			end := strings.IndexByte(s[i:], '}')
+			if i+1 >= len(s) || s[i+1] != '{' || end < 0 {
+				return "", false
+			}
+			r, err := strconv.ParseUint(s[i+2:i+end], 16, 31)
+			if err != nil {
+				return "", false
+			}
+			sb.WriteRune(rune(r))
+			i += end
+		default:
+			if c < '0' || c > '9' {
+				return "", false
+			}
+			// Up to three decimal digits give the value of a byte.
+			end := i + 1
+			for end < len(s) && end < i+3 && s[end] >= '0' && s[end] <= '9' {
+				end++
+			}
+			b, err := strconv.ParseUint(s[i:end], 10, 8)
+			if err != nil {
+				return "", false
+			}
+			sb.WriteByte(byte(b))
+			i = end - 1
+		}
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/raiguard/luapls/lua/ast"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstant(t *testing.T) {
+	src := `local prefix = "lib."
+local changed = "a"
+changed = "b"
+local values = {
+	"left",
+	[[raw]],
+	"a" .. "b" .. 1,
+	prefix .. "util",
+	1 + 2 * 3,
+	-0x10,
+	7 % 3,
+	10 / 4,
+	true,
+	changed .. "c",
+	1 / 0,
+	print(),
+}`
+	file, info := checkSource(t, src)
+	stmt := file.Block.Pairs[3].Node.(*ast.LocalStatement)
+	tbl := stmt.Exps.Pairs[0].Node.(*ast.TableLiteral)
+	expected := []string{`"left"`, `"raw"`, `"ab1"`, `"lib.util"`, "7", "-16", "1", "2.5", "true", "", "", ""}
+	require.Len(t, tbl.Fields.Pairs, len(expected))
+	for i, pair := range tbl.Fields.Pairs {
+		value := ""
+		if lit := Constant(pair.Node.(*ast.TableArrayField).Expr, info); lit != nil {
+			value = lit.Value
+		}
+		assert.Equal(t, expected[i], value, "field %d", i)
+	}
+}
+
+func TestConstantKeys(t *testing.T) {
+	src := `local KEY = "name"
+local t = { [KEY] = "foo", ["a" .. "b"] = 1 }
+local value = t[KEY]
+local ab = t.ab`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "{name: string, ab: number}", symbolType(t, file, info, src, "t"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "value"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "ab"))
+}
+
+func TestConstantRequire(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.lua":     `local base = "lib." local util = require(base .. "util")`,
+		"lib/util.lua": `return { foo = 1 }`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	requires := env.Modules.Requires(uriOf(t, root, "main.lua"))
+	require.Len(t, requires, 1)
+	assert.Equal(t, "lib.util", requires[0].Name)
+	assert.Equal(t, uriOf(t, root, "lib/util.lua"), requires[0].Target)
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{`plain`, "plain", true},
+		{`a\tb\n`, "a\tb\n", true},
+		{`\"quoted\" \\`, `"quoted" \`, true},
+		{`\x41\65\0669`, "AAB9", true},
+		{`\u{48}\u{e9}`, "Hé", true},
+		{"a\\z  \n  b", "ab", true},
+		{`\q`, "", false},
+		{`\x4`, "", false},
+		{`\256`, "", false},
+		{`trailing\`, "", false},
+	}
+	for _, test := range tests {
+		actual, ok := Unescape(test.input)
+		assert.Equal(t, test.ok, ok, test.input)
+		assert.Equal(t, test.expected, actual, test.input)
+	}
+}
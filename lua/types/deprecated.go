@@ -0,0 +1,63 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/ast"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Deprecation returns the `---@deprecated` annotation of the declaration that the given identifier or field access
+// in the given file refers to, or nil if it is not deprecated. Globals and their fields are deprecated if any of
+// their definitions are.
+func (e *Environment) Deprecation(uri protocol.URI, expr ast.Expression) *annotation.Deprecated {
+	info := e.Info[uri]
+	if info == nil {
+		return nil
+	}
+	if path, ok := GlobalPath(expr, info); ok {
+		for _, site := range e.Globals.Defs(path) {
+			if siteInfo := e.Info[site.URI]; siteInfo != nil && site.Stmt != nil {
+				if deprecated := siteInfo.Docs[site.Stmt].Deprecated(); deprecated != nil {
+					return deprecated
+				}
+			}
+		}
+		return nil
+	}
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		sym := info.SymbolOf(expr)
+		if sym == nil || sym.Kind != SymbolLocal || sym.Node == nil {
+			return nil
+		}
+		return info.Docs[sym.Node].Deprecated()
+	case *ast.IndexExpression:
+		key, ok := FieldKey(expr)
+		if !ok {
+			return nil
+		}
+		field := FieldOf(info.TypeOf(expr.Prefix), key)
+		if field == nil || field.Def == nil {
+			return nil
+		}
+		return e.declarationDoc(field.Loc.URI, field.Def).Deprecated()
+	}
+	return nil
+}
+
+// declarationDoc returns the doc comment of the innermost statement or table field that contains the given node.
+func (e *Environment) declarationDoc(uri protocol.URI, node ast.Node) *annotation.Doc {
+	file, info := e.Files[uri], e.Info[uri]
+	if file == nil || info == nil || file.Block == nil {
+		return nil
+	}
+	path := ast.GetSemanticNode(file.Block, node.Pos())
+	nodes := append(path.Parents, path.Node)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		switch nodes[i].(type) {
+		case ast.Statement, ast.TableField:
+			return info.Docs[nodes[i]]
+		}
+	}
+	return nil
+}
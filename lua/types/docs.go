@@ -0,0 +1,276 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// declareTypes adds every class, enum, and alias declared in the file to the environment, without resolving them.
+func (e *Environment) declareTypes(uri protocol.URI, info *Info) {
+	for _, typ := range e.Types {
+		switch typ := typ.(type) {
+		case *Enum:
+			typ.removeMembers(uri)
+		case *Named:
+			typ.removeFields(uri)
+		}
+	}
+	for _, doc := range info.DocBlocks {
+		for _, a := range doc.Annotations {
+			switch a := a.(type) {
+			case *annotation.Alias:
+				alias, ok := e.Types[a.Name].(*Alias)
+				if !ok {
+					alias = &Alias{Name: a.Name}
+					e.Types[a.Name] = alias
+				}
+				alias.Loc = Location{URI: uri, Range: a.NameRange}
+			case *annotation.Enum:
+				if _, ok := e.Types[a.Name].(*Enum); !ok {
+					e.Types[a.Name] = &Enum{Name: a.Name, Loc: Location{URI: uri, Range: a.NameRange}}
+				}
+			case *annotation.Class:
+				if _, ok := e.Types[a.Name].(*Named); !ok {
+					// TODO: Support multiple definition locations
+					e.Types[a.Name] = &Named{Name: a.Name, Loc: Location{URI: uri, Range: a.NameRange}}
+				}
+			}
+		}
+	}
+}
+
+// resolveTypes resolves the targets of the aliases and the fields of the classes declared in the file.
+func (e *Environment) resolveTypes(uri protocol.URI, info *Info) {
+	for _, doc := range info.DocBlocks {
+		var class *Named
+		for _, a := range doc.Annotations {
+			switch a := a.(type) {
+			case *annotation.Alias:
+				if alias, ok := e.Types[a.Name].(*Alias); ok {
+					alias.Type = e.resolveType(a.Type, &info.Diagnostics)
+				}
+			case *annotation.Class:
+				class, _ = e.Types[a.Name].(*Named)
+				if class == nil {
+					continue
+				}
+				for _, expr := range a.Parents {
+					typ := e.resolveType(expr, &info.Diagnostics)
+					if _, ok := Resolve(typ).(*Named); !ok {
+						info.Diagnostics = append(info.Diagnostics, ast.Diagnostic{
+							Message:  fmt.Sprintf("'%s' is not a class", expr),
+							Range:    expr.GetRange(),
+							Severity: protocol.DiagnosticSeverityWarning,
+							Code:     "invalid-annotation",
+						})
+						continue
+					}
+					class.AddParent(typ, uri)
+				}
+			case *annotation.Field:
+				typ := e.resolveType(a.Type, &info.Diagnostics)
+				if class == nil {
+					info.Diagnostics = append(info.Diagnostics, ast.Diagnostic{
+						Message:  "Field annotations must follow a class annotation",
+						Range:    a.Range,
+						Severity: protocol.DiagnosticSeverityWarning,
+						Code:     "invalid-annotation",
+					})
+					continue
+				}
+				if a.Key != nil {
+					// TODO: Index signatures
+					e.resolveType(a.Key, &info.Diagnostics)
+					continue
+				}
+				if a.Optional {
+					typ = NewUnion(typ, &Nil{})
+				}
+				class.SetField(NameAndType{Name: a.Name, Type: typ, Loc: Location{URI: uri, Range: a.NameRange}, Annotated: true})
+			}
+		}
+	}
+}
+
+// typeResolver converts annotated type expressions into types. Names that do not refer to a builtin type, a type
+// parameter, or a declared type are reported in diagnostics.
+type typeResolver struct {
+	env *Environment
+	// generics maps the names of the type parameters that are in scope to those parameters.
+	generics    map[string]*TypeParam
+	diagnostics *[]ast.Diagnostic
+}
+
+// resolveType converts an annotated type expression into a type, with no type parameters in scope.
+func (e *Environment) resolveType(expr annotation.TypeExpr, diagnostics *[]ast.Diagnostic) Type {
+	r := typeResolver{env: e, diagnostics: diagnostics}
+	return r.resolve(expr)
+}
+
+// genericResolver returns a resolver with the type parameters declared by the doc comment in scope, along with those
+// parameters.
+func (e *Environment) genericResolver(doc *annotation.Doc, diagnostics *[]ast.Diagnostic) (*typeResolver, []*TypeParam) {
+	r := &typeResolver{env: e, generics: map[string]*TypeParam{}, diagnostics: diagnostics}
+	generics := doc.Generics()
+	params := make([]*TypeParam, 0, len(generics))
+	for _, generic := range generics {
+		param := &TypeParam{Name: generic.Name}
+		r.generics[generic.Name] = param
+		params = append(params, param)
+	}
+	// Constraints are resolved afterwards so that they may refer to other parameters.
+	for i, generic := range generics {
+		if generic.Constraint != nil {
+			params[i].Constraint = r.resolve(generic.Constraint)
+		}
+	}
+	return r, params
+}
+
+func (r *typeResolver) resolve(expr annotation.TypeExpr) Type {
+	switch expr := expr.(type) {
+	case *annotation.ArrayType:
+		return &Table{Key: &Number{}, Value: r.resolve(expr.Elem)}
+	case *annotation.FunctionType:
+		fn := &Function{Params: []NameAndType{}}
+		for _, param := range expr.Params {
+			var typ Type = &Any{}
+			if param.Type != nil {
+				typ = r.resolve(param.Type)
+			}
+			if param.Optional {
+				typ = NewUnion(typ, &Nil{})
+			}
+			fn.Params = append(fn.Params, NameAndType{Name: param.Name, Type: typ})
+		}
+		if len(expr.Returns) > 0 {
+			types := make([]Type, 0, len(expr.Returns))
+			for _, ret := range expr.Returns {
+				types = append(types, r.resolve(ret))
+			}
+			fn.Return = NewTuple(types, nil)
+		}
+		return fn
+	case *annotation.NamedType:
+		switch expr.Name {
+		case "any", "lightuserdata", "thread", "userdata":
+			return &Any{}
+		case "boolean":
+			return &Boolean{}
+		case "function":
+			return &Function{}
+		case "integer", "number":
+			return &Number{}
+		case "nil":
+			return &Nil{}
+		case "string":
+			return &String{}
+		case "table":
+			tbl := &Table{}
+			if len(expr.Args) == 2 {
+				tbl.Key = r.resolve(expr.Args[0])
+				tbl.Value = r.resolve(expr.Args[1])
+			}
+			return tbl
+		case "unknown":
+			return &Unknown{}
+		}
+		if param := r.generics[expr.Name]; param != nil {
+			return param
+		}
+		if typ := r.env.Types[expr.Name]; typ != nil {
+			return typ
+		}
+		*r.diagnostics = append(*r.diagnostics, ast.Diagnostic{
+			Message:  fmt.Sprintf("Unknown type '%s'", expr.Name),
+			Range:    expr.Range,
+			Severity: protocol.DiagnosticSeverityWarning,
+			Code:     "unknown-type",
+		})
+	case *annotation.LiteralType:
+		literal := &Literal{Value: expr.Value}
+		switch expr.Kind {
+		case token.FALSE, token.TRUE:
+			literal.Base = &Boolean{}
+		case token.NUMBER:
+			literal.Base = &Number{}
+		default:
+			literal.Base = &String{}
+		}
+		return literal
+	case *annotation.OptionalType:
+		return NewUnion(r.resolve(expr.Elem), &Nil{})
+	case *annotation.UnionType:
+		members := make([]Type, 0, len(expr.Types))
+		for _, member := range expr.Types {
+			members = append(members, r.resolve(member))
+		}
+		return NewUnion(members...)
+	case *annotation.TableType:
+		tbl := &Table{}
+		for _, field := range expr.Fields {
+			typ := r.resolve(field.Type)
+			if field.Optional {
+				typ = NewUnion(typ, &Nil{})
+			}
+			tbl.Fields = append(tbl.Fields, NameAndType{Name: field.Name, Type: typ})
+		}
+		return tbl
+	}
+	return &Unknown{}
+}
+
+// attachDocs associates each doc comment block with the statement or table field that starts on the line after
+// it. When that declaration's value is a function expression, the block is attached to the function as well so that
+// its parameters and returns can be annotated.
+func attachDocs(file *ast.File, info *Info) {
+	if len(info.DocBlocks) == 0 || file.Block == nil {
+		return
+	}
+	targets := map[int]ast.Node{}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		switch n.(type) {
+		case ast.Statement, ast.TableField:
+			line := file.LineBreaks.Line(n.Pos())
+			if targets[line] == nil {
+				targets[line] = n
+			}
+		}
+		return true
+	})
+	for _, doc := range info.DocBlocks {
+		node := targets[doc.EndLine+1]
+		if node == nil {
+			continue
+		}
+		info.Docs[node] = doc
+		if fn := DeclaredFunction(node); fn != nil {
+			info.Docs[fn] = doc
+		}
+	}
+}
+
+// DeclaredFunction returns the function expression that the given declaration assigns, if it assigns exactly one.
+func DeclaredFunction(node ast.Node) *ast.FunctionExpression {
+	var value ast.Expression
+	switch node := node.(type) {
+	case *ast.AssignmentStatement:
+		if len(node.Exps.Pairs) == 1 {
+			value = node.Exps.Pairs[0].Node
+		}
+	case *ast.LocalStatement:
+		if node.Exps != nil && len(node.Exps.Pairs) == 1 {
+			value = node.Exps.Pairs[0].Node
+		}
+	case *ast.TableExpressionKeyField:
+		value = node.Expr
+	case *ast.TableSimpleKeyField:
+		value = node.Expr
+	}
+	fn, _ := value.(*ast.FunctionExpression)
+	return fn
+}
@@ -0,0 +1,312 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotatedLocals(t *testing.T) {
+	src := `---@class Vector
+---@field x number
+local Point = { y = 2 }
+
+function Point.new() return Point end
+
+---@type string[]
+local names = {}
+names = 5
+
+---@param a number The first number
+---@param b string
+---@return boolean
+local function check(a, b) end
+
+---@alias Names string[]
+
+---@type Names
+local aliased = {}
+local elem = aliased[1]
+local px = Point.x`
+	file, info := checkSource(t, src)
+	assert.Empty(t, info.Diagnostics)
+	assert.Equal(t, "Vector", symbolType(t, file, info, src, "Point"))
+	assert.Equal(t, "string[]", symbolType(t, file, info, src, "names"))
+	assert.Equal(t, "function(a: number, b: string) → boolean", symbolType(t, file, info, src, "check"))
+	assert.Equal(t, "Names", symbolType(t, file, info, src, "aliased"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "elem"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "px"))
+
+	point := info.SymbolOf(identAt(t, file, src, "Point", 1)).Type.(*Named)
+	require.NotNil(t, point.Field("new"))
+	assert.NotNil(t, point.Field("y"))
+
+	param := info.Docs[info.SymbolOf(identAt(t, file, src, "a", 1)).Node].Param("a")
+	require.NotNil(t, param)
+	assert.Equal(t, "The first number", param.Description)
+}
+
+func TestAnnotatedUnions(t *testing.T) {
+	src := `---@alias Side "left"|"right"
+
+---@param side Side
+---@param count? integer
+---@return (string|number)[]
+local function f(side, count)
+	local n = count or 1
+end
+
+---@type string?
+local maybe
+local definitely = maybe or "default"`
+	file, info := checkSource(t, src)
+	assert.Empty(t, info.Diagnostics)
+	assert.Equal(t, "function(side: Side, count: number|nil) → (string|number)[]", symbolType(t, file, info, src, "f"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "n"))
+	assert.Equal(t, "string|nil", symbolType(t, file, info, src, "maybe"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "definitely"))
+}
+
+func TestAnnotationDiagnostics(t *testing.T) {
+	src := `---@field x number
+
+---@type Missing
+local x`
+	_, info := checkSource(t, src)
+	require.Len(t, info.Diagnostics, 2)
+	assert.Equal(t, "Field annotations must follow a class annotation", info.Diagnostics[0].Message)
+	assert.Equal(t, "Unknown type 'Missing'", info.Diagnostics[1].Message)
+}
+
+func TestGenericFunctions(t *testing.T) {
+	src := `---@generic T
+---@param value T
+---@return T
+local function copy(value) return value end
+
+---@generic V
+---@param list V[]
+---@return V?
+local function first(list) return list[1] end
+
+---@generic K, V
+---@param tbl table<K, V>
+---@param fn fun(value: V): boolean
+---@return K
+local function find(tbl, fn) end
+
+local point = copy({ x = 1 })
+local name = first({ "a", "b" })
+local count = first({})
+local key = find(point, function(value) return true end)
+local px = point.x`
+	file, info := checkSource(t, src)
+	assert.Empty(t, info.Diagnostics)
+	assert.Equal(t, "function(value: T) → T", symbolType(t, file, info, src, "copy"))
+	assert.Equal(t, "{x: number}", symbolType(t, file, info, src, "point"))
+	assert.Equal(t, "string|nil", symbolType(t, file, info, src, "name"))
+	assert.Equal(t, "unknown|nil", symbolType(t, file, info, src, "count"))
+	assert.Equal(t, "unknown", symbolType(t, file, info, src, "key"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "px"))
+}
+
+func TestOverloads(t *testing.T) {
+	src := `---@param x number
+---@return number
+---@overload fun(x: string): string
+---@overload fun(x: boolean, y: table): boolean
+local function convert(x, y) end
+
+local a = convert(1)
+local b = convert("one")
+local c = convert(true, {})
+local d = convert(true)`
+	file, info := checkSource(t, src)
+	assert.Empty(t, info.Diagnostics)
+	convert := info.SymbolOf(identAt(t, file, src, "convert", 0)).Type.(*Function)
+	assert.Len(t, convert.Overloads, 2)
+	assert.Equal(t, "number", symbolType(t, file, info, src, "a"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "b"))
+	assert.Equal(t, "boolean", symbolType(t, file, info, src, "c"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "d"))
+}
+
+func TestMetaFiles(t *testing.T) {
+	main := `local socket = require("socket")
+local conn = socket.connect()
+local version = game.version
+local result = helper()`
+	root := writeFiles(t, map[string]string{
+		"main.lua": main,
+		"meta/socket.lua": `---@meta socket
+local socket = {}
+---@return Connection
+function socket.connect() end
+return socket`,
+		"meta/game.lua": `---@meta
+
+---@class Connection
+
+---@class Game
+---@field version string
+
+---@type Game
+game = nil
+
+function helper() end`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	file := env.Files[uriOf(t, root, "main.lua")]
+	info := env.Info[file.URI]
+	require.NotNil(t, env.Info[uriOf(t, root, "meta/game.lua")].Meta)
+	assert.Equal(t, uriOf(t, root, "meta/socket.lua"), env.Modules.Requires(file.URI)[0].Target)
+	assert.Equal(t, "Connection", symbolType(t, file, info, main, "conn"))
+	assert.Equal(t, "string", symbolType(t, file, info, main, "version"))
+	assert.Equal(t, "unknown", symbolType(t, file, info, main, "result"))
+}
+
+func TestExpectedTables(t *testing.T) {
+	src := `---@class Item
+---@field type "item"
+---@field name string
+---@field stack_size? number
+
+---@class Recipe
+---@field type "recipe"
+---@field name string
+---@field ingredients string[]
+
+---@alias AnyPrototype Item|Recipe
+
+---@class Data
+---@field extend fun(self: Data, prototypes: AnyPrototype[])
+
+---@type Data
+local data = {}
+
+data:extend({
+	{ type = "item", name = "iron-plate", stack_size = 100, icon = "iron-plate.png" },
+	{ type = "recipe", name = "iron-gear" },
+})`
+	_, info := checkSource(t, src)
+	require.Len(t, info.Diagnostics, 2)
+	assert.Equal(t, "Unknown field 'icon' in 'Item'", info.Diagnostics[0].Message)
+	assert.Equal(t, "Missing required field 'ingredients' of 'Recipe'", info.Diagnostics[1].Message)
+	require.Len(t, info.Diagnostics[0].Related, 1)
+	assert.Equal(t, "Class 'Item' is declared here", info.Diagnostics[0].Related[0].Message)
+	require.Len(t, info.Diagnostics[1].Related, 1)
+	assert.Equal(t, "Field 'ingredients' is declared here", info.Diagnostics[1].Related[0].Message)
+	assert.Len(t, info.Expected, 2)
+}
+
+func TestClassInheritance(t *testing.T) {
+	src := `---@class Animal
+---@field name string
+local Animal = {}
+Animal.__index = Animal
+
+function Animal:speak() return #self.name end
+
+---@class Dog: Animal
+---@field breed string
+local Dog = setmetatable({}, { __index = Animal })
+
+---@type Dog
+local dog = { name = "Rex" }
+local dogName = dog.name
+
+local instance = setmetatable({}, Animal)
+local method = instance.speak
+
+---@type Animal
+local animal = dog
+
+---@class Broken: number`
+	file, info := checkSource(t, src)
+	require.Len(t, info.Diagnostics, 1)
+	assert.Equal(t, "'number' is not a class", info.Diagnostics[0].Message)
+	assert.Equal(t, "string", symbolType(t, file, info, src, "dogName"))
+	assert.Equal(t, "function(self: Animal) → number", symbolType(t, file, info, src, "method"))
+	assert.Equal(t, "Animal", symbolType(t, file, info, src, "animal"))
+
+	dog := info.SymbolOf(identAt(t, file, src, "Dog", 1)).Type.(*Named)
+	assert.Len(t, dog.Fields, 1, "inherited fields should not be copied")
+	assert.Equal(t, []string{"breed", "name", "__index", "speak"}, fieldNames(dog.AllFields()))
+	animal := info.SymbolOf(identAt(t, file, src, "Animal", 1)).Type.(*Named)
+	assert.True(t, dog.Inherits(animal))
+	assert.False(t, animal.Inherits(dog))
+	assert.True(t, Assignable(dog, animal))
+	assert.False(t, Assignable(animal, dog))
+}
+
+func TestSupertypes(t *testing.T) {
+	src := `---@class Animal
+local Animal = {}
+Animal.__index = Animal
+
+---@class Dog: Animal
+local Dog = setmetatable({}, { __index = Animal })
+
+---@class Cat
+local Cat = setmetatable({}, Animal)`
+	file, info := checkSource(t, src)
+	animal := info.SymbolOf(identAt(t, file, src, "Animal", 1)).Type.(*Named)
+	dog := info.SymbolOf(identAt(t, file, src, "Dog", 1)).Type.(*Named)
+	cat := info.SymbolOf(identAt(t, file, src, "Cat", 1)).Type.(*Named)
+	assert.Empty(t, animal.Supertypes())
+	assert.Equal(t, []*Named{animal}, dog.Supertypes(), "the parent should not be repeated")
+	assert.Equal(t, []*Named{animal}, cat.Supertypes())
+}
+
+func TestFieldsOf(t *testing.T) {
+	src := `---@class Base
+---@field id number
+local Base = {}
+Base.__index = Base
+function Base:describe() return "" end
+
+---@class Derived: Base
+---@field extra string
+local Derived = {}
+
+local instance = setmetatable({ own = true, id = "shadowed" }, Base)`
+	file, info := checkSource(t, src)
+	derived := info.SymbolOf(identAt(t, file, src, "Derived", 1)).Type
+	instance := info.SymbolOf(identAt(t, file, src, "instance", 0)).Type
+	assert.Equal(t, []string{"extra", "id", "__index", "describe"}, fieldNames(FieldsOf(derived)))
+	assert.Equal(t, []string{"own", "id", "__index", "describe"}, fieldNames(FieldsOf(instance)))
+}
+
+func TestEnum(t *testing.T) {
+	src := `---@enum Direction
+local Direction = { north = 0, east = 1 }
+
+defines = {}
+---@enum defines.events
+defines.events = { on_tick = 0 }
+
+local dir = Direction.north
+local tick = defines.events.on_tick`
+	file, info := checkSource(t, src)
+	assert.Empty(t, info.Diagnostics)
+	assert.Equal(t, "Direction", symbolType(t, file, info, src, "dir"))
+	assert.Equal(t, "defines.events", symbolType(t, file, info, src, "tick"))
+
+	direction := info.SymbolOf(identAt(t, file, src, "dir", 0)).Type.(*Enum)
+	assert.Equal(t, []string{"north", "east"}, fieldNames(direction.Members))
+	assert.Equal(t, "number", direction.Member("east").Type.String())
+	assert.True(t, Assignable(direction, &Number{}))
+	assert.False(t, Assignable(&Number{}, direction))
+	assert.False(t, Assignable(&String{}, direction))
+}
+
+func fieldNames(fields []NameAndType) []string {
+	names := []string{}
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+	return names
+}
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/annotation"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Enum is a nominal type whose values are the values of a table, declared with `@enum`. Reading a field of the table
+// produces a value of the enum, and a location of the enum type only accepts such values.
+type Enum struct {
+	Name    string
+	Loc     Location
+	Members []NameAndType // The fields of the table, with the types of their values.
+}
+
+func (e *Enum) isType() {}
+
+func (e *Enum) String() string {
+	return e.Name
+}
+
+// Member returns the member with the given name, or nil if there is no such member.
+func (e *Enum) Member(name string) *NameAndType {
+	return findField(e.Members, name)
+}
+
+// removeMembers removes every member that was declared in the given file.
+func (e *Enum) removeMembers(uri protocol.URI) {
+	members := e.Members[:0]
+	for _, member := range e.Members {
+		if member.Loc.URI != uri {
+			members = append(members, member)
+		}
+	}
+	e.Members = members
+}
+
+// declareEnum records the fields of a table annotated with `---@enum` as the members of the enum, and gives those
+// fields the enum's type.
+func (in *inferrer) declareEnum(doc *annotation.Doc, typ Type) {
+	a := doc.Enum()
+	if a == nil {
+		return
+	}
+	enum, ok := in.env.Types[a.Name].(*Enum)
+	tbl, isTable := typ.(*Table)
+	if !ok || !isTable {
+		return
+	}
+	for i := range tbl.Fields {
+		field := &tbl.Fields[i]
+		member := *field
+		if field.Def != nil {
+			member.Loc = nodeLocation(in.file.URI, field.Def)
+		}
+		enum.Members = append(enum.Members, member)
+		field.Type = enum
+		field.Annotated = true
+	}
+}
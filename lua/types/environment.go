@@ -4,13 +4,14 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/raiguard/luapls/lua/annotation"
 	"github.com/raiguard/luapls/lua/ast"
 	"github.com/raiguard/luapls/lua/parser"
-	"github.com/raiguard/luapls/lua/token"
 	"github.com/raiguard/luapls/util"
 	"github.com/tliron/commonlog"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -18,35 +19,138 @@ import (
 
 type Environment struct {
 	Files    map[protocol.URI]*ast.File
+	Info     map[protocol.URI]*Info
+	Globals  *GlobalIndex
+	Modules  *ModuleGraph
 	RootPath string
 
+	// PackagePath contains the patterns used to resolve `require` calls, relative to each root. `?` is replaced by
+	// the module name with dots converted to path separators.
+	PackagePath []string
+
+	// Name identifies the environment in logs and configuration.
+	Name string
+
+	// Include contains glob patterns, relative to the root directory, of the files that this environment is
+	// responsible for. Other files are still loaded so that they can be required. An empty list includes every file
+	// in the root directory.
+	Include []string
+
+	// Exclude contains glob patterns, relative to the root directory, of files that this environment is not
+	// responsible for even though they match Include.
+	Exclude []string
+
+	// Priority decides which environment is responsible for a file that several environments include. The one with
+	// the highest priority is chosen.
+	Priority int
+
+	// Library contains additional files and directories, such as generated definitions, that are loaded alongside
+	// the files in the root directory.
+	Library []string
+
+	// Ignore contains patterns of files and directories that are not loaded from the root directory and the library,
+	// such as vendored dependencies and build outputs, in the syntax of `.gitignore` files. The `.gitignore` files in
+	// those directories are respected as well.
+	Ignore []string
+
+	// HostGlobals contains the globals that are provided by the host application, mapped to their types in
+	// annotation syntax, such as `fun(msg: string)`. Globals of unknown type are mapped to an empty string.
+	HostGlobals map[string]string
+
 	Types map[string]Type
 
+	// Concurrency is the maximum number of files that are parsed or bound at the same time during initialization.
+	// Zero uses one worker per CPU.
+	Concurrency int
+
+	// Progress is called by Init after each file is parsed, with the number of files parsed so far and the number
+	// of files in total. Calls are never made at the same time, even though files are parsed concurrently.
+	Progress func(done int, total int)
+
+	checking map[protocol.URI]bool  // Files currently being checked, to break require cycles.
+	pending  map[protocol.URI]*Info // Analysis results for files that have not finished all phases of checking.
+
+	metaModules    map[string]protocol.URI  // Module names declared by `---@meta name`, mapped to their definition files.
+	deletedModules map[string]protocol.URI  // The names of modules whose files were deleted, mapped to those files.
+	summaries      map[protocol.URI]summary // The summary of each file when it was last checked by Recheck or Init.
+
 	log commonlog.Logger
 }
 
 func NewEnvironment() *Environment {
 	return &Environment{
-		Files: map[protocol.URI]*ast.File{},
-		Types: map[string]Type{},
-		log:   commonlog.GetLogger("luapls.environment"),
+		Files:          map[protocol.URI]*ast.File{},
+		Info:           map[protocol.URI]*Info{},
+		Globals:        NewGlobalIndex(),
+		Modules:        NewModuleGraph(),
+		PackagePath:    DefaultPackagePath,
+		Types:          map[string]Type{},
+		checking:       map[protocol.URI]bool{},
+		pending:        map[protocol.URI]*Info{},
+		metaModules:    map[string]protocol.URI{},
+		deletedModules: map[string]protocol.URI{},
+		summaries:      map[protocol.URI]summary{},
+		log:            commonlog.GetLogger("luapls.environment"),
 	}
 }
 
-// Init parses all Lua files in the root directory and builds the type graph.
+// Init parses all Lua files in the root directory and the library that are not ignored and builds the type graph. An
+// environment without a root directory is not part of a workspace, so it only checks the files that were added to it.
 func (e *Environment) Init() {
 	before := time.Now()
-	filepath.WalkDir(e.RootPath, func(path string, info fs.DirEntry, err error) error {
-		if !info.IsDir() && strings.HasSuffix(path, ".lua") {
-			uri, err := util.PathToURI(path)
+	uris := []protocol.URI{}
+	seen := map[protocol.URI]bool{}
+	roots := []string{}
+	if e.RootPath != "" {
+		roots = append([]string{e.RootPath}, e.Library...)
+	}
+	for _, root := range roots {
+		ignore := newIgnoreRules(root, e.Ignore)
+		filepath.WalkDir(root, func(path string, info fs.DirEntry, err error) error {
 			if err != nil {
-				return err
+				e.log.Errorf("%s", err)
+				return nil
+			}
+			if ignore.ignored(path, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				ignore.readGitignore(path)
 			}
-			e.AddFile(uri)
+			if !info.IsDir() && strings.HasSuffix(path, ".lua") {
+				uri, err := util.PathToURI(path)
+				if err != nil {
+					return err
+				}
+				if e.Files[uri] == nil && !seen[uri] {
+					seen[uri] = true
+					uris = append(uris, uri)
+				}
+			}
+			return nil
+		})
+	}
+	files := make([]*ast.File, len(uris))
+	var mu sync.Mutex
+	done := 0
+	util.ParallelEach(indices(len(uris)), e.Concurrency, func(i int) {
+		files[i] = e.parseFile(uris[i])
+		if e.Progress != nil {
+			mu.Lock()
+			done++
+			e.Progress(done, len(uris))
+			mu.Unlock()
 		}
-		return nil
 	})
-	e.CheckPhase1()
+	for _, file := range files {
+		if file != nil {
+			e.Files[file.URI] = file
+		}
+	}
+	e.CheckAll()
 	e.log.Debugf("Initialization took %s", time.Since(before).String())
 
 	e.log.Debug("TYPES:")
@@ -55,10 +159,117 @@ func (e *Environment) Init() {
 	}
 }
 
+// Owns returns whether the environment is responsible for the given file, which is a file in the root directory that
+// Include matches and Exclude does not. Library files are never owned.
+func (e *Environment) Owns(uri protocol.URI) bool {
+	path, err := util.URIToPath(uri)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(e.RootPath, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range e.Exclude {
+		if util.MatchGlob(pattern, rel) {
+			return false
+		}
+	}
+	if len(e.Include) == 0 {
+		return true
+	}
+	for _, pattern := range e.Include {
+		if util.MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reindex discards every file and analysis result, then loads the root directory and the library again as Init does.
+// Files for which keep returns true, such as those that are open in the editor, are kept as they are. It returns the
+// URIs of the files that were loaded before but no longer exist.
+func (e *Environment) Reindex(keep func(uri protocol.URI) bool) []protocol.URI {
+	old := e.Files
+	e.Files = map[protocol.URI]*ast.File{}
+	for uri, file := range old {
+		if keep(uri) {
+			e.Files[uri] = file
+		}
+	}
+	e.Info = map[protocol.URI]*Info{}
+	e.Globals = NewGlobalIndex()
+	e.Modules = NewModuleGraph()
+	e.Types = map[string]Type{}
+	e.checking = map[protocol.URI]bool{}
+	e.pending = map[protocol.URI]*Info{}
+	e.metaModules = map[string]protocol.URI{}
+	e.summaries = map[protocol.URI]summary{}
+	e.Init()
+	removed := []protocol.URI{}
+	for uri := range old {
+		if e.Files[uri] == nil {
+			removed = append(removed, uri)
+		}
+	}
+	slices.Sort(removed)
+	return removed
+}
+
+// Loads returns whether the file is in the root directory or the library and is not ignored, which Init loads every
+// such file from.
+func (e *Environment) Loads(uri protocol.URI) bool {
+	path, err := util.URIToPath(uri)
+	if err != nil {
+		return false
+	}
+	for _, root := range append([]string{e.RootPath}, e.Library...) {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return !e.isIgnored(root, path)
+		}
+	}
+	return false
+}
+
+// Load adds a file that was created on disk after the environment was initialized, and checks it along with the
+// files whose requires now resolve to it. It returns the URIs of the files that were checked.
+func (e *Environment) Load(uri protocol.URI) []protocol.URI {
+	if e.Files[uri] != nil || e.AddFile(uri) == nil {
+		return nil
+	}
+	checked := e.Recheck(uri)
+	for _, other := range e.sortedURIs() {
+		for _, req := range e.Modules.Requires(other) {
+			if target, _ := e.ResolveModule(req.Name); target == req.Target {
+				continue
+			}
+			for _, uri := range e.Recheck(other) {
+				if !slices.Contains(checked, uri) {
+					checked = append(checked, uri)
+				}
+			}
+			break
+		}
+	}
+	return checked
+}
+
 func (e *Environment) AddFile(uri protocol.URI) *ast.File {
 	if existing := e.Files[uri]; existing != nil {
 		return existing
 	}
+	file := e.parseFile(uri)
+	if file != nil {
+		e.Files[uri] = file
+	}
+	return file
+}
+
+// parseFile reads and parses the file at the given URI without adding it to the environment, so that it can be
+// called for several files at once.
+func (e *Environment) parseFile(uri protocol.URI) *ast.File {
 	path, err := util.URIToPath(uri)
 	if err != nil {
 		e.log.Errorf("%s", err)
@@ -73,8 +284,6 @@ func (e *Environment) AddFile(uri protocol.URI) *ast.File {
 	file := util.Ptr(parser.New(string(src)).ParseFile())
 	e.log.Debugf("Parsed file '%s' in %s", path, time.Since(timer).String())
 	file.URI = uri
-	e.Files[uri] = file
-
 	return file
 }
 
@@ -96,44 +305,270 @@ func (e *Environment) AddTransientFile(uri protocol.URI, content string) *ast.Fi
 	return file
 }
 
+// Diagnostics returns the parse and analysis diagnostics for the given file.
+func (e *Environment) Diagnostics(uri protocol.URI) []ast.Diagnostic {
+	file := e.Files[uri]
+	if file == nil {
+		return nil
+	}
+	diagnostics := append([]ast.Diagnostic{}, file.Diagnostics...)
+	if info := e.Info[uri]; info != nil {
+		diagnostics = append(diagnostics, info.Diagnostics...)
+	}
+	return diagnostics
+}
+
+// CheckAll runs every phase of type checking on every file in the environment.
+func (e *Environment) CheckAll() {
+	e.CheckPhase1()
+	e.CheckPhase2()
+	e.CheckPhase3()
+	for uri := range e.Files {
+		e.summaries[uri] = e.summarize(uri)
+	}
+}
+
+// DeleteFile removes a file that was deleted from disk, like RemoveFile, and remembers the name that it could be
+// required by, so that the requires that still refer to it can be reported.
+func (e *Environment) DeleteFile(uri protocol.URI) []protocol.URI {
+	if name, ok := e.ModuleName(uri); ok && e.Files[uri] != nil {
+		e.deletedModules[name] = uri
+	}
+	return e.RemoveFile(uri)
+}
+
+// DeletedModule returns the file of the module with the given name if it was deleted, and has not been replaced by
+// another file since.
+func (e *Environment) DeletedModule(name string) (protocol.URI, bool) {
+	uri, ok := e.deletedModules[name]
+	if !ok {
+		return "", false
+	}
+	if _, ok := e.ResolveModule(name); ok {
+		return "", false
+	}
+	return uri, true
+}
+
+// RemoveFile removes a file and its analysis results from the environment, and rechecks the files that were
+// affected by it. It returns the URIs of the files that were rechecked.
+func (e *Environment) RemoveFile(uri protocol.URI) []protocol.URI {
+	if e.Files[uri] == nil {
+		return nil
+	}
+	for _, typ := range e.Types {
+		switch typ := typ.(type) {
+		case *Enum:
+			typ.removeMembers(uri)
+		case *Named:
+			typ.removeFields(uri)
+		}
+	}
+	for name, target := range e.metaModules {
+		if target == uri {
+			delete(e.metaModules, name)
+		}
+	}
+	affected := e.affectedBy(uri, e.summaries[uri], summary{})
+	e.Globals.Remove(uri)
+	e.Modules.Remove(uri)
+	delete(e.Files, uri)
+	delete(e.Info, uri)
+	delete(e.pending, uri)
+	delete(e.summaries, uri)
+	checked := []protocol.URI{}
+	for _, dependent := range affected {
+		for _, uri := range e.Recheck(dependent) {
+			if !slices.Contains(checked, uri) {
+				checked = append(checked, uri)
+			}
+		}
+	}
+	return checked
+}
+
+// Recheck re-runs analysis on the given file and on the files that are affected by it, and returns the URIs of all
+// files that were checked. Other files are only rechecked if the summary of a file that they depend on changed, so
+// edits to the bodies of functions do not cause the rest of the workspace to be checked again.
+func (e *Environment) Recheck(uri protocol.URI) []protocol.URI {
+	if e.Files[uri] == nil {
+		return nil
+	}
+	checked := []protocol.URI{}
+	counts := map[protocol.URI]int{}
+	queue := []protocol.URI{uri}
+	queued := map[protocol.URI]bool{uri: true}
+	for len(queue) > 0 {
+		uri := queue[0]
+		queue = queue[1:]
+		delete(queued, uri)
+		file := e.Files[uri]
+		if file == nil {
+			continue
+		}
+		before, ok := e.summaries[uri]
+		e.CheckFile(file)
+		after := e.summarize(uri)
+		e.summaries[uri] = after
+		if counts[uri] == 0 {
+			checked = append(checked, uri)
+		}
+		counts[uri]++
+		if ok && before.equal(after) {
+			continue
+		}
+		for _, dependent := range e.affectedBy(uri, before, after) {
+			// A file may be checked a second time if a file that it depends on changed after it was checked, but no
+			// more, so that cycles of requires always settle.
+			if !queued[dependent] && counts[dependent] < 2 {
+				queue = append(queue, dependent)
+				queued[dependent] = true
+			}
+		}
+	}
+	return checked
+}
+
 // CheckPhase1 executes the first phase of type checking.
 // The first phase gathers a list of which types exist in the environment, but does not delve into details.
 func (e *Environment) CheckPhase1() {
-	for _, file := range e.Files {
-		e.CheckFilePhase1(file)
+	e.Info = map[protocol.URI]*Info{}
+	e.pending = map[protocol.URI]*Info{}
+	files := e.sortedFiles()
+	infos := make([]*Info, len(files))
+	util.ParallelEach(indices(len(files)), e.Concurrency, func(i int) {
+		infos[i] = newFileInfo(files[i])
+	})
+	for i, file := range files {
+		e.declareFile(file, infos[i])
+	}
+}
+
+// CheckPhase2 executes the second phase of type checking, which resolves the fields of each type and the targets
+// of aliases, and binds the names in each file so that every global and require is indexed before inference. Files
+// are bound in parallel, since binding only depends on the file itself.
+func (e *Environment) CheckPhase2() {
+	files := []*ast.File{}
+	for _, file := range e.sortedFiles() {
+		if info := e.pending[file.URI]; info != nil {
+			e.resolveTypes(file.URI, info)
+			files = append(files, file)
+		}
+	}
+	util.ParallelEach(files, e.Concurrency, func(file *ast.File) {
+		Bind(file, e.pending[file.URI])
+	})
+	for _, file := range files {
+		e.indexFile(file, e.pending[file.URI])
+	}
+}
+
+// CheckPhase3 executes the third phase of type checking, which infers the types of expressions. Inference adds
+// fields to shared types and reads the results of other files, so files are inferred one at a time, with the modules
+// that each file requires inferred before it.
+func (e *Environment) CheckPhase3() {
+	for _, uri := range e.Modules.DependencyOrder(e.sortedURIs()) {
+		// Files may have already been checked in order to resolve a require.
+		if file := e.Files[uri]; file != nil && e.Info[uri] == nil {
+			e.CheckFilePhase3(file)
+		}
+	}
+}
+
+// sortedURIs returns the URIs of every file in the environment, in a stable order.
+func (e *Environment) sortedURIs() []protocol.URI {
+	uris := make([]protocol.URI, 0, len(e.Files))
+	for uri := range e.Files {
+		uris = append(uris, uri)
 	}
+	slices.Sort(uris)
+	return uris
+}
+
+// sortedFiles returns every file in the environment, in a stable order.
+func (e *Environment) sortedFiles() []*ast.File {
+	files := make([]*ast.File, 0, len(e.Files))
+	for _, uri := range e.sortedURIs() {
+		files = append(files, e.Files[uri])
+	}
+	return files
+}
+
+// CheckFile runs every phase of type checking on a single file.
+func (e *Environment) CheckFile(file *ast.File) {
+	e.CheckFilePhase1(file)
+	e.CheckFilePhase2(file)
+	e.CheckFilePhase3(file)
 }
 
 func (e *Environment) CheckFilePhase1(file *ast.File) {
-	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
-		for _, trivia := range n.GetLeadingTrivia() {
-			if trivia.Type != token.COMMENT {
-				continue
-			}
-			content, ok := strings.CutPrefix(trivia.Literal, "---")
-			if !ok {
-				continue
-			}
-			a, diags := annotation.Parse(content)
-			for _, diag := range diags {
-				diag.Range.Start += trivia.Pos + 3
-				diag.Range.End += trivia.Pos + 3
-				file.Diagnostics = append(file.Diagnostics, diag)
-			}
-			if a == nil {
-				continue
-			}
-			class, ok := a.(*annotation.Class)
-			if !ok {
-				continue
-			}
-			if e.Types[class.Name] != nil {
-				continue
+	e.declareFile(file, newFileInfo(file))
+}
+
+// newFileInfo creates the analysis results for a file, with its doc comments parsed.
+func newFileInfo(file *ast.File) *Info {
+	info := NewInfo()
+	info.DocBlocks, info.Diagnostics = annotation.ParseDocs(file.Comments, file.LineBreaks)
+	return info
+}
+
+// declareFile records the module name and the types that are declared by the file's doc comments.
+func (e *Environment) declareFile(file *ast.File, info *Info) {
+	for name, uri := range e.metaModules {
+		if uri == file.URI {
+			delete(e.metaModules, name)
+		}
+	}
+	for _, doc := range info.DocBlocks {
+		for _, a := range doc.Annotations {
+			if meta, ok := a.(*annotation.Meta); ok && info.Meta == nil {
+				info.Meta = meta
+				if meta.Name != "" {
+					e.metaModules[meta.Name] = file.URI
+				}
 			}
-			// TODO: Narrow location to actual name
-			// TODO: Support multiple definition locations
-			e.Types[class.Name] = &Named{Name: class.Name, Range: trivia.Range()}
 		}
-		return true
-	})
+	}
+	e.pending[file.URI] = info
+	e.declareTypes(file.URI, info)
+}
+
+func (e *Environment) CheckFilePhase2(file *ast.File) {
+	info := e.pending[file.URI]
+	if info == nil {
+		return
+	}
+	e.resolveTypes(file.URI, info)
+	Bind(file, info)
+	e.indexFile(file, info)
+}
+
+// indexFile adds the globals and requires of a bound file to the environment's indexes.
+func (e *Environment) indexFile(file *ast.File, info *Info) {
+	e.Globals.Update(file, info)
+	e.Modules.Update(file, info, e.ResolveModule)
+}
+
+func (e *Environment) CheckFilePhase3(file *ast.File) {
+	info := e.pending[file.URI]
+	if info == nil {
+		e.CheckFilePhase1(file)
+		e.CheckFilePhase2(file)
+		info = e.pending[file.URI]
+	}
+	delete(e.pending, file.URI)
+	e.checking[file.URI] = true
+	defer delete(e.checking, file.URI)
+	attachDocs(file, info)
+	e.infer(file, info)
+	e.Info[file.URI] = info
+}
+
+// indices returns the integers from zero up to n.
+func indices(n int) []int {
+	result := make([]int, n)
+	for i := range result {
+		result[i] = i
+	}
+	return result
 }
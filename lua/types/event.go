@@ -0,0 +1,69 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+)
+
+// EventsEnum is the enum of the events of the Factorio runtime API. Its members are named after the events, and the
+// data that the handlers of an event receive is the class named after the event in EventData.
+const EventsEnum = "defines.events"
+
+// EventData returns the class of the data that the handlers of an event receive, given an expression that reads a
+// member of EventsEnum and the type of that expression, such as `EventData.on_built_entity` for
+// `defines.events.on_built_entity`. It returns nil if the expression does not name an event.
+func (e *Environment) EventData(expr ast.Expression, typ Type) *Named {
+	ie, ok := expr.(*ast.IndexExpression)
+	if !ok {
+		return nil
+	}
+	if enum, ok := Resolve(typ).(*Enum); !ok || enum.Name != EventsEnum {
+		return nil
+	}
+	name, ok := FieldKey(ie)
+	if !ok {
+		return nil
+	}
+	class, _ := e.Types["EventData."+name].(*Named)
+	return class
+}
+
+// eventHandler gives the first parameter of a function that is passed to `script.on_event` the class of the data of
+// the events that the function handles, so that the fields of that data are known within it. The events are either
+// a single member of EventsEnum or a table of them.
+func (in *inferrer) eventHandler(fc *ast.FunctionCall) {
+	ie, ok := fc.Name.(*ast.IndexExpression)
+	if !ok || len(fc.Args.Pairs) < 2 {
+		return
+	}
+	if name, ok := FieldKey(ie); !ok || name != "on_event" {
+		return
+	}
+	handler, ok := fc.Args.Pairs[1].Node.(*ast.FunctionExpression)
+	if !ok {
+		return
+	}
+	var data []Type
+	switch events := fc.Args.Pairs[0].Node.(type) {
+	case *ast.TableLiteral:
+		for _, pair := range events.Fields.Pairs {
+			field, ok := pair.Node.(*ast.TableArrayField)
+			if !ok {
+				return
+			}
+			class := in.env.EventData(field.Expr, in.expr(field.Expr))
+			if class == nil {
+				return
+			}
+			data = append(data, class)
+		}
+	default:
+		class := in.env.EventData(events, in.expr(events))
+		if class == nil {
+			return
+		}
+		data = append(data, class)
+	}
+	if len(data) > 0 {
+		in.handlers[handler] = NewUnion(data...)
+	}
+}
@@ -0,0 +1,177 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// expectArgs checks the table literals passed to a function against the types of the parameters they are passed to.
+func (in *inferrer) expectArgs(fc *ast.FunctionCall, fn *Function) {
+	if len(fn.TypeParams) > 0 {
+		return
+	}
+	params := fn.Params
+	// Methods called with `:` receive the prefix as their first parameter.
+	if ie, ok := fc.Name.(*ast.IndexExpression); ok && ie.LeftIndexer.Type() == token.COLON {
+		if len(params) == 0 || params[0].Name != "self" {
+			return
+		}
+		params = params[1:]
+	}
+	for i, pair := range fc.Args.Pairs {
+		if i >= len(params) {
+			break
+		}
+		in.expect(pair.Node, params[i].Type)
+	}
+}
+
+// expect records the class that a table literal is expected to be an instance of, and reports fields that the class
+// does not have or that are missing from the literal. Tables nested in the literal are checked against the types of
+// their fields.
+func (in *inferrer) expect(expr ast.Expression, typ Type) {
+	tbl, ok := expr.(*ast.TableLiteral)
+	if !ok || typ == nil {
+		return
+	}
+	switch typ := Resolve(RemoveNil(typ)).(type) {
+	case *Union:
+		if named := discriminate(tbl, typ); named != nil {
+			in.expect(tbl, named)
+		}
+	case *Table:
+		if typ.Value == nil {
+			return
+		}
+		for _, pair := range tbl.Fields.Pairs {
+			if field, ok := pair.Node.(*ast.TableArrayField); ok {
+				in.expect(field.Expr, typ.Value)
+			}
+		}
+	case *Named:
+		in.info.Expected[tbl] = typ
+		fields := typ.AllFields()
+		if len(fields) == 0 || in.info.Meta != nil {
+			return
+		}
+		present := map[string]bool{}
+		for _, pair := range tbl.Fields.Pairs {
+			name, node, value := tableFieldKey(pair.Node)
+			if node == nil {
+				continue
+			}
+			present[name] = true
+			field := typ.Field(name)
+			if field == nil {
+				in.info.Diagnostics = append(in.info.Diagnostics, ast.Diagnostic{
+					Message:  fmt.Sprintf("Unknown field '%s' in '%s'", name, typ.Name),
+					Range:    ast.Range(node),
+					Severity: protocol.DiagnosticSeverityWarning,
+					Code:     "unknown-field",
+					Related:  relatedLocation(typ.Loc, "Class '"+typ.Name+"' is declared here"),
+				})
+				continue
+			}
+			in.expect(value, field.Type)
+		}
+		for _, field := range fields {
+			if !present[field.Name] && isRequired(field.Type) {
+				in.info.Diagnostics = append(in.info.Diagnostics, ast.Diagnostic{
+					Message:  fmt.Sprintf("Missing required field '%s' of '%s'", field.Name, typ.Name),
+					Range:    tbl.LeftBrace.Range(),
+					Severity: protocol.DiagnosticSeverityWarning,
+					Code:     "missing-field",
+					Related:  relatedLocation(field.Loc, "Field '"+field.Name+"' is declared here"),
+				})
+			}
+		}
+	}
+}
+
+// discriminate picks the class in a union that a table literal is an instance of, using the fields of each class
+// that only admit a single string, such as the `type` field of Factorio prototypes.
+func discriminate(tbl *ast.TableLiteral, union *Union) *Named {
+	for _, member := range union.Types {
+		named, ok := Resolve(member).(*Named)
+		if !ok {
+			continue
+		}
+		matched := false
+		for _, pair := range tbl.Fields.Pairs {
+			name, node, value := tableFieldKey(pair.Node)
+			lit, ok := value.(*ast.StringLiteral)
+			if node == nil || !ok {
+				continue
+			}
+			field := named.Field(name)
+			if field == nil {
+				continue
+			}
+			expected, ok := Resolve(field.Type).(*Literal)
+			if !ok {
+				continue
+			}
+			if str, ok := StringValue(lit); !ok || str != literalString(expected) {
+				matched = false
+				break
+			}
+			matched = true
+		}
+		if matched {
+			return named
+		}
+	}
+	return nil
+}
+
+// literalString returns the value of a string literal type without its quotes.
+func literalString(lit *Literal) string {
+	if _, ok := lit.Base.(*String); !ok || len(lit.Value) < 2 {
+		return lit.Value
+	}
+	return lit.Value[1 : len(lit.Value)-1]
+}
+
+// tableFieldKey returns the name, key node, and value of a table field with a string key. The key node is nil for
+// other fields.
+func tableFieldKey(field ast.TableField) (string, ast.Node, ast.Expression) {
+	switch field := field.(type) {
+	case *ast.TableSimpleKeyField:
+		return field.Name.Token.Literal, &field.Name, field.Expr
+	case *ast.TableExpressionKeyField:
+		if lit, ok := field.Name.(*ast.StringLiteral); ok {
+			if key, ok := StringValue(lit); ok {
+				return key, lit, field.Expr
+			}
+		}
+	}
+	return "", nil, nil
+}
+
+// isRequired returns whether a field of the given type must be present. Functions are assumed to be methods, which are
+// provided by the class rather than by each instance.
+func isRequired(typ Type) bool {
+	switch typ := Resolve(typ).(type) {
+	case *Any, *Function, *Nil, *Unknown:
+		return false
+	case *Union:
+		for _, member := range typ.Types {
+			if !isRequired(member) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// relatedLocation returns the related information of a diagnostic that points at the given location, or nil if the
+// location is unknown.
+func relatedLocation(loc Location, message string) []ast.Related {
+	if loc.URI == "" {
+		return nil
+	}
+	return []ast.Related{{URI: loc.URI, Range: loc.Range, Message: message}}
+}
@@ -0,0 +1,132 @@
+package types
+
+// TypeParam is a type parameter of a generic function, declared with `@generic`. It is replaced by a concrete type
+// at each call site.
+type TypeParam struct {
+	Name       string
+	Constraint Type // Nil if the parameter is unconstrained.
+}
+
+func (t *TypeParam) isType() {}
+
+func (t *TypeParam) String() string {
+	return t.Name
+}
+
+// instantiate returns the return type of a call to a generic function, with each type parameter replaced by the
+// type that the arguments give it.
+func instantiate(fn *Function, args []Type) Type {
+	bindings := map[*TypeParam]Type{}
+	for i, param := range fn.Params {
+		if i < len(args) {
+			bindTypeParams(param.Type, args[i], bindings)
+		}
+	}
+	return substitute(fn.Return, bindings, map[Type]bool{})
+}
+
+// bindTypeParams matches the structure of a parameter type against the type of an argument, recording the type of
+// each type parameter that it encounters.
+func bindTypeParams(param Type, arg Type, bindings map[*TypeParam]Type) {
+	if arg == nil {
+		return
+	}
+	switch param := param.(type) {
+	case *Function:
+		arg, ok := Resolve(arg).(*Function)
+		if !ok {
+			return
+		}
+		for i := range param.Params {
+			if i < len(arg.Params) {
+				bindTypeParams(param.Params[i].Type, arg.Params[i].Type, bindings)
+			}
+		}
+		bindTypeParams(param.Return, arg.Return, bindings)
+	case *Table:
+		arg, ok := Resolve(arg).(*Table)
+		if !ok {
+			return
+		}
+		bindTypeParams(param.Key, arg.Key, bindings)
+		bindTypeParams(param.Value, arg.Value, bindings)
+	case *Tuple:
+		for i, member := range param.Types {
+			bindTypeParams(member, At(arg, i), bindings)
+		}
+	case *TypeParam:
+		bindings[param] = NewUnion(bindings[param], arg)
+	case *Union:
+		// Only `T|nil` and the like can be matched unambiguously.
+		if member := RemoveNil(param); member != nil {
+			if _, ok := member.(*Union); !ok {
+				bindTypeParams(member, RemoveNil(arg), bindings)
+			}
+		}
+	}
+}
+
+// substitute replaces each type parameter within the given type with its binding, or with its constraint if it is
+// unbound. seen guards against self-referential tables.
+func substitute(typ Type, bindings map[*TypeParam]Type, seen map[Type]bool) Type {
+	if typ == nil || seen[typ] {
+		return typ
+	}
+	switch typ := typ.(type) {
+	case *Function:
+		seen[typ] = true
+		fn := &Function{Params: make([]NameAndType, 0, len(typ.Params)), Return: substitute(typ.Return, bindings, seen)}
+		changed := fn.Return != typ.Return
+		for _, param := range typ.Params {
+			substituted := substitute(param.Type, bindings, seen)
+			changed = changed || substituted != param.Type
+			param.Type = substituted
+			fn.Params = append(fn.Params, param)
+		}
+		if !changed {
+			return typ
+		}
+		return fn
+	case *Table:
+		seen[typ] = true
+		tbl := &Table{
+			Fields: make([]NameAndType, 0, len(typ.Fields)),
+			Key:    substitute(typ.Key, bindings, seen),
+			Value:  substitute(typ.Value, bindings, seen),
+		}
+		changed := tbl.Key != typ.Key || tbl.Value != typ.Value
+		for _, field := range typ.Fields {
+			substituted := substitute(field.Type, bindings, seen)
+			changed = changed || substituted != field.Type
+			field.Type = substituted
+			tbl.Fields = append(tbl.Fields, field)
+		}
+		// Tables without type parameters keep their identity, so that fields assigned to the result are visible
+		// through the original.
+		if !changed {
+			return typ
+		}
+		return tbl
+	case *Tuple:
+		tuple := &Tuple{Types: make([]Type, 0, len(typ.Types)), Rest: substitute(typ.Rest, bindings, seen)}
+		for _, member := range typ.Types {
+			tuple.Types = append(tuple.Types, substitute(member, bindings, seen))
+		}
+		return tuple
+	case *TypeParam:
+		if binding := bindings[typ]; binding != nil {
+			return binding
+		}
+		if typ.Constraint != nil {
+			return typ.Constraint
+		}
+		return &Unknown{}
+	case *Union:
+		members := make([]Type, 0, len(typ.Types))
+		for _, member := range typ.Types {
+			members = append(members, substitute(member, bindings, seen))
+		}
+		return NewUnion(members...)
+	}
+	return typ
+}
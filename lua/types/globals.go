@@ -0,0 +1,222 @@
+package types
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// GlobalSite is a single location where a global, or a field nested within a global, is assigned or read.
+type GlobalSite struct {
+	URI   protocol.URI
+	Path  string        // Dotted path from the global, e.g. `MyMod.util.foo`.
+	Node  ast.Node      // The identifier or index expression at this site.
+	Range token.Range   // The range of the final component of the path.
+	Value ast.Node      // The value assigned at this site. Nil for reads.
+	Stmt  ast.Statement // The statement that contains this site, if it is an assignment.
+}
+
+// GlobalIndex tracks where globals and their fields are assigned and read across every file in an environment.
+// Files are indexed independently so that a single file can be re-indexed after an edit.
+type GlobalIndex struct {
+	defs  map[string][]GlobalSite
+	reads map[string][]GlobalSite
+	files map[protocol.URI]fileGlobals
+}
+
+type fileGlobals struct {
+	defs  []GlobalSite
+	reads []GlobalSite
+}
+
+func NewGlobalIndex() *GlobalIndex {
+	return &GlobalIndex{
+		defs:  map[string][]GlobalSite{},
+		reads: map[string][]GlobalSite{},
+		files: map[protocol.URI]fileGlobals{},
+	}
+}
+
+// Update replaces all sites from the given file with the sites found in its current AST.
+func (g *GlobalIndex) Update(file *ast.File, info *Info) {
+	g.Remove(file.URI)
+	fg := collectGlobals(file, info)
+	g.files[file.URI] = fg
+	for _, site := range fg.defs {
+		g.defs[site.Path] = append(g.defs[site.Path], site)
+	}
+	for _, site := range fg.reads {
+		g.reads[site.Path] = append(g.reads[site.Path], site)
+	}
+}
+
+// Remove removes all sites from the given file.
+func (g *GlobalIndex) Remove(uri protocol.URI) {
+	fg, ok := g.files[uri]
+	if !ok {
+		return
+	}
+	delete(g.files, uri)
+	for _, site := range fg.defs {
+		g.defs[site.Path] = removeSites(g.defs[site.Path], uri)
+		if len(g.defs[site.Path]) == 0 {
+			delete(g.defs, site.Path)
+		}
+	}
+	for _, site := range fg.reads {
+		g.reads[site.Path] = removeSites(g.reads[site.Path], uri)
+		if len(g.reads[site.Path]) == 0 {
+			delete(g.reads, site.Path)
+		}
+	}
+}
+
+func removeSites(sites []GlobalSite, uri protocol.URI) []GlobalSite {
+	out := sites[:0]
+	for _, site := range sites {
+		if site.URI != uri {
+			out = append(out, site)
+		}
+	}
+	return out
+}
+
+// Defs returns every site that assigns the given path.
+func (g *GlobalIndex) Defs(path string) []GlobalSite {
+	return g.defs[path]
+}
+
+// Reads returns every site that reads the given path.
+func (g *GlobalIndex) Reads(path string) []GlobalSite {
+	return g.reads[path]
+}
+
+// IsDefined returns whether the given path is assigned anywhere in the environment.
+func (g *GlobalIndex) IsDefined(path string) bool {
+	return len(g.defs[path]) > 0
+}
+
+// FileDefs returns every assignment site in the given file.
+func (g *GlobalIndex) FileDefs(uri protocol.URI) []GlobalSite {
+	return g.files[uri].defs
+}
+
+// FileReads returns every read site in the given file.
+func (g *GlobalIndex) FileReads(uri protocol.URI) []GlobalSite {
+	return g.files[uri].reads
+}
+
+// Search returns the first assignment site of every path that contains the query, case-insensitively. Results are
+// sorted by path.
+func (g *GlobalIndex) Search(query string) []GlobalSite {
+	query = strings.ToLower(query)
+	paths := []string{}
+	for path := range g.defs {
+		if strings.Contains(strings.ToLower(path), query) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	results := make([]GlobalSite, 0, len(paths))
+	for _, path := range paths {
+		results = append(results, g.defs[path][0])
+	}
+	return results
+}
+
+// GlobalPath returns the dotted path of the given expression if it is a global or a chain of constant field
+// accesses rooted at a global.
+func GlobalPath(expr ast.Expression, info *Info) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		sym := info.SymbolOf(expr)
+		if sym == nil || sym.Kind != SymbolGlobal {
+			return "", false
+		}
+		return sym.Name, true
+	case *ast.IndexExpression:
+		prefix, ok := GlobalPath(expr.Prefix, info)
+		if !ok {
+			return "", false
+		}
+		key, ok := FieldKey(expr)
+		if !ok {
+			return "", false
+		}
+		return prefix + "." + key, true
+	}
+	return "", false
+}
+
+// FieldKey returns the constant field name of an index expression, such as `b` in `a.b`, `a:b`, or `a["b"]`.
+func FieldKey(ie *ast.IndexExpression) (string, bool) {
+	switch inner := ie.Inner.(type) {
+	case *ast.Identifier:
+		if ie.LeftIndexer.Type() != token.LBRACK {
+			return inner.Token.Literal, true
+		}
+	default:
+		// Keys such as `t["a" .. "b"]` are folded, but keys that refer to locals need an Info to be resolved.
+		return ConstantString(inner, nil)
+	}
+	return "", false
+}
+
+// globalSiteRange returns the range of the final path component of a global site.
+func globalSiteRange(node ast.Node) token.Range {
+	if ie, ok := node.(*ast.IndexExpression); ok && !ast.IsNil(ie.Inner) {
+		return ast.Range(ie.Inner)
+	}
+	return ast.Range(node)
+}
+
+func collectGlobals(file *ast.File, info *Info) fileGlobals {
+	fg := fileGlobals{}
+	targets := map[ast.Node]bool{}
+	addDef := func(target ast.Expression, value ast.Node, stmt ast.Statement) {
+		path, ok := GlobalPath(target, info)
+		if !ok {
+			return
+		}
+		targets[target] = true
+		fg.defs = append(fg.defs, GlobalSite{
+			URI:   file.URI,
+			Path:  path,
+			Node:  target,
+			Range: globalSiteRange(target),
+			Value: value,
+			Stmt:  stmt,
+		})
+	}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignmentStatement:
+			for i, pair := range n.Vars.Pairs {
+				var value ast.Node
+				if i < len(n.Exps.Pairs) {
+					value = n.Exps.Pairs[i].Node
+				}
+				addDef(pair.Node, value, n)
+			}
+		case *ast.FunctionStatement:
+			if n.LocalTok == nil {
+				addDef(n.Name, n, n)
+			}
+		}
+		return true
+	})
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		expr, ok := n.(ast.Expression)
+		if !ok || targets[n] {
+			return true
+		}
+		if path, ok := GlobalPath(expr, info); ok {
+			fg.reads = append(fg.reads, GlobalSite{URI: file.URI, Path: path, Node: n, Range: globalSiteRange(n)})
+		}
+		return true
+	})
+	return fg
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/raiguard/luapls/lua/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func indexSource(g *GlobalIndex, uri string, src string) {
+	file := parser.New(src).ParseFile()
+	file.URI = uri
+	info := NewInfo()
+	Bind(&file, info)
+	g.Update(&file, info)
+}
+
+func TestGlobalIndex(t *testing.T) {
+	g := NewGlobalIndex()
+	indexSource(g, "file:///a.lua", `MyMod = {}
+MyMod.util = {}
+function MyMod.util.foo() end
+local x = 1`)
+	indexSource(g, "file:///b.lua", `local y = MyMod.util.foo()
+MyMod["bar"] = y`)
+
+	assert.True(t, g.IsDefined("MyMod"))
+	assert.True(t, g.IsDefined("MyMod.util.foo"))
+	assert.True(t, g.IsDefined("MyMod.bar"))
+	assert.False(t, g.IsDefined("x"))
+	assert.Len(t, g.Reads("MyMod.util.foo"), 1)
+	assert.Len(t, g.Reads("MyMod"), 4)
+	assert.Len(t, g.Search("util"), 2)
+
+	indexSource(g, "file:///a.lua", `MyMod = {}`)
+	assert.False(t, g.IsDefined("MyMod.util.foo"))
+	assert.Len(t, g.Reads("MyMod.util.foo"), 1)
+
+	g.Remove("file:///b.lua")
+	assert.False(t, g.IsDefined("MyMod.bar"))
+	assert.Empty(t, g.Reads("MyMod"))
+}
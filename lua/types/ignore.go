@@ -0,0 +1,140 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raiguard/luapls/util"
+)
+
+// ignoreRule is a pattern of a `.gitignore` file or of Environment.Ignore.
+type ignoreRule struct {
+	base    string // The slash-separated directory that the pattern is relative to, relative to the root.
+	pattern string // A pattern for util.MatchGlob, relative to base.
+	negate  bool   // Whether matching paths are included again.
+	dirOnly bool   // Whether the pattern only matches directories.
+}
+
+// ignoreRules decides which files and directories under a root directory are not loaded, according to the
+// `.gitignore` files of the root and its subdirectories and the configured patterns. Like git, a later rule takes
+// precedence over an earlier one, and the rules of a subdirectory over those of its parents.
+type ignoreRules struct {
+	root  string
+	rules []ignoreRule
+}
+
+// newIgnoreRules returns the rules for a root directory with the given patterns, which have the syntax of lines of a
+// `.gitignore` file at the root. The `.gitignore` files are read by readGitignore as the directories are visited.
+func newIgnoreRules(root string, patterns []string) *ignoreRules {
+	r := &ignoreRules{root: root}
+	for _, pattern := range patterns {
+		r.add("", pattern)
+	}
+	return r
+}
+
+// add adds a line of a `.gitignore` file in the given directory. Blank lines and comments are skipped.
+func (r *ignoreRules) add(base string, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// Patterns without a slash match at any depth, and the others are relative to the directory of the file.
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	if line == "" {
+		return
+	}
+	rule.pattern = line
+	r.rules = append(r.rules, rule)
+}
+
+// readGitignore adds the rules of the `.gitignore` file in the directory, if it has one.
+func (r *ignoreRules) readGitignore(dir string) {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	base, ok := r.rel(dir)
+	if !ok {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		r.add(base, line)
+	}
+}
+
+// ignored returns whether the file or directory at the path is ignored. The `.git` directory is always ignored.
+func (r *ignoreRules) ignored(path string, isDir bool) bool {
+	if isDir && filepath.Base(path) == ".git" {
+		return true
+	}
+	rel, ok := r.rel(path)
+	if !ok || rel == "" {
+		return false
+	}
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if util.MatchGlob(rule.pattern, name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// rel returns the slash-separated path relative to the root, which is empty for the root itself, and whether the
+// path is in the root directory.
+func (r *ignoreRules) rel(path string) (string, bool) {
+	rel, err := filepath.Rel(r.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// isIgnored returns whether the path in the root directory is ignored, either because it is or because one of the
+// directories that contain it is. The `.gitignore` files of those directories are read to decide.
+func (e *Environment) isIgnored(root string, path string) bool {
+	r := newIgnoreRules(root, e.Ignore)
+	rel, ok := r.rel(path)
+	if !ok || rel == "" {
+		return false
+	}
+	dir := root
+	r.readGitignore(dir)
+	segments := strings.Split(rel, "/")
+	for _, segment := range segments[:len(segments)-1] {
+		dir = filepath.Join(dir, segment)
+		if r.ignored(dir, true) {
+			return true
+		}
+		r.readGitignore(dir)
+	}
+	return r.ignored(path, false)
+}
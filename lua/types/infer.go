@@ -0,0 +1,680 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// literalType returns the type of a literal expression, or nil if the expression is not a literal.
+func literalType(expr ast.Expression) Type {
+	switch expr.(type) {
+	case *ast.BooleanLiteral:
+		return &Boolean{}
+	case *ast.FunctionExpression:
+		return &Function{}
+	case *ast.NilLiteral:
+		return &Nil{}
+	case *ast.NumberLiteral:
+		return &Number{}
+	case *ast.StringLiteral:
+		return &String{}
+	case *ast.TableLiteral:
+		return &Table{}
+	}
+	return nil
+}
+
+// inferrer computes the types of expressions and local symbols in a single file. Inference is flow-insensitive:
+// a local's type is the union of every value assigned to it, while each use records the type that the local had at
+// that point in the file.
+type inferrer struct {
+	env      *Environment
+	file     *ast.File
+	info     *Info
+	returns  [][]Type          // The return types collected for each enclosing function.
+	varargs  []Type            // The type of each value of `...` in each enclosing function.
+	narrowed []narrowing       // The narrowings in effect for each enclosing block and condition, innermost last.
+	handlers map[ast.Node]Type // The event data that the first parameter of each event handler receives.
+}
+
+func (e *Environment) infer(file *ast.File, info *Info) {
+	// The arguments of a chunk are whatever it was loaded with.
+	in := inferrer{env: e, file: file, info: info, varargs: []Type{&Any{}}, handlers: map[ast.Node]Type{}}
+	if file.Block != nil {
+		in.block(file.Block)
+	}
+}
+
+func (in *inferrer) block(block *ast.Block) {
+	// Narrowings made by the statements of the block, such as `assert(x)`, last until the end of the block.
+	in.narrow(narrowing{}, func() {
+		for _, pair := range block.Pairs {
+			in.stmt(pair.Node)
+		}
+	})
+}
+
+func (in *inferrer) stmt(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.AssignmentStatement:
+		types := in.exprList(&stmt.Exps, len(stmt.Vars.Pairs))
+		doc := in.info.Docs[stmt]
+		in.declareEnum(doc, types[0])
+		for i, pair := range stmt.Vars.Pairs {
+			if ident, ok := pair.Node.(*ast.Identifier); ok {
+				if sym := in.info.Uses[ident]; sym != nil {
+					if annotated := in.annotatedType(doc, i, types[i]); annotated != nil {
+						sym.Annotated = annotated
+						sym.Type = annotated
+					}
+				}
+			}
+			in.assign(pair.Node, types[i])
+		}
+	case *ast.DoStatement:
+		in.block(&stmt.Body)
+	case *ast.ForStatement:
+		in.expr(stmt.Start.Node)
+		in.expr(stmt.Finish.Node)
+		if stmt.Step != nil {
+			in.expr(stmt.Step.Node)
+		}
+		if sym := in.info.Defs[stmt.Name]; sym != nil {
+			sym.Type = &Number{}
+		}
+		in.block(&stmt.Body)
+	case *ast.ForInStatement:
+		vars := in.iteratorTypes(&stmt.Exps, in.exprList(&stmt.Exps, 0))
+		for i, pair := range stmt.Names.Pairs {
+			if i < len(vars) {
+				in.widen(in.info.Defs[pair.Node], vars[i])
+			}
+		}
+		in.block(&stmt.Body)
+	case *ast.FunctionCall:
+		in.expr(stmt)
+		// `assert(x)` raises an error unless x is truthy.
+		if in.builtinName(stmt.Name) == "assert" && len(stmt.Args.Pairs) > 0 {
+			in.refine(in.narrowIf(stmt.Args.Pairs[0].Node, true))
+		}
+	case *ast.FunctionStatement:
+		fn := &Function{}
+		if ident, ok := stmt.Name.(*ast.Identifier); ok && stmt.LocalTok != nil {
+			// Assign before inferring the body so that recursive calls can see the function.
+			in.widen(in.info.Defs[ident], fn)
+		} else {
+			in.assign(stmt.Name, fn)
+		}
+		in.function(fn, stmt, &stmt.Params, &stmt.Body)
+	case *ast.IfStatement:
+		// Each clause is only reached if the conditions before it were false.
+		otherwise := narrowing{}
+		exits := true
+		in.narrow(otherwise, func() {
+			for _, clause := range stmt.Clauses {
+				if ast.IsNil(clause.Condition) {
+					in.block(&clause.Body)
+					exits = false
+					continue
+				}
+				in.expr(clause.Condition)
+				in.narrow(in.narrowIf(clause.Condition, true), func() {
+					in.block(&clause.Body)
+				})
+				exits = exits && Exits(&clause.Body)
+				for sym, typ := range in.narrowIf(clause.Condition, false) {
+					otherwise[sym] = typ
+				}
+			}
+		})
+		// `if not x then return end` narrows x for the rest of the block.
+		if exits {
+			in.refine(otherwise)
+		}
+	case *ast.LocalStatement:
+		var types []Type
+		if stmt.Exps != nil {
+			types = in.exprList(stmt.Exps, len(stmt.Names.Pairs))
+		}
+		doc := in.info.Docs[stmt]
+		if len(types) > 0 {
+			in.declareEnum(doc, types[0])
+		}
+		for i, pair := range stmt.Names.Pairs {
+			typ := Type(&Nil{})
+			if i < len(types) {
+				typ = types[i]
+			}
+			sym := in.info.Defs[pair.Node]
+			if annotated := in.annotatedType(doc, i, typ); annotated != nil && sym != nil {
+				sym.Annotated = annotated
+				sym.Type = annotated
+			}
+			in.widen(sym, typ)
+		}
+	case *ast.RepeatStatement:
+		in.block(&stmt.Body)
+		in.expr(stmt.Condition)
+	case *ast.ReturnStatement:
+		var typ Type = &Nil{}
+		if stmt.Exps != nil {
+			tuple := in.exprTuple(stmt.Exps)
+			typ = NewTuple(tuple.Types, tuple.Rest)
+		}
+		if len(in.returns) > 0 {
+			in.returns[len(in.returns)-1] = append(in.returns[len(in.returns)-1], typ)
+		}
+	case *ast.WhileStatement:
+		in.expr(stmt.Condition)
+		in.narrow(in.narrowIf(stmt.Condition, true), func() {
+			in.block(&stmt.Body)
+		})
+	}
+}
+
+// widen adds the given type to the set of types that the symbol may hold.
+func (in *inferrer) widen(sym *Symbol, typ Type) {
+	if sym == nil || typ == nil || sym.Annotated != nil {
+		return
+	}
+	sym.Type = NewUnion(sym.Type, typ)
+}
+
+// assign records the assignment of a value of the given type to the given target expression.
+func (in *inferrer) assign(target ast.Expression, typ Type) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		if sym := in.info.Uses[target]; sym != nil {
+			in.widen(sym, typ)
+			in.reassign(sym, typ)
+		}
+		in.info.Types[target] = typ
+	case *ast.IndexExpression:
+		prefix := in.expr(target.Prefix)
+		if target.LeftIndexer.Type() == token.LBRACK {
+			in.expr(target.Inner)
+		}
+		key, ok := in.fieldKey(target)
+		if !ok {
+			return
+		}
+		// Assigning to a field of a table that we know the shape of adds the field to that table.
+		switch prefix := Resolve(prefix).(type) {
+		case *Named:
+			prefix.SetField(NameAndType{Name: key, Def: target.Inner, Type: typ, Loc: nodeLocation(in.file.URI, target.Inner)})
+		case *Table:
+			prefix.SetField(NameAndType{Name: key, Def: target.Inner, Type: typ, Loc: nodeLocation(in.file.URI, target.Inner)})
+		}
+	}
+}
+
+// fieldKey returns the constant field name of an index expression, including keys that refer to constant locals,
+// such as `t[KEY]`.
+func (in *inferrer) fieldKey(ie *ast.IndexExpression) (string, bool) {
+	if key, ok := FieldKey(ie); ok {
+		return key, true
+	}
+	if ie.LeftIndexer.Type() != token.LBRACK {
+		return "", false
+	}
+	return ConstantString(ie.Inner, in.info)
+}
+
+// exprList infers each expression in the list and returns at least n types. Every value of a call or `...` at the
+// end of the list is included, and missing values are nil.
+func (in *inferrer) exprList(exps *ast.Punctuated[ast.Expression], n int) []Type {
+	tuple := in.exprTuple(exps)
+	types := tuple.Types
+	for len(types) < n {
+		types = append(types, At(tuple, len(types)))
+	}
+	return types
+}
+
+// exprTuple infers each expression in the list and returns the values that the list produces. Every expression but
+// the last is truncated to its first value.
+func (in *inferrer) exprTuple(exps *ast.Punctuated[ast.Expression]) *Tuple {
+	tuple := &Tuple{Types: make([]Type, 0, len(exps.Pairs))}
+	for i, pair := range exps.Pairs {
+		if i < len(exps.Pairs)-1 {
+			tuple.Types = append(tuple.Types, in.expr(pair.Node))
+			continue
+		}
+		types, rest := tupleValues(in.exprValues(pair.Node))
+		tuple.Types = append(tuple.Types, types...)
+		tuple.Rest = rest
+	}
+	return tuple
+}
+
+// expr infers the type of an expression, truncated to its first value.
+func (in *inferrer) expr(expr ast.Expression) Type {
+	return First(in.exprValues(expr))
+}
+
+// exprValues infers the type of an expression, keeping every value that it produces if it is a call or `...`. The
+// recorded type of the expression is its first value.
+func (in *inferrer) exprValues(expr ast.Expression) Type {
+	if ast.IsNil(expr) {
+		return &Unknown{}
+	}
+	typ := in.exprUncached(expr)
+	if typ == nil {
+		typ = &Unknown{}
+	}
+	in.info.Types[expr] = First(typ)
+	return typ
+}
+
+func (in *inferrer) exprUncached(expr ast.Expression) Type {
+	switch expr := expr.(type) {
+	case *ast.FunctionCall:
+		return in.call(expr)
+	case *ast.FunctionExpression:
+		fn := &Function{}
+		in.function(fn, expr, &expr.Params, &expr.Body)
+		return fn
+	case *ast.Identifier:
+		sym := in.info.Uses[expr]
+		if sym == nil {
+			return nil
+		}
+		return in.symbolType(sym)
+	case *ast.IndexExpression:
+		prefix := in.expr(expr.Prefix)
+		if expr.LeftIndexer.Type() == token.LBRACK {
+			in.expr(expr.Inner)
+		}
+		key, ok := in.fieldKey(expr)
+		switch prefix := Resolve(prefix).(type) {
+		case *Named:
+			if field := prefix.Field(key); ok && field != nil {
+				return field.Type
+			}
+		case *Table:
+			if field := prefix.Field(key); ok && field != nil {
+				return field.Type
+			}
+			return prefix.Value
+		}
+		return nil
+	case *ast.InfixExpression:
+		left := in.expr(expr.Left)
+		var right Type
+		switch expr.Operator.Type() {
+		case token.AND, token.OR:
+			// The right operand is only evaluated if the left one is truthy for `and`, or falsy for `or`.
+			in.narrow(in.narrowIf(expr.Left, expr.Operator.Type() == token.AND), func() {
+				right = in.expr(expr.Right)
+			})
+		default:
+			right = in.expr(expr.Right)
+		}
+		if typ := operatorResult(expr.Operator.Type(), left, right); typ != nil {
+			return typ
+		}
+		switch expr.Operator.Type() {
+		case token.PLUS, token.MINUS, token.MUL, token.SLASH, token.MOD, token.POW:
+			return &Number{}
+		case token.CONCAT:
+			return &String{}
+		case token.EQUAL, token.NEQ, token.LT, token.LEQ, token.GT, token.GEQ:
+			return &Boolean{}
+		case token.AND:
+			return right
+		case token.OR:
+			return NewUnion(RemoveNil(left), right)
+		}
+		return nil
+	case *ast.PrefixExpression:
+		operand := in.expr(expr.Right)
+		if name, ok := unaryMetamethods[expr.Operator.Type()]; ok {
+			if fn := metamethod(operand, name); fn != nil {
+				return returnOf(fn)
+			}
+		}
+		switch expr.Operator.Type() {
+		case token.NOT:
+			return &Boolean{}
+		case token.MINUS, token.LEN:
+			return &Number{}
+		}
+		return nil
+	case *ast.TableLiteral:
+		tbl := &Table{}
+		for i, pair := range expr.Fields.Pairs {
+			switch field := pair.Node.(type) {
+			case *ast.TableArrayField:
+				tbl.Key = &Number{}
+				if i < len(expr.Fields.Pairs)-1 {
+					tbl.Value = NewUnion(tbl.Value, in.expr(field.Expr))
+					continue
+				}
+				// The last value is expanded into every value that it produces.
+				types, rest := tupleValues(in.exprValues(field.Expr))
+				tbl.Value = NewUnion(append(append([]Type{tbl.Value}, types...), rest)...)
+			case *ast.TableExpressionKeyField:
+				in.expr(field.Name)
+				typ := in.expr(field.Expr)
+				if key, ok := ConstantString(field.Name, in.info); ok {
+					tbl.SetField(NameAndType{Name: key, Def: field.Name, Type: typ, Loc: nodeLocation(in.file.URI, field.Name)})
+				}
+			case *ast.TableSimpleKeyField:
+				tbl.SetField(NameAndType{Name: field.Name.Token.Literal, Def: &field.Name, Type: in.expr(field.Expr), Loc: nodeLocation(in.file.URI, &field.Name)})
+			}
+		}
+		return tbl
+	case *ast.Vararg:
+		return &Tuple{Rest: in.varargs[len(in.varargs)-1]}
+	}
+	return literalType(expr)
+}
+
+func (in *inferrer) call(fc *ast.FunctionCall) Type {
+	callee := in.expr(fc.Name)
+	in.eventHandler(fc)
+	tuple := in.exprTuple(&fc.Args)
+	args := tuple.Types
+	if req := in.env.Modules.RequireOf(in.file.URI, fc); req != nil {
+		return in.env.moduleType(req.Target)
+	}
+	switch in.builtinName(fc.Name) {
+	case "setmetatable":
+		if len(args) >= 2 {
+			return in.setMetatable(args[0], args[1])
+		}
+	case "select":
+		if len(fc.Args.Pairs) > 0 {
+			if lit, ok := fc.Args.Pairs[0].Node.(*ast.StringLiteral); ok {
+				if value, _ := StringValue(lit); value == "#" {
+					return &Number{}
+				}
+			}
+		}
+		if len(args) > 1 || tuple.Rest != nil {
+			// Which values are selected is not known, so each one may be any of them.
+			return &Tuple{Rest: NewUnion(append(args[min(1, len(args)):], tuple.Rest)...)}
+		}
+	case "pcall", "xpcall":
+		var ret Type = &Unknown{}
+		if len(args) > 0 {
+			if fn, ok := Resolve(args[0]).(*Function); ok {
+				ret = returnOf(fn)
+			}
+		}
+		// When the call fails, the first value is replaced by an error message and the rest are missing.
+		types, rest := tupleValues(ret)
+		values := []Type{&Boolean{}, NewUnion(At(ret, 0), &String{})}
+		for i := 1; i < len(types); i++ {
+			values = append(values, NewUnion(types[i], &Nil{}))
+		}
+		return NewTuple(values, rest)
+	case "table.pack":
+		tbl := &Table{Key: &Number{}, Value: NewUnion(append(args, tuple.Rest)...)}
+		tbl.SetField(NameAndType{Name: "n", Type: &Number{}})
+		return tbl
+	}
+	if call := metamethod(callee, "__call"); call != nil {
+		// The called value is passed as the first argument.
+		return returnOf(call)
+	}
+	if fn, ok := Resolve(callee).(*Function); ok {
+		// Overloads are selected by the values of constant arguments, such as the name of an event.
+		literals := append([]Type{}, args...)
+		for i, pair := range fc.Args.Pairs {
+			if lit := Constant(pair.Node, in.info); lit != nil && i < len(literals) {
+				literals[i] = lit
+			}
+		}
+		if receiver := Receiver(fc, fn, in.info); receiver != nil {
+			args = append([]Type{receiver}, args...)
+			literals = append([]Type{receiver}, literals...)
+		}
+		fn = SelectOverload(fn, literals)
+		in.expectArgs(fc, fn)
+		if fn.Return == nil {
+			return &Nil{}
+		}
+		if len(fn.TypeParams) > 0 {
+			return instantiate(fn, args)
+		}
+		return fn.Return
+	}
+	return nil
+}
+
+// setMetatable records the metatable given to a table or class table by `setmetatable(tbl, mt)`, and returns the
+// table.
+func (in *inferrer) setMetatable(tbl Type, mt Type) Type {
+	switch Resolve(mt).(type) {
+	case *Named, *Table:
+	default:
+		return tbl
+	}
+	switch t := tbl.(type) {
+	case *Named:
+		t.SetMetatable(mt, in.file.URI)
+	case *Table:
+		t.Metatable = mt
+	}
+	return tbl
+}
+
+// function infers the parameters and return type of a function body into fn.
+func (in *inferrer) function(fn *Function, node ast.Node, params *ast.Punctuated[*ast.Identifier], body *ast.Block) {
+	doc := in.info.Docs[node]
+	resolver, typeParams := in.env.genericResolver(doc, &in.info.Diagnostics)
+	in.info.Functions[node] = fn
+	if len(typeParams) > 0 {
+		fn.TypeParams = typeParams
+	}
+	fn.Params = []NameAndType{}
+	var vararg *ast.Unit
+	switch node := node.(type) {
+	case *ast.FunctionExpression:
+		vararg = node.Vararg
+	case *ast.FunctionStatement:
+		vararg = node.Vararg
+		if IsMethod(node) {
+			fn.Params = append(fn.Params, NameAndType{Name: "self", Type: in.selfType(node, doc, resolver)})
+		}
+	}
+	for i, pair := range params.Pairs {
+		var typ Type
+		if sym := in.info.Defs[pair.Node]; sym != nil {
+			if param := doc.Param(sym.Name); param != nil {
+				sym.Annotated = resolver.resolve(param.Type)
+				if param.Optional {
+					sym.Annotated = NewUnion(sym.Annotated, &Nil{})
+				}
+				sym.Type = sym.Annotated
+			} else if data := in.handlers[node]; data != nil && i == 0 {
+				sym.Type = data
+			}
+			typ = sym.Type
+		}
+		fn.Params = append(fn.Params, NameAndType{Name: pair.Node.Token.Literal, Def: pair.Node, Type: typ, Loc: nodeLocation(in.file.URI, pair.Node)})
+	}
+	var varargType Type = &Any{}
+	if vararg != nil {
+		if param := doc.Param("..."); param != nil {
+			varargType = resolver.resolve(param.Type)
+		}
+		fn.Params = append(fn.Params, NameAndType{Name: "...", Type: varargType})
+	}
+	in.returns = append(in.returns, []Type{})
+	in.varargs = append(in.varargs, varargType)
+	in.block(body)
+	returns := in.returns[len(in.returns)-1]
+	in.returns = in.returns[:len(in.returns)-1]
+	in.varargs = in.varargs[:len(in.varargs)-1]
+	fn.Return = unionTuples(returns)
+	if in.info.Meta != nil {
+		// Functions in definition files are stubs, so their bodies say nothing about what they return.
+		fn.Return = &Unknown{}
+	}
+	if values := doc.Returns(); len(values) > 0 {
+		types := make([]Type, 0, len(values))
+		var rest Type
+		for _, value := range values {
+			if value.Name == "..." {
+				rest = resolver.resolve(value.Type)
+				break
+			}
+			types = append(types, resolver.resolve(value.Type))
+		}
+		fn.Return = NewTuple(types, rest)
+	}
+	for _, expr := range doc.Overloads() {
+		if overload, ok := resolver.resolve(expr).(*Function); ok {
+			overload.TypeParams = fn.TypeParams
+			fn.Overloads = append(fn.Overloads, overload)
+		}
+	}
+}
+
+// selfType returns the type of the implicit `self` parameter of a method, which is the table that the method is
+// defined on unless it is annotated with `---@param self Type`. The type is given to the `self` symbol as well.
+func (in *inferrer) selfType(node *ast.FunctionStatement, doc *annotation.Doc, resolver *typeResolver) Type {
+	var typ Type = &Unknown{}
+	if ie, ok := node.Name.(*ast.IndexExpression); ok {
+		typ = in.info.TypeOf(ie.Prefix)
+	}
+	var sym *Symbol
+	if scope := in.info.Scopes[node]; scope != nil && len(scope.Symbols) > 0 && scope.Symbols[0].Decl == nil {
+		sym = scope.Symbols[0]
+	}
+	if param := doc.Param("self"); param != nil {
+		typ = resolver.resolve(param.Type)
+		if sym != nil {
+			sym.Annotated = typ
+		}
+	}
+	if sym != nil {
+		sym.Type = typ
+	}
+	return typ
+}
+
+// annotatedType returns the type that a doc comment gives to the i-th name of a declaration, or nil if it does not
+// give one. A class annotation takes on the fields and metatable of the table that the name is initialized with.
+func (in *inferrer) annotatedType(doc *annotation.Doc, i int, initial Type) Type {
+	if doc == nil {
+		return nil
+	}
+	if typ := doc.Type(); typ != nil && i < len(typ.Types) {
+		return in.env.resolveType(typ.Types[i], &in.info.Diagnostics)
+	}
+	class := doc.Class()
+	if class == nil || i > 0 {
+		return nil
+	}
+	named, ok := in.env.Types[class.Name].(*Named)
+	if !ok {
+		return nil
+	}
+	if tbl, ok := initial.(*Table); ok {
+		for _, field := range tbl.Fields {
+			field.Loc = nodeLocation(in.file.URI, field.Def)
+			named.SetField(field)
+		}
+		if tbl.Metatable != nil {
+			named.SetMetatable(tbl.Metatable, in.file.URI)
+		}
+	}
+	return named
+}
+
+// checkedInfo returns the analysis results for the given file, checking it first if needed. It returns nil if the
+// file is currently being checked, to break cycles.
+func (e *Environment) checkedInfo(uri protocol.URI) *Info {
+	file := e.Files[uri]
+	if file == nil {
+		return nil
+	}
+	if info := e.Info[uri]; info != nil {
+		return info
+	}
+	if e.checking[uri] {
+		return nil
+	}
+	e.CheckFilePhase3(file)
+	return e.Info[uri]
+}
+
+// moduleType returns the type of the value returned by the given module, checking it first if needed.
+func (e *Environment) moduleType(uri string) Type {
+	info := e.checkedInfo(uri)
+	if info == nil {
+		return nil
+	}
+	ret := ModuleReturn(e.Files[uri])
+	if ret == nil {
+		return nil
+	}
+	return info.TypeOf(ret)
+}
+
+// GlobalType returns the combined type of every value assigned to the given global outside of the excluded file,
+// checking the files that assign it if needed. If any of those files annotate the global, only the annotated types
+// are used.
+func (e *Environment) GlobalType(name string, exclude protocol.URI) Type {
+	inferred, annotated := []Type{}, []Type{}
+	seen := map[protocol.URI]bool{exclude: true}
+	for _, site := range e.Globals.Defs(name) {
+		if seen[site.URI] {
+			continue
+		}
+		seen[site.URI] = true
+		info := e.checkedInfo(site.URI)
+		if info == nil {
+			continue
+		}
+		if sym := info.Globals[name]; sym != nil && sym.Annotated != nil {
+			annotated = append(annotated, sym.Annotated)
+		} else if sym != nil {
+			inferred = append(inferred, sym.Type)
+		}
+	}
+	if typ := e.hostGlobalType(name); typ != nil {
+		annotated = append(annotated, typ)
+	}
+	// Annotations are deliberate, so they take precedence over whatever values happen to be assigned elsewhere.
+	if len(annotated) > 0 {
+		return NewUnion(annotated...)
+	}
+	return NewUnion(inferred...)
+}
+
+// hostGlobalType returns the configured type of a global that is provided by the host application, or nil if it has
+// none or it is invalid.
+func (e *Environment) hostGlobalType(name string) Type {
+	src := e.HostGlobals[name]
+	if src == "" {
+		return nil
+	}
+	expr, diagnostics := annotation.ParseType(src)
+	if expr == nil || len(diagnostics) > 0 {
+		return nil
+	}
+	return e.resolveType(expr, &diagnostics)
+}
+
+// RemoveNil returns the given type with nil removed from it.
+func RemoveNil(typ Type) Type {
+	switch typ := typ.(type) {
+	case *Nil:
+		return nil
+	case *Union:
+		members := []Type{}
+		for _, member := range typ.Types {
+			if _, ok := member.(*Nil); !ok {
+				members = append(members, member)
+			}
+		}
+		return NewUnion(members...)
+	}
+	return typ
+}
@@ -0,0 +1,307 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/raiguard/luapls/lua/ast"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkSource(t *testing.T, src string) (*ast.File, *Info) {
+	env := NewEnvironment()
+	file := env.AddTransientFile("file:///test.lua", src)
+	require.NotNil(t, file)
+	env.CheckFile(file)
+	return file, env.Info[file.URI]
+}
+
+func symbolType(t *testing.T, file *ast.File, info *Info, src string, name string) string {
+	sym := info.SymbolOf(identAt(t, file, src, name, 0))
+	require.NotNil(t, sym, "no symbol for %s", name)
+	return formatType(sym.Type, 0)
+}
+
+func TestInferLocals(t *testing.T) {
+	src := `local s = "foo"
+local n, b = 1 + 2, not s
+local c = s .. n
+local maybe
+maybe = 5
+local function add(a, b2) return a + b2 end
+local sum = add(1, 2)
+local t = { x = 1, y = "two" }
+t.z = true
+local tx = t.x`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "string", symbolType(t, file, info, src, "s"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "n"))
+	assert.Equal(t, "boolean", symbolType(t, file, info, src, "b"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "c"))
+	assert.Equal(t, "nil|number", symbolType(t, file, info, src, "maybe"))
+	assert.Equal(t, "function(a: unknown, b2: unknown) → number", symbolType(t, file, info, src, "add"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "sum"))
+	assert.Equal(t, "{x: number, y: string, z: boolean}", symbolType(t, file, info, src, "t"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "tx"))
+}
+
+func TestInferSelfReferentialTable(t *testing.T) {
+	src := `local t = {}
+t.self = t`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "{self: {self: table}}", symbolType(t, file, info, src, "t"))
+}
+
+func TestInferMetamethods(t *testing.T) {
+	src := `---@class Vector
+---@field x number
+local Vector = {}
+Vector.__index = Vector
+
+---@return Vector
+function Vector.new() return setmetatable({}, Vector) end
+
+---@return Vector
+function Vector.__add(a, b) return a end
+
+---@return string
+function Vector.__unm(a) return "" end
+
+local Factory = setmetatable({}, { __call = function(cls) return 1 end })
+local Lazy = setmetatable({}, { __index = function(tbl, key) return "loaded" end })
+
+local v = Vector.new()
+local sum = v + v
+local neg = -v
+local made = Factory()
+local lazy = Lazy.anything
+local plain = 1 + 2`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "Vector", symbolType(t, file, info, src, "sum"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "neg"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "made"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "lazy"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "plain"))
+}
+
+func TestInferIterators(t *testing.T) {
+	src := `---@type table<string, number>
+local counts = {}
+---@type boolean[]
+local flags = {}
+local point = { x = 1, y = 2 }
+
+---@return fun(): string|nil
+local function words() end
+
+for name, count in pairs(counts) do end
+for index, flag in ipairs(flags) do end
+for key, coord in next, point do end
+for word in words() do end
+for capture in ("a=b"):gmatch("(%w+)") do end
+for other in string.gmatch("", "") do end`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "string", symbolType(t, file, info, src, "name"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "count"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "index"))
+	assert.Equal(t, "boolean", symbolType(t, file, info, src, "flag"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "key"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "coord"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "word"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "capture"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "other"))
+}
+
+func TestInferVarargs(t *testing.T) {
+	src := `---@param ... number
+local function sum(...)
+	local all = {...}
+	local first = ...
+	local count = select("#", ...)
+	local second = select(2, ...)
+	local packed = table.pack(...)
+	return all, first, count, second, packed
+end`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "number[]", symbolType(t, file, info, src, "all"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "first"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "count"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "second"))
+	assert.Equal(t, "{n: number}", symbolType(t, file, info, src, "packed"))
+	assert.Equal(t, "function(...: number) → number[], number, number, number, {n: number}", symbolType(t, file, info, src, "sum"))
+}
+
+func TestInferMultipleReturns(t *testing.T) {
+	src := `local function two()
+	if math.random() > 0.5 then
+		return 1, "two"
+	end
+	return 1
+end
+local a, b = two()
+local c, d = two(), true
+local list = { two() }
+local ok, err = pcall(function() end)
+---@return string, number ...
+local function annotated() end
+local s, n1, n2 = annotated()`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "function() → number, string|nil", symbolType(t, file, info, src, "two"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "a"))
+	assert.Equal(t, "string|nil", symbolType(t, file, info, src, "b"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "c"))
+	assert.Equal(t, "boolean", symbolType(t, file, info, src, "d"))
+	assert.Equal(t, "(number|string|nil)[]", symbolType(t, file, info, src, "list"))
+	assert.Equal(t, "boolean", symbolType(t, file, info, src, "ok"))
+	assert.Equal(t, "nil|string", symbolType(t, file, info, src, "err"))
+	assert.Equal(t, "function() → string, ...: number", symbolType(t, file, info, src, "annotated"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "s"))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "n2"))
+}
+
+func TestInferFunctions(t *testing.T) {
+	src := `local M = {}
+function M.concat(a, b) return a .. b end
+local cb = function(n) return n + 1 end`
+	file, info := checkSource(t, src)
+	stmt := file.Block.Pairs[1].Node.(*ast.FunctionStatement)
+	require.NotNil(t, info.Functions[stmt])
+	assert.Equal(t, "function(a: unknown, b: unknown) → string", formatType(info.Functions[stmt], 0))
+	fn := DeclaredFunction(file.Block.Pairs[2].Node)
+	require.NotNil(t, fn)
+	require.NotNil(t, info.Functions[fn])
+	assert.Equal(t, "function(n: unknown) → number", formatType(info.Functions[fn], 0))
+}
+
+func TestInferEventHandlers(t *testing.T) {
+	src := `---@class EventData
+---@field tick number
+
+---@class EventData.on_built_entity : EventData
+---@field created_entity string
+
+---@class EventData.on_tick : EventData
+
+---@enum defines.events
+local events = { on_built_entity = 0, on_tick = 1, on_unknown = 2 }
+
+local script = {}
+function script.on_event(event, handler) end
+
+script.on_event(events.on_built_entity, function(built) local entity = built.created_entity end)
+script.on_event({ events.on_built_entity, events.on_tick }, function(either) end)
+script.on_event(events.on_unknown, function(other) end)
+script.on_event("custom-input", function(data) end)`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "EventData.on_built_entity", symbolType(t, file, info, src, "built"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "entity"))
+	assert.Equal(t, "EventData.on_built_entity|EventData.on_tick", symbolType(t, file, info, src, "either"))
+	assert.Equal(t, "unknown", symbolType(t, file, info, src, "other"))
+	assert.Equal(t, "unknown", symbolType(t, file, info, src, "data"))
+}
+
+func TestInferNarrowing(t *testing.T) {
+	src := `---@param v string|number|nil
+---@param w any
+local function f(v, w)
+	if type(v) == "string" then
+		print(v)
+	elseif v ~= nil then
+		print(v)
+	else
+		print(v)
+	end
+	if type(w) == "table" then
+		print(w)
+	end
+	print(v and v)
+	assert(v)
+	print(v)
+	v = nil
+	print(v)
+end`
+	file, info := checkSource(t, src)
+	typeAt := func(name string, n int) string {
+		return formatType(info.TypeOf(identAt(t, file, src, name, n)), 0)
+	}
+	assert.Equal(t, "string", typeAt("v", 3))
+	assert.Equal(t, "number", typeAt("v", 5))
+	assert.Equal(t, "nil", typeAt("v", 6))
+	assert.Equal(t, "{}", typeAt("w", 3))
+	assert.Equal(t, "string|number", typeAt("v", 8))
+	assert.Equal(t, "string|number", typeAt("v", 10))
+	assert.Equal(t, "nil", typeAt("v", 12))
+	// The type of the parameter itself is not narrowed.
+	assert.Equal(t, "string|number|nil", formatType(info.SymbolOf(identAt(t, file, src, "v", 1)).Type, 0))
+}
+
+func TestInferSelf(t *testing.T) {
+	src := `---@class Counter
+---@field count number
+local Counter = {}
+
+function Counter:get()
+	return self.count
+end
+
+local M = { items = {} }
+function M:first()
+	return self.items
+end
+
+---@param self string
+function M:annotated()
+	return self
+end
+
+---@generic T
+---@param self T
+---@return T
+function Counter:clone() end
+
+local value = Counter:get()
+local clone = Counter:clone()`
+	file, info := checkSource(t, src)
+	assert.Equal(t, "Counter", formatType(info.TypeOf(identAt(t, file, src, "self", 0)), 0))
+	assert.Same(t, info.SymbolOf(identAt(t, file, src, "M", 0)).Type, info.TypeOf(identAt(t, file, src, "self", 1)))
+	assert.Equal(t, "string", formatType(info.TypeOf(identAt(t, file, src, "self", 3)), 0))
+	assert.Equal(t, "number", symbolType(t, file, info, src, "value"))
+	assert.Equal(t, "Counter", formatType(info.SymbolOf(identAt(t, file, src, "clone", 1)).Type, 0))
+}
+
+func TestInferRequire(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.lua": `local util = require("util")
+local result = util.foo()`,
+		"util.lua": `local M = {}
+function M.foo() return "foo" end
+return M`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	main := env.Files[uriOf(t, root, "main.lua")]
+	info := env.Info[main.URI]
+	src := `local util = require("util")
+local result = util.foo()`
+	assert.Equal(t, "string", symbolType(t, main, info, src, "result"))
+}
+
+func TestInferHostGlobals(t *testing.T) {
+	src := `---@class Api
+---@field version string
+
+local ok = log("message")
+local apiVersion = api.version
+local unknown = missing`
+	env := NewEnvironment()
+	env.HostGlobals = map[string]string{"log": "fun(msg: string): boolean", "api": "Api", "missing": "Missing"}
+	file := env.AddTransientFile("file:///test.lua", src)
+	require.NotNil(t, file)
+	env.CheckFile(file)
+	info := env.Info[file.URI]
+	assert.Equal(t, "boolean", symbolType(t, file, info, src, "ok"))
+	assert.Equal(t, "string", symbolType(t, file, info, src, "apiVersion"))
+	assert.Equal(t, "unknown", symbolType(t, file, info, src, "unknown"))
+}
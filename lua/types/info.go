@@ -0,0 +1,82 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/annotation"
+	"github.com/raiguard/luapls/lua/ast"
+)
+
+// Info holds the results of semantic analysis for a single file. It lives alongside the AST rather than inside of it
+// so that syntax nodes stay immutable and analysis results can be thrown away and recomputed at will.
+type Info struct {
+	// Types maps expressions to their inferred types.
+	Types map[ast.Expression]Type
+	// Defs maps identifiers to the symbols they declare.
+	Defs map[*ast.Identifier]*Symbol
+	// Uses maps identifiers to the symbols they reference.
+	Uses map[*ast.Identifier]*Symbol
+	// Functions maps function statements and expressions to their signatures.
+	Functions map[ast.Node]*Function
+
+	// Scope is the file's root scope.
+	Scope *Scope
+	// Scopes maps nodes that introduce a scope to that scope.
+	Scopes map[ast.Node]*Scope
+	// Symbols contains every local and parameter declared in the file, in declaration order.
+	Symbols []*Symbol
+	// Globals maps the name of each global that the file reads or writes to its symbol.
+	Globals map[string]*Symbol
+
+	// DocBlocks contains every doc comment block in the file, in order.
+	DocBlocks []*annotation.Doc
+	// Meta is the file's `---@meta` annotation, if it is a definition file. Definition files describe values that
+	// exist at runtime without implementing them, so they are exempt from diagnostics about their behavior.
+	Meta *annotation.Meta
+	// Docs maps statements and table fields to the doc comment block directly above them.
+	Docs map[ast.Node]*annotation.Doc
+	// Expected maps table literals that are passed where a class is expected to that class.
+	Expected map[*ast.TableLiteral]*Named
+
+	// Diagnostics contains problems found during analysis. Parse errors are stored on the file itself.
+	Diagnostics []ast.Diagnostic
+}
+
+func NewInfo() *Info {
+	return &Info{
+		Types: map[ast.Expression]Type{},
+		Defs:  map[*ast.Identifier]*Symbol{},
+		Uses:  map[*ast.Identifier]*Symbol{},
+
+		Functions: map[ast.Node]*Function{},
+
+		Scope:   newScope(nil, nil),
+		Scopes:  map[ast.Node]*Scope{},
+		Globals: map[string]*Symbol{},
+
+		Docs:     map[ast.Node]*annotation.Doc{},
+		Expected: map[*ast.TableLiteral]*Named{},
+
+		Diagnostics: []ast.Diagnostic{},
+	}
+}
+
+// TypeOf returns the inferred type of the given expression, or Unknown if it has not been inferred. Declaring
+// identifiers have the combined type of every value assigned to their symbol.
+func (i *Info) TypeOf(expr ast.Expression) Type {
+	if typ := i.Types[expr]; typ != nil {
+		return typ
+	}
+	if ident, ok := expr.(*ast.Identifier); ok {
+		if sym := i.Defs[ident]; sym != nil && sym.Type != nil {
+			return sym.Type
+		}
+	}
+	return &Unknown{}
+}
+
+// SymbolOf returns the symbol that the given identifier declares or references, or nil if it is unresolved.
+func (i *Info) SymbolOf(ident *ast.Identifier) *Symbol {
+	if sym := i.Defs[ident]; sym != nil {
+		return sym
+	}
+	return i.Uses[ident]
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// iteratorTypes returns the types of the variables of a generic `for` loop, given the expressions that it iterates
+// over and their types. The standard iterators are recognized by name, and other iterators are typed by what their
+// function returns. Variables whose type is not known are nil.
+func (in *inferrer) iteratorTypes(exps *ast.Punctuated[ast.Expression], types []Type) []Type {
+	if len(types) == 0 {
+		return nil
+	}
+	switch first := exps.Pairs[0].Node.(type) {
+	case *ast.FunctionCall:
+		args := first.Args.Pairs
+		switch in.builtinName(first.Name) {
+		case "pairs":
+			if len(args) > 0 {
+				key, value := elementTypes(in.info.TypeOf(args[0].Node))
+				return []Type{key, value}
+			}
+		case "ipairs":
+			if len(args) > 0 {
+				_, value := elementTypes(in.info.TypeOf(args[0].Node))
+				return []Type{&Number{}, value}
+			}
+		case "string.gmatch":
+			// Every capture is a string. The number of captures is not known, so the first few variables are typed.
+			return []Type{&String{}, &String{}, &String{}, &String{}}
+		}
+	case *ast.Identifier:
+		if in.builtinName(first) == "next" && len(types) > 1 {
+			key, value := elementTypes(types[1])
+			return []Type{key, value}
+		}
+	}
+	if fn, ok := Resolve(types[0]).(*Function); ok && fn.Return != nil {
+		// The loop ends when the iterator's first value is nil, so the first variable is never nil within the body.
+		values, _ := tupleValues(fn.Return)
+		return append([]Type{RemoveNil(values[0])}, values[1:]...)
+	}
+	return nil
+}
+
+// builtinName returns the name of the standard library function that the expression refers to, such as `pairs` or
+// `string.gmatch`, or an empty string if it does not refer to one. Method calls on strings, such as `s:gmatch(p)`,
+// are included.
+func (in *inferrer) builtinName(expr ast.Expression) string {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		if sym := in.info.Uses[expr]; sym == nil || sym.Kind == SymbolGlobal {
+			return expr.Token.Literal
+		}
+	case *ast.IndexExpression:
+		key, ok := FieldKey(expr)
+		if !ok {
+			return ""
+		}
+		if expr.LeftIndexer.Type() == token.COLON {
+			if isString(in.info.TypeOf(expr.Prefix)) {
+				return "string." + key
+			}
+			return ""
+		}
+		if prefix := in.builtinName(expr.Prefix); prefix != "" {
+			return prefix + "." + key
+		}
+	}
+	return ""
+}
+
+// isString returns whether the type is a string or a string literal.
+func isString(typ Type) bool {
+	switch typ := Resolve(RemoveNil(typ)).(type) {
+	case *String:
+		return true
+	case *Literal:
+		_, ok := typ.Base.(*String)
+		return ok
+	}
+	return false
+}
+
+// elementTypes returns the types of the keys and values of a table or class, for iterating over it with `pairs`.
+func elementTypes(typ Type) (Type, Type) {
+	switch typ := Resolve(RemoveNil(typ)).(type) {
+	case *Table:
+		keys, values := []Type{typ.Key}, []Type{typ.Value}
+		if len(typ.Fields) > 0 {
+			keys = append(keys, &String{})
+		}
+		for _, field := range typ.Fields {
+			values = append(values, field.Type)
+		}
+		return NewUnion(keys...), NewUnion(values...)
+	case *Named:
+		values := []Type{}
+		for _, field := range typ.AllFields() {
+			values = append(values, field.Type)
+		}
+		return &String{}, NewUnion(values...)
+	}
+	return nil, nil
+}
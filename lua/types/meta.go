@@ -0,0 +1,154 @@
+package types
+
+import "github.com/raiguard/luapls/lua/token"
+
+// operatorMetamethods maps the operators that may be overloaded to the names of their metamethods.
+var operatorMetamethods = map[token.TokenType]string{
+	token.PLUS:   "__add",
+	token.MINUS:  "__sub",
+	token.MUL:    "__mul",
+	token.SLASH:  "__div",
+	token.MOD:    "__mod",
+	token.POW:    "__pow",
+	token.CONCAT: "__concat",
+}
+
+// unaryMetamethods maps the unary operators that may be overloaded to the names of their metamethods.
+var unaryMetamethods = map[token.TokenType]string{
+	token.MINUS: "__unm",
+	token.LEN:   "__len",
+}
+
+// lookupField finds a field of a table or class, following parents and metatable `__index` chains. Types that have
+// already been searched are skipped so that cyclic chains terminate.
+func lookupField(typ Type, name string, seen map[Type]bool) *NameAndType {
+	typ = Resolve(typ)
+	if typ == nil || seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+	var metatable Type
+	switch typ := typ.(type) {
+	case *Named:
+		if field := findField(typ.Fields, name); field != nil {
+			return field
+		}
+		for _, parent := range typ.Parents() {
+			if field := lookupField(parent, name, seen); field != nil {
+				return field
+			}
+		}
+		metatable = typ.Metatable()
+	case *Table:
+		if field := findField(typ.Fields, name); field != nil {
+			return field
+		}
+		metatable = typ.Metatable
+	default:
+		return nil
+	}
+	index := rawField(metatable, "__index")
+	if index == nil {
+		return nil
+	}
+	if fn, ok := Resolve(index.Type).(*Function); ok {
+		// The field is whatever the function returns for its key.
+		return &NameAndType{Name: name, Def: index.Def, Type: First(returnOf(fn)), Loc: index.Loc}
+	}
+	return lookupField(index.Type, name, seen)
+}
+
+// rawField returns a field of a table or class without consulting its metatable, like `rawget`. The fields of the
+// parents of a class are included, since they are part of its declaration.
+func rawField(typ Type, name string) *NameAndType {
+	switch typ := Resolve(typ).(type) {
+	case *Named:
+		for _, class := range typ.ancestors() {
+			if field := findField(class.Fields, name); field != nil {
+				return field
+			}
+		}
+	case *Table:
+		return findField(typ.Fields, name)
+	}
+	return nil
+}
+
+// metamethod returns the function that implements the given metamethod for values of the given type, or nil if there
+// is none. Instances of a class are assumed to use the class as their metatable, which is the usual way of defining
+// classes, so the class's own fields are searched if it has no metatable of its own that defines the metamethod.
+func metamethod(typ Type, name string) *Function {
+	var field *NameAndType
+	switch typ := Resolve(typ).(type) {
+	case *Named:
+		if field = rawField(typ.Metatable(), name); field == nil {
+			field = rawField(typ, name)
+		}
+	case *Table:
+		field = rawField(typ.Metatable, name)
+	}
+	if field == nil {
+		return nil
+	}
+	fn, _ := Resolve(field.Type).(*Function)
+	return fn
+}
+
+// operatorResult returns the result of applying a binary operator through the metamethod of either operand, or nil
+// if neither operand overloads it.
+func operatorResult(op token.TokenType, left Type, right Type) Type {
+	name, ok := operatorMetamethods[op]
+	if !ok {
+		return nil
+	}
+	for _, operand := range []Type{left, right} {
+		if fn := metamethod(operand, name); fn != nil {
+			return returnOf(fn)
+		}
+	}
+	return nil
+}
+
+// returnOf returns the type that calling the function produces.
+func returnOf(fn *Function) Type {
+	if fn.Return == nil {
+		return &Nil{}
+	}
+	return fn.Return
+}
+
+// FieldsOf returns every field of a class or table type, including the fields that it inherits from its parents and
+// from its metatable's `__index`. Fields that are overridden are only included once.
+func FieldsOf(typ Type) []NameAndType {
+	fields := []NameAndType{}
+	present := map[string]bool{}
+	seen := map[Type]bool{}
+	for {
+		typ = Resolve(RemoveNil(typ))
+		if typ == nil || seen[typ] {
+			return fields
+		}
+		seen[typ] = true
+		var own []NameAndType
+		var metatable Type
+		switch t := typ.(type) {
+		case *Named:
+			own, metatable = t.AllFields(), t.Metatable()
+		case *Table:
+			own, metatable = t.Fields, t.Metatable
+		default:
+			return fields
+		}
+		for _, field := range own {
+			if !present[field.Name] {
+				present[field.Name] = true
+				fields = append(fields, field)
+			}
+		}
+		index := rawField(metatable, "__index")
+		if index == nil {
+			return fields
+		}
+		typ = index.Type
+	}
+}
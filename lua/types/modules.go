@@ -0,0 +1,357 @@
+package types
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	"github.com/raiguard/luapls/util"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// DefaultPackagePath mirrors the file-relative portion of Lua's default `package.path`.
+var DefaultPackagePath = []string{"?.lua", "?/init.lua"}
+
+// Require is a single `require("name")` call.
+type Require struct {
+	Call   *ast.FunctionCall
+	Name   string       // The module name, e.g. `a.b.c`.
+	Range  token.Range  // The range of the module name argument.
+	Target protocol.URI // The file that the module resolved to, or empty if it could not be resolved.
+}
+
+// ModuleGraph records which files require which other files.
+type ModuleGraph struct {
+	requires   map[protocol.URI][]Require
+	dependents map[protocol.URI]map[protocol.URI]bool
+}
+
+func NewModuleGraph() *ModuleGraph {
+	return &ModuleGraph{
+		requires:   map[protocol.URI][]Require{},
+		dependents: map[protocol.URI]map[protocol.URI]bool{},
+	}
+}
+
+// Update replaces the outgoing edges of the given file with the requires found in its current AST.
+func (g *ModuleGraph) Update(file *ast.File, info *Info, resolve func(name string) (protocol.URI, bool)) {
+	g.Remove(file.URI)
+	requires := []Require{}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		fc, ok := n.(*ast.FunctionCall)
+		if !ok {
+			return true
+		}
+		name, arg, ok := RequireName(fc, info)
+		if !ok {
+			return true
+		}
+		req := Require{Call: fc, Name: name, Range: ast.Range(arg)}
+		if target, ok := resolve(name); ok {
+			req.Target = target
+			if g.dependents[target] == nil {
+				g.dependents[target] = map[protocol.URI]bool{}
+			}
+			g.dependents[target][file.URI] = true
+		}
+		requires = append(requires, req)
+		return true
+	})
+	g.requires[file.URI] = requires
+}
+
+// Remove removes the outgoing edges of the given file.
+func (g *ModuleGraph) Remove(uri protocol.URI) {
+	for _, req := range g.requires[uri] {
+		if req.Target != "" {
+			delete(g.dependents[req.Target], uri)
+		}
+	}
+	delete(g.requires, uri)
+}
+
+// Requires returns every require call in the given file.
+func (g *ModuleGraph) Requires(uri protocol.URI) []Require {
+	return g.requires[uri]
+}
+
+// RequireAt returns the require call in the given file whose call covers the given position.
+func (g *ModuleGraph) RequireAt(uri protocol.URI, pos token.Pos) *Require {
+	for i, req := range g.requires[uri] {
+		rng := ast.Range(req.Call)
+		if rng.ContainsPos(pos) {
+			return &g.requires[uri][i]
+		}
+	}
+	return nil
+}
+
+// RequireOf returns the require for the given call expression, if there is one.
+func (g *ModuleGraph) RequireOf(uri protocol.URI, call ast.Node) *Require {
+	for i, req := range g.requires[uri] {
+		if ast.Node(req.Call) == call {
+			return &g.requires[uri][i]
+		}
+	}
+	return nil
+}
+
+// Dependents returns every file that directly requires the given file.
+func (g *ModuleGraph) Dependents(uri protocol.URI) []protocol.URI {
+	dependents := []protocol.URI{}
+	for dependent := range g.dependents[uri] {
+		dependents = append(dependents, dependent)
+	}
+	return dependents
+}
+
+// TransitiveDependents returns every file that requires the given file, directly or indirectly. The given file is
+// not included. Require cycles are tolerated.
+func (g *ModuleGraph) TransitiveDependents(uri protocol.URI) []protocol.URI {
+	seen := map[protocol.URI]bool{uri: true}
+	queue := []protocol.URI{uri}
+	result := []protocol.URI{}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for dependent := range g.dependents[next] {
+			if seen[dependent] {
+				continue
+			}
+			seen[dependent] = true
+			result = append(result, dependent)
+			queue = append(queue, dependent)
+		}
+	}
+	return result
+}
+
+// DependencyOrder returns the given files ordered so that every file comes after the files that it requires. Files
+// in require cycles are ordered by when they are first reached.
+func (g *ModuleGraph) DependencyOrder(uris []protocol.URI) []protocol.URI {
+	seen := map[protocol.URI]bool{}
+	result := make([]protocol.URI, 0, len(uris))
+	var visit func(uri protocol.URI)
+	visit = func(uri protocol.URI) {
+		if seen[uri] {
+			return
+		}
+		seen[uri] = true
+		for _, req := range g.Requires(uri) {
+			if req.Target != "" {
+				visit(req.Target)
+			}
+		}
+		result = append(result, uri)
+	}
+	for _, uri := range uris {
+		visit(uri)
+	}
+	return result
+}
+
+// RequireName returns the module name and name argument of a `require("name")` call.
+func RequireName(fc *ast.FunctionCall, info *Info) (string, ast.Expression, bool) {
+	ident, ok := fc.Name.(*ast.Identifier)
+	if !ok || ident.Token.Literal != "require" || len(fc.Args.Pairs) == 0 {
+		return "", nil, false
+	}
+	if sym := info.SymbolOf(ident); sym != nil && sym.Kind != SymbolGlobal {
+		return "", nil, false
+	}
+	arg := fc.Args.Pairs[0].Node
+	name, ok := ConstantString(arg, info)
+	if !ok {
+		return "", nil, false
+	}
+	return name, arg, true
+}
+
+// StringValue returns the contents of a string literal with its quotes removed.
+// TODO: Escape sequences
+func StringValue(lit *ast.StringLiteral) (string, bool) {
+	literal := lit.Token.Literal
+	if lit.Token.Type == token.RAWSTRING {
+		level := strings.IndexByte(literal[1:], '[')
+		if level < 0 || len(literal) < 2*level+4 {
+			return "", false
+		}
+		return literal[level+2 : len(literal)-level-2], true
+	}
+	if len(literal) < 2 {
+		return "", false
+	}
+	return literal[1 : len(literal)-1], true
+}
+
+// ResolveModule returns the URI of the file that the given module name refers to by trying each package path
+// pattern against each root.
+func (e *Environment) ResolveModule(name string) (protocol.URI, bool) {
+	modulePath := strings.ReplaceAll(name, ".", "/")
+	for _, root := range e.Roots() {
+		for _, pattern := range e.PackagePath {
+			path := filepath.Join(root, filepath.FromSlash(strings.ReplaceAll(pattern, "?", modulePath)))
+			uri, err := util.PathToURI(path)
+			if err != nil {
+				continue
+			}
+			if e.Files[uri] != nil || util.FileExists(path) {
+				return uri, true
+			}
+		}
+	}
+	if uri, ok := e.metaModules[name]; ok {
+		return uri, true
+	}
+	return "", false
+}
+
+// ModuleNames returns the name that each module can be required by, mapped to the file that it resolves to. Files
+// are named by the package path patterns that match them relative to each root, and definition files by their
+// `---@meta` name.
+func (e *Environment) ModuleNames() map[string]protocol.URI {
+	names := map[string]protocol.URI{}
+	for uri := range e.Files {
+		if name, ok := e.ModuleName(uri); ok {
+			names[name] = uri
+		}
+	}
+	for name, uri := range e.metaModules {
+		if _, ok := names[name]; !ok {
+			names[name] = uri
+		}
+	}
+	return names
+}
+
+// ModuleName returns the name that the file at the given URI can be required by, which need not exist yet. A file
+// that can be required by several names is named by the shortest, such as `foo` for `foo/init.lua`. Names that
+// resolve to another file first, such as `foo` when there is also a `foo.lua`, are not considered.
+func (e *Environment) ModuleName(uri protocol.URI) (string, bool) {
+	path, err := util.URIToPath(uri)
+	if err != nil {
+		return "", false
+	}
+	best := ""
+	for _, root := range e.Roots() {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, pattern := range e.PackagePath {
+			prefix, suffix, ok := strings.Cut(pattern, "?")
+			if !ok || !strings.HasPrefix(rel, prefix) || !strings.HasSuffix(rel, suffix) || len(rel) <= len(prefix)+len(suffix) {
+				continue
+			}
+			name := strings.ReplaceAll(rel[len(prefix):len(rel)-len(suffix)], "/", ".")
+			if target, ok := e.ResolveModule(name); (!ok || target == uri) && (best == "" || len(name) < len(best)) {
+				best = name
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// Roots returns the directories that modules are resolved against.
+func (e *Environment) Roots() []string {
+	return []string{e.RootPath}
+}
+
+// ModuleReturn returns the first expression returned from the top level of the given file, if any.
+func ModuleReturn(file *ast.File) ast.Expression {
+	if file.Block == nil {
+		return nil
+	}
+	for _, pair := range file.Block.Pairs {
+		if rs, ok := pair.Node.(*ast.ReturnStatement); ok && rs.Exps != nil && len(rs.Exps.Pairs) > 0 {
+			return rs.Exps.Pairs[0].Node
+		}
+	}
+	return nil
+}
+
+// ModuleField returns the node that defines the given field of the table returned by the given module.
+func (e *Environment) ModuleField(uri protocol.URI, key string) ast.Node {
+	file := e.Files[uri]
+	info := e.Info[uri]
+	if file == nil || info == nil {
+		return nil
+	}
+	switch ret := ModuleReturn(file).(type) {
+	case *ast.TableLiteral:
+		return TableField(ret, key)
+	case *ast.Identifier:
+		sym := info.SymbolOf(ret)
+		if sym == nil || sym.Kind == SymbolGlobal {
+			return nil
+		}
+		return LocalField(file, info, sym, key)
+	}
+	return nil
+}
+
+// TableField returns the key node of the given field in a table constructor.
+func TableField(tl *ast.TableLiteral, key string) ast.Node {
+	for _, pair := range tl.Fields.Pairs {
+		switch field := pair.Node.(type) {
+		case *ast.TableSimpleKeyField:
+			if field.Name.Token.Literal == key {
+				return &field.Name
+			}
+		case *ast.TableExpressionKeyField:
+			if lit, ok := field.Name.(*ast.StringLiteral); ok {
+				if value, ok := StringValue(lit); ok && value == key {
+					return lit
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// LocalField returns the node that defines the given field of a local table, either in its constructor or in a
+// later assignment such as `M.key = value` or `function M.key() end`.
+func LocalField(file *ast.File, info *Info, sym *Symbol, key string) ast.Node {
+	if tl, ok := sym.Initializer().(*ast.TableLiteral); ok {
+		if node := TableField(tl, key); node != nil {
+			return node
+		}
+	}
+	var result ast.Node
+	matches := func(expr ast.Expression) bool {
+		ie, ok := expr.(*ast.IndexExpression)
+		if !ok {
+			return false
+		}
+		prefix, ok := ie.Prefix.(*ast.Identifier)
+		if !ok || info.SymbolOf(prefix) != sym {
+			return false
+		}
+		field, ok := FieldKey(ie)
+		if ok && field == key {
+			result = ie.Inner
+		}
+		return ok && field == key
+	}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		if result != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.AssignmentStatement:
+			for _, pair := range n.Vars.Pairs {
+				if matches(pair.Node) {
+					return false
+				}
+			}
+		case *ast.FunctionStatement:
+			if matches(n.Name) {
+				return false
+			}
+		}
+		return true
+	})
+	return result
+}
@@ -0,0 +1,314 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/parser"
+	"github.com/raiguard/luapls/util"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return root
+}
+
+func uriOf(t *testing.T, root string, name string) string {
+	uri, err := util.PathToURI(filepath.Join(root, filepath.FromSlash(name)))
+	require.NoError(t, err)
+	return uri
+}
+
+// edit replaces the contents of a file, as if it was changed in the editor.
+func edit(env *Environment, uri string, src string) {
+	file := env.Files[uri]
+	parsed := parser.New(src).ParseFile()
+	file.Block, file.Comments, file.LineBreaks, file.Text, file.Diagnostics = parsed.Block, parsed.Comments, parsed.LineBreaks, parsed.Text, parsed.Diagnostics
+}
+
+func TestModuleGraph(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.lua":      `local util = require("lib.util") util.foo()`,
+		"lib/util.lua":  `local M = {} function M.foo() end return M`,
+		"lib/init.lua":  `return { bar = require("lib.util") }`,
+		"other.lua":     `local lib = require("lib") local missing = require("missing")`,
+		"unrelated.lua": `print("hello")`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+
+	main := uriOf(t, root, "main.lua")
+	utilURI := uriOf(t, root, "lib/util.lua")
+	lib := uriOf(t, root, "lib/init.lua")
+	other := uriOf(t, root, "other.lua")
+
+	requires := env.Modules.Requires(main)
+	require.Len(t, requires, 1)
+	assert.Equal(t, "lib.util", requires[0].Name)
+	assert.Equal(t, utilURI, requires[0].Target)
+
+	otherRequires := env.Modules.Requires(other)
+	require.Len(t, otherRequires, 2)
+	assert.Equal(t, lib, otherRequires[0].Target)
+	assert.Empty(t, otherRequires[1].Target)
+
+	assert.ElementsMatch(t, []string{main, lib}, env.Modules.Dependents(utilURI))
+	assert.ElementsMatch(t, []string{main, lib, other}, env.Modules.TransitiveDependents(utilURI))
+	assert.Equal(t, []string{utilURI}, env.Recheck(utilURI), "unchanged files should not affect their dependents")
+
+	edit(env, utilURI, `local M = {} function M.foo() return 1 end return M`)
+	assert.ElementsMatch(t, []string{utilURI, main, lib, other}, env.Recheck(utilURI))
+	edit(env, utilURI, `local M = {} function M.foo() local one = 1 return one end return M`)
+	assert.Equal(t, []string{utilURI}, env.Recheck(utilURI), "edits to function bodies should not affect dependents")
+
+	foo := env.ModuleField(utilURI, "foo")
+	require.NotNil(t, foo)
+	assert.Equal(t, "foo", foo.(*ast.Identifier).Token.Literal)
+	assert.NotNil(t, env.ModuleField(lib, "bar"))
+	assert.Nil(t, env.ModuleField(lib, "baz"))
+}
+
+func TestModuleNames(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.lua":          `print("main")`,
+		"lib/util.lua":      `return {}`,
+		"lib/init.lua":      `return {}`,
+		"both.lua":          `return {}`,
+		"both/init.lua":     `return {}`,
+		"defs/factorio.lua": "---@meta factorio\nreturn {}",
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+
+	assert.Equal(t, map[string]string{
+		"main":          uriOf(t, root, "main.lua"),
+		"lib.util":      uriOf(t, root, "lib/util.lua"),
+		"lib":           uriOf(t, root, "lib/init.lua"),
+		"both":          uriOf(t, root, "both.lua"),
+		"both.init":     uriOf(t, root, "both/init.lua"),
+		"defs.factorio": uriOf(t, root, "defs/factorio.lua"),
+		"factorio":      uriOf(t, root, "defs/factorio.lua"),
+	}, env.ModuleNames())
+}
+
+func TestModuleName(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"both.lua":      `return {}`,
+		"both/init.lua": `return {}`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+
+	name := func(file string) string {
+		name, _ := env.ModuleName(uriOf(t, root, file))
+		return name
+	}
+	assert.Equal(t, "both", name("both.lua"))
+	assert.Equal(t, "both.init", name("both/init.lua"))
+	// Files that do not exist yet are named as if they did.
+	assert.Equal(t, "lib.util", name("lib/util.lua"))
+	assert.Equal(t, "lib", name("lib/init.lua"))
+	assert.Equal(t, "", name("../outside.lua"))
+	assert.Equal(t, "", name("readme.md"))
+}
+
+func TestRecheckGlobalsAndTypes(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"defs.lua":  `config = { size = 1 }`,
+		"main.lua":  `print(config.size)`,
+		"class.lua": "---@class Point\n---@field x number",
+		"other.lua": `local n = 1`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	defs, main := uriOf(t, root, "defs.lua"), uriOf(t, root, "main.lua")
+	class, other := uriOf(t, root, "class.lua"), uriOf(t, root, "other.lua")
+
+	edit(env, defs, `config = { size = "large" }`)
+	assert.ElementsMatch(t, []string{defs, main}, env.Recheck(defs))
+	edit(env, class, "---@class Point\n---@field x string")
+	assert.ElementsMatch(t, []string{class, defs, main, other}, env.Recheck(class))
+
+	assert.Equal(t, []string{main}, env.RemoveFile(defs))
+	assert.Nil(t, env.Files[defs])
+	assert.False(t, env.Globals.IsDefined("config"))
+	assert.ElementsMatch(t, []string{main, other}, env.RemoveFile(class))
+	assert.Empty(t, env.Types["Point"].(*Named).Fields)
+}
+
+func TestParallelInit(t *testing.T) {
+	files := map[string]string{"main.lua": `local total = require("mod1").value`}
+	for i := 1; i <= 20; i++ {
+		src := fmt.Sprintf(`return { value = require("mod%d").value }`, i+1)
+		if i == 20 {
+			src = `return { value = 1 }`
+		}
+		files[fmt.Sprintf("mod%d.lua", i)] = src
+	}
+	root := writeFiles(t, files)
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Concurrency = 4
+	progress := []int{}
+	env.Progress = func(done int, total int) {
+		assert.Equal(t, 21, total)
+		progress = append(progress, done)
+	}
+	env.Init()
+	assert.Len(t, progress, 21)
+	assert.Equal(t, 21, progress[20])
+	main := env.Files[uriOf(t, root, "main.lua")]
+	require.NotNil(t, main)
+	assert.Len(t, env.Files, 21)
+	assert.Equal(t, "number", symbolType(t, main, env.Info[main.URI], files["main.lua"], "total"))
+
+	order := env.Modules.DependencyOrder([]string{main.URI})
+	require.Len(t, order, 21)
+	assert.Equal(t, uriOf(t, root, "mod20.lua"), order[0])
+	assert.Equal(t, main.URI, order[20])
+}
+
+func TestReindex(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.lua": `local value = require("lib").value`,
+		"lib.lua":  `return { value = 1 }`,
+		"old.lua":  `OLD = true`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	main := uriOf(t, root, "main.lua")
+	lib := uriOf(t, root, "lib.lua")
+	old := uriOf(t, root, "old.lua")
+	// The editor's contents of the main file differ from what is on disk.
+	edit(env, main, `local value = require("lib").value local other = require("new")`)
+	env.Recheck(main)
+
+	require.NoError(t, os.Remove(filepath.Join(root, "old.lua")))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "lib.lua"), []byte(`return { value = "one" }`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "new.lua"), []byte(`return {}`), 0644))
+	removed := env.Reindex(func(uri string) bool { return uri == main })
+
+	assert.Equal(t, []string{old}, removed)
+	assert.Len(t, env.Files, 3)
+	assert.False(t, env.Globals.IsDefined("OLD"))
+	requires := env.Modules.Requires(main)
+	require.Len(t, requires, 2)
+	assert.Equal(t, lib, requires[0].Target)
+	assert.Equal(t, uriOf(t, root, "new.lua"), requires[1].Target)
+	file := env.Files[main]
+	assert.Equal(t, "string", symbolType(t, file, env.Info[main], `local value = require("lib").value`, "value"))
+}
+
+func TestLoad(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.lua": `local value = require("lib").value`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	main := uriOf(t, root, "main.lua")
+	lib := uriOf(t, root, "lib.lua")
+	require.Len(t, env.Modules.Requires(main), 1)
+	assert.Empty(t, env.Modules.Requires(main)[0].Target)
+
+	assert.True(t, env.Loads(lib))
+	assert.False(t, env.Loads("file:///elsewhere/lib.lua"))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "lib.lua"), []byte(`return { value = 1 }`), 0644))
+	checked := env.Load(lib)
+
+	assert.Equal(t, []string{lib, main}, checked)
+	assert.Equal(t, lib, env.Modules.Requires(main)[0].Target)
+	file := env.Files[main]
+	assert.Equal(t, "number", symbolType(t, file, env.Info[main], `local value = require("lib").value`, "value"))
+}
+
+func TestInitWithoutRoot(t *testing.T) {
+	library := writeFiles(t, map[string]string{
+		"lib.lua": `return { value = 1 }`,
+	})
+	env := NewEnvironment()
+	env.Library = []string{library}
+	env.Init()
+	assert.Empty(t, env.Files)
+
+	main := "file:///elsewhere/main.lua"
+	env.AddTransientFile(main, `local value = require("lib").value`)
+	assert.Equal(t, []string{main}, env.Recheck(main))
+	require.Len(t, env.Modules.Requires(main), 1)
+	assert.Empty(t, env.Modules.Requires(main)[0].Target)
+	assert.NotNil(t, env.Info[main])
+}
+
+func TestOwnsExclude(t *testing.T) {
+	env := NewEnvironment()
+	env.RootPath = "/project"
+	env.Include = []string{"src/**", "scripts/*.lua"}
+	env.Exclude = []string{"src/vendor/**"}
+
+	assert.True(t, env.Owns("file:///project/src/main.lua"))
+	assert.True(t, env.Owns("file:///project/scripts/build.lua"))
+	assert.False(t, env.Owns("file:///project/src/vendor/lib.lua"))
+	assert.False(t, env.Owns("file:///project/test/main_spec.lua"))
+	assert.False(t, env.Owns("file:///elsewhere/src/main.lua"))
+
+	env.Include = nil
+	assert.True(t, env.Owns("file:///project/test/main_spec.lua"))
+	assert.False(t, env.Owns("file:///project/src/vendor/lib.lua"))
+}
+
+func TestInitIgnore(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		".gitignore":               "# Build outputs\n/build/\n*.gen.lua\n!keep.gen.lua\n",
+		"main.lua":                 `return {}`,
+		"build/out.lua":            `return {}`,
+		"src/build/lib.lua":        `return {}`,
+		"src/data.gen.lua":         `return {}`,
+		"src/keep.gen.lua":         `return {}`,
+		"src/.gitignore":           "local.lua\n",
+		"src/local.lua":            `return {}`,
+		"local.lua":                `return {}`,
+		"node_modules/dep/dep.lua": `return {}`,
+		".git/hooks/hook.lua":      `return {}`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Ignore = []string{"node_modules/"}
+	env.Init()
+
+	loaded := []string{}
+	for uri := range env.Files {
+		path, err := util.URIToPath(uri)
+		require.NoError(t, err)
+		rel, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+		loaded = append(loaded, filepath.ToSlash(rel))
+	}
+	assert.ElementsMatch(t, []string{"main.lua", "local.lua", "src/build/lib.lua", "src/keep.gen.lua"}, loaded)
+
+	uri := func(name string) protocol.URI {
+		uri, err := util.PathToURI(filepath.Join(root, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		return uri
+	}
+	assert.True(t, env.Loads(uri("src/new.lua")))
+	assert.False(t, env.Loads(uri("build/new.lua")))
+	assert.False(t, env.Loads(uri("src/local.lua")))
+	assert.False(t, env.Loads(uri("node_modules/dep/new.lua")))
+}
@@ -1,15 +1,225 @@
 package types
 
-import "github.com/raiguard/luapls/lua/token"
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
 
-// Named represents a named type, constructed with `@class`.
-type Named struct {
-	Name  string
+// Location is a range within a specific file.
+type Location struct {
+	URI   protocol.URI
 	Range token.Range
 }
 
+// Named represents a named type, constructed with `@class`. A class may be declared in several places, so its fields
+// and parents record where they came from in order to be removed when that file changes.
+type Named struct {
+	Name   string
+	Loc    Location
+	Fields []NameAndType
+
+	parents   []declared
+	metatable *declared
+}
+
+// declared is a type that was given to a class in a specific file, such as a parent declared with `---@class Child:
+// Parent`, so that it can be removed when that file changes.
+type declared struct {
+	typ Type
+	uri protocol.URI
+}
+
 func (n *Named) isType() {}
 
 func (n *Named) String() string {
 	return n.Name
 }
+
+// Field returns the field with the given name, or nil if there is no such field. Fields that the class does not have
+// itself are looked up in its parents, and then in its metatable's `__index`.
+func (n *Named) Field(name string) *NameAndType {
+	return lookupField(n, name, map[Type]bool{})
+}
+
+// AllFields returns the fields of the class followed by the fields that it inherits and does not override.
+func (n *Named) AllFields() []NameAndType {
+	fields := []NameAndType{}
+	present := map[string]bool{}
+	for _, class := range n.ancestors() {
+		for _, field := range class.Fields {
+			if !present[field.Name] {
+				present[field.Name] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// Parents returns the classes that the class directly inherits from.
+func (n *Named) Parents() []*Named {
+	parents := []*Named{}
+	for _, parent := range n.parents {
+		if named, ok := Resolve(parent.typ).(*Named); ok {
+			parents = append(parents, named)
+		}
+	}
+	return parents
+}
+
+// Supertypes returns the classes that the class directly inherits from, followed by the class that its metatable's
+// `__index` refers to if it is not one of them.
+func (n *Named) Supertypes() []*Named {
+	supertypes := n.Parents()
+	index := rawField(n.Metatable(), "__index")
+	if index == nil {
+		return supertypes
+	}
+	named, ok := Resolve(index.Type).(*Named)
+	if !ok || named == n {
+		return supertypes
+	}
+	for _, parent := range supertypes {
+		if parent == named {
+			return supertypes
+		}
+	}
+	return append(supertypes, named)
+}
+
+// AddParent records that the class inherits from the given type, as declared in the given file.
+func (n *Named) AddParent(typ Type, uri protocol.URI) {
+	n.parents = append(n.parents, declared{typ, uri})
+}
+
+// Metatable returns the metatable that was given to the class table with `setmetatable`, or nil if it has none.
+func (n *Named) Metatable() Type {
+	if n.metatable == nil {
+		return nil
+	}
+	return n.metatable.typ
+}
+
+// SetMetatable records the metatable given to the class table with `setmetatable` in the given file.
+func (n *Named) SetMetatable(typ Type, uri protocol.URI) {
+	n.metatable = &declared{typ, uri}
+}
+
+// Inherits returns whether the class is the given class or one of its descendants.
+func (n *Named) Inherits(other *Named) bool {
+	for _, class := range n.ancestors() {
+		if class == other || class.Name == other.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// ancestors returns the class followed by each of its ancestors, nearest first.
+func (n *Named) ancestors() []*Named {
+	ancestors := []*Named{}
+	seen := map[*Named]bool{}
+	queue := []*Named{n}
+	for len(queue) > 0 {
+		class := queue[0]
+		queue = queue[1:]
+		if seen[class] {
+			continue
+		}
+		seen[class] = true
+		ancestors = append(ancestors, class)
+		queue = append(queue, class.Parents()...)
+	}
+	return ancestors
+}
+
+// SetField adds a field to the class, or widens the type of the existing field with the same name. Annotated fields
+// replace inferred ones, and are never widened by inferred ones.
+func (n *Named) SetField(field NameAndType) {
+	// Fields of the parents are overridden rather than widened.
+	if existing := findField(n.Fields, field.Name); existing != nil {
+		switch {
+		case existing.Annotated && !field.Annotated:
+		case field.Annotated && !existing.Annotated:
+			*existing = field
+		default:
+			existing.Type = NewUnion(existing.Type, field.Type)
+		}
+		return
+	}
+	n.Fields = append(n.Fields, field)
+}
+
+// removeFields removes every field and parent that was declared in the given file.
+func (n *Named) removeFields(uri protocol.URI) {
+	fields := n.Fields[:0]
+	for _, field := range n.Fields {
+		if field.Loc.URI != uri {
+			fields = append(fields, field)
+		}
+	}
+	n.Fields = fields
+	parents := n.parents[:0]
+	for _, parent := range n.parents {
+		if parent.uri != uri {
+			parents = append(parents, parent)
+		}
+	}
+	n.parents = parents
+	if n.metatable != nil && n.metatable.uri == uri {
+		n.metatable = nil
+	}
+}
+
+// Alias is a name for another type, constructed with `@alias`. Its target is resolved after every type in the
+// environment has been declared, so that aliases may refer to classes and aliases that are declared later.
+type Alias struct {
+	Name string
+	Loc  Location
+	Type Type
+}
+
+func (a *Alias) isType() {}
+
+func (a *Alias) String() string {
+	return a.Name
+}
+
+// Resolve follows aliases until it reaches a type that is not an alias.
+func Resolve(typ Type) Type {
+	for i := 0; i < 100; i++ {
+		alias, ok := typ.(*Alias)
+		if !ok {
+			return typ
+		}
+		typ = alias.Type
+	}
+	return &Unknown{}
+}
+
+// FieldOf returns the field with the given name of a class or table type, or nil if the type does not have it. Nil is
+// ignored so that the fields of optional values can be found.
+func FieldOf(typ Type, name string) *NameAndType {
+	switch typ := Resolve(RemoveNil(typ)).(type) {
+	case *Named:
+		return typ.Field(name)
+	case *Table:
+		return typ.Field(name)
+	}
+	return nil
+}
+
+func findField(fields []NameAndType, name string) *NameAndType {
+	for i := range fields {
+		if fields[i].Name == name {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// nodeLocation returns the location of a node in the given file.
+func nodeLocation(uri protocol.URI, node ast.Node) Location {
+	return Location{URI: uri, Range: ast.Range(node)}
+}
@@ -0,0 +1,238 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// narrowing maps symbols to the narrower types that they are known to have at some point in the file, such as within
+// `if x ~= nil then` or after `assert(x)`.
+type narrowing map[*Symbol]Type
+
+// symbolType returns the type of a symbol at the current point in the file, taking the enclosing narrowings into
+// account.
+func (in *inferrer) symbolType(sym *Symbol) Type {
+	for i := len(in.narrowed) - 1; i >= 0; i-- {
+		if typ, ok := in.narrowed[i][sym]; ok {
+			return typ
+		}
+	}
+	if sym.Kind == SymbolGlobal && sym.Annotated == nil {
+		return NewUnion(sym.Type, in.env.GlobalType(sym.Name, in.file.URI))
+	}
+	return sym.Type
+}
+
+// narrow calls f with the given narrowing in effect.
+func (in *inferrer) narrow(n narrowing, f func()) {
+	in.narrowed = append(in.narrowed, n)
+	f()
+	in.narrowed = in.narrowed[:len(in.narrowed)-1]
+}
+
+// refine narrows symbols for the rest of the current block.
+func (in *inferrer) refine(n narrowing) {
+	for sym, typ := range n {
+		in.narrowed[len(in.narrowed)-1][sym] = typ
+	}
+}
+
+// reassign records that a symbol was assigned a value of the given type, which replaces any narrowing of it.
+func (in *inferrer) reassign(sym *Symbol, typ Type) {
+	for _, n := range in.narrowed {
+		delete(n, sym)
+	}
+	if typ != nil {
+		in.narrowed[len(in.narrowed)-1][sym] = typ
+	}
+}
+
+// narrowIf returns the narrower types that symbols have when the condition evaluates to the given truthiness.
+func (in *inferrer) narrowIf(cond ast.Expression, truthy bool) narrowing {
+	n := narrowing{}
+	switch cond := cond.(type) {
+	case *ast.Identifier:
+		if sym := in.info.Uses[cond]; sym != nil {
+			n[sym] = narrowType(in.symbolType(sym), func(member Type) bool {
+				if truthy {
+					return !isFalsy(member)
+				}
+				return !isTruthy(member)
+			})
+		}
+	case *ast.PrefixExpression:
+		if cond.Operator.Type() == token.NOT {
+			return in.narrowIf(cond.Right, !truthy)
+		}
+	case *ast.InfixExpression:
+		switch cond.Operator.Type() {
+		case token.AND, token.OR:
+			// `a and b` is true, or `a or b` is false, only if both operands are.
+			if truthy != (cond.Operator.Type() == token.AND) {
+				return n
+			}
+			left := in.narrowIf(cond.Left, truthy)
+			in.narrow(left, func() {
+				n = in.narrowIf(cond.Right, truthy)
+			})
+			for sym, typ := range left {
+				if _, ok := n[sym]; !ok {
+					n[sym] = typ
+				}
+			}
+		case token.EQUAL, token.NEQ:
+			equal := truthy == (cond.Operator.Type() == token.EQUAL)
+			in.narrowComparison(n, cond.Left, cond.Right, equal)
+			in.narrowComparison(n, cond.Right, cond.Left, equal)
+		}
+	}
+	return n
+}
+
+// narrowComparison narrows the subject of a comparison with nil, such as `x == nil`, or of a comparison of its type
+// with a type name, such as `type(x) == "table"`, depending on whether the two sides are equal.
+func (in *inferrer) narrowComparison(n narrowing, subject ast.Expression, other ast.Expression, equal bool) {
+	if _, ok := other.(*ast.NilLiteral); ok {
+		if ident, ok := subject.(*ast.Identifier); ok {
+			if sym := in.info.Uses[ident]; sym != nil {
+				n[sym] = narrowType(in.symbolType(sym), func(member Type) bool {
+					_, isNil := member.(*Nil)
+					return isNil == equal
+				})
+			}
+		}
+		return
+	}
+	call, ok := subject.(*ast.FunctionCall)
+	if !ok || len(call.Args.Pairs) != 1 || in.builtinName(call.Name) != "type" {
+		return
+	}
+	ident, ok := call.Args.Pairs[0].Node.(*ast.Identifier)
+	if !ok {
+		return
+	}
+	lit, ok := other.(*ast.StringLiteral)
+	if !ok {
+		return
+	}
+	name, ok := StringValue(lit)
+	sym := in.info.Uses[ident]
+	if !ok || sym == nil {
+		return
+	}
+	typ := in.symbolType(sym)
+	if base := builtinType(name); base != nil && equal {
+		// Values whose type is not known may be of any type, so they are assumed to be of the named type.
+		switch Resolve(typ).(type) {
+		case nil, *Any, *TypeParam, *Unknown:
+			n[sym] = base
+			return
+		}
+	}
+	n[sym] = narrowType(typ, func(member Type) bool {
+		actual := typeName(member)
+		if actual == "" {
+			return true
+		}
+		return (actual == name) == equal
+	})
+}
+
+// narrowType returns the members of the type for which keep returns true. If there are none, the condition can
+// never hold, and the type is returned unchanged.
+func narrowType(typ Type, keep func(member Type) bool) Type {
+	members := []Type{Resolve(typ)}
+	if union, ok := members[0].(*Union); ok {
+		members = union.Types
+	}
+	kept := []Type{}
+	for _, member := range members {
+		if keep(Resolve(member)) {
+			kept = append(kept, member)
+		}
+	}
+	if len(kept) == 0 {
+		return typ
+	}
+	return NewUnion(kept...)
+}
+
+// isFalsy returns whether every value of the type is false or nil.
+func isFalsy(typ Type) bool {
+	switch typ := typ.(type) {
+	case *Nil:
+		return true
+	case *Literal:
+		return typ.Value == "false"
+	}
+	return false
+}
+
+// isTruthy returns whether no value of the type is false or nil.
+func isTruthy(typ Type) bool {
+	switch typ := typ.(type) {
+	case *Any, *Boolean, *Nil, *TypeParam, *Unknown:
+		return false
+	case *Literal:
+		return typ.Value != "false"
+	}
+	return true
+}
+
+// typeName returns the name that Lua's `type` function gives to values of the type, or an empty string if the type
+// may have values of several types.
+func typeName(typ Type) string {
+	switch typ := Resolve(typ).(type) {
+	case *Boolean:
+		return "boolean"
+	case *Function:
+		return "function"
+	case *Literal:
+		return typeName(typ.Base)
+	case *Named, *Table:
+		return "table"
+	case *Nil:
+		return "nil"
+	case *Number:
+		return "number"
+	case *String:
+		return "string"
+	}
+	return ""
+}
+
+// builtinType returns the type of values that Lua's `type` function gives the given name to, or nil if there is no
+// such type.
+func builtinType(name string) Type {
+	switch name {
+	case "boolean":
+		return &Boolean{}
+	case "function":
+		return &Function{}
+	case "nil":
+		return &Nil{}
+	case "number":
+		return &Number{}
+	case "string":
+		return &String{}
+	case "table":
+		return &Table{}
+	}
+	return nil
+}
+
+// Exits returns whether the block always leaves the enclosing block, by returning, breaking, jumping, or raising an
+// error.
+func Exits(block *ast.Block) bool {
+	if len(block.Pairs) == 0 {
+		return false
+	}
+	switch stmt := block.Pairs[len(block.Pairs)-1].Node.(type) {
+	case *ast.BreakStatement, *ast.GotoStatement, *ast.ReturnStatement:
+		return true
+	case *ast.FunctionCall:
+		ident, ok := stmt.Name.(*ast.Identifier)
+		return ok && ident.Token.Literal == "error"
+	}
+	return false
+}
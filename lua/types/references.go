@@ -0,0 +1,101 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// FieldReference is a single place where a field is accessed or assigned.
+type FieldReference struct {
+	Location
+	Write bool // Whether the reference assigns to the field rather than reading it.
+}
+
+// FieldAt returns the field that the identifier at the end of the given node path names, either as the key of an
+// index expression or as the key of a table constructor field, along with the type that it is a field of. The field
+// is nil if the identifier is not a field key.
+func FieldAt(info *Info, path ast.NodePath) (Type, *NameAndType) {
+	if _, ok := path.Node.(*ast.Identifier); !ok || len(path.Parents) == 0 {
+		return nil, nil
+	}
+	switch parent := path.Parents[len(path.Parents)-1].(type) {
+	case *ast.IndexExpression:
+		if parent.Inner != path.Node {
+			return nil, nil
+		}
+		key, ok := FieldKey(parent)
+		if !ok {
+			return nil, nil
+		}
+		owner := info.TypeOf(parent.Prefix)
+		return owner, FieldOf(owner, key)
+	case *ast.TableSimpleKeyField:
+		if &parent.Name != path.Node {
+			return nil, nil
+		}
+		for i := len(path.Parents) - 2; i >= 0; i-- {
+			if tbl, ok := path.Parents[i].(*ast.TableLiteral); ok {
+				owner := tableType(info, tbl)
+				return owner, FieldOf(owner, parent.Name.Token.Literal)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// FieldReferences returns every access of the field with the given name that was declared at the given location,
+// across every file in the environment. The key of the declaration is included if the field was declared in code.
+func (e *Environment) FieldReferences(name string, loc Location) []FieldReference {
+	refs := []FieldReference{}
+	for _, file := range e.sortedFiles() {
+		refs = append(refs, e.FileFieldReferences(file.URI, name, loc)...)
+	}
+	return refs
+}
+
+// FileFieldReferences returns the references to the field in a single file, in the same way as FieldReferences.
+func (e *Environment) FileFieldReferences(uri protocol.URI, name string, loc Location) []FieldReference {
+	refs := []FieldReference{}
+	file, info := e.Files[uri], e.Info[uri]
+	if info == nil || file == nil || file.Block == nil {
+		return refs
+	}
+	writes := map[ast.Node]bool{}
+	ast.WalkSemantic(file.Block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignmentStatement:
+			for _, pair := range n.Vars.Pairs {
+				writes[pair.Node] = true
+			}
+		case *ast.FunctionStatement:
+			writes[n.Name] = true
+		case *ast.IndexExpression:
+			if key, ok := FieldKey(n); ok && key == name {
+				if field := FieldOf(info.TypeOf(n.Prefix), key); field != nil && field.Loc == loc {
+					refs = append(refs, FieldReference{nodeLocation(file.URI, n.Inner), writes[n]})
+				}
+			}
+		case *ast.TableLiteral:
+			for _, pair := range n.Fields.Pairs {
+				key, node, _ := tableFieldKey(pair.Node)
+				if node == nil || key != name {
+					continue
+				}
+				if field := FieldOf(tableType(info, n), key); field != nil && field.Loc == loc {
+					refs = append(refs, FieldReference{nodeLocation(file.URI, node), true})
+				}
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// tableType returns the class that a table literal is expected to be an instance of, or its inferred type if it is
+// not expected to be an instance of any class.
+func tableType(info *Info, tbl *ast.TableLiteral) Type {
+	if class := info.Expected[tbl]; class != nil {
+		return class
+	}
+	return info.TypeOf(tbl)
+}
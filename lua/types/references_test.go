@@ -0,0 +1,69 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/raiguard/luapls/lua/ast"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldReferences(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"entity.lua": `---@class Entity
+---@field position number
+local Entity = {}
+
+function Entity:move() self.position = self.position + 1 end
+
+return Entity`,
+		"main.lua": `local util = require("util")
+---@type Entity
+local entity = {}
+entity.position = 1
+print(entity.position, util.foo, util.bar)`,
+		"util.lua": `local M = {foo = 1}
+M.bar = M.foo
+return M`,
+	})
+	env := NewEnvironment()
+	env.RootPath = root
+	env.Init()
+	entity := uriOf(t, root, "entity.lua")
+	main := uriOf(t, root, "main.lua")
+	util := uriOf(t, root, "util.lua")
+
+	// fieldAt returns the field named by the nth occurrence of the given name in a file.
+	fieldAt := func(uri string, src string, name string, n int) *NameAndType {
+		file := env.Files[uri]
+		_, field := FieldAt(env.Info[uri], ast.GetSemanticNode(file.Block, identAt(t, file, src, name, n).Pos()))
+		return field
+	}
+
+	position := fieldAt(main, `local util = require("util")
+---@type Entity
+local entity = {}
+entity.position = 1
+print(entity.position, util.foo, util.bar)`, "position", 1)
+	require.NotNil(t, position)
+	assert.Equal(t, entity, position.Loc.URI)
+	refs := env.FieldReferences("position", position.Loc)
+	uris, writes := []string{}, []bool{}
+	for _, ref := range refs {
+		uris = append(uris, ref.URI)
+		writes = append(writes, ref.Write)
+	}
+	// The `---@field` annotation is not in the code, so it is not a reference.
+	assert.Equal(t, []string{entity, entity, main, main}, uris)
+	assert.Equal(t, []bool{true, false, true, false}, writes)
+
+	foo := fieldAt(util, `local M = {foo = 1}
+M.bar = M.foo
+return M`, "foo", 0)
+	require.NotNil(t, foo)
+	refs = env.FieldReferences("foo", foo.Loc)
+	require.Len(t, refs, 3)
+	assert.Equal(t, foo.Loc, refs[1].Location)
+	assert.Equal(t, []string{main, util, util}, []string{refs[0].URI, refs[1].URI, refs[2].URI})
+}
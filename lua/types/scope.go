@@ -0,0 +1,75 @@
+package types
+
+import (
+	"math"
+
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+// Scope is a lexical scope that contains local declarations.
+type Scope struct {
+	Parent   *Scope
+	Children []*Scope
+	Node     ast.Node  // The node that introduced this scope. Nil for the file scope.
+	Symbols  []*Symbol // Symbols declared directly in this scope, in declaration order.
+
+	names map[string]*Symbol // The latest declaration of each name. Only meaningful while binding.
+}
+
+func newScope(parent *Scope, node ast.Node) *Scope {
+	scope := &Scope{Parent: parent, Node: node, names: map[string]*Symbol{}}
+	if parent != nil {
+		parent.Children = append(parent.Children, scope)
+	}
+	return scope
+}
+
+// Range returns the range that this scope covers. The file scope covers the entire file.
+func (s *Scope) Range() token.Range {
+	if s.Node == nil {
+		return token.Range{Start: 0, End: math.MaxInt}
+	}
+	return ast.Range(s.Node)
+}
+
+// Innermost returns the innermost scope that contains the given position.
+func (s *Scope) Innermost(pos token.Pos) *Scope {
+	for _, child := range s.Children {
+		rng := child.Range()
+		if rng.ContainsPos(pos) {
+			return child.Innermost(pos)
+		}
+	}
+	return s
+}
+
+// LookupAt returns the local symbol with the given name that is visible at the given position, or nil.
+func (s *Scope) LookupAt(name string, pos token.Pos) *Symbol {
+	for scope := s.Innermost(pos); scope != nil; scope = scope.Parent {
+		for i := len(scope.Symbols) - 1; i >= 0; i-- {
+			sym := scope.Symbols[i]
+			if sym.Name == name && sym.VisibleFrom <= pos {
+				return sym
+			}
+		}
+	}
+	return nil
+}
+
+// VisibleAt returns all local symbols that are visible at the given position. Shadowed symbols are omitted.
+func (s *Scope) VisibleAt(pos token.Pos) []*Symbol {
+	seen := map[string]bool{}
+	symbols := []*Symbol{}
+	for scope := s.Innermost(pos); scope != nil; scope = scope.Parent {
+		for i := len(scope.Symbols) - 1; i >= 0; i-- {
+			sym := scope.Symbols[i]
+			if sym.VisibleFrom > pos || seen[sym.Name] {
+				continue
+			}
+			seen[sym.Name] = true
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
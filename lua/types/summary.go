@@ -0,0 +1,127 @@
+package types
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// summary records everything about a file that other files can observe: the type of the value that it returns, the
+// types of the globals that it assigns, and the types and fields that it declares. A file whose summary is unchanged
+// after an edit cannot affect the analysis of any other file.
+type summary struct {
+	module  string
+	globals map[string]string // The types of the globals that the file assigns, by name.
+	types   string
+}
+
+func (s summary) equal(other summary) bool {
+	return s.module == other.module && s.types == other.types && maps.Equal(s.globals, other.globals)
+}
+
+// summarize computes the summary of a checked file.
+func (e *Environment) summarize(uri protocol.URI) summary {
+	s := summary{globals: map[string]string{}}
+	info := e.Info[uri]
+	if info == nil {
+		return s
+	}
+	if ret := ModuleReturn(e.Files[uri]); ret != nil {
+		s.module = formatType(info.TypeOf(ret), 0)
+	}
+	for _, site := range e.Globals.FileDefs(uri) {
+		name, _, _ := strings.Cut(site.Path, ".")
+		if sym := info.Globals[name]; sym != nil {
+			s.globals[name] = formatType(sym.Type, 0)
+		}
+	}
+	names := make([]string, 0, len(e.Types))
+	for name := range e.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		switch typ := e.Types[name].(type) {
+		case *Alias:
+			if typ.Loc.URI == uri {
+				sb.WriteString("alias " + name + " " + formatType(typ.Type, 0) + "\n")
+			}
+		case *Enum:
+			if typ.Loc.URI == uri {
+				sb.WriteString("enum " + name + " " + formatFields(typ.Members) + "\n")
+			}
+		case *Named:
+			declared := typ.Loc.URI == uri
+			parents := []string{}
+			for _, parent := range typ.parents {
+				if parent.uri == uri {
+					parents = append(parents, formatType(parent.typ, 0))
+				}
+			}
+			if typ.metatable != nil && typ.metatable.uri == uri {
+				parents = append(parents, "metatable "+formatType(typ.metatable.typ, 0))
+			}
+			fields := []NameAndType{}
+			for _, field := range typ.Fields {
+				if field.Loc.URI == uri {
+					fields = append(fields, field)
+				}
+			}
+			if declared || len(parents) > 0 || len(fields) > 0 {
+				sb.WriteString("class " + name + ": " + strings.Join(parents, ", ") + " " + formatFields(fields) + "\n")
+			}
+		}
+	}
+	s.types = sb.String()
+	return s
+}
+
+// affectedBy returns the files whose analysis may change when a file's summary changes from before to after.
+func (e *Environment) affectedBy(uri protocol.URI, before summary, after summary) []protocol.URI {
+	// Types may be used anywhere, so every other file is affected when they change.
+	if before.types != after.types {
+		affected := []protocol.URI{}
+		for other := range e.Files {
+			if other != uri {
+				affected = append(affected, other)
+			}
+		}
+		sort.Strings(affected)
+		return affected
+	}
+	affected := []protocol.URI{}
+	if before.module != after.module {
+		affected = append(affected, e.Modules.Dependents(uri)...)
+	}
+	names := map[string]bool{}
+	for name := range before.globals {
+		names[name] = true
+	}
+	for name := range after.globals {
+		names[name] = true
+	}
+	for name := range names {
+		if before.globals[name] == after.globals[name] {
+			continue
+		}
+		for _, site := range e.Globals.Reads(name) {
+			if site.URI != uri {
+				affected = append(affected, site.URI)
+			}
+		}
+	}
+	sort.Strings(affected)
+	return slices.Compact(affected)
+}
+
+func formatFields(fields []NameAndType) string {
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, field.format(0))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"github.com/raiguard/luapls/lua/ast"
+	"github.com/raiguard/luapls/lua/token"
+)
+
+type SymbolKind int
+
+const (
+	SymbolLocal SymbolKind = iota
+	SymbolParameter
+	SymbolGlobal
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolLocal:
+		return "local"
+	case SymbolParameter:
+		return "parameter"
+	case SymbolGlobal:
+		return "global"
+	}
+	return "unknown"
+}
+
+// Symbol is a named entity that identifiers can declare or reference.
+type Symbol struct {
+	Name string
+	Kind SymbolKind
+	Decl *ast.Identifier // The identifier that declared this symbol. Nil for globals and implicit `self` parameters.
+	Node ast.Node        // The statement or expression that declared this symbol. Nil for globals.
+	// Scope is the scope that this symbol was declared in. Nil for globals.
+	Scope *Scope
+	// VisibleFrom is the position at which the symbol comes into scope. For `local x = x`, this is after the
+	// initializer so that the right-hand `x` refers to the outer variable.
+	VisibleFrom token.Pos
+	Refs        []Reference
+	Type        Type
+	// Annotated is the type given to the symbol by an annotation, if any. It takes precedence over inferred types.
+	Annotated Type
+}
+
+// Reference is a single identifier that refers to a symbol, excluding its declaration.
+type Reference struct {
+	Ident *ast.Identifier
+	Write bool // Whether the reference assigns to the symbol rather than reading it.
+}
+
+// Reads returns the number of references that read the symbol's value.
+func (s *Symbol) Reads() int {
+	reads := 0
+	for _, ref := range s.Refs {
+		if !ref.Write {
+			reads++
+		}
+	}
+	return reads
+}
+
+// Initializer returns the expression that initialized the given local symbol in its declaration, if there was one.
+// Function statements are their own initializer.
+func (s *Symbol) Initializer() ast.Node {
+	switch node := s.Node.(type) {
+	case *ast.LocalStatement:
+		if node.Exps == nil {
+			return nil
+		}
+		for i, pair := range node.Names.Pairs {
+			if pair.Node == s.Decl && i < len(node.Exps.Pairs) {
+				return node.Exps.Pairs[i].Node
+			}
+		}
+	case *ast.FunctionStatement:
+		return node
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package types
+
+// Tuple is the list of values produced by a function call or by `...`. Outside of the end of an expression list,
+// a tuple is truncated to its first value.
+type Tuple struct {
+	Types []Type
+	Rest  Type // The type of any further values, such as `T` for `...: T`, or nil if there are none.
+}
+
+func (t *Tuple) isType() {}
+
+func (t *Tuple) String() string { return formatType(t, 0) }
+
+// NewTuple returns the type of a list of values. A single value is returned directly, and no values are nil.
+func NewTuple(types []Type, rest Type) Type {
+	switch {
+	case rest != nil:
+	case len(types) == 0:
+		return &Nil{}
+	case len(types) == 1:
+		return types[0]
+	}
+	return &Tuple{Types: types, Rest: rest}
+}
+
+// First returns the type of the first value of a tuple, which is the value that remains when it is truncated. Other
+// types are returned unchanged.
+func First(typ Type) Type {
+	return At(typ, 0)
+}
+
+// At returns the type of the i-th value of a tuple. Any other type is a single value, so every value after it is nil.
+func At(typ Type, i int) Type {
+	types, rest := tupleValues(typ)
+	if i < len(types) {
+		return types[i]
+	}
+	if rest != nil {
+		return rest
+	}
+	return &Nil{}
+}
+
+// tupleValues returns the types of the values of a tuple and the type of any further values. Any other type is a
+// single value.
+func tupleValues(typ Type) ([]Type, Type) {
+	if tuple, ok := typ.(*Tuple); ok {
+		return tuple.Types, tuple.Rest
+	}
+	return []Type{typ}, nil
+}
+
+// unionTuples combines the types of several lists of values position by position, such as the values of each return
+// statement of a function. Lists that are shorter than the others are padded with nil.
+func unionTuples(tuples []Type) Type {
+	n := 0
+	rests := []Type{}
+	for _, tuple := range tuples {
+		types, rest := tupleValues(tuple)
+		n = max(n, len(types))
+		rests = append(rests, rest)
+	}
+	if n <= 1 && NewUnion(rests...) == nil {
+		return NewUnion(tuples...)
+	}
+	types := make([]Type, n)
+	for i := range types {
+		members := make([]Type, 0, len(tuples))
+		for _, tuple := range tuples {
+			members = append(members, At(tuple, i))
+		}
+		types[i] = NewUnion(members...)
+	}
+	return NewTuple(types, NewUnion(rests...))
+}
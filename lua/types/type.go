@@ -16,13 +16,27 @@ type (
 	Any      struct{}
 	Boolean  struct{}
 	Function struct {
-		Params []NameAndType
-		Return Type
+		TypeParams []*TypeParam // The parameters of a generic function, declared with `@generic`.
+		Params     []NameAndType
+		Return     Type
+		Overloads  []*Function // Alternative signatures, declared with `@overload`.
 	}
+	// Literal is a type that only admits a single string, number, or boolean value, such as `"left"`.
+	Literal struct {
+		Value string // The literal as it was written.
+		Base  Type   // The type of the value, such as String.
+	}
+	Nil    struct{}
 	Number struct{}
 	String struct{}
 	Table  struct {
 		Fields []NameAndType
+		// Key and Value are the types of the table's dynamic keys and values, such as `number` and `T` for `T[]`.
+		Key   Type
+		Value Type
+		// Metatable is the table's metatable, set with `setmetatable`. Fields that the table does not have are looked up
+		// in its `__index`, and its other metamethods determine the results of calls and operators.
+		Metatable Type
 	}
 	Unknown struct{}
 )
@@ -30,54 +44,117 @@ type (
 func (a *Any) isType()      {}
 func (b *Boolean) isType()  {}
 func (f *Function) isType() {}
+func (l *Literal) isType()  {}
+func (n *Nil) isType()      {}
 func (n *Number) isType()   {}
 func (s *String) isType()   {}
 func (t *Table) isType()    {}
 func (u *Unknown) isType()  {}
 
-func (b *Any) String() string     { return "any" }
-func (b *Boolean) String() string { return "boolean" }
-func (f *Function) String() string {
-	output := "function("
-	for _, param := range f.Params {
-		output = output + param.String() + ", "
-	}
-	if len(f.Params) > 0 {
-		output = output[0 : len(output)-2]
-	}
-	output = output + ")"
-	if f.Return != nil {
-		output = output + " → " + f.Return.String()
-	}
-	return output
-}
-func (n *Number) String() string { return "number" }
-func (s *String) String() string { return "string" }
-func (t *Table) String() string {
-	var sb strings.Builder
-	sb.WriteByte('{')
-	for i := 0; i < len(t.Fields); i++ {
-		if i > 0 {
-			fmt.Fprint(&sb, ", ")
-		}
-		fmt.Fprintf(&sb, "%s", &t.Fields[i])
-	}
-	sb.WriteByte('}')
-
-	return sb.String()
-}
-func (u *Unknown) String() string { return "unknown" }
+func (b *Any) String() string      { return "any" }
+func (b *Boolean) String() string  { return "boolean" }
+func (f *Function) String() string { return formatType(f, 0) }
+func (l *Literal) String() string  { return l.Value }
+func (n *Nil) String() string      { return "nil" }
+func (n *Number) String() string   { return "number" }
+func (s *String) String() string   { return "string" }
+func (t *Table) String() string    { return formatType(t, 0) }
+func (u *Unknown) String() string  { return "unknown" }
 
 type NameAndType struct {
 	Name string
-	Def  ast.Node
+	Def  ast.Node // The node that defined this name, if it was defined in code.
 	Type Type
+	Loc  Location // Where this name was defined.
+	// Annotated is whether the type was declared by an annotation rather than inferred. Assignments do not widen
+	// annotated types.
+	Annotated bool
 }
 
 func (n *NameAndType) String() string {
-	typ := n.Type
-	if typ == nil {
-		typ = &Unknown{}
+	return n.format(0)
+}
+
+func (n *NameAndType) format(depth int) string {
+	return fmt.Sprintf("%s: %s", n.Name, formatType(n.Type, depth))
+}
+
+// Field returns the field with the given name, or nil if there is no such field. Fields that the table does not have
+// are looked up in its metatable's `__index`.
+func (t *Table) Field(name string) *NameAndType {
+	return lookupField(t, name, map[Type]bool{})
+}
+
+// SetField adds a field to the table, or widens the type of the existing field with the same name.
+func (t *Table) SetField(field NameAndType) {
+	if existing := findField(t.Fields, field.Name); existing != nil {
+		existing.Type = NewUnion(existing.Type, field.Type)
+		return
+	}
+	t.Fields = append(t.Fields, field)
+}
+
+// maxFormatDepth limits how many levels of nested tables are expanded when formatting a type, since tables can refer
+// to themselves.
+const maxFormatDepth = 2
+
+func formatType(typ Type, depth int) string {
+	switch typ := typ.(type) {
+	case nil:
+		return "unknown"
+	case *Function:
+		var sb strings.Builder
+		sb.WriteString("function(")
+		for i := range typ.Params {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(typ.Params[i].format(depth))
+		}
+		sb.WriteByte(')')
+		if typ.Return != nil {
+			sb.WriteString(" → ")
+			sb.WriteString(formatType(typ.Return, depth))
+		}
+		return sb.String()
+	case *Table:
+		if len(typ.Fields) == 0 && typ.Value != nil {
+			if _, ok := typ.Key.(*Number); ok {
+				if _, ok := typ.Value.(*Union); ok {
+					return "(" + formatType(typ.Value, depth) + ")[]"
+				}
+				return formatType(typ.Value, depth) + "[]"
+			}
+			return fmt.Sprintf("table<%s, %s>", formatType(typ.Key, depth), formatType(typ.Value, depth))
+		}
+		if depth >= maxFormatDepth {
+			return "table"
+		}
+		var sb strings.Builder
+		sb.WriteByte('{')
+		for i := range typ.Fields {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(typ.Fields[i].format(depth + 1))
+		}
+		sb.WriteByte('}')
+		return sb.String()
+	case *Union:
+		parts := make([]string, 0, len(typ.Types))
+		for _, member := range typ.Types {
+			parts = append(parts, formatType(member, depth))
+		}
+		return strings.Join(parts, "|")
+	case *Tuple:
+		parts := make([]string, 0, len(typ.Types)+1)
+		for _, member := range typ.Types {
+			parts = append(parts, formatType(member, depth))
+		}
+		if typ.Rest != nil {
+			parts = append(parts, "...: "+formatType(typ.Rest, depth))
+		}
+		return strings.Join(parts, ", ")
 	}
-	return fmt.Sprintf("%s: %s", n.Name, n.Type)
+	return typ.String()
 }
@@ -0,0 +1,93 @@
+package types
+
+// Union is a value that may be any one of several types.
+type Union struct {
+	Types []Type
+}
+
+func (u *Union) isType() {}
+
+func (u *Union) String() string { return formatType(u, 0) }
+
+// NewUnion returns a type that represents any of the given types. Nested unions are flattened and duplicates are
+// removed. If only one distinct type remains, it is returned directly. Nil entries are ignored.
+func NewUnion(types ...Type) Type {
+	members := []Type{}
+	var add func(typ Type)
+	add = func(typ Type) {
+		switch typ := typ.(type) {
+		case nil:
+			return
+		case *Union:
+			for _, member := range typ.Types {
+				add(member)
+			}
+			return
+		}
+		for _, existing := range members {
+			if Identical(existing, typ) {
+				return
+			}
+		}
+		members = append(members, typ)
+	}
+	for _, typ := range types {
+		add(typ)
+	}
+	switch len(members) {
+	case 0:
+		return nil
+	case 1:
+		return members[0]
+	}
+	return &Union{Types: members}
+}
+
+// Identical returns whether two types are known to be the same. Tables and functions are compared by identity,
+// since two values with the same shape may still be distinct types.
+func Identical(a, b Type) bool {
+	if a == b {
+		return true
+	}
+	switch a := a.(type) {
+	case *Any:
+		_, ok := b.(*Any)
+		return ok
+	case *Boolean:
+		_, ok := b.(*Boolean)
+		return ok
+	case *Enum:
+		b, ok := b.(*Enum)
+		return ok && a.Name == b.Name
+	case *Literal:
+		b, ok := b.(*Literal)
+		return ok && sameLiteral(a, b)
+	case *Named:
+		b, ok := b.(*Named)
+		return ok && a.Name == b.Name
+	case *Nil:
+		_, ok := b.(*Nil)
+		return ok
+	case *Number:
+		_, ok := b.(*Number)
+		return ok
+	case *String:
+		_, ok := b.(*String)
+		return ok
+	case *Union:
+		b, ok := b.(*Union)
+		if !ok || len(a.Types) != len(b.Types) {
+			return false
+		}
+		for i := range a.Types {
+			if !Identical(a.Types[i], b.Types[i]) {
+				return false
+			}
+		}
+		return true
+	case *Unknown:
+		_, ok := b.(*Unknown)
+		return ok
+	}
+	return false
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/raiguard/luapls/lsp"
@@ -33,11 +36,15 @@ func main() {
 		}
 		lexFile(args[2])
 	case "lsp":
-		level := int64(0)
-		if len(args) > 2 {
-			level, _ = strconv.ParseInt(args[2], 0, 8)
+		options, err := lspOptions(args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := lsp.Run(options); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		lsp.Run(int(level))
 	case "parse":
 		if len(args) < 3 {
 			fmt.Fprintln(os.Stderr, "Did not provide a filename")
@@ -54,6 +61,12 @@ func main() {
 		repl.Run()
 	case "check":
 		check()
+	case "stats":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Did not provide a filename or directory")
+			os.Exit(1)
+		}
+		printStats(args[2])
 	default:
 		fmt.Fprintf(os.Stderr, "%s: unrecognized subcommand\n", task)
 	}
@@ -61,6 +74,50 @@ func main() {
 	util.Exit(0)
 }
 
+// lspOptions parses the arguments of the lsp subcommand: `luapls lsp [log level] [--listen <addr> | --websocket <addr>
+// [--allow-origin <origin>]...] [--token <token>] [--shared]`.
+func lspOptions(args []string) (lsp.Options, error) {
+	options := lsp.Options{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "--") && arg != "--shared" {
+			if i+1 >= len(args) {
+				return options, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+		}
+		switch arg {
+		case "--listen":
+			options.Listen = args[i]
+		case "--websocket":
+			options.WebSocket = args[i]
+		case "--allow-origin":
+			options.AllowedOrigins = append(options.AllowedOrigins, args[i])
+		case "--token":
+			options.Token = args[i]
+		case "--shared":
+			options.Shared = true
+		default:
+			level, err := strconv.ParseInt(arg, 0, 8)
+			if err != nil {
+				return options, fmt.Errorf("%s: unrecognized argument", arg)
+			}
+			options.LogLevel = int(level)
+		}
+	}
+	if options.Token == "" {
+		// The token can be given in the environment, so that it is not visible to other users in the process list.
+		options.Token = os.Getenv("LUAPLS_TOKEN")
+	}
+	if options.Listen != "" && options.WebSocket != "" {
+		return options, fmt.Errorf("--listen and --websocket cannot be used together")
+	}
+	if options.Shared && options.Listen == "" && options.WebSocket == "" {
+		return options, fmt.Errorf("--shared requires --listen or --websocket")
+	}
+	return options, nil
+}
+
 func lexFile(filename string) {
 	src, err := os.ReadFile(filename)
 	if err != nil {
@@ -136,6 +193,42 @@ func readOrMakeSpecs(path string) []testSpec {
 	return specs
 }
 
+func printStats(root string) {
+	total := ast.Stats{NodesByType: map[string]int{}}
+	files := 0
+	err := filepath.WalkDir(root, func(path string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".lua") {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		file := parser.New(string(src)).ParseFile()
+		stats := ast.ComputeStats(&file)
+		fmt.Printf("%s: %d nodes, %d trivia, ~%d KiB\n", path, stats.Nodes, stats.Trivia, stats.Bytes/1024)
+		total.Add(stats)
+		files++
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("TOTAL (%d files): %d nodes, %d trivia, ~%d KiB\n", files, total.Nodes, total.Trivia, total.Bytes/1024)
+	typeNames := make([]string, 0, len(total.NodesByType))
+	for typ := range total.NodesByType {
+		typeNames = append(typeNames, typ)
+	}
+	sort.Strings(typeNames)
+	for _, typ := range typeNames {
+		fmt.Printf("    %s: %d\n", typ, total.NodesByType[typ])
+	}
+}
+
 func check() {
 	env := types.NewEnvironment()
 	if env == nil {
@@ -148,10 +241,10 @@ func check() {
 		fmt.Printf("    %s\n", typName)
 	}
 	fmt.Println("DIAGNOSTICS:")
-	for uri, file := range env.Files {
-		if len(file.Diagnostics) > 0 {
+	for uri := range env.Files {
+		if diagnostics := env.Diagnostics(uri); len(diagnostics) > 0 {
 			fmt.Printf("    %s\n", uri)
-			for _, diag := range file.Diagnostics {
+			for _, diag := range diagnostics {
 				fmt.Printf("        %s: %s\n", diag.Range.String(), diag.Message)
 			}
 		}
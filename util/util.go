@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -15,26 +19,108 @@ func Ptr[T any](value T) *T {
 	return &value
 }
 
+// ParallelEach calls f with every item, using at most the given number of goroutines at a time, and waits for all of
+// the calls to return. A limit of zero or less uses one goroutine per CPU.
+func ParallelEach[T any](items []T, limit int, f func(item T)) {
+	if limit <= 0 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+	for _, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item T) {
+			defer func() { <-sem; wg.Done() }()
+			f(item)
+		}(item)
+	}
+	wg.Wait()
+}
+
 // FileExists returns whether the given file exists on the filesystem.
 func FileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
 
-// URIToPath returns a path from the given URI.
+// MatchGlob returns whether the slash-separated path matches the pattern. In addition to the syntax of path.Match, a
+// `**` segment matches any number of directories.
+func MatchGlob(pattern string, name string) bool {
+	patterns := strings.Split(pattern, "/")
+	names := strings.Split(name, "/")
+	var match func(patterns, names []string) bool
+	match = func(patterns, names []string) bool {
+		for len(patterns) > 0 {
+			if patterns[0] == "**" {
+				for i := 0; i <= len(names); i++ {
+					if match(patterns[1:], names[i:]) {
+						return true
+					}
+				}
+				return false
+			}
+			if len(names) == 0 {
+				return false
+			}
+			if ok, _ := path.Match(patterns[0], names[0]); !ok {
+				return false
+			}
+			patterns, names = patterns[1:], names[1:]
+		}
+		return len(names) == 0
+	}
+	return match(patterns, names)
+}
+
+// URIToPath returns a path from the given URI. Percent-encoded characters are decoded, and on Windows, drive letters
+// and hosts become volumes, so that `file:///c%3A/foo` becomes `C:\foo`.
 func URIToPath(uri protocol.URI) (string, error) {
-	u, err := url.ParseRequestURI(uri)
+	u, err := url.Parse(uri)
 	if err != nil {
 		return "", errors.New(fmt.Sprintf("Failed to parse file URI: %s", err))
 	}
-	return u.Path, nil
+	p := u.Path
+	if len(p) > 1 && p[0] == '/' && filepath.VolumeName(p[1:]) != "" {
+		// The drive letter is capitalized, since clients disagree on its case.
+		p = strings.ToUpper(p[1:2]) + p[2:]
+	} else if u.Host != "" && u.Host != "localhost" {
+		p = "//" + u.Host + p
+	}
+	return filepath.FromSlash(p), nil
 }
 
-// PathToURI returns an absolute URI from a file path.
+// PathToURI returns an absolute URI from a file path. Characters that are not allowed in URIs are percent-encoded.
 func PathToURI(path string) (protocol.URI, error) {
 	abs, err := filepath.Abs(path)
 	if err != nil {
 		return "", errors.New(fmt.Sprintf("Failed to convert filepath to URI: %s", err))
 	}
-	return "file://" + abs, nil
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	if volume := filepath.VolumeName(abs); strings.HasPrefix(volume, `\\`) {
+		// UNC paths, such as `\\host\share\foo`, have the host in the authority.
+		host, share, _ := strings.Cut(filepath.ToSlash(volume)[2:], "/")
+		u.Host = host
+		u.Path = "/" + share + filepath.ToSlash(abs[len(volume):])
+	} else if volume != "" {
+		u.Path = "/" + strings.ToUpper(u.Path[:1]) + u.Path[1:]
+	}
+	return u.String(), nil
+}
+
+// NormalizeURI returns the URI of a file in the form that the server stores it in, so that URIs from clients, which may
+// encode the same path differently, can be compared with it. URIs of other schemes are returned unchanged.
+func NormalizeURI(uri protocol.URI) protocol.URI {
+	if !strings.HasPrefix(uri, "file:") {
+		return uri
+	}
+	path, err := URIToPath(uri)
+	if err != nil {
+		return uri
+	}
+	normalized, err := PathToURI(path)
+	if err != nil {
+		return uri
+	}
+	return normalized
 }
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURIToPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+	tests := map[string]string{
+		"file:///home/user/a.lua":                  "/home/user/a.lua",
+		"file:///home/user/my%20project/a%23b.lua": "/home/user/my project/a#b.lua",
+		"file://localhost/home/user/a.lua":         "/home/user/a.lua",
+	}
+	for uri, want := range tests {
+		path, err := URIToPath(uri)
+		require.NoError(t, err)
+		assert.Equal(t, want, path, uri)
+	}
+}
+
+func TestPathToURI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+	tests := map[string]string{
+		"/home/user/a.lua":              "file:///home/user/a.lua",
+		"/home/user/my project/a#b.lua": "file:///home/user/my%20project/a%23b.lua",
+		"/home/user/ünïcode/a.lua":      "file:///home/user/%C3%BCn%C3%AFcode/a.lua",
+		"/home/user/project/../a.lua":   "file:///home/user/a.lua",
+	}
+	for path, want := range tests {
+		uri, err := PathToURI(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, uri, path)
+	}
+}
+
+func TestNormalizeURI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+	tests := map[string]string{
+		"file:///home/user/a.lua":          "file:///home/user/a.lua",
+		"file:///home/%75ser/a.lua":        "file:///home/user/a.lua",
+		"file:///home/user/my project":     "file:///home/user/my%20project",
+		"file://localhost/home/user/a.lua": "file:///home/user/a.lua",
+		"untitled:Untitled-1":              "untitled:Untitled-1",
+	}
+	for uri, want := range tests {
+		assert.Equal(t, want, NormalizeURI(uri), uri)
+	}
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURIToPathWindows(t *testing.T) {
+	tests := map[string]string{
+		"file:///C:/Users/a.lua":          `C:\Users\a.lua`,
+		"file:///c:/Users/a.lua":          `C:\Users\a.lua`,
+		"file:///c%3A/Users/my%20a.lua":   `C:\Users\my a.lua`,
+		"file://server/share/dir/a.lua":   `\\server\share\dir\a.lua`,
+		"file://localhost/C:/Users/a.lua": `C:\Users\a.lua`,
+	}
+	for uri, want := range tests {
+		path, err := URIToPath(uri)
+		require.NoError(t, err)
+		assert.Equal(t, want, path, uri)
+	}
+}
+
+func TestPathToURIWindows(t *testing.T) {
+	tests := map[string]string{
+		`C:\Users\a.lua`:           "file:///C:/Users/a.lua",
+		`c:\Users\my a.lua`:        "file:///C:/Users/my%20a.lua",
+		`\\server\share\dir\a.lua`: "file://server/share/dir/a.lua",
+	}
+	for path, want := range tests {
+		uri, err := PathToURI(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, uri, path)
+	}
+}
+
+func TestNormalizeURIWindows(t *testing.T) {
+	tests := map[string]string{
+		"file:///c%3A/Users/a.lua":      "file:///C:/Users/a.lua",
+		"file:///c:/Users/a.lua":        "file:///C:/Users/a.lua",
+		"file://server/share/dir/a.lua": "file://server/share/dir/a.lua",
+	}
+	for uri, want := range tests {
+		assert.Equal(t, want, NormalizeURI(uri), uri)
+	}
+}